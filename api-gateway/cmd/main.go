@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/fairuzald/library-system/pkg/config"
+	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
 	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/gorilla/mux"
@@ -37,7 +38,8 @@ type APIGatewayConfig struct {
 	AppEnv                 string
 	ServerPort             string
 	LogLevel               string
-	JWTSecret              string
+	JWTAlgorithm           string
+	JWKSURL                string
 	BookServiceHTTPURL     string
 	CategoryServiceHTTPURL string
 	UserServiceHTTPURL     string
@@ -48,6 +50,7 @@ type APIGatewayConfig struct {
 	RateLimitIPBurst       int
 	RateLimitGlobal        float64
 	RateLimitGBurst        int
+	TrustedProxies         []string
 }
 
 func main() {
@@ -75,6 +78,11 @@ func main() {
 	router.NotFoundHandler = http.HandlerFunc(JSONNotFound)
 	router.MethodNotAllowedHandler = http.HandlerFunc(JSONMethodNotAllowed)
 
+	trustedProxies, err := middleware.NewTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
 	requestLogger := middleware.NewRequestLogger(log)
 	recoveryMiddleware := middleware.NewRecoveryMiddleware(log)
 
@@ -87,6 +95,7 @@ func main() {
 	)
 
 	router.Use(
+		trustedProxies.Middleware,
 		recoveryMiddleware.Middleware,
 		requestLogger.Middleware,
 		rateLimiter.Middleware,
@@ -316,6 +325,12 @@ func checkServiceHealth(serviceURL string, log *logger.Logger) string {
 func setupServiceProxies(router *mux.Router, cfg *APIGatewayConfig, log *logger.Logger) {
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
+	// Tokens are checked against the user service's keys before they are forwarded
+	if cfg.JWKSURL != "" && cfg.JWTAlgorithm != middleware.AlgorithmHS256 {
+		jwtAuth := middleware.NewJWTAuth("", 0).WithKeyResolver(middleware.NewRemoteJWKS(cfg.JWKSURL, constants.JWKSCacheTTL))
+		apiRouter.Use(jwtAuth.RejectInvalidTokens)
+	}
+
 	bookRouter := apiRouter.PathPrefix("/books").Subrouter()
 	bookProxy := createServiceProxy(cfg.BookServiceHTTPURL, log)
 	bookRouter.PathPrefix("").Handler(bookProxy)
@@ -331,6 +346,15 @@ func setupServiceProxies(router *mux.Router, cfg *APIGatewayConfig, log *logger.
 	authRouter := apiRouter.PathPrefix("/auth").Subrouter()
 	authRouter.PathPrefix("").Handler(userProxy)
 
+	oauthRouter := apiRouter.PathPrefix("/oauth").Subrouter()
+	oauthRouter.PathPrefix("").Handler(userProxy)
+
+	router.Handle("/.well-known/jwks.json", userProxy).Methods("GET")
+	router.Handle("/.well-known/openid-configuration", userProxy).Methods("GET")
+
+	// Client-facing OpenID Connect endpoints; clients authenticate to the user service themselves
+	router.PathPrefix("/oauth/").Handler(userProxy)
+
 	router.NotFoundHandler = http.HandlerFunc(JSONNotFound)
 	router.MethodNotAllowedHandler = http.HandlerFunc(JSONMethodNotAllowed)
 }
@@ -371,7 +395,10 @@ func createServiceProxy(serviceURL string, log *logger.Logger) http.Handler {
 		}
 
 		proxyReq.Header.Set("X-Forwarded-Host", r.Host)
-		proxyReq.Header.Set("X-Forwarded-For", r.RemoteAddr)
+		// Forward only the client address resolved against TRUSTED_PROXIES; the chain the client sent is
+		// not passed on, so services trusting the gateway cannot be handed a spoofed entry
+		proxyReq.Header.Set("X-Forwarded-For", middleware.ClientIP(r))
+		proxyReq.Header.Del("X-Real-IP")
 		proxyReq.Header.Set("X-Gateway", "library-system-api-gateway")
 
 		client := &http.Client{}
@@ -436,11 +463,6 @@ func loadAPIGatewayConfig() (*APIGatewayConfig, error) {
 		return nil, fmt.Errorf("required environment variable not set: APP_NAME")
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return nil, fmt.Errorf("required environment variable not set: JWT_SECRET")
-	}
-
 	bookServiceHTTPURL := os.Getenv("BOOK_SERVICE_HTTP_URL")
 	categoryServiceHTTPURL := os.Getenv("CATEGORY_SERVICE_HTTP_URL")
 	userServiceHTTPURL := os.Getenv("USER_SERVICE_HTTP_URL")
@@ -489,6 +511,11 @@ func loadAPIGatewayConfig() (*APIGatewayConfig, error) {
 		serverPort = "8000"
 	}
 
+	jwtAlgorithm := os.Getenv("JWT_ALGORITHM")
+	if jwtAlgorithm == "" {
+		jwtAlgorithm = middleware.AlgorithmRS256
+	}
+
 	logLevel := os.Getenv("LOG_LEVEL")
 	if logLevel == "" {
 		logLevel = "info"
@@ -514,12 +541,18 @@ func loadAPIGatewayConfig() (*APIGatewayConfig, error) {
 		return nil, err
 	}
 
+	var trustedProxies []string
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		trustedProxies = strings.Split(value, ",")
+	}
+
 	return &APIGatewayConfig{
 		AppName:                appName,
 		AppEnv:                 appEnv,
 		ServerPort:             serverPort,
 		LogLevel:               logLevel,
-		JWTSecret:              jwtSecret,
+		JWTAlgorithm:           jwtAlgorithm,
+		JWKSURL:                os.Getenv("JWKS_URL"),
 		BookServiceHTTPURL:     bookServiceHTTPURL,
 		CategoryServiceHTTPURL: categoryServiceHTTPURL,
 		UserServiceHTTPURL:     userServiceHTTPURL,
@@ -530,6 +563,7 @@ func loadAPIGatewayConfig() (*APIGatewayConfig, error) {
 		RateLimitIPBurst:       rateLimitIPBurst,
 		RateLimitGlobal:        rateLimitGlobal,
 		RateLimitGBurst:        rateLimitGBurst,
+		TrustedProxies:         trustedProxies,
 	}, nil
 }
 
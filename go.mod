@@ -14,8 +14,11 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/rs/cors v1.10.1
 	github.com/spf13/viper v1.16.0
+	github.com/swaggest/swgui v1.8.3
 	go.uber.org/zap v1.26.0
 	golang.org/x/crypto v0.21.0
+	golang.org/x/sync v0.8.0
+	golang.org/x/text v0.14.0
 	google.golang.org/grpc v1.64.0
 	gorm.io/driver/postgres v1.5.2
 	gorm.io/gorm v1.25.4
@@ -23,7 +26,6 @@ require (
 
 require (
 	github.com/shurcooL/httpgzip v0.0.0-20190720172056-320755c1c1b0 // indirect
-	github.com/swaggest/swgui v1.8.3 // indirect
 	github.com/vearutop/statigz v1.4.0 // indirect
 )
 
@@ -52,7 +54,6 @@ require (
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/net v0.22.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
 	google.golang.org/protobuf v1.36.6
 	gopkg.in/ini.v1 v1.67.0 // indirect
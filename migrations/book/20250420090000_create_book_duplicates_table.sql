@@ -0,0 +1,37 @@
+-- migrate:up
+ALTER TABLE books ADD COLUMN IF NOT EXISTS merged_into_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_books_merged_into_id ON books(merged_into_id);
+
+-- Candidate duplicate pairs found by the detection job, book_id < duplicate_id
+CREATE TABLE IF NOT EXISTS book_duplicates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    book_id UUID NOT NULL,
+    duplicate_id UUID NOT NULL,
+    score FLOAT NOT NULL,
+    reasons VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT book_duplicates_book_id_duplicate_id_key UNIQUE (book_id, duplicate_id)
+);
+
+ALTER TABLE book_duplicates
+    ADD CONSTRAINT fk_book_duplicates_book
+    FOREIGN KEY (book_id)
+    REFERENCES books(id)
+    ON DELETE CASCADE;
+
+ALTER TABLE book_duplicates
+    ADD CONSTRAINT fk_book_duplicates_duplicate
+    FOREIGN KEY (duplicate_id)
+    REFERENCES books(id)
+    ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_book_duplicates_status ON book_duplicates(status);
+CREATE INDEX IF NOT EXISTS idx_book_duplicates_duplicate_id ON book_duplicates(duplicate_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS book_duplicates;
+DROP INDEX IF EXISTS idx_books_merged_into_id;
+ALTER TABLE books DROP COLUMN IF EXISTS merged_into_id;
@@ -0,0 +1,15 @@
+-- migrate:up
+ALTER TABLE books ADD COLUMN IF NOT EXISTS class_number VARCHAR(50);
+ALTER TABLE books ADD COLUMN IF NOT EXISTS call_number VARCHAR(100);
+
+-- Normalized call number whose byte order is shelf order, see pkg/classification.
+-- The C collation keeps spaces and punctuation significant when sorting.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS shelf_key VARCHAR(150) COLLATE "C";
+
+CREATE INDEX IF NOT EXISTS idx_books_shelf_key ON books(shelf_key);
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_books_shelf_key;
+ALTER TABLE books DROP COLUMN IF EXISTS shelf_key;
+ALTER TABLE books DROP COLUMN IF EXISTS call_number;
+ALTER TABLE books DROP COLUMN IF EXISTS class_number;
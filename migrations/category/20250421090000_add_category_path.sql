@@ -0,0 +1,24 @@
+-- migrate:up
+-- Materialized path: every category stores the ids from the root down to itself as /root/.../self/
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS path TEXT NOT NULL DEFAULT '';
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS depth INT NOT NULL DEFAULT 0;
+
+WITH RECURSIVE tree AS (
+    SELECT id, '/' || id::text || '/' AS path, 0 AS depth
+    FROM categories
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT c.id, t.path || c.id::text || '/', t.depth + 1
+    FROM categories c
+    JOIN tree t ON c.parent_id = t.id
+)
+UPDATE categories SET path = tree.path, depth = tree.depth
+FROM tree
+WHERE categories.id = tree.id;
+
+CREATE INDEX IF NOT EXISTS idx_categories_path ON categories(path text_pattern_ops);
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_categories_path;
+ALTER TABLE categories DROP COLUMN IF EXISTS depth;
+ALTER TABLE categories DROP COLUMN IF EXISTS path;
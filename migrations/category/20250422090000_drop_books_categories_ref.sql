@@ -0,0 +1,15 @@
+-- migrate:up
+-- Book assignments are owned by book-service and queried over gRPC; this copy was never populated
+DROP TABLE IF EXISTS books_categories_ref;
+
+-- migrate:down
+CREATE TABLE IF NOT EXISTS books_categories_ref (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    book_id UUID NOT NULL,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT books_categories_ref_book_id_category_id_key UNIQUE (book_id, category_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_books_categories_ref_book_id ON books_categories_ref(book_id);
+CREATE INDEX IF NOT EXISTS idx_books_categories_ref_category_id ON books_categories_ref(category_id);
@@ -0,0 +1,13 @@
+-- migrate:up
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS classification_scheme VARCHAR(10);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS class_number VARCHAR(50);
+
+-- A class number belongs to at most one live category within its scheme
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_classification
+    ON categories(classification_scheme, class_number)
+    WHERE class_number <> '' AND deleted_at IS NULL;
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_categories_classification;
+ALTER TABLE categories DROP COLUMN IF EXISTS class_number;
+ALTER TABLE categories DROP COLUMN IF EXISTS classification_scheme;
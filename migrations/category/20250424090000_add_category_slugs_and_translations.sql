@@ -0,0 +1,41 @@
+-- migrate:up
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS slug VARCHAR(120);
+
+-- Backfill slugs from names, qualifying any collisions with the start of the category id
+UPDATE categories
+SET slug = COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'category');
+
+UPDATE categories c
+SET slug = c.slug || '-' || substr(c.id::text, 1, 8)
+WHERE EXISTS (
+    SELECT 1 FROM categories o
+    WHERE o.slug = c.slug AND o.id < c.id AND o.deleted_at IS NULL
+) AND c.deleted_at IS NULL;
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories(slug) WHERE deleted_at IS NULL;
+
+-- Names and descriptions in locales other than the default, which live on categories itself
+CREATE TABLE IF NOT EXISTS category_translations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    category_id UUID NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP,
+    CONSTRAINT idx_category_translations_category_locale UNIQUE (category_id, locale)
+);
+
+ALTER TABLE category_translations
+    ADD CONSTRAINT fk_category_translations_category
+    FOREIGN KEY (category_id)
+    REFERENCES categories(id)
+    ON DELETE CASCADE;
+
+-- migrate:down
+DROP TABLE IF EXISTS category_translations;
+DROP INDEX IF EXISTS idx_categories_slug;
+ALTER TABLE categories DROP COLUMN IF EXISTS slug;
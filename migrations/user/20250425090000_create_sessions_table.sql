@@ -0,0 +1,39 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    device_name VARCHAR(100),
+    user_agent VARCHAR(255),
+    ip_address VARCHAR(45),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+ALTER TABLE sessions
+    ADD CONSTRAINT fk_sessions_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions(deleted_at);
+
+-- Carry over the one live refresh token each user could hold, hashed like new sessions
+INSERT INTO sessions (user_id, token_hash, last_used_at, expires_at)
+SELECT id, encode(sha256(refresh_token::bytea), 'hex'), COALESCE(last_login, NOW()), refresh_token_exp
+FROM users
+WHERE refresh_token IS NOT NULL AND refresh_token <> '' AND refresh_token_exp > NOW();
+
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_token;
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_token_exp;
+
+-- migrate:down
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_token VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_token_exp TIMESTAMP;
+DROP TABLE IF EXISTS sessions;
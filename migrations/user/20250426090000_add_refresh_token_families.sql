@@ -0,0 +1,72 @@
+-- migrate:up
+-- Every refresh token a session has been issued; used ones stay until the session expires to detect replays
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_session
+    FOREIGN KEY (session_id)
+    REFERENCES sessions(id)
+    ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens(deleted_at);
+
+INSERT INTO refresh_tokens (session_id, token_hash)
+SELECT id, token_hash FROM sessions;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS token_hash;
+
+CREATE TABLE IF NOT EXISTS security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    session_id UUID,
+    type VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
+    details TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+ALTER TABLE security_events
+    ADD CONSTRAINT fk_security_events_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE;
+
+ALTER TABLE security_events
+    ADD CONSTRAINT fk_security_events_session
+    FOREIGN KEY (session_id)
+    REFERENCES sessions(id)
+    ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_security_events_type ON security_events(type);
+CREATE INDEX IF NOT EXISTS idx_security_events_deleted_at ON security_events(deleted_at);
+
+-- migrate:down
+DROP TABLE IF EXISTS security_events;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
+
+-- Sessions go back to holding their current, unused token
+UPDATE sessions s
+SET token_hash = rt.token_hash
+FROM refresh_tokens rt
+WHERE rt.session_id = s.id AND rt.used_at IS NULL;
+
+DELETE FROM sessions WHERE token_hash IS NULL;
+
+ALTER TABLE sessions ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE sessions ADD CONSTRAINT sessions_token_hash_key UNIQUE (token_hash);
+
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,6 @@
+-- migrate:up
+-- Access tokens carry the version current when they were issued; bumping it revokes all of them at once
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version BIGINT NOT NULL DEFAULT 0;
+
+-- migrate:down
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
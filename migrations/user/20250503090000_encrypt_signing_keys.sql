@@ -0,0 +1,9 @@
+-- migrate:up
+-- Private signing keys are now stored encrypted under JWT_KEY_ENCRYPTION_KEY. Plaintext keys cannot be
+-- encrypted here, so they are dropped and the user service creates a fresh key on start; access tokens
+-- signed with the old keys stop verifying and clients refresh them.
+DELETE FROM signing_keys;
+
+-- migrate:down
+-- Encrypted keys are unreadable to code that expects plaintext
+DELETE FROM signing_keys;
@@ -0,0 +1,6 @@
+-- migrate:up
+-- Throttles password reset emails per account
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_sent_at TIMESTAMP;
+
+-- migrate:down
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_sent_at;
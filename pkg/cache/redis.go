@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,6 +12,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrCacheMiss is returned by Get when the key does not exist
+var ErrCacheMiss = errors.New("key not found")
+
 type Redis struct {
 	client *redis.Client
 	log    *logger.Logger
@@ -69,11 +73,26 @@ func (r *Redis) Set(ctx context.Context, key string, value interface{}, expirati
 	return nil
 }
 
+// SetNX sets the key only if it does not exist yet, and reports whether it did
+func (r *Redis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	set, err := r.client.SetNX(ctx, key, data, expiration).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set key: %w", err)
+	}
+
+	return set, nil
+}
+
 func (r *Redis) Get(ctx context.Context, key string, dest interface{}) error {
 	data, err := r.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
-			return fmt.Errorf("key not found: %s", key)
+			return fmt.Errorf("%w: %s", ErrCacheMiss, key)
 		}
 		return fmt.Errorf("failed to get key: %w", err)
 	}
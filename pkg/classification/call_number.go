@@ -0,0 +1,221 @@
+package classification
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// cutterStep maps the first letter at or above a threshold to a Cutter digit
+type cutterStep struct {
+	from  string
+	digit byte
+}
+
+// Library of Congress Cutter table, each list sorted by threshold
+var (
+	afterVowel     = []cutterStep{{"b", '2'}, {"d", '3'}, {"l", '4'}, {"n", '5'}, {"p", '6'}, {"r", '7'}, {"s", '8'}, {"u", '9'}}
+	afterS         = []cutterStep{{"a", '2'}, {"ch", '3'}, {"e", '4'}, {"h", '5'}, {"m", '6'}, {"t", '7'}, {"u", '8'}, {"w", '9'}}
+	afterQu        = []cutterStep{{"a", '3'}, {"e", '4'}, {"i", '5'}, {"o", '6'}, {"r", '7'}, {"t", '8'}, {"y", '9'}}
+	afterConsonant = []cutterStep{{"a", '3'}, {"e", '4'}, {"i", '5'}, {"o", '6'}, {"r", '7'}, {"u", '8'}, {"y", '9'}}
+	expansion      = []cutterStep{{"a", '3'}, {"e", '4'}, {"i", '5'}, {"m", '6'}, {"p", '7'}, {"t", '8'}, {"w", '9'}}
+)
+
+// cutterDigits is the number of digits generated after the initial letter
+const cutterDigits = 2
+
+// leadingArticles are skipped when taking the work mark from a title
+var leadingArticles = map[string]bool{"a": true, "an": true, "the": true}
+
+// CallNumber is a shelf address made of a class number, a Cutter author code and the publication year
+type CallNumber struct {
+	Scheme string
+	Class  string
+	Cutter string
+	Year   int
+}
+
+// NewCallNumber builds a call number for a book. The Cutter is taken from the author's surname,
+// falling back to the title for works without an author; Dewey numbers also get a work mark.
+func NewCallNumber(classNumber, author, title string, year int) (*CallNumber, error) {
+	scheme, class, err := Normalize("", classNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	cutter := Cutter(author)
+	if cutter == "" {
+		if words := strings.Fields(significantTitle(title)); len(words) > 0 {
+			cutter = cutterCode(lettersOnly(words[0]))
+		}
+	} else if scheme == SchemeDDC {
+		cutter += WorkMark(title)
+	}
+
+	return &CallNumber{Scheme: scheme, Class: class, Cutter: cutter, Year: year}, nil
+}
+
+// String formats the call number on one line, e.g. "823.912 T65h 1937" or "PR6039 .T65 1937"
+func (c *CallNumber) String() string {
+	parts := []string{c.Class}
+	if c.Cutter != "" {
+		parts = append(parts, c.cutterLabel())
+	}
+	if c.Year > 0 {
+		parts = append(parts, strconv.Itoa(c.Year))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Lines splits the call number into spine label lines; LC classes put letters and numbers on separate lines
+func (c *CallNumber) Lines() []string {
+	lines := make([]string, 0, 4)
+	if c.Scheme == SchemeLCC {
+		letters, number := splitLCC(c.Class)
+		lines = append(lines, letters)
+		if number != "" {
+			lines = append(lines, number)
+		}
+	} else {
+		lines = append(lines, c.Class)
+	}
+	if c.Cutter != "" {
+		lines = append(lines, c.cutterLabel())
+	}
+	if c.Year > 0 {
+		lines = append(lines, strconv.Itoa(c.Year))
+	}
+	return lines
+}
+
+// ShelfKey returns a string whose byte order matches shelf order within and across both schemes
+func (c *CallNumber) ShelfKey() string {
+	var class string
+	if c.Scheme == SchemeLCC {
+		letters, number := splitLCC(c.Class)
+		whole, fraction, _ := strings.Cut(number, ".")
+		if whole != "" {
+			whole = fmt.Sprintf("%05s", whole)
+		}
+		if fraction != "" {
+			fraction = "." + fraction
+		}
+		class = "L " + fmt.Sprintf("%-3s", letters) + whole + fraction
+	} else {
+		class = "D " + c.Class
+	}
+
+	key := class + " " + strings.ToUpper(c.Cutter)
+	if c.Year > 0 {
+		key += fmt.Sprintf(" %04d", c.Year)
+	}
+	return key
+}
+
+func (c *CallNumber) cutterLabel() string {
+	if c.Scheme == SchemeLCC {
+		return "." + c.Cutter
+	}
+	return c.Cutter
+}
+
+// Cutter returns the Library of Congress Cutter code for an author, e.g. Tolkien → T65, Fitzgerald → F58.
+// Names in "Surname, Given" form use the part before the comma, otherwise the last word.
+func Cutter(author string) string {
+	return cutterCode(lettersOnly(surname(author)))
+}
+
+func cutterCode(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	initial := strings.ToUpper(name[:1])
+	rest := name[1:]
+
+	var table []cutterStep
+	switch {
+	case strings.ContainsRune("aeiou", rune(name[0])):
+		table = afterVowel
+	case name[0] == 's':
+		table = afterS
+	case strings.HasPrefix(name, "qu"):
+		rest = name[2:]
+		table = afterQu
+	default:
+		table = afterConsonant
+	}
+
+	var digits []byte
+	if rest != "" {
+		digit, used := cutterDigit(table, rest)
+		digits = append(digits, digit)
+		rest = rest[used:]
+	}
+	for len(digits) < cutterDigits && rest != "" {
+		digit, used := cutterDigit(expansion, rest)
+		digits = append(digits, digit)
+		rest = rest[used:]
+	}
+
+	return initial + string(digits)
+}
+
+// WorkMark returns the lowercase first letter of the title, ignoring a leading article
+func WorkMark(title string) string {
+	word := lettersOnly(significantTitle(title))
+	if word == "" {
+		return ""
+	}
+	return word[:1]
+}
+
+// cutterDigit looks up the digit for the start of s and reports how many letters it consumed
+func cutterDigit(table []cutterStep, s string) (byte, int) {
+	digit, used := table[0].digit, 1
+	for _, step := range table {
+		if len(step.from) > 1 {
+			if strings.HasPrefix(s, step.from) {
+				digit, used = step.digit, len(step.from)
+			}
+			continue
+		}
+		if s[0] >= step.from[0] {
+			digit, used = step.digit, 1
+		}
+	}
+	return digit, used
+}
+
+func surname(author string) string {
+	author = strings.TrimSpace(author)
+	if i := strings.IndexByte(author, ','); i >= 0 {
+		return author[:i]
+	}
+	fields := strings.Fields(author)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+func significantTitle(title string) string {
+	fields := strings.Fields(strings.ToLower(title))
+	for i, field := range fields {
+		if !leadingArticles[field] || i == len(fields)-1 {
+			return strings.Join(fields[i:], " ")
+		}
+	}
+	return ""
+}
+
+func lettersOnly(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package classification
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+)
+
+// Supported classification schemes
+const (
+	SchemeDDC = "ddc"
+	SchemeLCC = "lcc"
+)
+
+var (
+	// ErrInvalidClassNumber is returned when a class number does not match its scheme
+	ErrInvalidClassNumber = errors.New(constants.ErrInvalidClassNumber)
+	// ErrUnknownScheme is returned for schemes other than DDC and LCC
+	ErrUnknownScheme = errors.New(constants.ErrUnknownScheme)
+)
+
+var (
+	ddcPattern = regexp.MustCompile(`^(\d{3})(\.\d+)?$`)
+	lccPattern = regexp.MustCompile(`^([A-Z]{1,3})(\d{1,4}(?:\.\d+)?)?$`)
+)
+
+// ParseScheme validates a scheme name case-insensitively
+func ParseScheme(scheme string) (string, error) {
+	switch s := strings.ToLower(strings.TrimSpace(scheme)); s {
+	case SchemeDDC, SchemeLCC:
+		return s, nil
+	default:
+		return "", ErrUnknownScheme
+	}
+}
+
+// Detect guesses the scheme of a class number: Dewey numbers start with a digit, LC classes with a letter
+func Detect(classNumber string) string {
+	classNumber = strings.TrimSpace(classNumber)
+	if classNumber == "" {
+		return ""
+	}
+	if classNumber[0] >= '0' && classNumber[0] <= '9' {
+		return SchemeDDC
+	}
+	return SchemeLCC
+}
+
+// Normalize trims and validates a class number. An empty scheme is detected from the number.
+func Normalize(scheme, classNumber string) (string, string, error) {
+	classNumber = strings.ToUpper(strings.Join(strings.Fields(classNumber), ""))
+	if classNumber == "" {
+		return "", "", ErrInvalidClassNumber
+	}
+
+	if scheme == "" {
+		scheme = Detect(classNumber)
+	}
+
+	var err error
+	if scheme, err = ParseScheme(scheme); err != nil {
+		return "", "", err
+	}
+
+	switch scheme {
+	case SchemeDDC:
+		if !ddcPattern.MatchString(classNumber) {
+			return "", "", fmt.Errorf("%w: %q is not a Dewey number", ErrInvalidClassNumber, classNumber)
+		}
+	case SchemeLCC:
+		if !lccPattern.MatchString(classNumber) {
+			return "", "", fmt.Errorf("%w: %q is not an LC class", ErrInvalidClassNumber, classNumber)
+		}
+	}
+
+	return scheme, classNumber, nil
+}
+
+// Parent returns the class number one level up the hierarchy, or an empty string at the top.
+// DDC walks 823.912 → 823.91 → 823.9 → 823 → 820 → 800; LCC walks PR6039.5 → PR6039 → PR → P.
+func Parent(scheme, classNumber string) string {
+	switch scheme {
+	case SchemeDDC:
+		m := ddcPattern.FindStringSubmatch(classNumber)
+		if m == nil {
+			return ""
+		}
+		if m[2] != "" {
+			return strings.TrimSuffix(classNumber[:len(classNumber)-1], ".")
+		}
+		base := m[1]
+		switch {
+		case base[1:] == "00":
+			return ""
+		case base[2] == '0':
+			return base[:1] + "00"
+		default:
+			return base[:2] + "0"
+		}
+	case SchemeLCC:
+		m := lccPattern.FindStringSubmatch(classNumber)
+		if m == nil {
+			return ""
+		}
+		if i := strings.IndexByte(m[2], '.'); i >= 0 {
+			return m[1] + m[2][:i]
+		}
+		if m[2] != "" {
+			return m[1]
+		}
+		if len(m[1]) > 1 {
+			return m[1][:1]
+		}
+	}
+	return ""
+}
+
+// splitLCC separates an LC class into its letters and numeric part
+func splitLCC(classNumber string) (string, string) {
+	m := lccPattern.FindStringSubmatch(classNumber)
+	if m == nil {
+		return classNumber, ""
+	}
+	return m[1], m[2]
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -22,6 +24,8 @@ type Config struct {
 	DBSSLMode          string        `mapstructure:"DB_SSLMODE"`
 	JWTSecret          string        `mapstructure:"JWT_SECRET"`
 	JWTExpirationHours int           `mapstructure:"JWT_EXPIRATION_HOURS"`
+	JWTAlgorithm       string        `mapstructure:"JWT_ALGORITHM"`
+	JWKSURL            string        `mapstructure:"JWKS_URL"`
 	AccessTokenExpiry  time.Duration `mapstructure:"ACCESS_TOKEN_EXPIRY"`
 	RefreshTokenExpiry time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRY"`
 	RedisHost          string        `mapstructure:"REDIS_HOST"`
@@ -32,6 +36,58 @@ type Config struct {
 	BookServiceURL     string        `mapstructure:"BOOK_SERVICE_URL"`
 	CategoryServiceURL string        `mapstructure:"CATEGORY_SERVICE_URL"`
 	UserServiceURL     string        `mapstructure:"USER_SERVICE_URL"`
+
+	DuplicateScanInterval time.Duration `mapstructure:"DUPLICATE_SCAN_INTERVAL"`
+
+	JWTKeyRotationInterval time.Duration `mapstructure:"JWT_KEY_ROTATION_INTERVAL"`
+	// JWTKeyEncryptionKey encrypts stored signing keys; 32 base64-encoded bytes
+	JWTKeyEncryptionKey string `mapstructure:"JWT_KEY_ENCRYPTION_KEY"`
+
+	// TokenVersionURL is the user service endpoint other services read token versions from when Redis has
+	// lost them; TokenVersionFailOpen accepts tokens whose version cannot be read at all
+	TokenVersionURL      string `mapstructure:"TOKEN_VERSION_URL"`
+	TokenVersionFailOpen bool   `mapstructure:"TOKEN_VERSION_FAIL_OPEN"`
+
+	// TrustedProxies lists the proxies, as CIDR ranges or IPs, whose forwarding headers name the client
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	OIDCIssuer           string `mapstructure:"OIDC_ISSUER"`
+	OIDCAuthorizationURL string `mapstructure:"OIDC_AUTHORIZATION_URL"`
+
+	MFAIssuer        string   `mapstructure:"MFA_ISSUER"`
+	MFARequiredRoles []string `mapstructure:"MFA_REQUIRED_ROLES"`
+
+	// Failed logins back off exponentially and then lock out, counted per account and per client IP
+	LoginMaxAttempts   int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginLockout       time.Duration `mapstructure:"LOGIN_LOCKOUT"`
+	LoginIPMaxAttempts int           `mapstructure:"LOGIN_IP_MAX_ATTEMPTS"`
+	LoginIPLockout     time.Duration `mapstructure:"LOGIN_IP_LOCKOUT"`
+
+	// New passwords are hashed with PasswordHashAlgorithm (argon2id or bcrypt); hashes made another way are
+	// replaced at the next login
+	PasswordHashAlgorithm     string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	PasswordBcryptCost        int    `mapstructure:"PASSWORD_BCRYPT_COST"`
+	PasswordArgon2Memory      int    `mapstructure:"PASSWORD_ARGON2_MEMORY"`
+	PasswordArgon2Iterations  int    `mapstructure:"PASSWORD_ARGON2_ITERATIONS"`
+	PasswordArgon2Parallelism int    `mapstructure:"PASSWORD_ARGON2_PARALLELISM"`
+
+	PasswordMinLength      int  `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength      int  `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordMinCharClasses int  `mapstructure:"PASSWORD_MIN_CHAR_CLASSES"`
+	PasswordRejectPersonal bool `mapstructure:"PASSWORD_REJECT_PERSONAL"`
+	PasswordRejectCommon   bool `mapstructure:"PASSWORD_REJECT_COMMON"`
+
+	SMTPHost       string `mapstructure:"SMTP_HOST"`
+	SMTPPort       string `mapstructure:"SMTP_PORT"`
+	SMTPUsername   string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword   string `mapstructure:"SMTP_PASSWORD"`
+	SMTPFrom       string `mapstructure:"SMTP_FROM"`
+	SMTPRequireTLS bool   `mapstructure:"SMTP_REQUIRE_TLS"`
+
+	// PasswordResetURL is the web app page reset links point to; the token is appended as ?token=
+	PasswordResetURL string `mapstructure:"PASSWORD_RESET_URL"`
+	// EmailVerificationURL is the web app page verification links point to, in the same way
+	EmailVerificationURL string `mapstructure:"EMAIL_VERIFICATION_URL"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -44,12 +100,53 @@ func LoadConfig(path string) (*Config, error) {
 		DBPort:             getEnv("DB_PORT", "5432"),
 		DBSSLMode:          getEnv("DB_SSLMODE", "disable"),
 		JWTExpirationHours: getEnvAsInt("JWT_EXPIRATION_HOURS", 24),
+		JWTAlgorithm:       getEnv("JWT_ALGORITHM", "RS256"),
 		LogLevel:           getEnv("LOG_LEVEL", "info"),
 		AccessTokenExpiry:  getEnvAsDuration("ACCESS_TOKEN_EXPIRY", 15*time.Minute),
 		RefreshTokenExpiry: getEnvAsDuration("REFRESH_TOKEN_EXPIRY", 7*24*time.Hour),
 		RedisHost:          getEnv("REDIS_HOST", "localhost"),
 		RedisPort:          getEnv("REDIS_PORT", "6379"),
 		GRPCPort:           getEnv("GRPC_PORT", "50051"),
+
+		DuplicateScanInterval: getEnvAsDuration("DUPLICATE_SCAN_INTERVAL", 24*time.Hour),
+
+		JWTKeyRotationInterval: getEnvAsDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		JWTKeyEncryptionKey:    getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+
+		TokenVersionURL:      getEnv("TOKEN_VERSION_URL", ""),
+		TokenVersionFailOpen: getEnvAsBool("TOKEN_VERSION_FAIL_OPEN", false),
+
+		TrustedProxies: getEnvAsSlice("TRUSTED_PROXIES", nil),
+
+		MFAIssuer:        getEnv("MFA_ISSUER", "Library System"),
+		MFARequiredRoles: getEnvAsSlice("MFA_REQUIRED_ROLES", []string{"admin", "librarian"}),
+
+		LoginMaxAttempts:   getEnvAsInt("LOGIN_MAX_ATTEMPTS", 10),
+		LoginLockout:       getEnvAsDuration("LOGIN_LOCKOUT", 15*time.Minute),
+		LoginIPMaxAttempts: getEnvAsInt("LOGIN_IP_MAX_ATTEMPTS", 100),
+		LoginIPLockout:     getEnvAsDuration("LOGIN_IP_LOCKOUT", time.Hour),
+
+		PasswordHashAlgorithm:     getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		PasswordBcryptCost:        getEnvAsInt("PASSWORD_BCRYPT_COST", 12),
+		PasswordArgon2Memory:      getEnvAsInt("PASSWORD_ARGON2_MEMORY", 19456),
+		PasswordArgon2Iterations:  getEnvAsInt("PASSWORD_ARGON2_ITERATIONS", 2),
+		PasswordArgon2Parallelism: getEnvAsInt("PASSWORD_ARGON2_PARALLELISM", 1),
+
+		PasswordMinLength:      getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:      getEnvAsInt("PASSWORD_MAX_LENGTH", 128),
+		PasswordMinCharClasses: getEnvAsInt("PASSWORD_MIN_CHAR_CLASSES", 0),
+		PasswordRejectPersonal: getEnvAsBool("PASSWORD_REJECT_PERSONAL", true),
+		PasswordRejectCommon:   getEnvAsBool("PASSWORD_REJECT_COMMON", true),
+
+		SMTPHost:       getEnv("SMTP_HOST", ""),
+		SMTPPort:       getEnv("SMTP_PORT", "587"),
+		SMTPUsername:   getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:       getEnv("SMTP_FROM", ""),
+		SMTPRequireTLS: getEnvAsBool("SMTP_REQUIRE_TLS", true),
+
+		PasswordResetURL:     getEnv("PASSWORD_RESET_URL", ""),
+		EmailVerificationURL: getEnv("EMAIL_VERIFICATION_URL", ""),
 	}
 
 	viper.SetConfigFile(path)
@@ -66,7 +163,6 @@ func LoadConfig(path string) (*Config, error) {
 		"DB_NAME",
 		"DB_USER",
 		"DB_PASSWORD",
-		"JWT_SECRET",
 	}
 
 	for _, env := range requiredEnvs {
@@ -90,6 +186,19 @@ func LoadConfig(path string) (*Config, error) {
 	if config.JWTSecret == "" {
 		config.JWTSecret = os.Getenv("JWT_SECRET")
 	}
+	if config.JWKSURL == "" {
+		config.JWKSURL = os.Getenv("JWKS_URL")
+	}
+	if config.OIDCIssuer == "" {
+		config.OIDCIssuer = os.Getenv("OIDC_ISSUER")
+	}
+	if config.OIDCAuthorizationURL == "" {
+		config.OIDCAuthorizationURL = os.Getenv("OIDC_AUTHORIZATION_URL")
+	}
+	// The shared secret is only used when tokens are HS256
+	if config.JWTAlgorithm == "HS256" && config.JWTSecret == "" {
+		return nil, fmt.Errorf("required environment variable not set: JWT_SECRET")
+	}
 	if config.BookServiceURL == "" {
 		config.BookServiceURL = os.Getenv("BOOK_SERVICE_URL")
 	}
@@ -99,15 +208,42 @@ func LoadConfig(path string) (*Config, error) {
 	if config.UserServiceURL == "" {
 		config.UserServiceURL = os.Getenv("USER_SERVICE_URL")
 	}
+	// The argon2 parameters are narrowed to uint32 and uint8, which would wrap out-of-range values around
+	if err := checkRange("PASSWORD_ARGON2_MEMORY", config.PasswordArgon2Memory, math.MaxUint32); err != nil {
+		return nil, err
+	}
+	if err := checkRange("PASSWORD_ARGON2_ITERATIONS", config.PasswordArgon2Iterations, math.MaxUint32); err != nil {
+		return nil, err
+	}
+	if err := checkRange("PASSWORD_ARGON2_PARALLELISM", config.PasswordArgon2Parallelism, math.MaxUint8); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
 
+// checkRange rejects a setting outside 0..max, where 0 selects the default
+func checkRange(key string, value int, max int64) error {
+	if value < 0 || int64(value) > max {
+		return fmt.Errorf("%s must be between 0 and %d", key, max)
+	}
+	return nil
+}
+
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode)
 }
 
+// TokenKeysURL returns the JWKS endpoint access tokens are verified against, or "" when tokens are HS256
+// and verified with the shared secret
+func (c *Config) TokenKeysURL() string {
+	if c.JWTAlgorithm == "HS256" {
+		return ""
+	}
+	return c.JWKSURL
+}
+
 func (c *Config) GetRedisAddress() string {
 	return fmt.Sprintf("%s:%s", c.RedisHost, c.RedisPort)
 }
@@ -145,3 +281,19 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvAsSlice reads a comma-separated list; an empty value gives an empty list
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
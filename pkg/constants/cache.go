@@ -11,6 +11,23 @@ const (
 	CacheKeyCategories = "categories:"
 	CacheKeyUsers      = "users:"
 
+	// CacheKeyCategoryCountsVersion is bumped by any service whose writes change category book or child
+	// counts; cached counts are keyed by it so a bump invalidates all of them at once
+	CacheKeyCategoryCountsVersion = "categories:counts:version"
+
+	// CacheKeyTokenVersion prefixes each user's current token version, published by the user service and
+	// read by every service that validates access tokens
+	CacheKeyTokenVersion = "auth:token_version:"
+
+	// CacheKeyLoginFailures and CacheKeyLoginBlock prefix the failed login counters and lockouts kept per
+	// account and per client IP
+	CacheKeyLoginFailures = "auth:login_failures:"
+	CacheKeyLoginBlock    = "auth:login_block:"
+
+	// JWKSCacheTTL is how long verifiers cache the user service's key set. New signing keys are published
+	// this long before they start signing.
+	JWKSCacheTTL = 5 * time.Minute
+
 	CacheDefaultTTL = 15 * time.Minute
 	CacheLongTTL    = 1 * time.Hour
 	CacheShortTTL   = 5 * time.Minute
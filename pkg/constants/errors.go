@@ -22,8 +22,56 @@ const (
 	ErrCategoryNotFound   = "category not found"
 	ErrUserNotFound       = "user not found"
 	ErrBookNotFound       = "book not found"
+	ErrInvalidCursor      = "invalid pagination cursor"
+	ErrInvalidFilter      = "invalid filter expression"
+	ErrInvalidSort        = "invalid sort expression"
+	ErrDuplicateNotFound  = "duplicate candidate not found"
+	ErrMergeSameBook      = "cannot merge a book into itself"
+	ErrBatchTooLarge      = "too many IDs in batch request"
+
+	ErrMergeSameCategory       = "cannot merge a category into itself"
+	ErrMergeIntoDescendant     = "cannot merge a category into its own subtree"
+	ErrReassignTargetRequired  = "reassignment target is required to force delete a category"
+	ErrReassignTargetInSubtree = "reassignment target must be outside the deleted subtree"
+	ErrBookServiceUnavailable  = "book service unavailable"
+
+	ErrCategoryServiceUnavailable = "category service unavailable"
+
+	ErrInvalidClassNumber        = "invalid classification number"
+	ErrUnknownScheme             = "unknown classification scheme"
+	ErrBookNotClassified         = "book has no classification number"
+	ErrClassNumberTaken          = "classification number already assigned to another category"
+	ErrInvalidClassificationFile = "invalid classification file"
+
+	ErrUnsupportedLocale = "unsupported locale"
+	ErrInvalidSlug       = "slug must contain only lowercase letters, digits and single hyphens"
+	ErrSlugTaken         = "slug already in use"
 
 	ErrTokenRevoked     = "token has been revoked"
 	ErrTokenBlacklisted = "token is blacklisted"
 	ErrInvalidRole      = "invalid user role"
+
+	ErrSessionNotFound    = "session not found"
+	ErrRefreshTokenReused = "refresh token has already been used"
+
+	ErrAccessChangeForbidden = "only administrators can change a user's role or status"
+
+	ErrOAuthClientNotFound = "oauth client not found"
+	ErrConsentNotFound     = "consent not found"
+	ErrInvalidRedirectURI  = "redirect URIs must be https URLs without a fragment, or http on a loopback host"
+
+	ErrInvalidMFAToken   = "invalid or expired MFA token"
+	ErrInvalidMFACode    = "invalid verification code"
+	ErrMFALocked         = "too many invalid verification codes, try again later"
+	ErrMFANotEnabled     = "two-factor authentication is not enabled"
+	ErrMFAAlreadyEnabled = "two-factor authentication is already enabled"
+	ErrMFARequired       = "two-factor authentication is required for this role"
+
+	ErrInvalidAccountToken   = "invalid or expired token"
+	ErrAccountPending        = "account is pending activation; verify your email address or wait for an administrator to approve it"
+	ErrAccountNotPending     = "account is not pending activation"
+	ErrAccountBlocked        = "account is blocked; contact an administrator"
+	ErrAccountInactive       = "account is inactive; contact an administrator"
+	ErrVerificationThrottled = "a verification email was sent recently, try again later"
+	ErrTooManyLoginAttempts  = "too many failed login attempts, try again later"
 )
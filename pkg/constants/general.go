@@ -4,11 +4,19 @@ const (
 	DefaultPageSize    = 10
 	MaxPageSize        = 100
 	DefaultSearchLimit = 20
+	MaxBatchSize       = 100
+
+	MaxFilterLength     = 1024
+	MaxFilterConditions = 20
+	MaxFilterInValues   = 100
 
-	PasswordMinLength = 8
 	UsernameMinLength = 3
 	UsernameMaxLength = 30
 
+	LocaleEnglish    = "en"
+	LocaleIndonesian = "id"
+	DefaultLocale    = LocaleEnglish
+
 	TokenTypBearer      = "Bearer"
 	HeaderAuthorization = "Authorization"
 
@@ -8,6 +8,13 @@ const (
 	BookStatusMaintenance = "maintenance"
 )
 
+// Book duplicate candidate status
+const (
+	DuplicateStatusPending   = "pending"
+	DuplicateStatusMerged    = "merged"
+	DuplicateStatusDismissed = "dismissed"
+)
+
 // User status
 const (
 	UserStatusActive   = "active"
@@ -0,0 +1,353 @@
+package listquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"gorm.io/gorm/clause"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// ParseFilter parses a filter expression such as
+// `published_year>=1950 AND language in (English,French)` into a GORM condition.
+//
+// Supported operators are =, !=, >, >=, <, <=, in, not in and like (case-insensitive substring).
+// Conditions combine with AND, OR, NOT and parentheses. Values may be bare words or quoted with
+// single or double quotes; a bare null matches NULL. It returns nil when the expression is empty.
+func (s Schema) ParseFilter(expr string) (clause.Expression, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	if len(expr) > constants.MaxFilterLength {
+		return nil, fmt.Errorf("%w: expression exceeds %d characters", ErrInvalidFilter, constants.MaxFilterLength)
+	}
+
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{schema: s, tokens: tokens}
+
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrInvalidFilter, tok.value, tok.pos)
+	}
+
+	return cond, nil
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ",", pos: i})
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string at position %d", ErrInvalidFilter, i)
+			}
+			tokens = append(tokens, token{kind: tokenString, value: expr[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("%w: unexpected \"!\" at position %d", ErrInvalidFilter, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, value: op, pos: i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n\r(),'\"=!<>", rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: expr[start:i], pos: start})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+type parser struct {
+	schema     Schema
+	tokens     []token
+	pos        int
+	conditions int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) keyword(word string) bool {
+	tok := p.peek()
+	if tok.kind == tokenWord && strings.EqualFold(tok.value, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, p.unexpected(tok, what)
+	}
+	return tok, nil
+}
+
+func (p *parser) unexpected(tok token, what string) error {
+	if tok.kind == tokenEOF {
+		return fmt.Errorf("%w: expected %s at end of expression", ErrInvalidFilter, what)
+	}
+	return fmt.Errorf("%w: expected %s at position %d, got %q", ErrInvalidFilter, what, tok.pos, tok.value)
+}
+
+func (p *parser) parseOr() (clause.Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []clause.Expression{left}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, right)
+	}
+
+	// a single-element OrConditions would be joined to its siblings with OR
+	if len(exprs) == 1 {
+		return left, nil
+	}
+
+	return clause.Or(exprs...), nil
+}
+
+func (p *parser) parseAnd() (clause.Expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []clause.Expression{left}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, right)
+	}
+
+	return clause.And(exprs...), nil
+}
+
+func (p *parser) parseUnary() (clause.Expression, error) {
+	if p.keyword("not") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return clause.Not(expr), nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "\")\""); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	return p.parseCondition()
+}
+
+func (p *parser) parseCondition() (clause.Expression, error) {
+	p.conditions++
+	if p.conditions > constants.MaxFilterConditions {
+		return nil, fmt.Errorf("%w: more than %d conditions", ErrInvalidFilter, constants.MaxFilterConditions)
+	}
+
+	name, err := p.expect(tokenWord, "field name")
+	if err != nil {
+		return nil, err
+	}
+
+	field, ok := p.schema[name.value]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot filter by %q", ErrInvalidFilter, name.value)
+	}
+	column := clause.Column{Name: field.Column}
+
+	negate := p.keyword("not")
+
+	switch {
+	case p.keyword("in"):
+		values, err := p.parseList(field)
+		if err != nil {
+			return nil, err
+		}
+		var expr clause.Expression = clause.IN{Column: column, Values: values}
+		if negate {
+			expr = clause.Not(expr)
+		}
+		return expr, nil
+	case p.keyword("like"):
+		if field.Type != TypeString {
+			return nil, fmt.Errorf("%w: like is only supported on text fields", ErrInvalidFilter)
+		}
+		tok, err := p.parseValueToken()
+		if err != nil {
+			return nil, err
+		}
+		pattern := "%" + escapeLike(tok.value) + "%"
+		var expr clause.Expression = clause.Expr{SQL: "? ILIKE ?", Vars: []interface{}{column, pattern}}
+		if negate {
+			expr = clause.Not(expr)
+		}
+		return expr, nil
+	case negate:
+		return nil, p.unexpected(p.peek(), "\"in\" or \"like\" after \"not\"")
+	}
+
+	op, err := p.expect(tokenOp, "operator")
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.parseValue(field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.value {
+	case "=":
+		return clause.Eq{Column: column, Value: value}, nil
+	case "!=":
+		return clause.Neq{Column: column, Value: value}, nil
+	}
+
+	if value == nil {
+		return nil, fmt.Errorf("%w: null can only be compared with = or !=", ErrInvalidFilter)
+	}
+
+	switch op.value {
+	case ">":
+		return clause.Gt{Column: column, Value: value}, nil
+	case ">=":
+		return clause.Gte{Column: column, Value: value}, nil
+	case "<":
+		return clause.Lt{Column: column, Value: value}, nil
+	default:
+		return clause.Lte{Column: column, Value: value}, nil
+	}
+}
+
+func (p *parser) parseList(field Field) ([]interface{}, error) {
+	if _, err := p.expect(tokenLParen, "\"(\""); err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	for {
+		value, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, fmt.Errorf("%w: null is not allowed in a list", ErrInvalidFilter)
+		}
+		values = append(values, value)
+
+		if len(values) > constants.MaxFilterInValues {
+			return nil, fmt.Errorf("%w: more than %d list values", ErrInvalidFilter, constants.MaxFilterInValues)
+		}
+
+		tok := p.next()
+		if tok.kind == tokenRParen {
+			return values, nil
+		}
+		if tok.kind != tokenComma {
+			return nil, p.unexpected(tok, "\",\" or \")\"")
+		}
+	}
+}
+
+func (p *parser) parseValueToken() (token, error) {
+	tok := p.next()
+	if tok.kind != tokenWord && tok.kind != tokenString {
+		return tok, p.unexpected(tok, "value")
+	}
+	return tok, nil
+}
+
+func (p *parser) parseValue(field Field) (interface{}, error) {
+	tok, err := p.parseValueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := field.convert(tok.value, tok.kind == tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid value %q at position %d", ErrInvalidFilter, tok.value, tok.pos)
+	}
+
+	return value, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
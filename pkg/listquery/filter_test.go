@@ -0,0 +1,214 @@
+package listquery
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var testSchema = Schema{
+	"id":             {Column: "id", Type: TypeUUID},
+	"title":          {Column: "title", Type: TypeString},
+	"language":       {Column: "language", Type: TypeString},
+	"published_year": {Column: "published_year", Type: TypeInt},
+	"rating":         {Column: "average_rating", Type: TypeFloat, Nullable: true},
+	"available":      {Column: "is_available", Type: TypeBool},
+	"description":    {Column: "description", Type: TypeString, Unsortable: true},
+}
+
+// dryRun returns a session that builds statements without a database connection
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run session: %v", err)
+	}
+	return db
+}
+
+// whereSQL renders a condition as the WHERE clause of a query on books
+func whereSQL(t *testing.T, cond clause.Expression) (string, []interface{}) {
+	t.Helper()
+
+	var rows []map[string]interface{}
+	stmt := dryRun(t).Table("books").Where(cond).Find(&rows).Statement
+	sql := strings.TrimPrefix(stmt.SQL.String(), `SELECT * FROM "books" WHERE `)
+	return sql, stmt.Vars
+}
+
+func TestParseFilter(t *testing.T) {
+	id := uuid.MustParse("6f1c1d9e-9a4b-4c3e-8f0e-2b7d5a1c3e90")
+
+	tests := []struct {
+		name string
+		expr string
+		sql  string
+		vars []interface{}
+	}{
+		{
+			name: "equality",
+			expr: "title=Dune",
+			sql:  `"title" = $1`,
+			vars: []interface{}{"Dune"},
+		},
+		{
+			name: "comparison operators",
+			expr: "published_year>=1950 and published_year<2000 and rating>4.5 and published_year!=1984",
+			sql:  `("published_year" >= $1 AND "published_year" < $2 AND "average_rating" > $3 AND "published_year" <> $4)`,
+			vars: []interface{}{int64(1950), int64(2000), 4.5, int64(1984)},
+		},
+		{
+			name: "and binds tighter than or",
+			expr: "title=a OR title=b AND published_year>1",
+			sql:  `("title" = $1 OR ("title" = $2 AND "published_year" > $3))`,
+			vars: []interface{}{"a", "b", int64(1)},
+		},
+		{
+			name: "parentheses override precedence",
+			expr: "(title=a OR title=b) AND published_year>1",
+			sql:  `(("title" = $1 OR "title" = $2) AND "published_year" > $3)`,
+			vars: []interface{}{"a", "b", int64(1)},
+		},
+		{
+			name: "not negates a condition",
+			expr: "NOT title=a",
+			sql:  `"title" <> $1`,
+			vars: []interface{}{"a"},
+		},
+		{
+			name: "not negates a group",
+			expr: "not (title=a or available=true)",
+			sql:  `NOT ("title" = $1 OR "is_available" = $2)`,
+			vars: []interface{}{"a", true},
+		},
+		{
+			name: "in list",
+			expr: "language in (English, 'Old French')",
+			sql:  `"language" IN ($1,$2)`,
+			vars: []interface{}{"English", "Old French"},
+		},
+		{
+			name: "not in list",
+			expr: "language NOT IN (English,French)",
+			sql:  `"language" NOT IN ($1,$2)`,
+			vars: []interface{}{"English", "French"},
+		},
+		{
+			name: "like escapes wildcards",
+			expr: `title like "50%_\"`,
+			sql:  `"title" ILIKE $1`,
+			vars: []interface{}{`%50\%\_\\%`},
+		},
+		{
+			name: "not like",
+			expr: "title not like dune",
+			sql:  `NOT "title" ILIKE $1`,
+			vars: []interface{}{"%dune%"},
+		},
+		{
+			name: "bare null matches NULL",
+			expr: "rating = null",
+			sql:  `"average_rating" IS NULL`,
+		},
+		{
+			name: "quoted null is a string",
+			expr: "title != 'null'",
+			sql:  `"title" <> $1`,
+			vars: []interface{}{"null"},
+		},
+		{
+			name: "quotes keep spaces and operators in the value",
+			expr: `title = "War and Peace" and language='a=b'`,
+			sql:  `("title" = $1 AND "language" = $2)`,
+			vars: []interface{}{"War and Peace", "a=b"},
+		},
+		{
+			name: "uuid values are parsed",
+			expr: "id=" + id.String(),
+			sql:  `"id" = $1`,
+			vars: []interface{}{id},
+		},
+		{
+			name: "injection in a value stays a bound parameter",
+			expr: `title="x' OR '1'='1" or title=a;DROP`,
+			sql:  `("title" = $1 OR "title" = $2)`,
+			vars: []interface{}{"x' OR '1'='1", "a;DROP"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := testSchema.ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.expr, err)
+			}
+
+			sql, vars := whereSQL(t, cond)
+			if sql != tt.sql {
+				t.Errorf("ParseFilter(%q) built\n  %s\nwant\n  %s", tt.expr, sql, tt.sql)
+			}
+			if len(vars) != 0 || len(tt.vars) != 0 {
+				if !reflect.DeepEqual(vars, tt.vars) {
+					t.Errorf("ParseFilter(%q) bound %#v, want %#v", tt.expr, vars, tt.vars)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFilterEmpty(t *testing.T) {
+	cond, err := testSchema.ParseFilter("   ")
+	if err != nil || cond != nil {
+		t.Errorf("ParseFilter of a blank expression = %v, %v; want nil, nil", cond, err)
+	}
+}
+
+func TestParseFilterRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "unknown field", expr: "isbn=123"},
+		{name: "column name that is not a public field", expr: "average_rating>3"},
+		{name: "statement separator in field", expr: "title;DROP TABLE books"},
+		{name: "quoted field name", expr: `"title"=a`},
+		{name: "sql in place of a field", expr: "1=1"},
+		{name: "subquery", expr: "title in (select title from users)"},
+		{name: "unbalanced parenthesis", expr: "title=a) OR (1=1"},
+		{name: "unclosed parenthesis", expr: "(title=a"},
+		{name: "comment", expr: "title=a --"},
+		{name: "doubled quote does not escape", expr: "title='x'' OR 1=1 --'"},
+		{name: "trailing operator", expr: "title=a and"},
+		{name: "missing value", expr: "title="},
+		{name: "bang without equals", expr: "title ! a"},
+		{name: "unterminated string", expr: "title='dune"},
+		{name: "wrong value type", expr: "published_year=nineteen"},
+		{name: "invalid uuid", expr: "id=123"},
+		{name: "like on a number", expr: "published_year like 19"},
+		{name: "null in ordering comparison", expr: "rating > null"},
+		{name: "null in a list", expr: "language in (English, null)"},
+		{name: "empty list", expr: "language in ()"},
+		{name: "not without in or like", expr: "title not = a"},
+		{name: "too many conditions", expr: strings.Repeat("title=a and ", 50) + "title=a"},
+		{name: "too long", expr: "title='" + strings.Repeat("a", 5000) + "'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := testSchema.ParseFilter(tt.expr)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("ParseFilter(%q) = %v, %v; want an ErrInvalidFilter", tt.expr, cond, err)
+			}
+		})
+	}
+}
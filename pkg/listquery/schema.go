@@ -0,0 +1,131 @@
+package listquery
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/pagination"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidFilter is wrapped by every filter parse error
+	ErrInvalidFilter = errors.New(constants.ErrInvalidFilter)
+	// ErrInvalidSort is wrapped by every sort parse error
+	ErrInvalidSort = errors.New(constants.ErrInvalidSort)
+)
+
+// Type is the value type of a queryable field
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeTime
+	TypeUUID
+)
+
+// Field maps a public field name to a database column. Nullable marks a column that may hold NULL, which
+// sorts as the zero value of its type.
+type Field struct {
+	Column     string
+	Type       Type
+	Unsortable bool
+	Nullable   bool
+}
+
+// nullAs is the SQL literal for the zero value a NULL of the type scans into
+func (t Type) nullAs() string {
+	switch t {
+	case TypeInt, TypeFloat:
+		return "0"
+	case TypeBool:
+		return "false"
+	case TypeTime:
+		return "'0001-01-01 00:00:00'"
+	default:
+		return "''"
+	}
+}
+
+// Schema is the whitelist of fields a list endpoint can filter and sort on, keyed by public name
+type Schema map[string]Field
+
+// ParseSort parses a comma-separated sort expression such as "-published_year,title".
+// A leading "-" sorts descending. The default keys are returned when the expression is empty.
+func (s Schema) ParseSort(expr string, def ...pagination.SortKey) ([]pagination.SortKey, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return def, nil
+	}
+
+	parts := strings.Split(expr, ",")
+	keys := make([]pagination.SortKey, 0, len(parts))
+	seen := make(map[string]bool, len(parts))
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+
+		desc := false
+		if strings.HasPrefix(part, "-") {
+			desc = true
+			part = part[1:]
+		} else {
+			part = strings.TrimPrefix(part, "+")
+		}
+
+		field, ok := s[part]
+		if !ok || field.Unsortable {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSort, part)
+		}
+
+		if seen[part] {
+			return nil, fmt.Errorf("%w: duplicate sort field %q", ErrInvalidSort, part)
+		}
+		seen[part] = true
+
+		key := pagination.SortKey{Column: field.Column, Desc: desc}
+		if field.Nullable {
+			key.NullAs = field.Type.nullAs()
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// SortExpr turns the legacy sort_by/desc pair into a sort expression
+func SortExpr(sortBy string, desc bool) string {
+	sortBy = strings.TrimSpace(sortBy)
+	if !desc || sortBy == "" || strings.ContainsAny(sortBy, ",-+") {
+		return sortBy
+	}
+	return "-" + sortBy
+}
+
+func (f Field) convert(raw string, quoted bool) (interface{}, error) {
+	if !quoted && strings.EqualFold(raw, "null") {
+		return nil, nil
+	}
+
+	switch f.Type {
+	case TypeInt:
+		return strconv.ParseInt(raw, 10, 64)
+	case TypeFloat:
+		return strconv.ParseFloat(raw, 64)
+	case TypeBool:
+		return strconv.ParseBool(raw)
+	case TypeTime:
+		return utils.ParseTime(raw)
+	case TypeUUID:
+		return uuid.Parse(raw)
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,126 @@
+package listquery
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fairuzald/library-system/pkg/pagination"
+)
+
+// orderSQL renders sort keys as the ORDER BY clause of a page query on books
+func orderSQL(t *testing.T, keys []pagination.SortKey) string {
+	t.Helper()
+
+	params, err := pagination.NewParams(keys, "", 0, 10)
+	if err != nil {
+		t.Fatalf("NewParams: %v", err)
+	}
+
+	var rows []map[string]interface{}
+	sql := params.Apply(dryRun(t).Table("books")).Find(&rows).Statement.SQL.String()
+	sql = strings.TrimPrefix(sql, `SELECT * FROM "books" ORDER BY `)
+	return strings.TrimSuffix(sql, " LIMIT 11")
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		keys []pagination.SortKey
+		sql  string
+	}{
+		{
+			name: "ascending",
+			expr: "title",
+			keys: []pagination.SortKey{{Column: "title"}},
+			sql:  `"title","id"`,
+		},
+		{
+			name: "descending and explicit ascending",
+			expr: " -published_year , +title ",
+			keys: []pagination.SortKey{{Column: "published_year", Desc: true}, {Column: "title"}},
+			sql:  `"published_year" DESC,"title","id"`,
+		},
+		{
+			name: "public name maps to its column",
+			expr: "-rating",
+			keys: []pagination.SortKey{{Column: "average_rating", Desc: true, NullAs: "0"}},
+			sql:  `COALESCE(average_rating, 0) DESC,"id" DESC`,
+		},
+		{
+			name: "empty uses the default",
+			expr: "",
+			keys: []pagination.SortKey{{Column: "created_at", Desc: true}},
+			sql:  `"created_at" DESC,"id" DESC`,
+		},
+	}
+
+	def := pagination.SortKey{Column: "created_at", Desc: true}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := testSchema.ParseSort(tt.expr, def)
+			if err != nil {
+				t.Fatalf("ParseSort(%q): %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("ParseSort(%q) = %+v, want %+v", tt.expr, keys, tt.keys)
+			}
+			if sql := orderSQL(t, keys); sql != tt.sql {
+				t.Errorf("ParseSort(%q) ordered by %s, want %s", tt.expr, sql, tt.sql)
+			}
+		})
+	}
+}
+
+func TestParseSortRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "unknown field", expr: "isbn"},
+		{name: "column name that is not a public field", expr: "average_rating"},
+		{name: "unsortable field", expr: "description"},
+		{name: "duplicate field", expr: "title,-title"},
+		{name: "statement separator", expr: "title;DROP TABLE books"},
+		{name: "expression after a valid key", expr: "-title,1=1"},
+		{name: "direction keyword", expr: "title desc"},
+		{name: "comment", expr: "title--"},
+		{name: "subquery", expr: "(select 1)"},
+		{name: "quoted identifier", expr: `"title"`},
+		{name: "empty key", expr: "title,"},
+		{name: "double sign", expr: "--title"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := testSchema.ParseSort(tt.expr)
+			if !errors.Is(err, ErrInvalidSort) {
+				t.Errorf("ParseSort(%q) = %+v, %v; want an ErrInvalidSort", tt.expr, keys, err)
+			}
+		})
+	}
+}
+
+func TestSortExpr(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		desc   bool
+		want   string
+	}{
+		{sortBy: "title", want: "title"},
+		{sortBy: "title", desc: true, want: "-title"},
+		{sortBy: " title ", desc: true, want: "-title"},
+		{sortBy: "", desc: true, want: ""},
+		{sortBy: "-title", desc: true, want: "-title"},
+		{sortBy: "title,published_year", desc: true, want: "title,published_year"},
+	}
+
+	for _, tt := range tests {
+		if got := SortExpr(tt.sortBy, tt.desc); got != tt.want {
+			t.Errorf("SortExpr(%q, %v) = %q, want %q", tt.sortBy, tt.desc, got, tt.want)
+		}
+	}
+}
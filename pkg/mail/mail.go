@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"context"
+
+	"github.com/fairuzald/library-system/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email. Services depend on this interface so the transport can be swapped, for example for
+// a fake in development.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender writes messages to the log instead of sending them, for environments without a mail server.
+// Bodies can hold secrets such as reset links, so they are only logged at debug level.
+type LogSender struct {
+	log *logger.Logger
+}
+
+func NewLogSender(log *logger.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	s.log.Warn("Mail is not configured; message not sent",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+	)
+	s.log.Debug("Unsent message body", zap.String("to", msg.To), zap.String("body", msg.Body))
+	return nil
+}
@@ -0,0 +1,290 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// received is what the fake server saw of one SMTP session
+type received struct {
+	from   string
+	to     []string
+	data   string
+	tls    bool
+	quit   bool
+	greets int
+}
+
+// fakeSMTP is a single-session SMTP server listening on loopback. It offers STARTTLS when given a
+// certificate.
+type fakeSMTP struct {
+	listener net.Listener
+	tls      *tls.Config
+	done     chan received
+}
+
+func newFakeSMTP(t *testing.T, cert *tls.Certificate) *fakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeSMTP{listener: listener, done: make(chan received, 1)}
+	if cert != nil {
+		f.tls = &tls.Config{Certificates: []tls.Certificate{*cert}}
+	}
+
+	go f.serve()
+	return f
+}
+
+func (f *fakeSMTP) serve() {
+	var got received
+	defer func() { f.done <- got }()
+
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake ESMTP")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			got.greets++
+			if f.tls != nil && !got.tls {
+				text.PrintfLine("250-fake")
+				text.PrintfLine("250 STARTTLS")
+			} else {
+				text.PrintfLine("250 fake")
+			}
+		case "STARTTLS":
+			if f.tls == nil || got.tls {
+				text.PrintfLine("502 not supported")
+				continue
+			}
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, f.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			got.tls = true
+		case "MAIL":
+			got.from = arg
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			got.to = append(got.to, arg)
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := readData(text.Reader.R)
+			if err != nil {
+				return
+			}
+			got.data = data
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			got.quit = true
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+// readData reads a DATA payload up to the terminating dot line, keeping CRLF line endings and dot stuffing
+// so the test sees exactly what went over the wire
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(line)
+	}
+}
+
+func (f *fakeSMTP) session(t *testing.T) received {
+	t.Helper()
+	select {
+	case got := <-f.done:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session did not finish")
+		return received{}
+	}
+}
+
+// selfSigned returns a certificate for 127.0.0.1 and a pool that trusts it
+func selfSigned(t *testing.T) (*tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func newTestSender(t *testing.T, f *fakeSMTP, requireTLS bool, roots *x509.CertPool) *SMTPSender {
+	t.Helper()
+
+	_, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	sender, err := NewSMTPSender(SMTPConfig{
+		Host:       "127.0.0.1",
+		Port:       port,
+		From:       "Library <noreply@library.test>",
+		RequireTLS: requireTLS,
+		Timeout:    5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPSender: %v", err)
+	}
+	sender.rootCAs = roots
+	return sender
+}
+
+func TestSMTPSenderSendsEnvelopeAndBody(t *testing.T) {
+	f := newFakeSMTP(t, nil)
+	sender := newTestSender(t, f, false, nil)
+
+	err := sender.Send(context.Background(), Message{
+		To:      "reader@library.test",
+		Subject: "Réinitialiser",
+		Body:    "line one\nline two\r\n.leading dot",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := f.session(t)
+	if !strings.HasPrefix(got.from, "FROM:<noreply@library.test>") {
+		t.Errorf("MAIL %q, want the bare sender address", got.from)
+	}
+	if len(got.to) != 1 || got.to[0] != "TO:<reader@library.test>" {
+		t.Errorf("RCPT %q, want one recipient reader@library.test", got.to)
+	}
+	if got.tls {
+		t.Error("session was upgraded although the server does not offer STARTTLS")
+	}
+	if !got.quit {
+		t.Error("client did not QUIT")
+	}
+
+	headers, body, ok := strings.Cut(got.data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header/body separator: %q", got.data)
+	}
+	for _, want := range []string{
+		"From: Library <noreply@library.test>",
+		"To: reader@library.test",
+		"Subject: =?utf-8?q?R=C3=A9initialiser?=",
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(headers+"\r\n", want+"\r\n") {
+			t.Errorf("headers lack %q:\n%s", want, headers)
+		}
+	}
+	if want := "line one\r\nline two\r\n..leading dot\r\n"; body != want {
+		t.Errorf("body %q, want %q", body, want)
+	}
+}
+
+func TestSMTPSenderUpgradesWithSTARTTLS(t *testing.T) {
+	cert, roots := selfSigned(t)
+	f := newFakeSMTP(t, cert)
+	sender := newTestSender(t, f, true, roots)
+
+	if err := sender.Send(context.Background(), Message{To: "reader@library.test", Subject: "Hi", Body: "secret link"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := f.session(t)
+	if !got.tls {
+		t.Fatal("message was sent without STARTTLS")
+	}
+	if got.greets != 2 {
+		t.Errorf("client greeted %d times, want EHLO again after STARTTLS", got.greets)
+	}
+	if !strings.Contains(got.data, "secret link") {
+		t.Errorf("body missing from message: %q", got.data)
+	}
+}
+
+func TestSMTPSenderRefusesPlaintextWhenTLSRequired(t *testing.T) {
+	f := newFakeSMTP(t, nil)
+	sender := newTestSender(t, f, true, nil)
+
+	err := sender.Send(context.Background(), Message{To: "reader@library.test", Subject: "Hi", Body: "secret link"})
+	if err == nil {
+		t.Fatal("Send succeeded without TLS although RequireTLS is set")
+	}
+
+	got := f.session(t)
+	if got.from != "" || got.data != "" {
+		t.Errorf("message reached a server without TLS: MAIL %q, DATA %q", got.from, got.data)
+	}
+}
+
+func TestSMTPSenderRejectsHeaderInjection(t *testing.T) {
+	f := newFakeSMTP(t, nil)
+	sender := newTestSender(t, f, false, nil)
+
+	err := sender.Send(context.Background(), Message{To: "reader@library.test\r\nBcc: attacker@evil.test", Subject: "Hi"})
+	if err == nil {
+		t.Fatal("Send accepted a recipient with a line break")
+	}
+}
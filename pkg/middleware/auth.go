@@ -3,10 +3,12 @@ package middleware
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/golang-jwt/jwt/v4"
 	"google.golang.org/grpc"
@@ -21,6 +23,7 @@ const (
 	UserIDKey     ContextKey = "user_id"
 	UserRoleKey   ContextKey = "user_role"
 	UserEmailKey  ContextKey = "user_email"
+	SessionIDKey  ContextKey = "session_id"
 	AuthTokenKey  ContextKey = "auth_token"
 	AuthHeaderKey            = "Authorization"
 	BearerSchema             = "Bearer"
@@ -31,12 +34,32 @@ type Claims struct {
 	Email    string `json:"email"`
 	Role     string `json:"role"`
 	Username string `json:"username"`
+	// SessionID identifies the login session the token was issued for
+	SessionID string `json:"sid,omitempty"`
+	// TokenVersion is the user's token version at issue; bumping the version revokes every older token
+	TokenVersion int64 `json:"ver,omitempty"`
+	// ClientID and Scope are set on tokens issued to OAuth clients, which only grant the scopes the user
+	// consented to and are not accepted by the library's own APIs
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenVersionStore reports a user's current token version
+type TokenVersionStore interface {
+	TokenVersion(ctx context.Context, userID string) (int64, error)
+}
+
+// JWTAuth signs and validates access tokens. Tokens are HS256 with the shared secret unless a signer or key
+// resolver is configured, in which case they are RS256 or EdDSA with a kid header and HS256 is rejected.
 type JWTAuth struct {
 	secretKey     []byte
 	tokenDuration time.Duration
+	versions      TokenVersionStore
+	// versionsFailOpen accepts tokens whose version cannot be looked up instead of treating them as revoked
+	versionsFailOpen bool
+	signer           TokenSigner
+	keys             KeyResolver
 }
 
 func NewJWTAuth(secretKey string, tokenDuration time.Duration) *JWTAuth {
@@ -46,32 +69,82 @@ func NewJWTAuth(secretKey string, tokenDuration time.Duration) *JWTAuth {
 	}
 }
 
-func (j *JWTAuth) GenerateToken(userID, email, role, username string) (string, error) {
-	claims := Claims{
-		UserID:   userID,
-		Email:    email,
-		Role:     role,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.tokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// WithTokenVersions makes the middleware and interceptors reject tokens older than the user's current
+// token version. A version that cannot be looked up counts as revoked unless failOpen is set, which trades
+// revocation for availability while the stores are down.
+func (j *JWTAuth) WithTokenVersions(store TokenVersionStore, failOpen bool) *JWTAuth {
+	j.versions = store
+	j.versionsFailOpen = failOpen
+	return j
+}
+
+// WithSigner signs new tokens with the signer's current key instead of the shared secret
+func (j *JWTAuth) WithSigner(signer TokenSigner) *JWTAuth {
+	j.signer = signer
+	return j
+}
+
+// WithKeyResolver validates tokens against the resolver's public keys instead of the shared secret
+func (j *JWTAuth) WithKeyResolver(keys KeyResolver) *JWTAuth {
+	j.keys = keys
+	return j
+}
+
+// GenerateToken signs the given claims, setting their issue and expiry times
+func (j *JWTAuth) GenerateToken(ctx context.Context, claims Claims) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.tokenDuration)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secretKey)
+	return j.Sign(ctx, claims)
+}
+
+// Sign signs arbitrary claims as they are, with the same key as access tokens
+func (j *JWTAuth) Sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	if j.signer == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(j.secretKey)
+	}
+
+	key, err := j.signer.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Key)
 }
 
 func (j *JWTAuth) ValidateToken(tokenString string) (*Claims, error) {
+	return j.validateToken(context.Background(), tokenString)
+}
+
+func (j *JWTAuth) validateToken(ctx context.Context, tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected token signing method")
+			if j.keys != nil {
+				kid, _ := token.Header["kid"].(string)
+				if kid == "" {
+					return nil, errors.New("token has no key ID")
+				}
+				return j.keys.VerificationKey(ctx, kid)
+			}
+
+			if len(j.secretKey) == 0 {
+				return nil, errors.New("no token verification key configured")
 			}
 			return j.secretKey, nil
 		},
+		jwt.WithValidMethods(j.validMethods()),
 	)
 
 	if err != nil {
@@ -86,6 +159,46 @@ func (j *JWTAuth) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// validMethods lists the algorithms accepted in the alg header, so that a token cannot pick a weaker one
+func (j *JWTAuth) validMethods() []string {
+	if j.keys != nil {
+		return []string{AlgorithmRS256, AlgorithmEdDSA}
+	}
+	return []string{AlgorithmHS256}
+}
+
+// Authenticate validates a token and checks it was not revoked by a token version bump. The token is passed
+// to the version store in the context, for stores that look the version up as the token's owner.
+func (j *JWTAuth) Authenticate(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := j.validateToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// OAuth access tokens name a client, and ID tokens, signed with the same key, carry no user_id
+	if claims.ClientID != "" || claims.UserID == "" {
+		return nil, errors.New("token is not a library access token")
+	}
+
+	if j.versions == nil {
+		return claims, nil
+	}
+
+	current, err := j.versions.TokenVersion(context.WithValue(ctx, AuthTokenKey, tokenString), claims.UserID)
+	if err != nil {
+		if j.versionsFailOpen {
+			return claims, nil
+		}
+		return nil, fmt.Errorf("%s: %w", constants.ErrTokenRevoked, err)
+	}
+
+	if claims.TokenVersion < current {
+		return nil, errors.New(constants.ErrTokenRevoked)
+	}
+
+	return claims, nil
+}
+
 func (j *JWTAuth) HTTPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get(AuthHeaderKey)
@@ -100,7 +213,7 @@ func (j *JWTAuth) HTTPMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		claims, err := j.ValidateToken(parts[1])
+		claims, err := j.Authenticate(r.Context(), parts[1])
 		if err != nil {
 			utils.RespondWithError(w, http.StatusUnauthorized, "invalid or expired token", err)
 			return
@@ -109,12 +222,45 @@ func (j *JWTAuth) HTTPMiddleware(next http.Handler) http.Handler {
 		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 		ctx = context.WithValue(ctx, UserRoleKey, claims.Role)
 		ctx = context.WithValue(ctx, UserEmailKey, claims.Email)
+		ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
 		ctx = context.WithValue(ctx, AuthTokenKey, parts[1])
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RejectInvalidTokens turns away requests whose bearer token is malformed or not signed by a trusted key,
+// and lets requests without a token through. Expired tokens are passed on, since clients send them along
+// when refreshing. It suits an edge proxy; the services still authenticate and authorize every request.
+func (j *JWTAuth) RejectInvalidTokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get(AuthHeaderKey)
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != BearerSchema {
+			utils.RespondWithError(w, http.StatusUnauthorized, "invalid authorization header format", nil)
+			return
+		}
+
+		if _, err := j.validateToken(r.Context(), parts[1]); err != nil && !isOnlyExpired(err) {
+			utils.RespondWithError(w, http.StatusUnauthorized, "invalid or expired token", err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isOnlyExpired reports whether a validation error means the token is genuine but past its expiry
+func isOnlyExpired(err error) bool {
+	var validationErr *jwt.ValidationError
+	return errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired
+}
+
 func (j *JWTAuth) UnaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
@@ -122,32 +268,11 @@ func (j *JWTAuth) UnaryInterceptor() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Errorf(codes.Unauthenticated, "metadata is not provided")
-		}
-
-		values := md.Get(AuthHeaderKey)
-		if len(values) == 0 {
-			return nil, status.Errorf(codes.Unauthenticated, "authorization token is not provided")
-		}
-
-		authHeader := values[0]
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != BearerSchema {
-			return nil, status.Errorf(codes.Unauthenticated, "invalid authorization header format")
-		}
-
-		claims, err := j.ValidateToken(parts[1])
+		newCtx, err := j.authenticateIncoming(ctx)
 		if err != nil {
-			return nil, status.Errorf(codes.Unauthenticated, "invalid or expired token: %v", err)
+			return nil, err
 		}
 
-		newCtx := context.WithValue(ctx, UserIDKey, claims.UserID)
-		newCtx = context.WithValue(newCtx, UserRoleKey, claims.Role)
-		newCtx = context.WithValue(newCtx, UserEmailKey, claims.Email)
-		newCtx = context.WithValue(newCtx, AuthTokenKey, parts[1])
-
 		return handler(newCtx, req)
 	}
 }
@@ -159,33 +284,11 @@ func (j *JWTAuth) StreamInterceptor() grpc.StreamServerInterceptor {
 		info *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		ctx := ss.Context()
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return status.Errorf(codes.Unauthenticated, "metadata is not provided")
-		}
-
-		values := md.Get(AuthHeaderKey)
-		if len(values) == 0 {
-			return status.Errorf(codes.Unauthenticated, "authorization token is not provided")
-		}
-
-		authHeader := values[0]
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != BearerSchema {
-			return status.Errorf(codes.Unauthenticated, "invalid authorization header format")
-		}
-
-		claims, err := j.ValidateToken(parts[1])
+		newCtx, err := j.authenticateIncoming(ss.Context())
 		if err != nil {
-			return status.Errorf(codes.Unauthenticated, "invalid or expired token: %v", err)
+			return err
 		}
 
-		newCtx := context.WithValue(ctx, UserIDKey, claims.UserID)
-		newCtx = context.WithValue(newCtx, UserRoleKey, claims.Role)
-		newCtx = context.WithValue(newCtx, UserEmailKey, claims.Email)
-		newCtx = context.WithValue(newCtx, AuthTokenKey, parts[1])
-
 		wrappedStream := &wrappedServerStream{
 			ServerStream: ss,
 			ctx:          newCtx,
@@ -195,6 +298,48 @@ func (j *JWTAuth) StreamInterceptor() grpc.StreamServerInterceptor {
 	}
 }
 
+// authenticateIncoming validates the bearer token in incoming gRPC metadata and returns a context carrying its claims
+func (j *JWTAuth) authenticateIncoming(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "metadata is not provided")
+	}
+
+	values := md.Get(AuthHeaderKey)
+	if len(values) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "authorization token is not provided")
+	}
+
+	authHeader := values[0]
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != BearerSchema {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid authorization header format")
+	}
+
+	claims, err := j.Authenticate(ctx, parts[1])
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid or expired token: %v", err)
+	}
+
+	newCtx := context.WithValue(ctx, UserIDKey, claims.UserID)
+	newCtx = context.WithValue(newCtx, UserRoleKey, claims.Role)
+	newCtx = context.WithValue(newCtx, UserEmailKey, claims.Email)
+	newCtx = context.WithValue(newCtx, SessionIDKey, claims.SessionID)
+	newCtx = context.WithValue(newCtx, AuthTokenKey, parts[1])
+
+	return newCtx, nil
+}
+
+// ForwardAuthToken copies the caller's bearer token into outgoing gRPC metadata, so a downstream
+// service authorizes the call as the same user
+func ForwardAuthToken(ctx context.Context) context.Context {
+	token, ok := ctx.Value(AuthTokenKey).(string)
+	if !ok || token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, strings.ToLower(AuthHeaderKey), BearerSchema+" "+token)
+}
+
 type wrappedServerStream struct {
 	grpc.ServerStream
 	ctx context.Context
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/peer"
+)
+
+// ClientIPKey holds the client address resolved by TrustedProxies.Middleware
+const ClientIPKey ContextKey = "client_ip"
+
+// TrustedProxies resolves the address of the client behind the proxies in front of a service. Forwarding
+// headers are only believed when they were added by a listed proxy: X-Forwarded-For is read from the right,
+// past every trusted hop, and the first untrusted address is the client. Anything further left was written
+// by the client and is ignored, so it cannot pick the address it is throttled or logged under.
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// NewTrustedProxies parses the proxies' addresses, given as CIDR ranges or single IPs. With none, only the
+// connection's remote address is used.
+func NewTrustedProxies(proxies []string) (*TrustedProxies, error) {
+	t := &TrustedProxies{}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			t.networks = append(t.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		t.networks = append(t.networks, network)
+	}
+	return t, nil
+}
+
+func (t *TrustedProxies) trusts(ip net.IP) bool {
+	if t == nil {
+		return false
+	}
+	for _, network := range t.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP resolves the request's client address
+func (t *TrustedProxies) ClientIP(r *http.Request) string {
+	peer := parseHostIP(r.RemoteAddr)
+	if peer == nil {
+		return r.RemoteAddr
+	}
+	if !t.trusts(peer) {
+		return peer.String()
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	if len(hops) == 0 {
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			return ip.String()
+		}
+		return peer.String()
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHostIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !t.trusts(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Middleware resolves the client address once and stores it for ClientIP. It must run before anything
+// that reads the address, such as the request logger and rate limiter.
+func (t *TrustedProxies) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ClientIPKey, t.ClientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GRPCClientIP returns the client address of a gRPC call. An address the caller reports for its own client,
+// such as a request's ip_address field, is only taken from a trusted proxy; anyone else gets the address
+// of their connection.
+func (t *TrustedProxies) GRPCClientIP(ctx context.Context, reported string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	peerIP := parseHostIP(p.Addr.String())
+	if peerIP == nil {
+		return p.Addr.String()
+	}
+	if t.trusts(peerIP) {
+		if ip := net.ParseIP(strings.TrimSpace(reported)); ip != nil {
+			return ip.String()
+		}
+	}
+	return peerIP.String()
+}
+
+// ClientIP returns the client address resolved by TrustedProxies.Middleware, or the connection's remote
+// address for requests that did not pass through it
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey).(string); ok && ip != "" {
+		return ip
+	}
+
+	if ip := parseHostIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// parseHostIP parses an address with or without a port
+func parseHostIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+// Token signing algorithms, named as in the JWT "alg" header
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmHS256 = "HS256"
+)
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is a private key that access tokens are signed with, identified in tokens by its kid
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Key       crypto.Signer
+}
+
+// TokenSigner provides the key new access tokens are signed with
+type TokenSigner interface {
+	SigningKey(ctx context.Context) (*SigningKey, error)
+}
+
+// KeyResolver looks up the public key for a token's kid
+type KeyResolver interface {
+	VerificationKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517). RSA keys use n and e, Ed25519 keys use crv and x.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the key set served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK encodes a public signing key
+func NewJWK(kid, algorithm string, key crypto.PublicKey) (JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: algorithm,
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: algorithm,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// PublicKey decodes the key
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// signingMethod maps an algorithm name to its JWT signing method
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+// RemoteJWKS resolves keys from a JWKS endpoint. The set is cached for ttl and refetched early when a token
+// names a kid it does not contain, which is how verifiers pick up a rotated key. Refetches are spaced at
+// least minRefresh apart so that tokens with made-up kids cannot flood the endpoint, and the last good set
+// is kept while the endpoint is unreachable. Lookups only take a read lock; the fetch runs outside the
+// lock, and concurrent misses share one fetch.
+type RemoteJWKS struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	refreshes  singleflight.Group
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+func NewRemoteJWKS(url string, ttl time.Duration) *RemoteJWKS {
+	return &RemoteJWKS{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		ttl:        ttl,
+		minRefresh: 30 * time.Second,
+	}
+}
+
+func (r *RemoteJWKS) VerificationKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok, stale := r.lookup(kid)
+	if ok && !stale {
+		return key, nil
+	}
+
+	// The fetch is shared, so one caller's cancellation must not fail the others waiting on it
+	_, err, _ := r.refreshes.Do("", func() (interface{}, error) {
+		return nil, r.refresh(context.WithoutCancel(ctx))
+	})
+
+	key, ok, _ = r.lookup(kid)
+	if !ok {
+		if err != nil {
+			return nil, fmt.Errorf("fetch JWKS: %w", err)
+		}
+		return nil, errUnknownKey
+	}
+	return key, nil
+}
+
+func (r *RemoteJWKS) lookup(kid string) (crypto.PublicKey, bool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[kid]
+	return key, ok, time.Since(r.fetchedAt) > r.ttl
+}
+
+// refresh refetches the set unless the last attempt was less than minRefresh ago
+func (r *RemoteJWKS) refresh(ctx context.Context) error {
+	r.mu.Lock()
+	if time.Since(r.triedAt) < r.minRefresh {
+		r.mu.Unlock()
+		return nil
+	}
+	r.triedAt = time.Now()
+	r.mu.Unlock()
+
+	keys, err := r.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.fetchedAt = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *RemoteJWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+)
+
+const LocaleKey ContextKey = "locale"
+
+// SupportedLocales lists the locales content can be translated into, the default first
+var SupportedLocales = []string{constants.LocaleEnglish, constants.LocaleIndonesian}
+
+// NormalizeLocale maps a language tag such as "id-ID" to a supported locale, or returns an empty string
+func NormalizeLocale(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+
+	for _, locale := range SupportedLocales {
+		if tag == locale {
+			return locale
+		}
+	}
+
+	return ""
+}
+
+// ParseAcceptLanguage picks the supported locale the client prefers most, honouring q-values,
+// and falls back to the default locale
+func ParseAcceptLanguage(header string) string {
+	type candidate struct {
+		locale  string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		if locale := NormalizeLocale(tag); locale != "" {
+			candidates = append(candidates, candidate{locale: locale, quality: quality})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	if len(candidates) > 0 {
+		return candidates[0].locale
+	}
+
+	return constants.DefaultLocale
+}
+
+// WithLocale stores a locale in the context, ignoring unsupported values
+func WithLocale(ctx context.Context, locale string) context.Context {
+	if locale = NormalizeLocale(locale); locale == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, LocaleKey, locale)
+}
+
+// LocaleFromContext returns the request locale, or the default locale when none was set
+func LocaleFromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(LocaleKey).(string); ok && locale != "" {
+		return locale
+	}
+
+	return constants.DefaultLocale
+}
+
+// LocaleMiddleware resolves the request locale from the Accept-Language header
+func LocaleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", locale)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), LocaleKey, locale)))
+	})
+}
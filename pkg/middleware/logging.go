@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"net"
 	"net/http"
 	"time"
 
@@ -34,7 +33,7 @@ func (l *RequestLogger) Middleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(crw, r)
 
-		clientIP := extractClientIP(r)
+		clientIP := ClientIP(r)
 
 		duration := time.Since(start)
 		l.log.Info("HTTP request",
@@ -60,27 +59,3 @@ func (crw *customResponseWriter) WriteHeader(code int) {
 	crw.statusCode = code
 	crw.ResponseWriter.WriteHeader(code)
 }
-
-func extractClientIP(r *http.Request) string {
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		ips := net.ParseIP(xForwardedFor)
-		if ips != nil {
-			return ips.String()
-		}
-	}
-
-	xRealIP := r.Header.Get("X-Real-IP")
-	if xRealIP != "" {
-		ip := net.ParseIP(xRealIP)
-		if ip != nil {
-			return ip.String()
-		}
-	}
-
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Permission names an action that roles can be granted
+type Permission string
+
+const (
+	// PermBookWrite allows creating, updating, deleting and merging books and reviewing duplicates
+	PermBookWrite Permission = "book:write"
+	// PermCategoryWrite allows creating, updating, moving, merging, deleting and importing categories
+	PermCategoryWrite Permission = "category:write"
+	// PermUserRead allows looking up and listing other users
+	PermUserRead Permission = "user:read"
+	// PermUserAdmin allows creating, updating and deleting other users and managing their tokens
+	PermUserAdmin Permission = "user:admin"
+	// PermOAuthClientAdmin allows registering and removing OAuth clients
+	PermOAuthClientAdmin Permission = "oauth_client:admin"
+)
+
+// Policy maps roles to the permissions they are granted
+type Policy struct {
+	grants map[string]map[Permission]bool
+}
+
+func NewPolicy(grants map[string][]Permission) *Policy {
+	p := &Policy{grants: make(map[string]map[Permission]bool, len(grants))}
+	for role, permissions := range grants {
+		p.grants[role] = make(map[Permission]bool, len(permissions))
+		for _, permission := range permissions {
+			p.grants[role][permission] = true
+		}
+	}
+	return p
+}
+
+// DefaultPolicy lets librarians manage the catalogue and look up patrons, and reserves user
+// administration for admins. Members and guests only get what needs no permission.
+func DefaultPolicy() *Policy {
+	return NewPolicy(map[string][]Permission{
+		constants.RoleAdmin:     {PermBookWrite, PermCategoryWrite, PermUserRead, PermUserAdmin, PermOAuthClientAdmin},
+		constants.RoleLibrarian: {PermBookWrite, PermCategoryWrite, PermUserRead},
+		constants.RoleMember:    {},
+		constants.RoleGuest:     {},
+	})
+}
+
+// Allows reports whether the role holds every one of the permissions
+func (p *Policy) Allows(role string, permissions ...Permission) bool {
+	granted := p.grants[role]
+	for _, permission := range permissions {
+		if !granted[permission] {
+			return false
+		}
+	}
+	return true
+}
+
+// Can reports whether the authenticated caller in ctx holds every one of the permissions
+func (p *Policy) Can(ctx context.Context, permissions ...Permission) bool {
+	role, ok := ctx.Value(UserRoleKey).(string)
+	return ok && p.Allows(role, permissions...)
+}
+
+// SubjectID returns the ID of the authenticated caller in ctx
+func SubjectID(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(UserIDKey).(string)
+	return userID, ok && userID != ""
+}
+
+// IsSubject reports whether the authenticated caller in ctx is the user with the given ID
+func IsSubject(ctx context.Context, userID string) bool {
+	subject, ok := SubjectID(ctx)
+	return ok && subject == userID
+}
+
+// CanAccess reports whether the caller owns the resource or, failing that, holds every one of the
+// permissions that grant access to resources owned by others
+func (p *Policy) CanAccess(ctx context.Context, ownerID string, permissions ...Permission) bool {
+	return IsSubject(ctx, ownerID) || p.Can(ctx, permissions...)
+}
+
+// Require returns HTTP middleware that rejects callers lacking any of the permissions. It must run
+// after JWTAuth.HTTPMiddleware.
+func (p *Policy) Require(permissions ...Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(UserRoleKey).(string); !ok {
+				utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+				return
+			}
+
+			if !p.Can(r.Context(), permissions...) {
+				utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MethodPermissions maps full gRPC method names to the permissions they require. Listed methods with no
+// permissions only require an authenticated caller.
+type MethodPermissions map[string][]Permission
+
+// MethodAccess says who may call each gRPC method. Public methods need no caller and the methods in
+// Permissions need an authenticated caller holding their permissions. Any other method is refused, so a
+// method added without an entry stays closed.
+type MethodAccess struct {
+	Public      []string
+	Permissions MethodPermissions
+}
+
+// UnaryInterceptor authenticates and authorizes calls according to access
+func (p *Policy) UnaryInterceptor(auth *JWTAuth, access MethodAccess) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		newCtx, err := p.authorizeIncoming(ctx, auth, access, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(newCtx, req)
+	}
+}
+
+// StreamInterceptor authenticates and authorizes streams according to access
+func (p *Policy) StreamInterceptor(auth *JWTAuth, access MethodAccess) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		newCtx, err := p.authorizeIncoming(ss.Context(), auth, access, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: newCtx})
+	}
+}
+
+func (p *Policy) authorizeIncoming(ctx context.Context, auth *JWTAuth, access MethodAccess, method string) (context.Context, error) {
+	if slices.Contains(access.Public, method) {
+		return ctx, nil
+	}
+
+	permissions, listed := access.Permissions[method]
+	if !listed {
+		return nil, status.Error(codes.PermissionDenied, constants.ErrForbidden)
+	}
+
+	newCtx, err := auth.authenticateIncoming(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.Can(newCtx, permissions...) {
+		return nil, status.Error(codes.PermissionDenied, constants.ErrForbidden)
+	}
+
+	return newCtx, nil
+}
@@ -103,7 +103,7 @@ func (rl *RateLimiter) cleanup() {
 
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		clientIP := extractClientIP(r)
+		clientIP := ClientIP(r)
 
 		if !rl.Allow(clientIP) {
 			rl.log.Warn("Rate limit exceeded",
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/cache"
+	"github.com/fairuzald/library-system/pkg/constants"
+)
+
+// TokenVersions layers the versions published in Redis over an authoritative source, such as the users
+// table or the user service. Either may be nil; with neither it returns nil and versions are not checked.
+func TokenVersions(redis *cache.Redis, source TokenVersionStore) TokenVersionStore {
+	if redis == nil {
+		return source
+	}
+	return &RedisTokenVersions{cache: redis, source: source}
+}
+
+// RedisTokenVersions keeps token versions in the Redis instance shared by all services. A version that is
+// missing, because it was evicted or flushed, or that cannot be read is taken from the source instead and
+// published again.
+type RedisTokenVersions struct {
+	cache  *cache.Redis
+	source TokenVersionStore
+}
+
+func NewRedisTokenVersions(cache *cache.Redis) *RedisTokenVersions {
+	return &RedisTokenVersions{cache: cache}
+}
+
+// TokenVersion returns the user's current version. Without a source, a version that is not published is
+// an error rather than zero, since a missing key cannot be told apart from a lost one.
+func (s *RedisTokenVersions) TokenVersion(ctx context.Context, userID string) (int64, error) {
+	var version int64
+	err := s.cache.Get(ctx, constants.CacheKeyTokenVersion+userID, &version)
+	if err == nil {
+		return version, nil
+	}
+
+	if s.source == nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return 0, errors.New("token version is not published")
+		}
+		return 0, err
+	}
+
+	version, err = s.source.TokenVersion(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only fills the gap: a bump published since the source was read must not be overwritten
+	_, _ = s.cache.SetNX(ctx, constants.CacheKeyTokenVersion+userID, version, 0)
+	return version, nil
+}
+
+// SetTokenVersion publishes the user's current version. It does not expire, since tokens of any age must
+// be checked against it.
+func (s *RedisTokenVersions) SetTokenVersion(ctx context.Context, userID string, version int64) error {
+	return s.cache.Set(ctx, constants.CacheKeyTokenVersion+userID, version, 0)
+}
+
+// RemoteTokenVersions asks the user service for a user's token version. The request is authenticated with
+// the token being checked, so the endpoint only ever reveals the caller's own version, and a token the user
+// service refuses is reported as revoked.
+type RemoteTokenVersions struct {
+	url    string
+	client *http.Client
+}
+
+func NewRemoteTokenVersions(url string) *RemoteTokenVersions {
+	return &RemoteTokenVersions{
+		url:    url,
+		client: &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (r *RemoteTokenVersions) TokenVersion(ctx context.Context, userID string) (int64, error) {
+	token, _ := ctx.Value(AuthTokenKey).(string)
+	if token == "" {
+		return 0, errors.New("no token to look up the version with")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set(AuthHeaderKey, BearerSchema+" "+token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return 0, errors.New(constants.ErrTokenRevoked)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			UserID       string `json:"user_id"`
+			TokenVersion int64  `json:"token_version"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+
+	if body.Data.UserID != userID {
+		return 0, errors.New("token version is for another user")
+	}
+
+	return body.Data.TokenVersion, nil
+}
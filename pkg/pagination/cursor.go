@@ -0,0 +1,141 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+)
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded or does not match the requested sort
+var ErrInvalidCursor = errors.New(constants.ErrInvalidCursor)
+
+// Cursor marks the position of a boundary row in an ordered result set
+type Cursor struct {
+	Sort     string
+	Values   []interface{}
+	ID       string
+	Backward bool
+}
+
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+type cursorPayload struct {
+	Sort     string        `json:"s"`
+	Values   []cursorValue `json:"v"`
+	ID       string        `json:"id"`
+	Backward bool          `json:"b,omitempty"`
+}
+
+// EncodeCursor serializes a cursor into an opaque URL-safe string
+func EncodeCursor(c *Cursor) (string, error) {
+	payload := cursorPayload{
+		Sort:     c.Sort,
+		Values:   make([]cursorValue, 0, len(c.Values)),
+		ID:       c.ID,
+		Backward: c.Backward,
+	}
+
+	for _, v := range c.Values {
+		encoded, err := encodeValue(v)
+		if err != nil {
+			return "", err
+		}
+		payload.Values = append(payload.Values, encoded)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if payload.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &Cursor{
+		Sort:     payload.Sort,
+		Values:   make([]interface{}, 0, len(payload.Values)),
+		ID:       payload.ID,
+		Backward: payload.Backward,
+	}
+
+	for _, v := range payload.Values {
+		decoded, err := decodeValue(v)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		c.Values = append(c.Values, decoded)
+	}
+
+	return c, nil
+}
+
+func encodeValue(v interface{}) (cursorValue, error) {
+	switch val := v.(type) {
+	case nil:
+		return cursorValue{Type: "null"}, nil
+	case time.Time:
+		return cursorValue{Type: "time", Value: val.UTC().Format(time.RFC3339Nano)}, nil
+	case string:
+		return cursorValue{Type: "string", Value: val}, nil
+	case bool:
+		return cursorValue{Type: "bool", Value: strconv.FormatBool(val)}, nil
+	case fmt.Stringer:
+		return cursorValue{Type: "string", Value: val.String()}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cursorValue{Type: "int", Value: strconv.FormatInt(rv.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorValue{Type: "int", Value: strconv.FormatUint(rv.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return cursorValue{Type: "float", Value: strconv.FormatFloat(rv.Float(), 'g', -1, 64)}, nil
+	}
+
+	return cursorValue{}, fmt.Errorf("unsupported cursor value type %T", v)
+}
+
+func decodeValue(v cursorValue) (interface{}, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, v.Value)
+	case "string":
+		return v.Value, nil
+	case "bool":
+		return strconv.ParseBool(v.Value)
+	case "int":
+		return strconv.ParseInt(v.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(v.Value, 64)
+	}
+
+	return nil, fmt.Errorf("unknown cursor value type %q", v.Type)
+}
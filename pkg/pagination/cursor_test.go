@@ -0,0 +1,79 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	published := time.Date(1965, 8, 1, 12, 30, 0, 500, time.FixedZone("WIB", 7*60*60))
+	id := uuid.MustParse("6f1c1d9e-9a4b-4c3e-8f0e-2b7d5a1c3e90")
+
+	in := &Cursor{
+		Sort:     "-published_at,title,rating,year,count,available,category_id,shelf",
+		Values:   []interface{}{published, "Dune", 4.25, int32(1965), uint(7), true, id, nil},
+		ID:       "42",
+		Backward: true,
+	}
+
+	encoded, err := EncodeCursor(in)
+	if err != nil {
+		t.Fatalf("EncodeCursor: %v", err)
+	}
+
+	out, err := DecodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+
+	// Integers widen to int64, stringers such as UUIDs come back as strings and times as UTC
+	want := &Cursor{
+		Sort:     in.Sort,
+		Values:   []interface{}{published.UTC(), "Dune", 4.25, int64(1965), int64(7), true, id.String(), nil},
+		ID:       "42",
+		Backward: true,
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("DecodeCursor(EncodeCursor(c)) = %#v, want %#v", out, want)
+	}
+}
+
+func TestEncodeCursorRejectsUnsupportedValues(t *testing.T) {
+	_, err := EncodeCursor(&Cursor{Sort: "tags", Values: []interface{}{[]string{"a"}}, ID: "1"})
+	if err == nil {
+		t.Error("EncodeCursor accepted a slice value")
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(payload string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(payload))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"s":"title","v":[],"id":"1"}`))},
+		{name: "not json", cursor: encode("title,1")},
+		{name: "missing id", cursor: encode(`{"s":"title","v":[{"t":"string","v":"Dune"}]}`)},
+		{name: "unknown value type", cursor: encode(`{"s":"title","v":[{"t":"blob","v":"AA"}],"id":"1"}`)},
+		{name: "malformed int", cursor: encode(`{"s":"year","v":[{"t":"int","v":"1965 OR 1=1"}],"id":"1"}`)},
+		{name: "malformed time", cursor: encode(`{"s":"published_at","v":[{"t":"time","v":"yesterday"}],"id":"1"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := DecodeCursor(tt.cursor)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) = %+v, %v; want ErrInvalidCursor", tt.cursor, c, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// IDColumn is the tiebreaker appended to every sort so keyset positions are unique
+const IDColumn = "id"
+
+var schemaCache = &sync.Map{}
+
+// SortKey is a single ORDER BY term. NullAs is the SQL literal a nullable column's NULLs are ordered and
+// compared as; it must be the value the model field scans NULL into, which is what a cursor taken on such
+// a row holds.
+type SortKey struct {
+	Column string
+	Desc   bool
+	NullAs string
+}
+
+// column is the key's column, wrapped in COALESCE for a nullable one. Comparing the bare column would drop
+// every NULL row from a keyset page, as NULL never compares greater or less than the cursor.
+func (k SortKey) column() clause.Column {
+	if k.NullAs == "" {
+		return clause.Column{Name: k.Column}
+	}
+	return clause.Column{Name: fmt.Sprintf("COALESCE(%s, %s)", k.Column, k.NullAs), Raw: true}
+}
+
+// Cursors holds the opaque cursors of the pages adjacent to the current one
+type Cursors struct {
+	Next string
+	Prev string
+}
+
+// Params describes a page request, either by cursor or by offset
+type Params struct {
+	Keys   []SortKey
+	Cursor *Cursor
+	Offset int
+	Limit  int
+}
+
+// NewParams builds page parameters. A non-empty cursor takes precedence over the offset.
+func NewParams(keys []SortKey, cursor string, offset, limit int) (*Params, error) {
+	p := &Params{
+		Keys:   keys,
+		Offset: offset,
+		Limit:  limit,
+	}
+
+	if cursor == "" {
+		return p, nil
+	}
+
+	c, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Sort != signature(keys) || len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	p.Cursor = c
+	p.Offset = 0
+
+	return p, nil
+}
+
+// Apply adds ordering, the keyset condition or offset, and a limit of one row past the page size
+func (p *Params) Apply(db *gorm.DB) *gorm.DB {
+	backward := p.Cursor != nil && p.Cursor.Backward
+
+	for _, key := range p.orderKeys() {
+		db = db.Order(clause.OrderByColumn{
+			Column: key.column(),
+			Desc:   key.Desc != backward,
+		})
+	}
+
+	if p.Cursor != nil {
+		db = db.Where(p.keysetCondition())
+	} else if p.Offset > 0 {
+		db = db.Offset(p.Offset)
+	}
+
+	return db.Limit(p.Limit + 1)
+}
+
+// Build trims the look-ahead row, restores the order of a backward page and computes the adjacent cursors
+func Build[T any](ctx context.Context, db *gorm.DB, p *Params, rows []T) ([]T, *Cursors, error) {
+	cursors := &Cursors{}
+
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+
+	backward := p.Cursor != nil && p.Cursor.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		return rows, cursors, nil
+	}
+
+	hasNext := more
+	hasPrev := p.Cursor != nil || p.Offset > 0
+	if backward {
+		hasNext = true
+		hasPrev = more
+	}
+
+	var err error
+	if hasNext {
+		cursors.Next, err = p.cursorFor(ctx, db, rows[len(rows)-1], false)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if hasPrev {
+		cursors.Prev, err = p.cursorFor(ctx, db, rows[0], true)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return rows, cursors, nil
+}
+
+func (p *Params) orderKeys() []SortKey {
+	keys := make([]SortKey, 0, len(p.Keys)+1)
+	keys = append(keys, p.Keys...)
+
+	desc := false
+	if len(p.Keys) > 0 {
+		desc = p.Keys[len(p.Keys)-1].Desc
+	}
+
+	return append(keys, SortKey{Column: IDColumn, Desc: desc})
+}
+
+// keysetCondition expands (k1, k2, id) > (v1, v2, vid) into OR-ed prefixes so mixed sort directions work
+func (p *Params) keysetCondition() clause.Expression {
+	keys := p.orderKeys()
+	values := make([]interface{}, 0, len(keys))
+	values = append(values, p.Cursor.Values...)
+	values = append(values, p.Cursor.ID)
+
+	ors := make([]clause.Expression, 0, len(keys))
+	for i, key := range keys {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: keys[j].column(), Value: values[j]})
+		}
+
+		column := key.column()
+		if key.Desc == p.Cursor.Backward {
+			ands = append(ands, clause.Gt{Column: column, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Lt{Column: column, Value: values[i]})
+		}
+
+		ors = append(ors, clause.And(ands...))
+	}
+
+	return clause.Or(ors...)
+}
+
+func (p *Params) cursorFor(ctx context.Context, db *gorm.DB, row interface{}, backward bool) (string, error) {
+	s, err := schema.Parse(row, schemaCache, db.NamingStrategy)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse schema for cursor: %w", err)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(row))
+
+	c := &Cursor{
+		Sort:     signature(p.Keys),
+		Values:   make([]interface{}, 0, len(p.Keys)),
+		Backward: backward,
+	}
+
+	for _, key := range p.Keys {
+		field := s.LookUpField(key.Column)
+		if field == nil {
+			return "", fmt.Errorf("unknown sort column %q", key.Column)
+		}
+		value, _ := field.ValueOf(ctx, rv)
+		c.Values = append(c.Values, value)
+	}
+
+	idField := s.LookUpField(IDColumn)
+	if idField == nil {
+		return "", fmt.Errorf("model %s has no %s column", s.Name, IDColumn)
+	}
+	id, _ := idField.ValueOf(ctx, rv)
+	c.ID = fmt.Sprint(id)
+
+	return EncodeCursor(c)
+}
+
+func signature(keys []SortKey) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.Desc {
+			parts = append(parts, "-"+key.Column)
+		} else {
+			parts = append(parts, key.Column)
+		}
+	}
+	return strings.Join(parts, ",")
+}
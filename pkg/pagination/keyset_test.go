@@ -0,0 +1,272 @@
+package pagination
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type testBook struct {
+	ID            string
+	Title         string
+	AverageRating float64
+}
+
+var ratingKeys = []SortKey{
+	{Column: "average_rating", Desc: true, NullAs: "0"},
+	{Column: "title"},
+}
+
+// dryRun returns a session that builds statements without a database connection
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run session: %v", err)
+	}
+	return db
+}
+
+func mustEncode(t *testing.T, c *Cursor) string {
+	t.Helper()
+
+	s, err := EncodeCursor(c)
+	if err != nil {
+		t.Fatalf("EncodeCursor: %v", err)
+	}
+	return s
+}
+
+func TestNewParamsRejectsCursorOfAnotherSort(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor *Cursor
+	}{
+		{
+			name:   "different column",
+			cursor: &Cursor{Sort: "-average_rating,author", Values: []interface{}{4.5, "Herbert"}, ID: "42"},
+		},
+		{
+			name:   "different direction",
+			cursor: &Cursor{Sort: "average_rating,title", Values: []interface{}{4.5, "Dune"}, ID: "42"},
+		},
+		{
+			name:   "different key order",
+			cursor: &Cursor{Sort: "title,-average_rating", Values: []interface{}{"Dune", 4.5}, ID: "42"},
+		},
+		{
+			name:   "value count does not match the keys",
+			cursor: &Cursor{Sort: "-average_rating,title", Values: []interface{}{4.5}, ID: "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParams(ratingKeys, mustEncode(t, tt.cursor), 0, 10)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("NewParams accepted a cursor for %q: %v", tt.cursor.Sort, err)
+			}
+		})
+	}
+}
+
+func TestNewParamsCursorOverridesOffset(t *testing.T) {
+	cursor := mustEncode(t, &Cursor{Sort: "-average_rating,title", Values: []interface{}{4.5, "Dune"}, ID: "42"})
+
+	p, err := NewParams(ratingKeys, cursor, 30, 10)
+	if err != nil {
+		t.Fatalf("NewParams: %v", err)
+	}
+	if p.Offset != 0 || p.Cursor == nil || p.Cursor.ID != "42" {
+		t.Errorf("NewParams = offset %d, cursor %+v; want the cursor and no offset", p.Offset, p.Cursor)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor *Cursor
+		offset int
+		sql    string
+		vars   []interface{}
+	}{
+		{
+			name: "first page",
+			sql:  `ORDER BY COALESCE(average_rating, 0) DESC,"title","id" LIMIT 11`,
+		},
+		{
+			name:   "offset page",
+			offset: 20,
+			sql:    `ORDER BY COALESCE(average_rating, 0) DESC,"title","id" LIMIT 11 OFFSET 20`,
+		},
+		{
+			name:   "next page expands the keyset into OR-ed prefixes",
+			cursor: &Cursor{Sort: "-average_rating,title", Values: []interface{}{4.5, "Dune"}, ID: "42"},
+			sql: `WHERE (COALESCE(average_rating, 0) < $1` +
+				` OR (COALESCE(average_rating, 0) = $2 AND "title" > $3)` +
+				` OR (COALESCE(average_rating, 0) = $4 AND "title" = $5 AND "id" > $6))` +
+				` ORDER BY COALESCE(average_rating, 0) DESC,"title","id" LIMIT 11`,
+			vars: []interface{}{4.5, 4.5, "Dune", 4.5, "Dune", "42"},
+		},
+		{
+			name:   "previous page flips every comparison and direction",
+			cursor: &Cursor{Sort: "-average_rating,title", Values: []interface{}{4.5, "Dune"}, ID: "42", Backward: true},
+			sql: `WHERE (COALESCE(average_rating, 0) > $1` +
+				` OR (COALESCE(average_rating, 0) = $2 AND "title" < $3)` +
+				` OR (COALESCE(average_rating, 0) = $4 AND "title" = $5 AND "id" < $6))` +
+				` ORDER BY COALESCE(average_rating, 0),"title" DESC,"id" DESC LIMIT 11`,
+			vars: []interface{}{4.5, 4.5, "Dune", 4.5, "Dune", "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := ""
+			if tt.cursor != nil {
+				cursor = mustEncode(t, tt.cursor)
+			}
+
+			p, err := NewParams(ratingKeys, cursor, tt.offset, 10)
+			if err != nil {
+				t.Fatalf("NewParams: %v", err)
+			}
+
+			var rows []testBook
+			stmt := p.Apply(dryRun(t).Table("books")).Find(&rows).Statement
+			sql := strings.TrimPrefix(stmt.SQL.String(), `SELECT * FROM "books" `)
+
+			if sql != tt.sql {
+				t.Errorf("Apply built\n  %s\nwant\n  %s", sql, tt.sql)
+			}
+			if len(stmt.Vars) != 0 || len(tt.vars) != 0 {
+				if !reflect.DeepEqual(stmt.Vars, tt.vars) {
+					t.Errorf("Apply bound %#v, want %#v", stmt.Vars, tt.vars)
+				}
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	books := func(ids ...string) []testBook {
+		rows := make([]testBook, 0, len(ids))
+		for _, id := range ids {
+			rows = append(rows, testBook{ID: id, Title: "title " + id, AverageRating: 4})
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name   string
+		cursor *Cursor
+		offset int
+		rows   []testBook
+		ids    []string
+		next   string
+		prev   string
+	}{
+		{
+			name: "first page with more rows",
+			rows: books("1", "2", "3"),
+			ids:  []string{"1", "2"},
+			next: "2",
+		},
+		{
+			name: "only page",
+			rows: books("1", "2"),
+			ids:  []string{"1", "2"},
+		},
+		{
+			name:   "offset page has a previous page",
+			offset: 2,
+			rows:   books("3", "4"),
+			ids:    []string{"3", "4"},
+			prev:   "3",
+		},
+		{
+			name:   "middle page reached going forward",
+			cursor: &Cursor{Sort: "-average_rating,title", Values: []interface{}{4.0, "title 2"}, ID: "2"},
+			rows:   books("3", "4", "5"),
+			ids:    []string{"3", "4"},
+			next:   "4",
+			prev:   "3",
+		},
+		{
+			name:   "page reached going back is restored to sort order",
+			cursor: &Cursor{Sort: "-average_rating,title", Values: []interface{}{4.0, "title 5"}, ID: "5", Backward: true},
+			rows:   books("4", "3", "2"),
+			ids:    []string{"3", "4"},
+			next:   "4",
+			prev:   "3",
+		},
+		{
+			name:   "first page reached going back",
+			cursor: &Cursor{Sort: "-average_rating,title", Values: []interface{}{4.0, "title 3"}, ID: "3", Backward: true},
+			rows:   books("2", "1"),
+			ids:    []string{"1", "2"},
+			next:   "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := ""
+			if tt.cursor != nil {
+				cursor = mustEncode(t, tt.cursor)
+			}
+
+			p, err := NewParams(ratingKeys, cursor, tt.offset, 2)
+			if err != nil {
+				t.Fatalf("NewParams: %v", err)
+			}
+
+			rows, cursors, err := Build(context.Background(), dryRun(t), p, tt.rows)
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+
+			ids := make([]string, 0, len(rows))
+			for _, row := range rows {
+				ids = append(ids, row.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("Build returned rows %v, want %v", ids, tt.ids)
+			}
+
+			checkCursor(t, "next", cursors.Next, tt.next, false)
+			checkCursor(t, "prev", cursors.Prev, tt.prev, true)
+		})
+	}
+}
+
+// checkCursor asserts a page cursor points at the row with the wanted ID in the wanted direction, and
+// that it is accepted back for the same sort
+func checkCursor(t *testing.T, name, cursor, wantID string, backward bool) {
+	t.Helper()
+
+	if wantID == "" {
+		if cursor != "" {
+			t.Errorf("%s cursor = %q, want none", name, cursor)
+		}
+		return
+	}
+
+	p, err := NewParams(ratingKeys, cursor, 0, 2)
+	if err != nil {
+		t.Fatalf("%s cursor rejected for its own sort: %v", name, err)
+	}
+
+	want := []interface{}{4.0, "title " + wantID}
+	if p.Cursor.ID != wantID || p.Cursor.Backward != backward || !reflect.DeepEqual(p.Cursor.Values, want) {
+		t.Errorf("%s cursor = %+v, want row %s with values %v and backward %v", name, p.Cursor, wantID, want, backward)
+	}
+}
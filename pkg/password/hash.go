@@ -0,0 +1,180 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashing algorithms
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+
+	// bcryptMaxPasswordBytes is where bcrypt stops reading its input
+	bcryptMaxPasswordBytes = 72
+)
+
+var argon2Encoding = base64.RawStdEncoding
+
+// HashConfig selects how new password hashes are made. Zero values take OWASP's recommended minimums:
+// argon2id with 19 MiB of memory, 2 iterations and 1 lane, or bcrypt at cost 12.
+type HashConfig struct {
+	Algorithm  string
+	BcryptCost int
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// Hasher hashes passwords with the configured algorithm and verifies hashes made by either algorithm, so
+// the algorithm and its parameters can change while old hashes keep working
+type Hasher struct {
+	cfg HashConfig
+}
+
+func NewHasher(cfg HashConfig) (*Hasher, error) {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = AlgorithmArgon2id
+	}
+	if cfg.BcryptCost == 0 {
+		cfg.BcryptCost = 12
+	}
+	if cfg.Argon2Memory == 0 {
+		cfg.Argon2Memory = 19 * 1024
+	}
+	if cfg.Argon2Iterations == 0 {
+		cfg.Argon2Iterations = 2
+	}
+	if cfg.Argon2Parallelism == 0 {
+		cfg.Argon2Parallelism = 1
+	}
+
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		if cfg.Argon2Memory < 8*uint32(cfg.Argon2Parallelism) {
+			return nil, errors.New("argon2id memory must be at least 8 KiB per lane")
+		}
+	case AlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hashing algorithm %q", cfg.Algorithm)
+	}
+
+	return &Hasher{cfg: cfg}, nil
+}
+
+// MaxPasswordBytes is the longest password the algorithm uses in full, or 0 when there is no limit
+func (h *Hasher) MaxPasswordBytes() int {
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		return bcryptMaxPasswordBytes
+	}
+	return 0
+}
+
+// Hash returns a new salted hash of the password. Argon2id hashes use the PHC string format, which records
+// the parameters alongside the salt and key.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		return string(hashedBytes), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.cfg.Argon2Iterations, h.cfg.Argon2Memory, h.cfg.Argon2Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.cfg.Argon2Memory, h.cfg.Argon2Iterations, h.cfg.Argon2Parallelism,
+		argon2Encoding.EncodeToString(salt), argon2Encoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether the password matches the hash and, when it does, whether the hash should be
+// replaced because it was made with another algorithm or older parameters
+func (h *Hasher) Verify(password, hash string) (match bool, rehash bool) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false, false
+		}
+
+		computed := argon2.IDKey([]byte(password), salt, params.Argon2Iterations, params.Argon2Memory, params.Argon2Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false
+		}
+
+		return true, h.cfg.Algorithm != AlgorithmArgon2id ||
+			params.Argon2Memory != h.cfg.Argon2Memory ||
+			params.Argon2Iterations != h.cfg.Argon2Iterations ||
+			params.Argon2Parallelism != h.cfg.Argon2Parallelism ||
+			len(key) != argon2KeyLength
+
+	case strings.HasPrefix(hash, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false, false
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		return true, err != nil || h.cfg.Algorithm != AlgorithmBcrypt || cost != h.cfg.BcryptCost
+
+	default:
+		return false, false
+	}
+}
+
+// parseArgon2id reads the parameters, salt and key from a PHC string such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func parseArgon2id(hash string) (HashConfig, []byte, []byte, error) {
+	var params HashConfig
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2Memory, &params.Argon2Iterations, &params.Argon2Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	if params.Argon2Iterations == 0 || params.Argon2Parallelism == 0 {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := argon2Encoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+
+	key, err := argon2Encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("malformed argon2id key")
+	}
+
+	params.Algorithm = AlgorithmArgon2id
+	return params, salt, key, nil
+}
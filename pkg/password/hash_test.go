@@ -0,0 +1,179 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2 keeps the tests quick; the parameters only have to be valid
+var fastArgon2 = HashConfig{Algorithm: AlgorithmArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1}
+
+func newTestHasher(t *testing.T, cfg HashConfig) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher(%+v): %v", cfg, err)
+	}
+	return h
+}
+
+func mustHash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	return hash
+}
+
+func TestNewHasherDefaults(t *testing.T) {
+	h := newTestHasher(t, HashConfig{})
+
+	want := HashConfig{Algorithm: AlgorithmArgon2id, BcryptCost: 12, Argon2Memory: 19 * 1024, Argon2Iterations: 2, Argon2Parallelism: 1}
+	if h.cfg != want {
+		t.Errorf("NewHasher defaults = %+v, want %+v", h.cfg, want)
+	}
+}
+
+func TestNewHasherRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  HashConfig
+	}{
+		{name: "unknown algorithm", cfg: HashConfig{Algorithm: "md5"}},
+		{name: "bcrypt cost too low", cfg: HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost - 1}},
+		{name: "bcrypt cost too high", cfg: HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost + 1}},
+		{name: "argon2 memory below 8 KiB per lane", cfg: HashConfig{Argon2Memory: 31, Argon2Parallelism: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHasher(tt.cfg); err == nil {
+				t.Errorf("NewHasher(%+v) succeeded", tt.cfg)
+			}
+		})
+	}
+}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	h := newTestHasher(t, fastArgon2)
+
+	hash := mustHash(t, h, "correct horse battery staple")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Hash = %q, want a PHC string recording the parameters", hash)
+	}
+
+	if match, rehash := h.Verify("correct horse battery staple", hash); !match || rehash {
+		t.Errorf("Verify(right password) = %v, %v; want true, false", match, rehash)
+	}
+	if match, _ := h.Verify("correct horse battery stapler", hash); match {
+		t.Error("Verify accepted the wrong password")
+	}
+
+	if again := mustHash(t, h, "correct horse battery staple"); again == hash {
+		t.Error("two hashes of the same password are identical; the salt is not random")
+	}
+}
+
+// TestArgon2idReferenceVector checks Verify against the argon2id vector of the reference implementation
+// (password "password", salt "somesalt", t=2, m=64 MiB, p=1)
+func TestArgon2idReferenceVector(t *testing.T) {
+	const hash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+	h := newTestHasher(t, HashConfig{Argon2Memory: 65536, Argon2Iterations: 2, Argon2Parallelism: 1})
+	if match, rehash := h.Verify("password", hash); !match || rehash {
+		t.Errorf("Verify(reference vector) = %v, %v; want true, false", match, rehash)
+	}
+	if match, _ := h.Verify("Password", hash); match {
+		t.Error("Verify accepted the wrong password for the reference vector")
+	}
+}
+
+func TestVerifyRequestsRehash(t *testing.T) {
+	argonHash := mustHash(t, newTestHasher(t, fastArgon2), "correct horse battery staple")
+	bcryptHash := mustHash(t, newTestHasher(t, HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}), "correct horse battery staple")
+
+	tests := []struct {
+		name   string
+		cfg    HashConfig
+		hash   string
+		rehash bool
+	}{
+		{name: "same argon2 parameters", cfg: fastArgon2, hash: argonHash},
+		{name: "more argon2 memory", cfg: HashConfig{Argon2Memory: 128, Argon2Iterations: 1, Argon2Parallelism: 1}, hash: argonHash, rehash: true},
+		{name: "more argon2 iterations", cfg: HashConfig{Argon2Memory: 64, Argon2Iterations: 2, Argon2Parallelism: 1}, hash: argonHash, rehash: true},
+		{name: "more argon2 lanes", cfg: HashConfig{Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 2}, hash: argonHash, rehash: true},
+		{name: "bcrypt hash migrates to argon2id", cfg: fastArgon2, hash: bcryptHash, rehash: true},
+		{name: "same bcrypt cost", cfg: HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, hash: bcryptHash},
+		{name: "higher bcrypt cost", cfg: HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}, hash: bcryptHash, rehash: true},
+		{name: "argon2id hash moves to bcrypt", cfg: HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, hash: argonHash, rehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash := newTestHasher(t, tt.cfg).Verify("correct horse battery staple", tt.hash)
+			if !match || rehash != tt.rehash {
+				t.Errorf("Verify = %v, %v; want true, %v", match, rehash, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestBcryptMigrationRoundTrip(t *testing.T) {
+	legacy := mustHash(t, newTestHasher(t, HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}), "s3cret-Passphrase")
+	h := newTestHasher(t, fastArgon2)
+
+	match, rehash := h.Verify("s3cret-Passphrase", legacy)
+	if !match || !rehash {
+		t.Fatalf("Verify(bcrypt hash) = %v, %v; want true, true", match, rehash)
+	}
+
+	upgraded := mustHash(t, h, "s3cret-Passphrase")
+	if match, rehash := h.Verify("s3cret-Passphrase", upgraded); !match || rehash {
+		t.Errorf("Verify(upgraded hash) = %v, %v; want true, false", match, rehash)
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, fastArgon2)
+	valid := mustHash(t, h, "password")
+	parts := strings.Split(valid, "$")
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "plaintext", hash: "password"},
+		{name: "argon2i", hash: strings.Replace(valid, "$argon2id$", "$argon2i$", 1)},
+		{name: "other version", hash: strings.Replace(valid, "$v=19$", "$v=16$", 1)},
+		{name: "missing key", hash: strings.Join(parts[:5], "$")},
+		{name: "empty key", hash: strings.Join(parts[:5], "$") + "$"},
+		{name: "zero iterations", hash: strings.Replace(valid, "t=1", "t=0", 1)},
+		{name: "zero lanes", hash: strings.Replace(valid, "p=1", "p=0", 1)},
+		{name: "garbled parameters", hash: strings.Replace(valid, "m=64", "m=x", 1)},
+		{name: "salt not base64", hash: strings.Replace(valid, parts[4], "!!!", 1)},
+		{name: "truncated bcrypt", hash: "$2a$04$abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if match, rehash := h.Verify("password", tt.hash); match || rehash {
+				t.Errorf("Verify(%q) = %v, %v; want false, false", tt.hash, match, rehash)
+			}
+		})
+	}
+}
+
+func TestMaxPasswordBytes(t *testing.T) {
+	if got := newTestHasher(t, fastArgon2).MaxPasswordBytes(); got != 0 {
+		t.Errorf("argon2id MaxPasswordBytes = %d, want 0", got)
+	}
+	if got := newTestHasher(t, HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}).MaxPasswordBytes(); got != 72 {
+		t.Errorf("bcrypt MaxPasswordBytes = %d, want 72", got)
+	}
+}
@@ -0,0 +1,170 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+)
+
+// leetReplacer undoes the character substitutions people make to dress up a common word
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+// minPersonalLength keeps short names from matching inside unrelated passwords
+const minPersonalLength = 4
+
+// PolicyConfig sets the rules new passwords must follow
+type PolicyConfig struct {
+	MinLength int
+	// MaxLength is in bytes, which bounds the work of hashing; zero means no limit
+	MaxLength int
+	// MinCharClasses is how many of lowercase letters, uppercase letters, digits and symbols must appear
+	MinCharClasses int
+	// RejectPersonal rejects passwords containing the user's username, email name or real name
+	RejectPersonal bool
+	// RejectCommon rejects passwords on the bundled list of common and breached passwords
+	RejectCommon bool
+}
+
+// Policy checks new passwords against the configured rules
+type Policy struct {
+	cfg PolicyConfig
+}
+
+func NewPolicy(cfg PolicyConfig) *Policy {
+	return &Policy{cfg: cfg}
+}
+
+// PolicyError lists the rules a password broke. Its message is ErrWeakPassword, so it compares like the
+// other service errors.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return constants.ErrWeakPassword
+}
+
+// Check returns a PolicyError when the password breaks a rule. The personal values, such as the user's
+// username, email address and names, are what it must not be built from.
+func (p *Policy) Check(password string, personal ...string) error {
+	var violations []string
+
+	if length := utf8.RuneCountInString(password); length < p.cfg.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.cfg.MinLength))
+	}
+	if p.cfg.MaxLength > 0 && len(password) > p.cfg.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.cfg.MaxLength))
+	}
+
+	if p.cfg.MinCharClasses > 0 && charClasses(password) < p.cfg.MinCharClasses {
+		violations = append(violations, fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.cfg.MinCharClasses))
+	}
+
+	if p.cfg.RejectPersonal && containsPersonal(password, personal) {
+		violations = append(violations, "must not contain your username, email address or name")
+	}
+
+	if p.cfg.RejectCommon && isCommon(password) {
+		violations = append(violations, "is too common; it appears in lists of leaked passwords")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// containsPersonal reports whether the password contains one of the personal values, or is contained in
+// one, ignoring case. Email addresses are matched by the part before the @.
+func containsPersonal(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if at := strings.LastIndex(value, "@"); at >= 0 {
+			value = value[:at]
+		}
+		if utf8.RuneCountInString(value) < minPersonalLength {
+			continue
+		}
+
+		if strings.Contains(lowered, value) || (utf8.RuneCountInString(lowered) >= minPersonalLength && strings.Contains(value, lowered)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCommon reports whether the password is on the common list, as typed, with trailing digits and symbols
+// removed, or with common substitutions undone, so "Dragon2024!" and "p@ssw0rd" are caught too
+func isCommon(password string) bool {
+	commonPasswordsOnce.Do(loadCommonPasswords)
+
+	lowered := strings.ToLower(password)
+	base := strings.TrimRightFunc(lowered, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+
+	for _, candidate := range []string{lowered, base, leetReplacer.Replace(lowered), leetReplacer.Replace(base)} {
+		if candidate == "" {
+			continue
+		}
+		if _, ok := commonPasswords[candidate]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func loadCommonPasswords() {
+	commonPasswords = make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commonPasswords[line] = struct{}{}
+	}
+}
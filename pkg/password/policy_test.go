@@ -0,0 +1,161 @@
+package password
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	strict := PolicyConfig{MinLength: 12, MaxLength: 72, MinCharClasses: 3, RejectPersonal: true, RejectCommon: true}
+	personal := []string{"bookworm", "jane.doe@example.com", "Jane", "Doe"}
+
+	tests := []struct {
+		name       string
+		cfg        PolicyConfig
+		password   string
+		violations []string
+	}{
+		{
+			name:     "strong password",
+			cfg:      strict,
+			password: "Velvet-Harbor-91",
+		},
+		{
+			name:       "too short",
+			cfg:        strict,
+			password:   "Vh-91x",
+			violations: []string{"must be at least 12 characters long"},
+		},
+		{
+			name:     "length counts characters, not bytes",
+			cfg:      PolicyConfig{MinLength: 4},
+			password: "ñøßé",
+		},
+		{
+			name:       "too long in bytes",
+			cfg:        strict,
+			password:   "Vh-91" + strings.Repeat("é", 34),
+			violations: []string{"must be at most 72 bytes long"},
+		},
+		{
+			name:       "too few character classes",
+			cfg:        strict,
+			password:   "velvetharbor91",
+			violations: []string{"must mix at least 3 of lowercase letters, uppercase letters, digits and symbols"},
+		},
+		{
+			name:     "symbols and non-ASCII letters count as classes",
+			cfg:      PolicyConfig{MinCharClasses: 3},
+			password: "Élan vital!",
+		},
+		{
+			name:       "contains the username, in another case",
+			cfg:        strict,
+			password:   "My-BookWorm-2024",
+			violations: []string{"must not contain your username, email address or name"},
+		},
+		{
+			name:       "contains the email name",
+			cfg:        strict,
+			password:   "Jane.Doe#1987xx",
+			violations: []string{"must not contain your username, email address or name"},
+		},
+		{
+			name:     "short personal values are ignored",
+			cfg:      strict,
+			password: "Doe-Velvet-Harbor-9",
+		},
+		{
+			name:       "is part of a personal value",
+			cfg:        PolicyConfig{RejectPersonal: true},
+			password:   "okwo",
+			violations: []string{"must not contain your username, email address or name"},
+		},
+		{
+			name:       "common password",
+			cfg:        PolicyConfig{RejectCommon: true},
+			password:   "iloveyou",
+			violations: []string{"is too common; it appears in lists of leaked passwords"},
+		},
+		{
+			name:       "common password with a year and symbol",
+			cfg:        PolicyConfig{RejectCommon: true},
+			password:   "Dragon2024!",
+			violations: []string{"is too common; it appears in lists of leaked passwords"},
+		},
+		{
+			name:       "common password with substitutions",
+			cfg:        PolicyConfig{RejectCommon: true},
+			password:   "P@ssw0rd",
+			violations: []string{"is too common; it appears in lists of leaked passwords"},
+		},
+		{
+			name:     "disabled rules are not applied",
+			cfg:      PolicyConfig{},
+			password: "bookworm",
+		},
+		{
+			name:     "every broken rule is listed",
+			cfg:      strict,
+			password: "qwerty",
+			violations: []string{
+				"must be at least 12 characters long",
+				"must mix at least 3 of lowercase letters, uppercase letters, digits and symbols",
+				"is too common; it appears in lists of leaked passwords",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewPolicy(tt.cfg).Check(tt.password, personal...)
+
+			if tt.violations == nil {
+				if err != nil {
+					t.Errorf("Check(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+
+			var weak *PolicyError
+			if !errors.As(err, &weak) {
+				t.Fatalf("Check(%q) = %v, want a PolicyError", tt.password, err)
+			}
+			if !reflect.DeepEqual(weak.Violations, tt.violations) {
+				t.Errorf("Check(%q) violations = %q, want %q", tt.password, weak.Violations, tt.violations)
+			}
+			if err.Error() != constants.ErrWeakPassword {
+				t.Errorf("PolicyError message = %q, want %q", err.Error(), constants.ErrWeakPassword)
+			}
+		})
+	}
+}
+
+func TestIsCommon(t *testing.T) {
+	tests := []struct {
+		password string
+		common   bool
+	}{
+		{password: "password", common: true},
+		{password: "PASSWORD", common: true},
+		{password: "password123", common: true},
+		{password: "Monkey!!", common: true},
+		{password: "l3tm31n", common: true},
+		{password: "qwerty", common: true},
+		{password: "7391", common: false},
+		{password: "", common: false},
+		{password: "!!!", common: false},
+		{password: "Velvet-Harbor-91", common: false},
+		{password: "passwordless-login", common: false},
+	}
+
+	for _, tt := range tests {
+		if got := isCommon(tt.password); got != tt.common {
+			t.Errorf("isCommon(%q) = %v, want %v", tt.password, got, tt.common)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package utils
 
 import (
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"fmt"
 	"math/rand"
 	"strings"
 	"time"
 
 	"strconv"
+	"unicode"
 
 	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
 )
 
 func init() {
@@ -24,16 +29,69 @@ func GenerateRandomString(length int) string {
 	return string(b)
 }
 
+// GenerateSecureToken returns an unguessable URL-safe token built from n bytes of crypto/rand output
+func GenerateSecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 // GenerateUUID generates a new UUID
 func GenerateUUID() string {
 	return uuid.New().String()
 }
 
+// ParseUUIDs parses and de-duplicates a list of IDs, returning the values that are not valid UUIDs separately
+func ParseUUIDs(ids []string) ([]uuid.UUID, []string) {
+	parsed := make([]uuid.UUID, 0, len(ids))
+	var invalid []string
+	seen := make(map[uuid.UUID]bool, len(ids))
+
+	for _, id := range ids {
+		u, err := uuid.Parse(strings.TrimSpace(id))
+		if err != nil {
+			invalid = append(invalid, id)
+			continue
+		}
+		if !seen[u] {
+			seen[u] = true
+			parsed = append(parsed, u)
+		}
+	}
+
+	return parsed, invalid
+}
+
 // NormalizeString normalizes a string for searching
 func NormalizeString(s string) string {
 	return strings.ToLower(strings.TrimSpace(s))
 }
 
+// Slugify lowercases s, strips accents and joins its ASCII letters and digits with single hyphens for use in URLs
+func Slugify(s string) string {
+	var b strings.Builder
+	pendingHyphen := false
+
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			pendingHyphen = false
+			continue
+		}
+		pendingHyphen = true
+	}
+
+	return b.String()
+}
+
 // SliceContains checks if a slice contains an element
 func SliceContains(slice []string, str string) bool {
 	for _, item := range slice {
@@ -77,6 +135,14 @@ func ParseBool(s string) (bool, error) {
 	return strconv.ParseBool(s)
 }
 
+// ParseTime parses an RFC3339 timestamp or a plain 2006-01-02 date
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
 // StringPtr returns a pointer to the string value
 func StringPtr(s string) *string {
 	return &s
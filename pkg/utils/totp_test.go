@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors, the ASCII string "12345678901234567890",
+// base32-encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors are the SHA-1 rows of RFC 6238 appendix B. The RFC lists 8-digit codes; a 6-digit code
+// is the same value modulo 10^6, so its last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	step int64
+	code string
+}{
+	{unix: 59, step: 0x1, code: "94287082"},
+	{unix: 1111111109, step: 0x23523EC, code: "07081804"},
+	{unix: 1111111111, step: 0x23523ED, code: "14050471"},
+	{unix: 1234567890, step: 0x273EF07, code: "89005924"},
+	{unix: 2000000000, step: 0x3F940AA, code: "69279037"},
+	{unix: 20000000000, step: 0x27BC86AA, code: "65353130"},
+}
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		at := time.Unix(v.unix, 0)
+
+		if step := TOTPStep(at); step != v.step {
+			t.Errorf("TOTPStep(%d) = %#x, want %#x", v.unix, step, v.step)
+		}
+
+		code, err := TOTPCode(rfc6238Secret, v.step)
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if want := v.code[len(v.code)-TOTPDigits:]; code != want {
+			t.Errorf("TOTPCode at %d = %s, want %s", v.unix, code, want)
+		}
+	}
+}
+
+func TestValidateTOTPRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		code := v.code[len(v.code)-TOTPDigits:]
+
+		step, ok := ValidateTOTP(rfc6238Secret, code, time.Unix(v.unix, 0), 0)
+		if !ok || step != v.step {
+			t.Errorf("ValidateTOTP(%s) at %d = %#x, %v; want %#x, true", code, v.unix, step, ok, v.step)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	// 1111111111 is the second of step 0x23523ED; its neighbours are the vectors around it
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	codeAt := func(step int64) string {
+		code, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name   string
+		offset int64
+		skew   int
+		ok     bool
+	}{
+		{name: "current step without skew", offset: 0, skew: 0, ok: true},
+		{name: "previous step without skew", offset: -1, skew: 0},
+		{name: "previous step within skew", offset: -1, skew: 1, ok: true},
+		{name: "next step within skew", offset: 1, skew: 1, ok: true},
+		{name: "two steps behind with skew 1", offset: -2, skew: 1},
+		{name: "two steps ahead with skew 1", offset: 2, skew: 1},
+		{name: "two steps behind with skew 2", offset: -2, skew: 2, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, codeAt(current+tt.offset), now, tt.skew)
+			if ok != tt.ok {
+				t.Fatalf("ValidateTOTP = %v, want %v", ok, tt.ok)
+			}
+			if ok && step != current+tt.offset {
+				t.Errorf("ValidateTOTP matched step %#x, want the code's own step %#x", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+// TestValidateTOTPReportsStepForReplay checks that a code keeps reporting the step it was generated for as
+// the clock moves through the skew window. Callers reject steps at or before the last accepted one, so
+// the same code entered again a step later is still recognised as a replay.
+func TestValidateTOTPReportsStepForReplay(t *testing.T) {
+	issued := time.Unix(1234567890, 0)
+	code := "005924"
+
+	first, ok := ValidateTOTP(rfc6238Secret, code, issued, 1)
+	if !ok {
+		t.Fatal("ValidateTOTP rejected the current code")
+	}
+
+	again, ok := ValidateTOTP(rfc6238Secret, code, issued.Add(TOTPPeriod), 1)
+	if !ok || again != first {
+		t.Errorf("ValidateTOTP one step later = %#x, %v; want the same step %#x", again, ok, first)
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, code, issued.Add(2*TOTPPeriod), 1); ok {
+		t.Error("ValidateTOTP accepted a code two steps after it expired")
+	}
+}
+
+func TestValidateTOTPRejects(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{name: "wrong code", secret: rfc6238Secret, code: "287083"},
+		{name: "eight digits", secret: rfc6238Secret, code: "94287082"},
+		{name: "too short", secret: rfc6238Secret, code: "28708"},
+		{name: "empty", secret: rfc6238Secret, code: ""},
+		{name: "other secret", secret: "JBSWY3DPEHPK3PXP", code: "287082"},
+		{name: "invalid secret", secret: "not base32!", code: "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if step, ok := ValidateTOTP(tt.secret, tt.code, now, 1); ok {
+				t.Errorf("ValidateTOTP(%q, %q) accepted step %#x", tt.secret, tt.code, step)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPAcceptsTypingVariations(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	if _, ok := ValidateTOTP(rfc6238Secret, " 287082\n", now, 0); !ok {
+		t.Error("ValidateTOTP rejected a code with surrounding whitespace")
+	}
+	if _, ok := ValidateTOTP(strings.ToLower(rfc6238Secret), "287082", now, 0); !ok {
+		t.Error("ValidateTOTP rejected a lowercase secret")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretSize {
+		t.Errorf("GenerateTOTPSecret = %q, want %d base32-encoded bytes", secret, totpSecretSize)
+	}
+
+	if other, _ := GenerateTOTPSecret(); other == secret {
+		t.Error("GenerateTOTPSecret returned the same secret twice")
+	}
+}
@@ -81,6 +81,9 @@ func main() {
 		db,
 		redisClient,
 		cfg.JWTSecret,
+		cfg.TokenKeysURL(),
+		cfg.TokenVersionURL,
+		cfg.TokenVersionFailOpen,
 		cfg.CategoryServiceURL,
 		log,
 	)
@@ -91,9 +94,14 @@ func main() {
 
 	router := mux.NewRouter()
 
+	trustedProxies, err := middleware.NewTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
 	requestLogger := middleware.NewRequestLogger(log)
 	recoveryMiddleware := middleware.NewRecoveryMiddleware(log)
-	router.Use(recoveryMiddleware.Middleware, requestLogger.Middleware)
+	router.Use(trustedProxies.Middleware, recoveryMiddleware.Middleware, requestLogger.Middleware)
 
 	router.HandleFunc("/health", bookModule.HealthHandler.HandleHealth).Methods("GET")
 
@@ -101,7 +109,9 @@ func main() {
 	routes.SetupRoutes(
 		router,
 		bookModule.BookHandler,
+		bookModule.DuplicateHandler,
 		bookModule.JWTAuth,
+		bookModule.Policy,
 		log,
 	)
 
@@ -117,10 +127,12 @@ func main() {
 		grpc.ChainUnaryInterceptor(
 			middleware.UnaryRecoveryInterceptor(log),
 			middleware.UnaryLoggingInterceptor(log),
+			bookModule.Policy.UnaryInterceptor(bookModule.JWTAuth, routes.GRPCAccess()),
 		),
 		grpc.ChainStreamInterceptor(
 			middleware.StreamRecoveryInterceptor(log),
 			middleware.StreamLoggingInterceptor(log),
+			bookModule.Policy.StreamInterceptor(bookModule.JWTAuth, routes.GRPCAccess()),
 		),
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			MaxConnectionIdle:     15 * time.Minute,
@@ -140,6 +152,10 @@ func main() {
 
 	reflection.Register(grpcServer)
 
+	scannerCtx, stopScanner := context.WithCancel(context.Background())
+	defer stopScanner()
+	go bookModule.DuplicateService.RunScanner(scannerCtx, cfg.DuplicateScanInterval)
+
 	go func() {
 		log.Info("Starting HTTP server", zap.String("port", cfg.ServerPort))
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -166,6 +182,8 @@ func main() {
 
 	log.Info("Shutting down servers...")
 
+	stopScanner()
+
 	healthServer.SetServingStatus("book-service", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -23,6 +23,8 @@ type BookResponse struct {
 	AverageRating     float64   `json:"average_rating"`
 	Quantity          int       `json:"quantity"`
 	AvailableQuantity int       `json:"available_quantity"`
+	ClassNumber       string    `json:"class_number,omitempty"`
+	CallNumber        string    `json:"call_number,omitempty"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 }
@@ -44,15 +46,36 @@ func NewBookResponse(book *model.Book) *BookResponse {
 		AverageRating:     book.AverageRating,
 		Quantity:          book.Quantity,
 		AvailableQuantity: book.AvailableQuantity,
+		ClassNumber:       book.ClassNumber,
+		CallNumber:        book.CallNumber,
 		CreatedAt:         book.CreatedAt,
 		UpdatedAt:         book.UpdatedAt,
 	}
 }
 
+// SpineLabelResponse is a book's call number broken into the lines printed on its spine
+type SpineLabelResponse struct {
+	BookID     uuid.UUID `json:"book_id"`
+	CallNumber string    `json:"call_number"`
+	Lines      []string  `json:"lines"`
+}
+
+type CategoryBookCountResponse struct {
+	Direct int64 `json:"direct"`
+	Total  int64 `json:"total"`
+}
+
+type BookBatchResponse struct {
+	Books      []BookResponse `json:"books"`
+	MissingIDs []string       `json:"missing_ids"`
+}
+
 type BookListResponse struct {
 	Books       []BookResponse `json:"books"`
 	TotalItems  int64          `json:"total_items"`
 	TotalPages  int            `json:"total_pages"`
 	CurrentPage int            `json:"current_page"`
 	PageSize    int            `json:"page_size"`
+	NextCursor  string         `json:"next_cursor,omitempty"`
+	PrevCursor  string         `json:"prev_cursor,omitempty"`
 }
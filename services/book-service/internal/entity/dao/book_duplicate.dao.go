@@ -0,0 +1,51 @@
+package dao
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/model"
+	"github.com/google/uuid"
+)
+
+type BookDuplicateResponse struct {
+	ID          uuid.UUID `json:"id"`
+	BookID      uuid.UUID `json:"book_id"`
+	DuplicateID uuid.UUID `json:"duplicate_id"`
+	Score       float64   `json:"score"`
+	Reasons     []string  `json:"reasons"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewBookDuplicateResponse(candidate *model.BookDuplicate) *BookDuplicateResponse {
+	reasons := []string{}
+	if candidate.Reasons != "" {
+		reasons = strings.Split(candidate.Reasons, ",")
+	}
+
+	return &BookDuplicateResponse{
+		ID:          candidate.ID,
+		BookID:      candidate.BookID,
+		DuplicateID: candidate.DuplicateID,
+		Score:       candidate.Score,
+		Reasons:     reasons,
+		Status:      candidate.Status,
+		CreatedAt:   candidate.CreatedAt,
+		UpdatedAt:   candidate.UpdatedAt,
+	}
+}
+
+type BookDuplicateListResponse struct {
+	Duplicates  []BookDuplicateResponse `json:"duplicates"`
+	TotalItems  int64                   `json:"total_items"`
+	TotalPages  int                     `json:"total_pages"`
+	CurrentPage int                     `json:"current_page"`
+	PageSize    int                     `json:"page_size"`
+}
+
+type DuplicateScanResponse struct {
+	BooksScanned    int `json:"books_scanned"`
+	CandidatesFound int `json:"candidates_found"`
+}
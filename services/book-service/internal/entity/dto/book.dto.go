@@ -1,7 +1,16 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/utils"
+)
+
+// Category match modes for BookFilter.CategoryMatch
+const (
+	CategoryMatchAny = "any"
+	CategoryMatchAll = "all"
 )
 
 type BookCreate struct {
@@ -16,6 +25,7 @@ type BookCreate struct {
 	PageCount     int      `json:"page_count" validate:"required,gt=0"`
 	CoverImage    string   `json:"cover_image,omitempty"`
 	Quantity      int      `json:"quantity,omitempty" validate:"omitempty,gt=0"`
+	ClassNumber   string   `json:"class_number,omitempty" validate:"omitempty,max=50"`
 }
 
 type BookUpdate struct {
@@ -32,24 +42,37 @@ type BookUpdate struct {
 	CoverImage        *string  `json:"cover_image,omitempty"`
 	Quantity          *int     `json:"quantity,omitempty" validate:"omitempty,gt=0"`
 	AvailableQuantity *int     `json:"available_quantity,omitempty" validate:"omitempty,gte=0"`
+	ClassNumber       *string  `json:"class_number,omitempty" validate:"omitempty,max=50"`
 }
 
 type BookFilter struct {
-	Page       int    `form:"page,default=1" query:"page,default=1"`
-	Limit      int    `form:"limit,default=10" query:"limit,default=10"`
-	SortBy     string `form:"sort_by,default=created_at" query:"sort_by,default=created_at"`
-	Desc       bool   `form:"desc" query:"desc"`
-	Status     string `form:"status" query:"status"`
-	CategoryID string `form:"category_id" query:"category_id"`
-	Author     string `form:"author" query:"author"`
-	Language   string `form:"language" query:"language"`
+	Page          int        `form:"page,default=1" query:"page,default=1"`
+	Limit         int        `form:"limit,default=10" query:"limit,default=10"`
+	SortBy        string     `form:"sort_by,default=created_at" query:"sort_by,default=created_at"`
+	Desc          bool       `form:"desc" query:"desc"`
+	Status        string     `form:"status" query:"status"`
+	CategoryID    string     `form:"category_id" query:"category_id"`
+	CategoryIDs   []string   `form:"category_ids" query:"category_ids"`
+	CategoryMatch string     `form:"category_match,default=any" query:"category_match,default=any"`
+	Author        string     `form:"author" query:"author"`
+	Language      string     `form:"language" query:"language"`
+	MinYear       int        `form:"min_year" query:"min_year"`
+	MaxYear       int        `form:"max_year" query:"max_year"`
+	MinPages      int        `form:"min_pages" query:"min_pages"`
+	MaxPages      int        `form:"max_pages" query:"max_pages"`
+	AvailableOnly bool       `form:"available_only" query:"available_only"`
+	CreatedAfter  *time.Time `form:"created_after" query:"created_after"`
+	UpdatedAfter  *time.Time `form:"updated_after" query:"updated_after"`
+	Filter        string     `form:"filter" query:"filter"`
+	Cursor        string     `form:"cursor" query:"cursor"`
 }
 
 type BookSearch struct {
-	Query string `form:"query" validate:"required"`
-	Field string `form:"field"`
-	Page  int    `form:"page,default=1" query:"page,default=1"`
-	Limit int    `form:"limit,default=10" query:"limit,default=10"`
+	Query  string `form:"query" validate:"required"`
+	Field  string `form:"field"`
+	Page   int    `form:"page,default=1" query:"page,default=1"`
+	Limit  int    `form:"limit,default=10" query:"limit,default=10"`
+	Cursor string `form:"cursor" query:"cursor"`
 }
 
 func (f *BookFilter) Validate() {
@@ -67,8 +90,60 @@ func (f *BookFilter) Validate() {
 		f.Status != constants.BookStatusReserved && f.Status != constants.BookStatusMaintenance {
 		f.Status = ""
 	}
+
+	if f.MinYear < 0 {
+		f.MinYear = 0
+	}
+
+	if f.MaxYear < 0 {
+		f.MaxYear = 0
+	}
+
+	if f.MinPages < 0 {
+		f.MinPages = 0
+	}
+
+	if f.MaxPages < 0 {
+		f.MaxPages = 0
+	}
+
+	if f.CategoryID != "" {
+		f.CategoryIDs = append(f.CategoryIDs, f.CategoryID)
+		f.CategoryID = ""
+	}
+	f.CategoryIDs = utils.Unique(f.CategoryIDs)
+
+	if f.CategoryMatch != CategoryMatchAll {
+		f.CategoryMatch = CategoryMatchAny
+	}
 }
 
 func (f *BookFilter) GetOffset() int {
 	return (f.Page - 1) * f.Limit
 }
+
+type BookMerge struct {
+	DuplicateID string `json:"duplicate_id" validate:"required,uuid"`
+}
+
+type BookDuplicateFilter struct {
+	Status string `form:"status,default=pending" query:"status,default=pending"`
+	Page   int    `form:"page,default=1" query:"page,default=1"`
+	Limit  int    `form:"limit,default=10" query:"limit,default=10"`
+}
+
+func (f *BookDuplicateFilter) Validate() {
+	if f.Page <= 0 {
+		f.Page = 1
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = constants.DefaultPageSize
+	} else if f.Limit > constants.MaxPageSize {
+		f.Limit = constants.MaxPageSize
+	}
+
+	if f.Status != constants.DuplicateStatusMerged && f.Status != constants.DuplicateStatusDismissed {
+		f.Status = constants.DuplicateStatusPending
+	}
+}
@@ -9,20 +9,24 @@ import (
 
 type Book struct {
 	models.Base
-	Title             string   `gorm:"type:varchar(255);not null" json:"title"`
-	Author            string   `gorm:"type:varchar(255);not null" json:"author"`
-	ISBN              string   `gorm:"type:varchar(20);uniqueIndex;not null" json:"isbn"`
-	PublishedYear     int      `gorm:"not null" json:"published_year"`
-	Publisher         string   `gorm:"type:varchar(255);not null" json:"publisher"`
-	Description       string   `gorm:"type:text" json:"description"`
-	Language          string   `gorm:"type:varchar(50);not null" json:"language"`
-	PageCount         int      `gorm:"not null" json:"page_count"`
-	Status            string   `gorm:"type:varchar(20);not null;default:'available'" json:"status"`
-	CoverImage        string   `gorm:"type:text" json:"cover_image,omitempty"`
-	AverageRating     float64  `gorm:"default:0" json:"average_rating"`
-	Quantity          int      `gorm:"not null;default:1" json:"quantity"`
-	AvailableQuantity int      `gorm:"not null;default:1" json:"available_quantity"`
-	CategoryIDs       []string `gorm:"-" json:"category_ids,omitempty"`
+	Title             string     `gorm:"type:varchar(255);not null" json:"title"`
+	Author            string     `gorm:"type:varchar(255);not null" json:"author"`
+	ISBN              string     `gorm:"type:varchar(20);uniqueIndex;not null" json:"isbn"`
+	PublishedYear     int        `gorm:"not null" json:"published_year"`
+	Publisher         string     `gorm:"type:varchar(255);not null" json:"publisher"`
+	Description       string     `gorm:"type:text" json:"description"`
+	Language          string     `gorm:"type:varchar(50);not null" json:"language"`
+	PageCount         int        `gorm:"not null" json:"page_count"`
+	Status            string     `gorm:"type:varchar(20);not null;default:'available'" json:"status"`
+	CoverImage        string     `gorm:"type:text" json:"cover_image,omitempty"`
+	AverageRating     float64    `gorm:"default:0" json:"average_rating"`
+	Quantity          int        `gorm:"not null;default:1" json:"quantity"`
+	AvailableQuantity int        `gorm:"not null;default:1" json:"available_quantity"`
+	MergedIntoID      *uuid.UUID `gorm:"type:uuid" json:"merged_into_id,omitempty"`
+	ClassNumber       string     `gorm:"type:varchar(50)" json:"class_number,omitempty"`
+	CallNumber        string     `gorm:"type:varchar(100)" json:"call_number,omitempty"`
+	ShelfKey          string     `gorm:"type:varchar(150);index" json:"-"`
+	CategoryIDs       []string   `gorm:"-" json:"category_ids,omitempty"`
 }
 
 func (Book) TableName() string {
@@ -39,6 +43,12 @@ func (BookCategory) TableName() string {
 	return "books_categories"
 }
 
+// CategoryBookCount is the number of live books filed directly under a category and under its whole subtree
+type CategoryBookCount struct {
+	Direct int64
+	Total  int64
+}
+
 func NewBook(title, author, isbn string, publishedYear int, publisher, description, language string, pageCount int, categoryIDs []string) *Book {
 	return &Book{
 		Base: models.Base{
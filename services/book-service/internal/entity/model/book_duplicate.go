@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BookDuplicate is a candidate pair of books that likely describe the same title.
+// BookID always sorts before DuplicateID so each pair is stored once.
+type BookDuplicate struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BookID      uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
+	DuplicateID uuid.UUID `gorm:"type:uuid;not null" json:"duplicate_id"`
+	Score       float64   `gorm:"not null" json:"score"`
+	Reasons     string    `gorm:"type:varchar(255)" json:"reasons"`
+	Status      string    `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (BookDuplicate) TableName() string {
+	return "book_duplicates"
+}
@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
-	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/services/book-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/book-service/internal/service"
@@ -28,20 +29,7 @@ func NewBookHandler(bookService service.BookService, log *logger.Logger) *BookHa
 	}
 }
 
-func (h *BookHandler) isAdminOrLibrarian(r *http.Request) bool {
-	role, ok := r.Context().Value(middleware.UserRoleKey).(string)
-	if !ok {
-		return false
-	}
-	return role == constants.RoleAdmin || role == constants.RoleLibrarian
-}
-
 func (h *BookHandler) HandleCreateBook(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdminOrLibrarian(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
-		return
-	}
-
 	var req dto.BookCreate
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.log.Error("Failed to decode request body", zap.Error(err))
@@ -61,6 +49,8 @@ func (h *BookHandler) HandleCreateBook(w http.ResponseWriter, r *http.Request) {
 
 		if err.Error() == constants.ErrInternalServer {
 			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		} else if err.Error() == constants.ErrCategoryServiceUnavailable {
+			utils.RespondWithError(w, http.StatusServiceUnavailable, constants.ErrCategoryServiceUnavailable, nil)
 		} else {
 			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
 		}
@@ -95,6 +85,33 @@ func (h *BookHandler) HandleGetBook(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithSuccess(w, http.StatusOK, "Book retrieved successfully", book)
 }
 
+func (h *BookHandler) HandleGetSpineLabel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid book ID", err)
+		return
+	}
+
+	label, err := h.bookService.GetSpineLabel(r.Context(), id)
+	if err != nil {
+		switch err.Error() {
+		case constants.ErrBookNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrBookNotFound, nil)
+		case constants.ErrBookNotClassified:
+			utils.RespondWithError(w, http.StatusUnprocessableEntity, constants.ErrBookNotClassified, nil)
+		default:
+			h.log.Error("Failed to get spine label", zap.Error(err), zap.String("id", id.String()))
+			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Spine label retrieved successfully", label)
+}
+
 func (h *BookHandler) HandleGetBookByISBN(w http.ResponseWriter, r *http.Request) {
 	isbn := r.URL.Query().Get("isbn")
 	if isbn == "" {
@@ -118,11 +135,6 @@ func (h *BookHandler) HandleGetBookByISBN(w http.ResponseWriter, r *http.Request
 }
 
 func (h *BookHandler) HandleUpdateBook(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdminOrLibrarian(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
-		return
-	}
-
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
@@ -156,6 +168,8 @@ func (h *BookHandler) HandleUpdateBook(w http.ResponseWriter, r *http.Request) {
 
 		if err.Error() == constants.ErrInternalServer {
 			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		} else if err.Error() == constants.ErrCategoryServiceUnavailable {
+			utils.RespondWithError(w, http.StatusServiceUnavailable, constants.ErrCategoryServiceUnavailable, nil)
 		} else {
 			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
 		}
@@ -166,11 +180,6 @@ func (h *BookHandler) HandleUpdateBook(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *BookHandler) HandleDeleteBook(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdminOrLibrarian(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
-		return
-	}
-
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
@@ -201,6 +210,12 @@ func (h *BookHandler) HandleListBooks(w http.ResponseWriter, r *http.Request) {
 		Author:     r.URL.Query().Get("author"),
 		Language:   r.URL.Query().Get("language"),
 		SortBy:     r.URL.Query().Get("sort_by"),
+		Filter:     r.URL.Query().Get("filter"),
+		Cursor:     r.URL.Query().Get("cursor"),
+	}
+
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		filter.SortBy = sort
 	}
 
 	if page := r.URL.Query().Get("page"); page != "" {
@@ -219,8 +234,67 @@ func (h *BookHandler) HandleListBooks(w http.ResponseWriter, r *http.Request) {
 		filter.Desc = true
 	}
 
+	for param, dst := range map[string]*int{
+		"min_year":  &filter.MinYear,
+		"max_year":  &filter.MaxYear,
+		"min_pages": &filter.MinPages,
+		"max_pages": &filter.MaxPages,
+	} {
+		if value := r.URL.Query().Get(param); value != "" {
+			num, err := strconv.Atoi(value)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid "+param, err)
+				return
+			}
+			*dst = num
+		}
+	}
+
+	for param, dst := range map[string]**time.Time{
+		"created_after": &filter.CreatedAfter,
+		"updated_after": &filter.UpdatedAfter,
+	} {
+		if value := r.URL.Query().Get(param); value != "" {
+			t, err := utils.ParseTime(value)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid "+param, err)
+				return
+			}
+			*dst = &t
+		}
+	}
+
+	if availableOnly := r.URL.Query().Get("available_only"); availableOnly == "true" {
+		filter.AvailableOnly = true
+	}
+
+	for _, ids := range r.URL.Query()["category_ids"] {
+		for _, id := range strings.Split(ids, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				filter.CategoryIDs = append(filter.CategoryIDs, id)
+			}
+		}
+	}
+
+	filter.CategoryMatch = r.URL.Query().Get("category_match")
+
 	books, err := h.bookService.ListBooks(r.Context(), filter)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidCursor, nil)
+			return
+		}
+
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidFilter) {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidFilter, err)
+			return
+		}
+
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidSort) {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidSort, err)
+			return
+		}
+
 		h.log.Error("Failed to list books", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
 		return
@@ -237,8 +311,9 @@ func (h *BookHandler) HandleSearchBooks(w http.ResponseWriter, r *http.Request)
 	}
 
 	search := &dto.BookSearch{
-		Query: query,
-		Field: r.URL.Query().Get("field"),
+		Query:  query,
+		Field:  r.URL.Query().Get("field"),
+		Cursor: r.URL.Query().Get("cursor"),
 	}
 
 	if page := r.URL.Query().Get("page"); page != "" {
@@ -255,6 +330,11 @@ func (h *BookHandler) HandleSearchBooks(w http.ResponseWriter, r *http.Request)
 
 	books, err := h.bookService.SearchBooks(r.Context(), search)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidCursor, nil)
+			return
+		}
+
 		h.log.Error("Failed to search books", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
 		return
@@ -286,12 +366,16 @@ func (h *BookHandler) HandleGetBooksByCategory(w http.ResponseWriter, r *http.Re
 		}
 	}
 
-	books, err := h.bookService.GetBooksByCategory(r.Context(), categoryID, page, limit)
+	includeDescendants := r.URL.Query().Get("include_descendants") == "true"
+
+	books, err := h.bookService.GetBooksByCategory(r.Context(), categoryID, includeDescendants, page, limit)
 	if err != nil {
 		h.log.Error("Failed to get books by category", zap.Error(err), zap.String("category_id", categoryID))
 
 		if err.Error() == constants.ErrInternalServer {
 			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		} else if err.Error() == constants.ErrCategoryServiceUnavailable {
+			utils.RespondWithError(w, http.StatusServiceUnavailable, constants.ErrCategoryServiceUnavailable, nil)
 		} else {
 			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
 		}
@@ -51,16 +51,66 @@ func (h *BookGRPCHandler) GetBook(ctx context.Context, req *book.GetBookRequest)
 	}, nil
 }
 
+func (h *BookGRPCHandler) BatchGetBooks(ctx context.Context, req *book.BatchGetBooksRequest) (*book.BatchGetBooksResponse, error) {
+	response, err := h.bookService.BatchGetBooks(ctx, req.GetIds())
+	if err != nil {
+		if err.Error() == constants.ErrBatchTooLarge {
+			return nil, status.Error(codes.InvalidArgument, constants.ErrBatchTooLarge)
+		}
+		h.log.Error("Failed to batch get books", zap.Error(err))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	protoResponse := &book.BatchGetBooksResponse{
+		Books:      make([]*book.Book, 0, len(response.Books)),
+		MissingIds: response.MissingIDs,
+	}
+
+	for _, b := range response.Books {
+		protoResponse.Books = append(protoResponse.Books, convertBookResponseToProtoBook(&b))
+	}
+
+	return protoResponse, nil
+}
+
 func (h *BookGRPCHandler) ListBooks(ctx context.Context, req *book.ListBooksRequest) (*book.ListBooksResponse, error) {
 	filter := &dto.BookFilter{
-		Page:   int(req.GetPage()),
-		Limit:  int(req.GetPageSize()),
-		SortBy: req.GetSortBy(),
-		Desc:   req.GetSortDesc(),
+		Page:          int(req.GetPage()),
+		Limit:         int(req.GetPageSize()),
+		SortBy:        req.GetSortBy(),
+		Desc:          req.GetSortDesc(),
+		Status:        req.GetStatus(),
+		Author:        req.GetAuthor(),
+		Language:      req.GetLanguage(),
+		CategoryIDs:   req.GetCategoryIds(),
+		CategoryMatch: req.GetCategoryMatch(),
+		MinYear:       int(req.GetMinYear()),
+		MaxYear:       int(req.GetMaxYear()),
+		MinPages:      int(req.GetMinPages()),
+		MaxPages:      int(req.GetMaxPages()),
+		Filter:        req.GetFilter(),
+		Cursor:        req.GetCursor(),
+		AvailableOnly: req.GetAvailableOnly(),
+	}
+
+	if req.CreatedAfter != nil {
+		createdAfter := req.GetCreatedAfter().AsTime()
+		filter.CreatedAfter = &createdAfter
+	}
+
+	if req.UpdatedAfter != nil {
+		updatedAfter := req.GetUpdatedAfter().AsTime()
+		filter.UpdatedAfter = &updatedAfter
 	}
 
 	response, err := h.bookService.ListBooks(ctx, filter)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			return nil, status.Error(codes.InvalidArgument, constants.ErrInvalidCursor)
+		}
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidFilter) || strings.HasPrefix(err.Error(), constants.ErrInvalidSort) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		h.log.Error("Failed to list books", zap.Error(err))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -71,6 +121,8 @@ func (h *BookGRPCHandler) ListBooks(ctx context.Context, req *book.ListBooksRequ
 		TotalPages:  int32(response.TotalPages),
 		CurrentPage: int32(response.CurrentPage),
 		PageSize:    int32(response.PageSize),
+		NextCursor:  response.NextCursor,
+		PrevCursor:  response.PrevCursor,
 	}
 
 	for _, b := range response.Books {
@@ -101,14 +153,19 @@ func (h *BookGRPCHandler) CreateBook(ctx context.Context, req *book.CreateBookRe
 		createDTO.Quantity = int(req.GetQuantity())
 	}
 
+	createDTO.ClassNumber = req.GetClassNumber()
+
 	bookResponse, err := h.bookService.CreateBook(ctx, createDTO)
 	if err != nil {
 		if strings.Contains(err.Error(), "already exists") {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
-		if strings.Contains(err.Error(), "invalid category ID") {
+		if strings.Contains(err.Error(), "invalid category ID") || strings.HasPrefix(err.Error(), constants.ErrInvalidClassNumber) {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
+		if err.Error() == constants.ErrCategoryServiceUnavailable {
+			return nil, status.Error(codes.Unavailable, constants.ErrCategoryServiceUnavailable)
+		}
 		h.log.Error("Failed to create book", zap.Error(err))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -190,6 +247,11 @@ func (h *BookGRPCHandler) UpdateBook(ctx context.Context, req *book.UpdateBookRe
 		updateDTO.AvailableQuantity = &availableQuantity
 	}
 
+	if req.ClassNumber != nil {
+		classNumber := req.GetClassNumber()
+		updateDTO.ClassNumber = &classNumber
+	}
+
 	bookResponse, err := h.bookService.UpdateBook(ctx, id, updateDTO)
 	if err != nil {
 		if strings.Contains(err.Error(), constants.ErrBookNotFound) {
@@ -198,9 +260,12 @@ func (h *BookGRPCHandler) UpdateBook(ctx context.Context, req *book.UpdateBookRe
 		if strings.Contains(err.Error(), "already exists") {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
-		if strings.Contains(err.Error(), "invalid category ID") {
+		if strings.Contains(err.Error(), "invalid category ID") || strings.HasPrefix(err.Error(), constants.ErrInvalidClassNumber) {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
+		if err.Error() == constants.ErrCategoryServiceUnavailable {
+			return nil, status.Error(codes.Unavailable, constants.ErrCategoryServiceUnavailable)
+		}
 		h.log.Error("Failed to update book", zap.Error(err), zap.String("id", id.String()))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -229,9 +294,10 @@ func (h *BookGRPCHandler) DeleteBook(ctx context.Context, req *book.DeleteBookRe
 
 func (h *BookGRPCHandler) SearchBooks(ctx context.Context, req *book.SearchBooksRequest) (*book.ListBooksResponse, error) {
 	search := &dto.BookSearch{
-		Query: req.GetQuery(),
-		Page:  int(req.GetPage()),
-		Limit: int(req.GetPageSize()),
+		Query:  req.GetQuery(),
+		Page:   int(req.GetPage()),
+		Limit:  int(req.GetPageSize()),
+		Cursor: req.GetCursor(),
 	}
 
 	if req.Field != nil {
@@ -240,6 +306,9 @@ func (h *BookGRPCHandler) SearchBooks(ctx context.Context, req *book.SearchBooks
 
 	response, err := h.bookService.SearchBooks(ctx, search)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			return nil, status.Error(codes.InvalidArgument, constants.ErrInvalidCursor)
+		}
 		h.log.Error("Failed to search books", zap.Error(err))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -250,6 +319,8 @@ func (h *BookGRPCHandler) SearchBooks(ctx context.Context, req *book.SearchBooks
 		TotalPages:  int32(response.TotalPages),
 		CurrentPage: int32(response.CurrentPage),
 		PageSize:    int32(response.PageSize),
+		NextCursor:  response.NextCursor,
+		PrevCursor:  response.PrevCursor,
 	}
 
 	for _, b := range response.Books {
@@ -264,7 +335,7 @@ func (h *BookGRPCHandler) GetBooksByCategory(ctx context.Context, req *book.GetB
 	page := int(req.GetPage())
 	pageSize := int(req.GetPageSize())
 
-	response, err := h.bookService.GetBooksByCategory(ctx, categoryID, page, pageSize)
+	response, err := h.bookService.GetBooksByCategory(ctx, categoryID, req.GetIncludeDescendants(), page, pageSize)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid category ID") {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
@@ -272,6 +343,9 @@ func (h *BookGRPCHandler) GetBooksByCategory(ctx context.Context, req *book.GetB
 		if strings.Contains(err.Error(), "does not exist") {
 			return nil, status.Error(codes.NotFound, err.Error())
 		}
+		if err.Error() == constants.ErrCategoryServiceUnavailable {
+			return nil, status.Error(codes.Unavailable, constants.ErrCategoryServiceUnavailable)
+		}
 		h.log.Error("Failed to get books by category", zap.Error(err), zap.String("category_id", categoryID))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -282,6 +356,8 @@ func (h *BookGRPCHandler) GetBooksByCategory(ctx context.Context, req *book.GetB
 		TotalPages:  int32(response.TotalPages),
 		CurrentPage: int32(response.CurrentPage),
 		PageSize:    int32(response.PageSize),
+		NextCursor:  response.NextCursor,
+		PrevCursor:  response.PrevCursor,
 	}
 
 	for _, b := range response.Books {
@@ -291,6 +367,50 @@ func (h *BookGRPCHandler) GetBooksByCategory(ctx context.Context, req *book.GetB
 	return protoResponse, nil
 }
 
+func (h *BookGRPCHandler) CountBooksByCategory(ctx context.Context, req *book.CountBooksByCategoryRequest) (*book.CountBooksByCategoryResponse, error) {
+	subtrees := make(map[string][]string, len(req.GetQueries()))
+	for _, query := range req.GetQueries() {
+		subtrees[query.GetCategoryId()] = query.GetDescendantIds()
+	}
+
+	counts, err := h.bookService.CountBooksByCategory(ctx, subtrees)
+	if err != nil {
+		if err.Error() == constants.ErrBatchTooLarge || strings.Contains(err.Error(), "invalid category ID") {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		h.log.Error("Failed to count books by category", zap.Error(err))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	protoResponse := &book.CountBooksByCategoryResponse{
+		Counts: make(map[string]*book.CategoryBookCount, len(counts)),
+	}
+
+	for id, count := range counts {
+		protoResponse.Counts[id] = &book.CategoryBookCount{
+			Direct: count.Direct,
+			Total:  count.Total,
+		}
+	}
+
+	return protoResponse, nil
+}
+
+func (h *BookGRPCHandler) ReassignCategory(ctx context.Context, req *book.ReassignCategoryRequest) (*book.ReassignCategoryResponse, error) {
+	updated, err := h.bookService.ReassignCategory(ctx, req.GetFromCategoryIds(), req.GetToCategoryId())
+	if err != nil {
+		if err.Error() == constants.ErrMergeSameCategory || strings.Contains(err.Error(), "invalid category ID") {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		h.log.Error("Failed to reassign category", zap.Error(err), zap.String("to_category_id", req.GetToCategoryId()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return &book.ReassignCategoryResponse{
+		BooksUpdated: updated,
+	}, nil
+}
+
 func (h *BookGRPCHandler) Health(ctx context.Context, _ *emptypb.Empty) (*book.HealthResponse, error) {
 	return &book.HealthResponse{
 		Status:  "ok",
@@ -303,6 +423,8 @@ func convertBookResponseToProtoBook(b *dao.BookResponse) *book.Book {
 	avgRating := float32(b.AverageRating)
 	quantity := int32(b.Quantity)
 	availableQuantity := int32(b.AvailableQuantity)
+	classNumber := b.ClassNumber
+	callNumber := b.CallNumber
 
 	return &book.Book{
 		Id:                b.ID.String(),
@@ -322,6 +444,8 @@ func convertBookResponseToProtoBook(b *dao.BookResponse) *book.Book {
 		AverageRating:     &avgRating,
 		Quantity:          &quantity,
 		AvailableQuantity: &availableQuantity,
+		ClassNumber:       &classNumber,
+		CallNumber:        &callNumber,
 	}
 }
 
@@ -332,6 +456,8 @@ func convertDaoBookToProtoBook(bookResponse interface{}) *book.Book {
 		avgRating := float32(br.AverageRating)
 		quantity := int32(br.Quantity)
 		availableQuantity := int32(br.AvailableQuantity)
+		classNumber := br.ClassNumber
+		callNumber := br.CallNumber
 
 		return &book.Book{
 			Id:                br.ID.String(),
@@ -351,6 +477,8 @@ func convertDaoBookToProtoBook(bookResponse interface{}) *book.Book {
 			AverageRating:     &avgRating,
 			Quantity:          &quantity,
 			AvailableQuantity: &availableQuantity,
+			ClassNumber:       &classNumber,
+			CallNumber:        &callNumber,
 		}
 	default:
 		return nil
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/book-service/internal/service"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type DuplicateHandler struct {
+	duplicateService service.DuplicateService
+	log              *logger.Logger
+}
+
+func NewDuplicateHandler(duplicateService service.DuplicateService, log *logger.Logger) *DuplicateHandler {
+	return &DuplicateHandler{
+		duplicateService: duplicateService,
+		log:              log,
+	}
+}
+
+func (h *DuplicateHandler) HandleListDuplicates(w http.ResponseWriter, r *http.Request) {
+	filter := &dto.BookDuplicateFilter{
+		Status: r.URL.Query().Get("status"),
+	}
+
+	if page := r.URL.Query().Get("page"); page != "" {
+		if pageNum, err := strconv.Atoi(page); err == nil {
+			filter.Page = pageNum
+		}
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		if limitNum, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = limitNum
+		}
+	}
+
+	duplicates, err := h.duplicateService.ListDuplicates(r.Context(), filter)
+	if err != nil {
+		h.log.Error("Failed to list duplicate books", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Duplicate candidates retrieved successfully", duplicates)
+}
+
+func (h *DuplicateHandler) HandleScanDuplicates(w http.ResponseWriter, r *http.Request) {
+	result, err := h.duplicateService.ScanDuplicates(r.Context())
+	if err != nil {
+		h.log.Error("Failed to scan for duplicate books", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Duplicate scan completed", result)
+}
+
+func (h *DuplicateHandler) HandleDismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid duplicate ID", err)
+		return
+	}
+
+	if err := h.duplicateService.DismissDuplicate(r.Context(), id); err != nil {
+		if err.Error() == constants.ErrDuplicateNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrDuplicateNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to dismiss duplicate", zap.Error(err), zap.String("id", id.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Duplicate candidate dismissed", nil)
+}
+
+func (h *DuplicateHandler) HandleMergeBooks(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid book ID", err)
+		return
+	}
+
+	var req dto.BookMerge
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for merge books request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	book, err := h.duplicateService.MergeBooks(r.Context(), id, &req)
+	if err != nil {
+		switch err.Error() {
+		case constants.ErrBookNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrBookNotFound, nil)
+		case constants.ErrMergeSameBook, constants.ErrInvalidField:
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		default:
+			h.log.Error("Failed to merge books", zap.Error(err), zap.String("id", id.String()))
+			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Books merged successfully", book)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 
 	"github.com/fairuzald/library-system/pkg/cache"
+	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
 	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/proto/book"
@@ -23,14 +24,19 @@ type Module struct {
 	Redis *cache.Redis
 
 	JWTAuth *middleware.JWTAuth
+	Policy  *middleware.Policy
 
 	CategoryClient service.CategoryClient
 	BookRepo       repository.BookRepository
 	BookService    service.BookService
 
-	BookHandler     *handler.BookHandler
-	HealthHandler   *handler.HealthHandler
-	BookGRPCHandler *handler.BookGRPCHandler
+	DuplicateRepo    repository.DuplicateRepository
+	DuplicateService service.DuplicateService
+
+	BookHandler      *handler.BookHandler
+	DuplicateHandler *handler.DuplicateHandler
+	HealthHandler    *handler.HealthHandler
+	BookGRPCHandler  *handler.BookGRPCHandler
 
 	Log *logger.Logger
 }
@@ -39,6 +45,9 @@ func New(
 	db *sql.DB,
 	redis *cache.Redis,
 	jwtSecret string,
+	jwksURL string,
+	tokenVersionURL string,
+	tokenVersionFailOpen bool,
 	categoryServiceURL string,
 	log *logger.Logger,
 ) (*Module, error) {
@@ -58,6 +67,17 @@ func New(
 	}
 
 	m.JWTAuth = middleware.NewJWTAuth(jwtSecret, 0) // JWT duration not needed for this service
+	if jwksURL != "" {
+		m.JWTAuth.WithKeyResolver(middleware.NewRemoteJWKS(jwksURL, constants.JWKSCacheTTL))
+	}
+	var versionSource middleware.TokenVersionStore
+	if tokenVersionURL != "" {
+		versionSource = middleware.NewRemoteTokenVersions(tokenVersionURL)
+	}
+	if versions := middleware.TokenVersions(redis, versionSource); versions != nil {
+		m.JWTAuth.WithTokenVersions(versions, tokenVersionFailOpen)
+	}
+	m.Policy = middleware.DefaultPolicy()
 
 	m.CategoryClient, err = service.NewCategoryClient(categoryServiceURL, log)
 	if err != nil {
@@ -67,7 +87,11 @@ func New(
 	m.BookRepo = repository.NewBookRepository(m.GormDB, redis, log)
 	m.BookService = service.NewBookService(m.BookRepo, m.CategoryClient, log)
 
+	m.DuplicateRepo = repository.NewDuplicateRepository(m.GormDB, redis, log)
+	m.DuplicateService = service.NewDuplicateService(m.DuplicateRepo, m.BookRepo, log)
+
 	m.BookHandler = handler.NewBookHandler(m.BookService, log)
+	m.DuplicateHandler = handler.NewDuplicateHandler(m.DuplicateService, log)
 	m.HealthHandler = handler.NewHealthHandler(db, log)
 	m.BookGRPCHandler = handler.NewBookGRPCHandler(m.BookService, log)
 
@@ -8,10 +8,14 @@ import (
 
 	"github.com/fairuzald/library-system/pkg/cache"
 	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/listquery"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/pagination"
+	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/services/book-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/book-service/internal/entity/model"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -20,14 +24,38 @@ type BookRepository interface {
 	Create(ctx context.Context, book *model.Book) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Book, error)
 	GetByISBN(ctx context.Context, isbn string) (*model.Book, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Book, error)
 	Update(ctx context.Context, book *model.Book) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	List(ctx context.Context, filter *dto.BookFilter) ([]*model.Book, int64, error)
-	Search(ctx context.Context, search *dto.BookSearch) ([]*model.Book, int64, error)
-	GetByCategory(ctx context.Context, categoryID string, page, limit int) ([]*model.Book, int64, error)
+	List(ctx context.Context, filter *dto.BookFilter) ([]*model.Book, int64, *pagination.Cursors, error)
+	Search(ctx context.Context, search *dto.BookSearch) ([]*model.Book, int64, *pagination.Cursors, error)
+	GetByCategory(ctx context.Context, categoryIDs []string, page, limit int) ([]*model.Book, int64, error)
 	AddCategories(ctx context.Context, bookID uuid.UUID, categoryIDs []string) error
 	RemoveCategories(ctx context.Context, bookID uuid.UUID) error
 	GetBookCategories(ctx context.Context, bookID uuid.UUID) ([]string, error)
+	GetCategoriesForBooks(ctx context.Context, bookIDs []uuid.UUID) (map[uuid.UUID][]string, error)
+	CountByCategories(ctx context.Context, subtrees map[uuid.UUID][]uuid.UUID) (map[uuid.UUID]model.CategoryBookCount, error)
+	ReassignCategories(ctx context.Context, fromIDs []uuid.UUID, toID uuid.UUID) (int64, error)
+}
+
+// bookListSchema whitelists the fields clients can filter and sort books on
+var bookListSchema = listquery.Schema{
+	"id":                 {Column: "id", Type: listquery.TypeUUID, Unsortable: true},
+	"title":              {Column: "title", Type: listquery.TypeString},
+	"author":             {Column: "author", Type: listquery.TypeString},
+	"isbn":               {Column: "isbn", Type: listquery.TypeString},
+	"published_year":     {Column: "published_year", Type: listquery.TypeInt},
+	"publisher":          {Column: "publisher", Type: listquery.TypeString},
+	"language":           {Column: "language", Type: listquery.TypeString},
+	"page_count":         {Column: "page_count", Type: listquery.TypeInt},
+	"status":             {Column: "status", Type: listquery.TypeString},
+	"average_rating":     {Column: "average_rating", Type: listquery.TypeFloat, Nullable: true},
+	"quantity":           {Column: "quantity", Type: listquery.TypeInt},
+	"available_quantity": {Column: "available_quantity", Type: listquery.TypeInt},
+	"class_number":       {Column: "class_number", Type: listquery.TypeString, Nullable: true},
+	"shelf_order":        {Column: "shelf_key", Type: listquery.TypeString, Nullable: true},
+	"created_at":         {Column: "created_at", Type: listquery.TypeTime},
+	"updated_at":         {Column: "updated_at", Type: listquery.TypeTime},
 }
 
 type bookRepository struct {
@@ -74,6 +102,7 @@ func (r *bookRepository) Create(ctx context.Context, book *model.Book) error {
 		cacheKey := fmt.Sprintf("%slist", constants.CacheKeyBooks)
 		_ = r.cache.Delete(ctx, cacheKey)
 	}
+	r.invalidateCategoryCounts(ctx)
 
 	return nil
 }
@@ -148,6 +177,25 @@ func (r *bookRepository) GetByISBN(ctx context.Context, isbn string) (*model.Boo
 	return &book, nil
 }
 
+func (r *bookRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Book, error) {
+	var books []*model.Book
+
+	if len(ids) == 0 {
+		return books, nil
+	}
+
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&books).Error; err != nil {
+		r.log.Error("Failed to batch get books", zap.Error(err), zap.Int("count", len(ids)))
+		return nil, err
+	}
+
+	if err := r.attachCategories(ctx, books); err != nil {
+		r.log.Error("Failed to get book categories", zap.Error(err))
+	}
+
+	return books, nil
+}
+
 func (r *bookRepository) Update(ctx context.Context, book *model.Book) error {
 	tx := r.db.WithContext(ctx).Begin()
 	defer func() {
@@ -187,6 +235,7 @@ func (r *bookRepository) Update(ctx context.Context, book *model.Book) error {
 		cacheKey = fmt.Sprintf("%slist", constants.CacheKeyBooks)
 		_ = r.cache.Delete(ctx, cacheKey)
 	}
+	r.invalidateCategoryCounts(ctx)
 
 	return nil
 }
@@ -228,16 +277,37 @@ func (r *bookRepository) Delete(ctx context.Context, id uuid.UUID) error {
 		cacheKey = fmt.Sprintf("%slist", constants.CacheKeyBooks)
 		_ = r.cache.Delete(ctx, cacheKey)
 	}
+	r.invalidateCategoryCounts(ctx)
 
 	return nil
 }
 
-func (r *bookRepository) List(ctx context.Context, filter *dto.BookFilter) ([]*model.Book, int64, error) {
+func (r *bookRepository) List(ctx context.Context, filter *dto.BookFilter) ([]*model.Book, int64, *pagination.Cursors, error) {
 	var books []*model.Book
 	var count int64
 
+	sortKeys, err := bookListSchema.ParseSort(listquery.SortExpr(filter.SortBy, filter.Desc),
+		pagination.SortKey{Column: "created_at", Desc: true})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	cond, err := bookListSchema.ParseFilter(filter.Filter)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	page, err := pagination.NewParams(sortKeys, filter.Cursor, filter.GetOffset(), filter.Limit)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
 	query := r.db.WithContext(ctx).Model(&model.Book{})
 
+	if cond != nil {
+		query = query.Where(cond)
+	}
+
 	if filter.Status != "" {
 		query = query.Where("status = ?", filter.Status)
 	}
@@ -250,51 +320,78 @@ func (r *bookRepository) List(ctx context.Context, filter *dto.BookFilter) ([]*m
 		query = query.Where("language = ?", filter.Language)
 	}
 
-	if filter.CategoryID != "" {
+	if filter.MinYear > 0 {
+		query = query.Where("published_year >= ?", filter.MinYear)
+	}
+
+	if filter.MaxYear > 0 {
+		query = query.Where("published_year <= ?", filter.MaxYear)
+	}
+
+	if filter.MinPages > 0 {
+		query = query.Where("page_count >= ?", filter.MinPages)
+	}
+
+	if filter.MaxPages > 0 {
+		query = query.Where("page_count <= ?", filter.MaxPages)
+	}
+
+	if filter.AvailableOnly {
+		query = query.Where("available_quantity > 0")
+	}
+
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at > ?", *filter.CreatedAfter)
+	}
+
+	if filter.UpdatedAfter != nil {
+		query = query.Where("updated_at > ?", *filter.UpdatedAfter)
+	}
+
+	if len(filter.CategoryIDs) > 0 {
 		subQuery := r.db.Table("books_categories").
 			Select("book_id").
-			Where("category_id = ?", filter.CategoryID)
+			Where("category_id IN ?", filter.CategoryIDs)
+		if filter.CategoryMatch == dto.CategoryMatchAll {
+			subQuery = subQuery.Group("book_id").
+				Having("COUNT(DISTINCT category_id) = ?", len(filter.CategoryIDs))
+		}
 		query = query.Where("id IN (?)", subQuery)
 	}
 
 	if err := query.Count(&count).Error; err != nil {
 		r.log.Error("Failed to count books", zap.Error(err))
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
-	if filter.SortBy != "" {
-		if filter.Desc {
-			query = query.Order(fmt.Sprintf("%s DESC", filter.SortBy))
-		} else {
-			query = query.Order(filter.SortBy)
-		}
-	} else {
-		query = query.Order("created_at DESC")
+	if err := page.Apply(query).Find(&books).Error; err != nil {
+		r.log.Error("Failed to list books", zap.Error(err))
+		return nil, 0, nil, err
 	}
 
-	query = query.Offset(filter.GetOffset()).Limit(filter.Limit)
-
-	if err := query.Find(&books).Error; err != nil {
-		r.log.Error("Failed to list books", zap.Error(err))
-		return nil, 0, err
+	books, cursors, err := pagination.Build(ctx, r.db, page, books)
+	if err != nil {
+		r.log.Error("Failed to build book cursors", zap.Error(err))
+		return nil, 0, nil, err
 	}
 
-	for _, book := range books {
-		categoryIDs, err := r.GetBookCategories(ctx, book.ID)
-		if err != nil {
-			r.log.Error("Failed to get book categories", zap.Error(err), zap.String("book_id", book.ID.String()))
-			continue
-		}
-		book.CategoryIDs = categoryIDs
+	if err := r.attachCategories(ctx, books); err != nil {
+		r.log.Error("Failed to get book categories", zap.Error(err))
 	}
 
-	return books, count, nil
+	return books, count, cursors, nil
 }
 
-func (r *bookRepository) Search(ctx context.Context, search *dto.BookSearch) ([]*model.Book, int64, error) {
+func (r *bookRepository) Search(ctx context.Context, search *dto.BookSearch) ([]*model.Book, int64, *pagination.Cursors, error) {
 	var books []*model.Book
 	var count int64
 
+	sortKeys := []pagination.SortKey{{Column: "created_at", Desc: true}}
+	page, err := pagination.NewParams(sortKeys, search.Cursor, (search.Page-1)*search.Limit, search.Limit)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
 	query := r.db.WithContext(ctx).Model(&model.Book{})
 
 	searchTerm := "%" + search.Query + "%"
@@ -321,39 +418,40 @@ func (r *bookRepository) Search(ctx context.Context, search *dto.BookSearch) ([]
 
 	if err := query.Count(&count).Error; err != nil {
 		r.log.Error("Failed to count search results", zap.Error(err))
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
-	offset := (search.Page - 1) * search.Limit
-	if err := query.Offset(offset).Limit(search.Limit).Find(&books).Error; err != nil {
+	if err := page.Apply(query).Find(&books).Error; err != nil {
 		r.log.Error("Failed to search books", zap.Error(err))
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
-	for _, book := range books {
-		categoryIDs, err := r.GetBookCategories(ctx, book.ID)
-		if err != nil {
-			r.log.Error("Failed to get book categories", zap.Error(err), zap.String("book_id", book.ID.String()))
-			continue
-		}
-		book.CategoryIDs = categoryIDs
+	books, cursors, err := pagination.Build(ctx, r.db, page, books)
+	if err != nil {
+		r.log.Error("Failed to build search cursors", zap.Error(err))
+		return nil, 0, nil, err
 	}
 
-	return books, count, nil
+	if err := r.attachCategories(ctx, books); err != nil {
+		r.log.Error("Failed to get book categories", zap.Error(err))
+	}
+
+	return books, count, cursors, nil
 }
 
-func (r *bookRepository) GetByCategory(ctx context.Context, categoryID string, page, limit int) ([]*model.Book, int64, error) {
+// GetByCategory pages through books filed under any of the given categories; a book in several of them appears once
+func (r *bookRepository) GetByCategory(ctx context.Context, categoryIDs []string, page, limit int) ([]*model.Book, int64, error) {
 	var books []*model.Book
 	var count int64
 
-	catUUID, err := uuid.Parse(categoryID)
-	if err != nil {
-		return nil, 0, err
+	catUUIDs, invalid := utils.ParseUUIDs(categoryIDs)
+	if len(invalid) > 0 {
+		return nil, 0, fmt.Errorf("invalid category ID format: %s", invalid[0])
 	}
 
 	subQuery := r.db.Table("books_categories").
 		Select("book_id").
-		Where("category_id = ?", catUUID)
+		Where("category_id IN ?", catUUIDs)
 
 	query := r.db.WithContext(ctx).Model(&model.Book{}).Where("id IN (?)", subQuery)
 
@@ -368,13 +466,8 @@ func (r *bookRepository) GetByCategory(ctx context.Context, categoryID string, p
 		return nil, 0, err
 	}
 
-	for _, book := range books {
-		categoryIDs, err := r.GetBookCategories(ctx, book.ID)
-		if err != nil {
-			r.log.Error("Failed to get book categories", zap.Error(err), zap.String("book_id", book.ID.String()))
-			continue
-		}
-		book.CategoryIDs = categoryIDs
+	if err := r.attachCategories(ctx, books); err != nil {
+		r.log.Error("Failed to get book categories", zap.Error(err))
 	}
 
 	return books, count, nil
@@ -405,6 +498,164 @@ func (r *bookRepository) GetBookCategories(ctx context.Context, bookID uuid.UUID
 	return categoryIDs, nil
 }
 
+// GetCategoriesForBooks loads the category IDs of many books in a single query
+func (r *bookRepository) GetCategoriesForBooks(ctx context.Context, bookIDs []uuid.UUID) (map[uuid.UUID][]string, error) {
+	categories := make(map[uuid.UUID][]string, len(bookIDs))
+
+	if len(bookIDs) == 0 {
+		return categories, nil
+	}
+
+	var rows []struct {
+		BookID     uuid.UUID
+		CategoryID uuid.UUID
+	}
+
+	err := r.db.WithContext(ctx).Table("books_categories").
+		Select("book_id", "category_id").
+		Where("book_id IN ?", bookIDs).
+		Order("created_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		categories[row.BookID] = append(categories[row.BookID], row.CategoryID.String())
+	}
+
+	return categories, nil
+}
+
+// CountByCategories counts live books per category in one query. Each category is paired with the ids of its
+// descendants; a book filed under several categories of the same subtree is counted once in its total. The
+// pairs are sent as two array parameters, so the statement has a fixed number of placeholders however many
+// categories are counted.
+func (r *bookRepository) CountByCategories(ctx context.Context, subtrees map[uuid.UUID][]uuid.UUID) (map[uuid.UUID]model.CategoryBookCount, error) {
+	counts := make(map[uuid.UUID]model.CategoryBookCount, len(subtrees))
+
+	if len(subtrees) == 0 {
+		return counts, nil
+	}
+
+	var rootIDs, categoryIDs pq.StringArray
+	for categoryID, descendants := range subtrees {
+		counts[categoryID] = model.CategoryBookCount{}
+		rootIDs = append(rootIDs, categoryID.String())
+		categoryIDs = append(categoryIDs, categoryID.String())
+		for _, descendantID := range descendants {
+			if descendantID == categoryID {
+				continue
+			}
+			rootIDs = append(rootIDs, categoryID.String())
+			categoryIDs = append(categoryIDs, descendantID.String())
+		}
+	}
+
+	var rows []struct {
+		RootID uuid.UUID
+		Direct int64
+		Total  int64
+	}
+
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT g.root_id,
+			COUNT(DISTINCT bc.book_id) FILTER (WHERE bc.category_id = g.root_id) AS direct,
+			COUNT(DISTINCT bc.book_id) AS total
+		FROM unnest(?::uuid[], ?::uuid[]) AS g(root_id, category_id)
+		JOIN books_categories bc ON bc.category_id = g.category_id
+		JOIN books b ON b.id = bc.book_id AND b.deleted_at IS NULL
+		GROUP BY g.root_id`, rootIDs, categoryIDs).
+		Scan(&rows).Error
+	if err != nil {
+		r.log.Error("Failed to count books by category", zap.Error(err), zap.Int("categories", len(subtrees)))
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.RootID] = model.CategoryBookCount{Direct: row.Direct, Total: row.Total}
+	}
+
+	return counts, nil
+}
+
+// ReassignCategories moves every book filed under any of fromIDs to toID, dropping the old assignments.
+// It is idempotent, so category-service can safely retry it when its own half of a merge or delete fails.
+func (r *bookRepository) ReassignCategories(ctx context.Context, fromIDs []uuid.UUID, toID uuid.UUID) (int64, error) {
+	var affected []struct {
+		ID   uuid.UUID
+		ISBN string
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("books").
+			Select("DISTINCT books.id, books.isbn").
+			Joins("JOIN books_categories bc ON bc.book_id = books.id").
+			Where("bc.category_id IN ?", fromIDs).
+			Scan(&affected).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`INSERT INTO books_categories (book_id, category_id)
+			SELECT DISTINCT book_id, ? FROM books_categories WHERE category_id IN ?
+			ON CONFLICT (book_id, category_id) DO NOTHING`, toID, fromIDs).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec("DELETE FROM books_categories WHERE category_id IN ?", fromIDs).Error
+	})
+	if err != nil {
+		r.log.Error("Failed to reassign book categories", zap.Error(err), zap.String("to_category_id", toID.String()))
+		return 0, err
+	}
+
+	if r.cache != nil {
+		for _, book := range affected {
+			_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyBook, book.ID.String()))
+			_ = r.cache.Delete(ctx, fmt.Sprintf("%sisbn:%s", constants.CacheKeyBook, book.ISBN))
+		}
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%slist", constants.CacheKeyBooks))
+	}
+	r.invalidateCategoryCounts(ctx)
+
+	return int64(len(affected)), nil
+}
+
+// invalidateCategoryCounts bumps the shared counts version so category-service stops serving cached book counts
+func (r *bookRepository) invalidateCategoryCounts(ctx context.Context) {
+	if r.cache == nil {
+		return
+	}
+
+	if _, err := r.cache.Incr(ctx, constants.CacheKeyCategoryCountsVersion); err != nil {
+		r.log.Warn("Failed to invalidate category counts", zap.Error(err))
+	}
+}
+
+func (r *bookRepository) attachCategories(ctx context.Context, books []*model.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(books))
+	for _, book := range books {
+		ids = append(ids, book.ID)
+	}
+
+	categories, err := r.GetCategoriesForBooks(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		book.CategoryIDs = categories[book.ID]
+	}
+
+	return nil
+}
+
 func (r *bookRepository) addBookCategories(tx *gorm.DB, bookID uuid.UUID, categoryIDs []string) error {
 	for _, catID := range categoryIDs {
 		if catID == "" {
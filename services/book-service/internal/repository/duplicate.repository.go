@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/cache"
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DuplicateRepository interface {
+	ListCatalogue(ctx context.Context) ([]*model.Book, error)
+	UpsertCandidates(ctx context.Context, candidates []*model.BookDuplicate) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.BookDuplicate, error)
+	List(ctx context.Context, status string, page, limit int) ([]*model.BookDuplicate, int64, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
+	Merge(ctx context.Context, survivorID, duplicateID uuid.UUID) (*model.Book, error)
+}
+
+type duplicateRepository struct {
+	db    *gorm.DB
+	cache *cache.Redis
+	log   *logger.Logger
+}
+
+func NewDuplicateRepository(db *gorm.DB, cache *cache.Redis, log *logger.Logger) DuplicateRepository {
+	return &duplicateRepository{
+		db:    db,
+		cache: cache,
+		log:   log,
+	}
+}
+
+// ListCatalogue loads the fields the duplicate scorer needs for every live book
+func (r *duplicateRepository) ListCatalogue(ctx context.Context) ([]*model.Book, error) {
+	var books []*model.Book
+
+	err := r.db.WithContext(ctx).
+		Select("id", "title", "author", "isbn", "publisher", "published_year").
+		Order("id").
+		Find(&books).Error
+	if err != nil {
+		r.log.Error("Failed to load books for duplicate scan", zap.Error(err))
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// UpsertCandidates stores scored pairs, refreshing the score of pairs still pending review
+func (r *duplicateRepository) UpsertCandidates(ctx context.Context, candidates []*model.BookDuplicate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "book_id"}, {Name: "duplicate_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"score":      gorm.Expr("EXCLUDED.score"),
+			"reasons":    gorm.Expr("EXCLUDED.reasons"),
+			"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: "book_duplicates", Name: "status"}, Value: constants.DuplicateStatusPending},
+		}},
+	}).CreateInBatches(candidates, 100).Error
+	if err != nil {
+		r.log.Error("Failed to store duplicate candidates", zap.Error(err), zap.Int("count", len(candidates)))
+		return err
+	}
+
+	return nil
+}
+
+func (r *duplicateRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.BookDuplicate, error) {
+	var candidate model.BookDuplicate
+
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&candidate).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrDuplicateNotFound, err)
+		}
+		return nil, err
+	}
+
+	return &candidate, nil
+}
+
+func (r *duplicateRepository) List(ctx context.Context, status string, page, limit int) ([]*model.BookDuplicate, int64, error) {
+	var candidates []*model.BookDuplicate
+	var count int64
+
+	query := r.db.WithContext(ctx).Model(&model.BookDuplicate{})
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&count).Error; err != nil {
+		r.log.Error("Failed to count duplicate candidates", zap.Error(err))
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("score DESC").Order("id").Offset(offset).Limit(limit).Find(&candidates).Error; err != nil {
+		r.log.Error("Failed to list duplicate candidates", zap.Error(err))
+		return nil, 0, err
+	}
+
+	return candidates, count, nil
+}
+
+func (r *duplicateRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	result := r.db.WithContext(ctx).Model(&model.BookDuplicate{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "updated_at": time.Now()})
+	if result.Error != nil {
+		r.log.Error("Failed to update duplicate candidate", zap.Error(result.Error), zap.String("id", id.String()))
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s: %w", constants.ErrDuplicateNotFound, gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
+
+// Merge folds the duplicate into the survivor: copies and categories move over, missing metadata is
+// filled in, the duplicate is soft-deleted with merged_into_id pointing at the survivor and any
+// pending candidate pairs involving it are closed. The book schema has no review or loan tables;
+// those would be re-pointed here as well once they exist.
+func (r *duplicateRepository) Merge(ctx context.Context, survivorID, duplicateID uuid.UUID) (*model.Book, error) {
+	var survivor, duplicate model.Book
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+
+		if err := locked.Where("id = ?", survivorID).First(&survivor).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%s: %w", constants.ErrBookNotFound, err)
+			}
+			return err
+		}
+
+		if err := locked.Where("id = ?", duplicateID).First(&duplicate).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%s: %w", constants.ErrBookNotFound, err)
+			}
+			return err
+		}
+
+		survivor.Quantity += duplicate.Quantity
+		survivor.AvailableQuantity += duplicate.AvailableQuantity
+		if survivor.AvailableQuantity > 0 && survivor.Status == constants.BookStatusBorrowed {
+			survivor.Status = constants.BookStatusAvailable
+		}
+		if survivor.Description == "" {
+			survivor.Description = duplicate.Description
+		}
+		if survivor.CoverImage == "" {
+			survivor.CoverImage = duplicate.CoverImage
+		}
+		if survivor.AverageRating == 0 {
+			survivor.AverageRating = duplicate.AverageRating
+		}
+		survivor.UpdatedAt = time.Now()
+
+		if err := tx.Save(&survivor).Error; err != nil {
+			return err
+		}
+
+		err := tx.Exec(`INSERT INTO books_categories (book_id, category_id)
+			SELECT ?, category_id FROM books_categories WHERE book_id = ?
+			ON CONFLICT (book_id, category_id) DO NOTHING`, survivorID, duplicateID).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM books_categories WHERE book_id = ?", duplicateID).Error; err != nil {
+			return err
+		}
+
+		// Books merged into the duplicate earlier now resolve straight to the survivor
+		err = tx.Unscoped().Model(&model.Book{}).
+			Where("merged_into_id = ?", duplicateID).
+			Update("merged_into_id", survivorID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.Book{}).Where("id = ?", duplicateID).Updates(map[string]interface{}{
+			"merged_into_id":     survivorID,
+			"quantity":           0,
+			"available_quantity": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&model.Book{}, duplicateID).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.BookDuplicate{}).
+			Where("(book_id = ? OR duplicate_id = ?) AND status = ?", duplicateID, duplicateID, constants.DuplicateStatusPending).
+			Updates(map[string]interface{}{"status": constants.DuplicateStatusMerged, "updated_at": time.Now()}).Error
+	})
+	if err != nil {
+		r.log.Error("Failed to merge books", zap.Error(err),
+			zap.String("survivor_id", survivorID.String()),
+			zap.String("duplicate_id", duplicateID.String()))
+		return nil, err
+	}
+
+	if r.cache != nil {
+		for _, book := range []*model.Book{&survivor, &duplicate} {
+			_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyBook, book.ID.String()))
+			_ = r.cache.Delete(ctx, fmt.Sprintf("%sisbn:%s", constants.CacheKeyBook, book.ISBN))
+		}
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%slist", constants.CacheKeyBooks))
+		if _, err := r.cache.Incr(ctx, constants.CacheKeyCategoryCountsVersion); err != nil {
+			r.log.Warn("Failed to invalidate category counts", zap.Error(err))
+		}
+	}
+
+	return &survivor, nil
+}
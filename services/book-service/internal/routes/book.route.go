@@ -10,24 +10,36 @@ import (
 func SetupRoutes(
 	router *mux.Router,
 	bookHandler *handler.BookHandler,
+	duplicateHandler *handler.DuplicateHandler,
 	jwtAuth *middleware.JWTAuth,
+	policy *middleware.Policy,
 	log *logger.Logger,
 ) {
 	apiRouter := router.PathPrefix("/api").Subrouter()
 	booksRouter := apiRouter.PathPrefix("/books").Subrouter()
 
+	// Duplicate review routes (book:write required), registered before /{id} so "duplicates" is not taken as a book ID
+	duplicatesRouter := booksRouter.PathPrefix("/duplicates").Subrouter()
+	duplicatesRouter.Use(jwtAuth.HTTPMiddleware, policy.Require(middleware.PermBookWrite))
+
+	duplicatesRouter.HandleFunc("", duplicateHandler.HandleListDuplicates).Methods("GET")
+	duplicatesRouter.HandleFunc("/scan", duplicateHandler.HandleScanDuplicates).Methods("POST")
+	duplicatesRouter.HandleFunc("/{id}/dismiss", duplicateHandler.HandleDismissDuplicate).Methods("POST")
+
 	// Public routes (no auth required)
 	booksRouter.HandleFunc("", bookHandler.HandleListBooks).Methods("GET")
 	booksRouter.HandleFunc("/search", bookHandler.HandleSearchBooks).Methods("GET")
 	booksRouter.HandleFunc("/isbn", bookHandler.HandleGetBookByISBN).Methods("GET")
 	booksRouter.HandleFunc("/category/{categoryId}", bookHandler.HandleGetBooksByCategory).Methods("GET")
 	booksRouter.HandleFunc("/{id}", bookHandler.HandleGetBook).Methods("GET")
+	booksRouter.HandleFunc("/{id}/spine-label", bookHandler.HandleGetSpineLabel).Methods("GET")
 
-	// Protected routes (auth required)
+	// Protected routes (book:write required)
 	protectedRouter := booksRouter.NewRoute().Subrouter()
-	protectedRouter.Use(jwtAuth.HTTPMiddleware)
+	protectedRouter.Use(jwtAuth.HTTPMiddleware, policy.Require(middleware.PermBookWrite))
 
 	protectedRouter.HandleFunc("", bookHandler.HandleCreateBook).Methods("POST")
 	protectedRouter.HandleFunc("/{id}", bookHandler.HandleUpdateBook).Methods("PUT", "PATCH")
 	protectedRouter.HandleFunc("/{id}", bookHandler.HandleDeleteBook).Methods("DELETE")
+	protectedRouter.HandleFunc("/{id}/merge", duplicateHandler.HandleMergeBooks).Methods("POST")
 }
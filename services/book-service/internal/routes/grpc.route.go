@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/proto/book"
+)
+
+// GRPCAccess lists who may call each gRPC method; reads stay open to other services
+func GRPCAccess() middleware.MethodAccess {
+	return middleware.MethodAccess{
+		Public: []string{
+			book.BookService_GetBook_FullMethodName,
+			book.BookService_BatchGetBooks_FullMethodName,
+			book.BookService_ListBooks_FullMethodName,
+			book.BookService_SearchBooks_FullMethodName,
+			book.BookService_GetBooksByCategory_FullMethodName,
+			book.BookService_GetRecommendedBooks_FullMethodName,
+			book.BookService_CountBooksByCategory_FullMethodName,
+			book.BookService_Health_FullMethodName,
+		},
+		Permissions: middleware.MethodPermissions{
+			book.BookService_CreateBook_FullMethodName:       {middleware.PermBookWrite},
+			book.BookService_UpdateBook_FullMethodName:       {middleware.PermBookWrite},
+			book.BookService_DeleteBook_FullMethodName:       {middleware.PermBookWrite},
+			book.BookService_ReassignCategory_FullMethodName: {middleware.PermBookWrite},
+		},
+	}
+}
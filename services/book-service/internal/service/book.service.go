@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/fairuzald/library-system/pkg/classification"
 	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/listquery"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/pagination"
+	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/services/book-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/book-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/book-service/internal/entity/model"
@@ -39,24 +43,8 @@ func (s *bookService) CreateBook(ctx context.Context, req *dto.BookCreate) (*dao
 	}
 
 	// Validate category IDs if provided
-	if len(req.CategoryIDs) > 0 && s.categoryGRPC != nil {
-		for _, catID := range req.CategoryIDs {
-			if catID == "" {
-				continue
-			}
-
-			_, err := uuid.Parse(catID)
-			if err != nil {
-				return nil, fmt.Errorf("invalid category ID format: %s", catID)
-			}
-
-			exists, err := s.categoryGRPC.CategoryExists(ctx, catID)
-			if err != nil {
-				s.log.Warn("Failed to validate category ID", zap.Error(err), zap.String("category_id", catID))
-			} else if !exists {
-				return nil, fmt.Errorf("category with ID %s does not exist", catID)
-			}
-		}
+	if err := s.validateCategoryIDs(ctx, req.CategoryIDs); err != nil {
+		return nil, err
 	}
 
 	book := model.NewBook(
@@ -80,6 +68,11 @@ func (s *bookService) CreateBook(ctx context.Context, req *dto.BookCreate) (*dao
 		book.AvailableQuantity = req.Quantity
 	}
 
+	book.ClassNumber = req.ClassNumber
+	if err := assignCallNumber(book); err != nil {
+		return nil, err
+	}
+
 	if err := s.bookRepo.Create(ctx, book); err != nil {
 		s.log.Error("Failed to create book", zap.Error(err))
 		return nil, errors.New(constants.ErrInternalServer)
@@ -109,8 +102,18 @@ func (s *bookService) DeleteBook(ctx context.Context, id uuid.UUID) error {
 func (s *bookService) ListBooks(ctx context.Context, filter *dto.BookFilter) (*dao.BookListResponse, error) {
 	filter.Validate()
 
-	books, count, err := s.bookRepo.List(ctx, filter)
+	if _, invalid := utils.ParseUUIDs(filter.CategoryIDs); len(invalid) > 0 {
+		return nil, fmt.Errorf("%w: invalid category ID %q", listquery.ErrInvalidFilter, invalid[0])
+	}
+
+	books, count, cursors, err := s.bookRepo.List(ctx, filter)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, errors.New(constants.ErrInvalidCursor)
+		}
+		if errors.Is(err, listquery.ErrInvalidFilter) || errors.Is(err, listquery.ErrInvalidSort) {
+			return nil, err
+		}
 		s.log.Error("Failed to list books", zap.Error(err))
 		return nil, errors.New(constants.ErrInternalServer)
 	}
@@ -121,6 +124,8 @@ func (s *bookService) ListBooks(ctx context.Context, filter *dto.BookFilter) (*d
 		TotalPages:  (int(count) + filter.Limit - 1) / filter.Limit,
 		CurrentPage: filter.Page,
 		PageSize:    filter.Limit,
+		NextCursor:  cursors.Next,
+		PrevCursor:  cursors.Prev,
 	}
 
 	for _, book := range books {
@@ -141,8 +146,11 @@ func (s *bookService) SearchBooks(ctx context.Context, search *dto.BookSearch) (
 		search.Limit = constants.MaxPageSize
 	}
 
-	books, count, err := s.bookRepo.Search(ctx, search)
+	books, count, cursors, err := s.bookRepo.Search(ctx, search)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, errors.New(constants.ErrInvalidCursor)
+		}
 		s.log.Error("Failed to search books", zap.Error(err))
 		return nil, errors.New(constants.ErrInternalServer)
 	}
@@ -153,6 +161,8 @@ func (s *bookService) SearchBooks(ctx context.Context, search *dto.BookSearch) (
 		TotalPages:  (int(count) + search.Limit - 1) / search.Limit,
 		CurrentPage: search.Page,
 		PageSize:    search.Limit,
+		NextCursor:  cursors.Next,
+		PrevCursor:  cursors.Prev,
 	}
 
 	for _, book := range books {
@@ -162,7 +172,8 @@ func (s *bookService) SearchBooks(ctx context.Context, search *dto.BookSearch) (
 	return response, nil
 }
 
-func (s *bookService) GetBooksByCategory(ctx context.Context, categoryID string, page, limit int) (*dao.BookListResponse, error) {
+// GetBooksByCategory lists books filed under the category, or anywhere in its subtree when includeDescendants is set
+func (s *bookService) GetBooksByCategory(ctx context.Context, categoryID string, includeDescendants bool, page, limit int) (*dao.BookListResponse, error) {
 	if page <= 0 {
 		page = 1
 	}
@@ -179,8 +190,21 @@ func (s *bookService) GetBooksByCategory(ctx context.Context, categoryID string,
 		return nil, fmt.Errorf("invalid category ID format: %s", categoryID)
 	}
 
-	// Check if category exists
-	if s.categoryGRPC != nil {
+	categoryIDs := []string{categoryID}
+
+	// Check if category exists, resolving its subtree when descendants are requested
+	if s.categoryGRPC != nil && includeDescendants {
+		descendantIDs, err := s.categoryGRPC.GetDescendantIDs(ctx, categoryID)
+		if err != nil {
+			// Listing the category alone would pass off a partial shelf as the whole subtree
+			s.log.Error("Failed to resolve category subtree", zap.Error(err), zap.String("category_id", categoryID))
+			return nil, errors.New(constants.ErrCategoryServiceUnavailable)
+		} else if len(descendantIDs) == 0 {
+			return nil, fmt.Errorf("category with ID %s does not exist", categoryID)
+		} else {
+			categoryIDs = descendantIDs
+		}
+	} else if s.categoryGRPC != nil {
 		exists, err := s.categoryGRPC.CategoryExists(ctx, categoryID)
 		if err != nil {
 			s.log.Warn("Failed to validate category ID", zap.Error(err), zap.String("category_id", categoryID))
@@ -189,7 +213,7 @@ func (s *bookService) GetBooksByCategory(ctx context.Context, categoryID string,
 		}
 	}
 
-	books, count, err := s.bookRepo.GetByCategory(ctx, categoryID, page, limit)
+	books, count, err := s.bookRepo.GetByCategory(ctx, categoryIDs, page, limit)
 	if err != nil {
 		s.log.Error("Failed to get books by category", zap.Error(err), zap.String("category_id", categoryID))
 		return nil, errors.New(constants.ErrInternalServer)
@@ -210,6 +234,76 @@ func (s *bookService) GetBooksByCategory(ctx context.Context, categoryID string,
 	return response, nil
 }
 
+// CountBooksByCategory takes category IDs mapped to their descendant IDs and returns direct and subtree book counts
+func (s *bookService) CountBooksByCategory(ctx context.Context, subtrees map[string][]string) (map[string]dao.CategoryBookCountResponse, error) {
+	if len(subtrees) > constants.MaxBatchSize {
+		return nil, errors.New(constants.ErrBatchTooLarge)
+	}
+
+	parsed := make(map[uuid.UUID][]uuid.UUID, len(subtrees))
+	for categoryID, descendantIDs := range subtrees {
+		id, err := uuid.Parse(categoryID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category ID format: %s", categoryID)
+		}
+
+		descendants, invalid := utils.ParseUUIDs(descendantIDs)
+		if len(invalid) > 0 {
+			return nil, fmt.Errorf("invalid category ID format: %s", invalid[0])
+		}
+		parsed[id] = descendants
+	}
+
+	counts, err := s.bookRepo.CountByCategories(ctx, parsed)
+	if err != nil {
+		s.log.Error("Failed to count books by category", zap.Error(err))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	response := make(map[string]dao.CategoryBookCountResponse, len(counts))
+	for id, count := range counts {
+		response[id.String()] = dao.CategoryBookCountResponse{Direct: count.Direct, Total: count.Total}
+	}
+
+	return response, nil
+}
+
+// ReassignCategory files every book in the fromIDs categories under toID instead
+func (s *bookService) ReassignCategory(ctx context.Context, fromIDs []string, toID string) (int64, error) {
+	targetID, err := uuid.Parse(toID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid category ID format: %s", toID)
+	}
+
+	sourceIDs, invalid := utils.ParseUUIDs(fromIDs)
+	if len(invalid) > 0 {
+		return 0, fmt.Errorf("invalid category ID format: %s", invalid[0])
+	}
+
+	if len(sourceIDs) == 0 {
+		return 0, nil
+	}
+
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return 0, errors.New(constants.ErrMergeSameCategory)
+		}
+	}
+
+	updated, err := s.bookRepo.ReassignCategories(ctx, sourceIDs, targetID)
+	if err != nil {
+		s.log.Error("Failed to reassign category", zap.Error(err), zap.String("to_category_id", toID))
+		return 0, errors.New(constants.ErrInternalServer)
+	}
+
+	s.log.Info("Reassigned books to category",
+		zap.Strings("from_category_ids", fromIDs),
+		zap.String("to_category_id", toID),
+		zap.Int64("books_updated", updated))
+
+	return updated, nil
+}
+
 func (s *bookService) GetBookByID(ctx context.Context, id uuid.UUID) (*dao.BookResponse, error) {
 	book, err := s.bookRepo.GetByID(ctx, id)
 	if err != nil {
@@ -223,6 +317,41 @@ func (s *bookService) GetBookByID(ctx context.Context, id uuid.UUID) (*dao.BookR
 	return dao.NewBookResponse(book), nil
 }
 
+func (s *bookService) BatchGetBooks(ctx context.Context, ids []string) (*dao.BookBatchResponse, error) {
+	if len(ids) > constants.MaxBatchSize {
+		return nil, errors.New(constants.ErrBatchTooLarge)
+	}
+
+	bookIDs, invalid := utils.ParseUUIDs(ids)
+
+	books, err := s.bookRepo.GetByIDs(ctx, bookIDs)
+	if err != nil {
+		s.log.Error("Failed to batch get books", zap.Error(err))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	byID := make(map[uuid.UUID]*model.Book, len(books))
+	for _, book := range books {
+		byID[book.ID] = book
+	}
+
+	response := &dao.BookBatchResponse{
+		Books:      make([]dao.BookResponse, 0, len(books)),
+		MissingIDs: append([]string{}, invalid...),
+	}
+
+	for _, id := range bookIDs {
+		book, ok := byID[id]
+		if !ok {
+			response.MissingIDs = append(response.MissingIDs, id.String())
+			continue
+		}
+		response.Books = append(response.Books, *dao.NewBookResponse(book))
+	}
+
+	return response, nil
+}
+
 func (s *bookService) GetBookByISBN(ctx context.Context, isbn string) (*dao.BookResponse, error) {
 	book, err := s.bookRepo.GetByISBN(ctx, isbn)
 	if err != nil {
@@ -316,29 +445,21 @@ func (s *bookService) UpdateBook(ctx context.Context, id uuid.UUID, req *dto.Boo
 
 	// Validate and update category IDs if provided
 	if len(req.CategoryIDs) > 0 {
-		if s.categoryGRPC != nil {
-			for _, catID := range req.CategoryIDs {
-				if catID == "" {
-					continue
-				}
-
-				_, err := uuid.Parse(catID)
-				if err != nil {
-					return nil, fmt.Errorf("invalid category ID format: %s", catID)
-				}
-
-				exists, err := s.categoryGRPC.CategoryExists(ctx, catID)
-				if err != nil {
-					s.log.Warn("Failed to validate category ID", zap.Error(err), zap.String("category_id", catID))
-				} else if !exists {
-					return nil, fmt.Errorf("category with ID %s does not exist", catID)
-				}
-			}
+		if err := s.validateCategoryIDs(ctx, req.CategoryIDs); err != nil {
+			return nil, err
 		}
 
 		book.CategoryIDs = req.CategoryIDs
 	}
 
+	// Author, title and year all feed the call number, so it is rebuilt on every update
+	if req.ClassNumber != nil {
+		book.ClassNumber = *req.ClassNumber
+	}
+	if err := assignCallNumber(book); err != nil {
+		return nil, err
+	}
+
 	if err := s.bookRepo.Update(ctx, book); err != nil {
 		s.log.Error("Failed to update book", zap.Error(err), zap.String("id", id.String()))
 		return nil, errors.New(constants.ErrInternalServer)
@@ -346,3 +467,79 @@ func (s *bookService) UpdateBook(ctx context.Context, id uuid.UUID, req *dto.Boo
 
 	return dao.NewBookResponse(book), nil
 }
+
+func (s *bookService) GetSpineLabel(ctx context.Context, id uuid.UUID) (*dao.SpineLabelResponse, error) {
+	book, err := s.GetBookByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if book.ClassNumber == "" {
+		return nil, errors.New(constants.ErrBookNotClassified)
+	}
+
+	callNumber, err := classification.NewCallNumber(book.ClassNumber, book.Author, book.Title, book.PublishedYear)
+	if err != nil {
+		s.log.Error("Stored class number is invalid", zap.Error(err), zap.String("id", id.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	return &dao.SpineLabelResponse{
+		BookID:     book.ID,
+		CallNumber: callNumber.String(),
+		Lines:      callNumber.Lines(),
+	}, nil
+}
+
+// assignCallNumber normalizes the book's class number and derives its call number and shelf key.
+// Unclassified books have neither and sort ahead of every shelved book.
+func assignCallNumber(book *model.Book) error {
+	if strings.TrimSpace(book.ClassNumber) == "" {
+		book.ClassNumber, book.CallNumber, book.ShelfKey = "", "", ""
+		return nil
+	}
+
+	callNumber, err := classification.NewCallNumber(book.ClassNumber, book.Author, book.Title, book.PublishedYear)
+	if err != nil {
+		return err
+	}
+
+	book.ClassNumber = callNumber.Class
+	book.CallNumber = callNumber.String()
+	book.ShelfKey = callNumber.ShelfKey()
+	return nil
+}
+
+// validateCategoryIDs checks the format of every ID and resolves them all with one category-service call
+func (s *bookService) validateCategoryIDs(ctx context.Context, categoryIDs []string) error {
+	ids := make([]string, 0, len(categoryIDs))
+	for _, catID := range categoryIDs {
+		if catID == "" {
+			continue
+		}
+
+		parsed, err := uuid.Parse(catID)
+		if err != nil {
+			return fmt.Errorf("invalid category ID format: %s", catID)
+		}
+		ids = append(ids, parsed.String())
+	}
+
+	if len(ids) == 0 || s.categoryGRPC == nil {
+		return nil
+	}
+
+	exists, err := s.categoryGRPC.CategoriesExist(ctx, ids)
+	if err != nil {
+		s.log.Error("Failed to validate category IDs", zap.Error(err), zap.Strings("category_ids", ids))
+		return errors.New(constants.ErrCategoryServiceUnavailable)
+	}
+
+	for _, catID := range ids {
+		if !exists[catID] {
+			return fmt.Errorf("category with ID %s does not exist", catID)
+		}
+	}
+
+	return nil
+}
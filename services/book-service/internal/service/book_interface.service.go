@@ -12,9 +12,13 @@ type BookService interface {
 	CreateBook(ctx context.Context, req *dto.BookCreate) (*dao.BookResponse, error)
 	GetBookByID(ctx context.Context, id uuid.UUID) (*dao.BookResponse, error)
 	GetBookByISBN(ctx context.Context, isbn string) (*dao.BookResponse, error)
+	BatchGetBooks(ctx context.Context, ids []string) (*dao.BookBatchResponse, error)
 	UpdateBook(ctx context.Context, id uuid.UUID, req *dto.BookUpdate) (*dao.BookResponse, error)
 	DeleteBook(ctx context.Context, id uuid.UUID) error
 	ListBooks(ctx context.Context, filter *dto.BookFilter) (*dao.BookListResponse, error)
 	SearchBooks(ctx context.Context, search *dto.BookSearch) (*dao.BookListResponse, error)
-	GetBooksByCategory(ctx context.Context, categoryID string, page, limit int) (*dao.BookListResponse, error)
+	GetBooksByCategory(ctx context.Context, categoryID string, includeDescendants bool, page, limit int) (*dao.BookListResponse, error)
+	CountBooksByCategory(ctx context.Context, subtrees map[string][]string) (map[string]dao.CategoryBookCountResponse, error)
+	ReassignCategory(ctx context.Context, fromIDs []string, toID string) (int64, error)
+	GetSpineLabel(ctx context.Context, id uuid.UUID) (*dao.SpineLabelResponse, error)
 }
@@ -8,7 +8,9 @@ import (
 	"github.com/fairuzald/library-system/proto/category"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 type grpcCategoryClient struct {
@@ -67,6 +69,30 @@ func (c *grpcCategoryClient) CategoryExists(ctx context.Context, categoryID stri
 	return resp.Exists, nil
 }
 
+func (c *grpcCategoryClient) CategoriesExist(ctx context.Context, categoryIDs []string) (map[string]bool, error) {
+	req := &category.BatchGetCategoriesRequest{
+		Ids: categoryIDs,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	resp, err := c.client.BatchGetCategories(ctx, req)
+	if err != nil {
+		c.log.Error("Failed to batch check categories",
+			zap.Error(err),
+			zap.Strings("category_ids", categoryIDs))
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(categoryIDs))
+	for _, cat := range resp.GetCategories() {
+		exists[cat.GetId()] = true
+	}
+
+	return exists, nil
+}
+
 func (c *grpcCategoryClient) GetCategoryName(ctx context.Context, categoryID string) (string, error) {
 	req := &category.GetCategoryRequest{
 		Id: categoryID,
@@ -86,6 +112,28 @@ func (c *grpcCategoryClient) GetCategoryName(ctx context.Context, categoryID str
 	return resp.Category.Name, nil
 }
 
+func (c *grpcCategoryClient) GetDescendantIDs(ctx context.Context, categoryID string) ([]string, error) {
+	req := &category.GetCategoryDescendantsRequest{
+		Id: categoryID,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	resp, err := c.client.GetCategoryDescendants(ctx, req)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		c.log.Error("Failed to get category descendants",
+			zap.Error(err),
+			zap.String("category_id", categoryID))
+		return nil, err
+	}
+
+	return resp.GetIds(), nil
+}
+
 func (c *grpcCategoryClient) Close() error {
 	if c.conn != nil {
 		return c.conn.Close()
@@ -104,12 +152,29 @@ func (m *mockCategoryClient) CategoryExists(ctx context.Context, categoryID stri
 	return true, nil
 }
 
+func (m *mockCategoryClient) CategoriesExist(ctx context.Context, categoryIDs []string) (map[string]bool, error) {
+	m.log.Warn("Using mock category client, assuming categories exist",
+		zap.Strings("category_ids", categoryIDs))
+
+	exists := make(map[string]bool, len(categoryIDs))
+	for _, id := range categoryIDs {
+		exists[id] = true
+	}
+	return exists, nil
+}
+
 func (m *mockCategoryClient) GetCategoryName(ctx context.Context, categoryID string) (string, error) {
 	m.log.Warn("Using mock category client, returning unknown category",
 		zap.String("category_id", categoryID))
 	return "Unknown Category", nil
 }
 
+func (m *mockCategoryClient) GetDescendantIDs(ctx context.Context, categoryID string) ([]string, error) {
+	m.log.Warn("Using mock category client, returning category without descendants",
+		zap.String("category_id", categoryID))
+	return []string{categoryID}, nil
+}
+
 func (m *mockCategoryClient) Close() error {
 	return nil
 }
@@ -7,7 +7,12 @@ import (
 type CategoryClient interface {
 	CategoryExists(ctx context.Context, categoryID string) (bool, error)
 
+	CategoriesExist(ctx context.Context, categoryIDs []string) (map[string]bool, error)
+
 	GetCategoryName(ctx context.Context, categoryID string) (string, error)
 
+	// GetDescendantIDs returns the category and all categories below it, or nothing when it does not exist
+	GetDescendantIDs(ctx context.Context, categoryID string) ([]string, error)
+
 	Close() error
 }
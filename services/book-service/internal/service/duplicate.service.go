@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/model"
+	"github.com/fairuzald/library-system/services/book-service/internal/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type duplicateService struct {
+	duplicateRepo repository.DuplicateRepository
+	bookRepo      repository.BookRepository
+	log           *logger.Logger
+}
+
+func NewDuplicateService(duplicateRepo repository.DuplicateRepository, bookRepo repository.BookRepository, log *logger.Logger) DuplicateService {
+	return &duplicateService{
+		duplicateRepo: duplicateRepo,
+		bookRepo:      bookRepo,
+		log:           log,
+	}
+}
+
+func (s *duplicateService) ScanDuplicates(ctx context.Context) (*dao.DuplicateScanResponse, error) {
+	books, err := s.duplicateRepo.ListCatalogue(ctx)
+	if err != nil {
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	fingerprints := make([]*bookFingerprint, 0, len(books))
+	blocks := make(map[string][]int)
+	for i, book := range books {
+		fp := newBookFingerprint(book)
+		fingerprints = append(fingerprints, fp)
+		for _, key := range fp.blockKeys() {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	now := time.Now()
+	var candidates []*model.BookDuplicate
+
+	for key, members := range blocks {
+		if len(members) > maxBlockSize {
+			s.log.Debug("Skipping oversized duplicate block", zap.String("key", key), zap.Int("size", len(members)))
+			continue
+		}
+
+		for i := 0; i < len(members); i++ {
+			for j := i + 1; j < len(members); j++ {
+				pair := [2]int{members[i], members[j]}
+				if seen[pair] {
+					continue
+				}
+				seen[pair] = true
+
+				a, b := fingerprints[pair[0]], fingerprints[pair[1]]
+				score, reasons := scoreDuplicate(a, b)
+				if score < duplicateThreshold {
+					continue
+				}
+
+				bookID, duplicateID := a.book.ID, b.book.ID
+				if bytes.Compare(bookID[:], duplicateID[:]) > 0 {
+					bookID, duplicateID = duplicateID, bookID
+				}
+
+				candidates = append(candidates, &model.BookDuplicate{
+					ID:          uuid.New(),
+					BookID:      bookID,
+					DuplicateID: duplicateID,
+					Score:       math.Round(score*1000) / 1000,
+					Reasons:     strings.Join(reasons, ","),
+					Status:      constants.DuplicateStatusPending,
+					CreatedAt:   now,
+					UpdatedAt:   now,
+				})
+			}
+		}
+	}
+
+	if err := s.duplicateRepo.UpsertCandidates(ctx, candidates); err != nil {
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	s.log.Info("Duplicate scan completed",
+		zap.Int("books_scanned", len(books)),
+		zap.Int("candidates_found", len(candidates)))
+
+	return &dao.DuplicateScanResponse{
+		BooksScanned:    len(books),
+		CandidatesFound: len(candidates),
+	}, nil
+}
+
+func (s *duplicateService) ListDuplicates(ctx context.Context, filter *dto.BookDuplicateFilter) (*dao.BookDuplicateListResponse, error) {
+	filter.Validate()
+
+	candidates, count, err := s.duplicateRepo.List(ctx, filter.Status, filter.Page, filter.Limit)
+	if err != nil {
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	response := &dao.BookDuplicateListResponse{
+		Duplicates:  make([]dao.BookDuplicateResponse, 0, len(candidates)),
+		TotalItems:  count,
+		TotalPages:  (int(count) + filter.Limit - 1) / filter.Limit,
+		CurrentPage: filter.Page,
+		PageSize:    filter.Limit,
+	}
+
+	for _, candidate := range candidates {
+		response.Duplicates = append(response.Duplicates, *dao.NewBookDuplicateResponse(candidate))
+	}
+
+	return response, nil
+}
+
+func (s *duplicateService) DismissDuplicate(ctx context.Context, id uuid.UUID) error {
+	if err := s.duplicateRepo.UpdateStatus(ctx, id, constants.DuplicateStatusDismissed); err != nil {
+		if strings.Contains(err.Error(), constants.ErrDuplicateNotFound) {
+			return errors.New(constants.ErrDuplicateNotFound)
+		}
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	return nil
+}
+
+func (s *duplicateService) MergeBooks(ctx context.Context, survivorID uuid.UUID, req *dto.BookMerge) (*dao.BookResponse, error) {
+	duplicateID, err := uuid.Parse(req.DuplicateID)
+	if err != nil {
+		return nil, errors.New(constants.ErrInvalidField)
+	}
+
+	if duplicateID == survivorID {
+		return nil, errors.New(constants.ErrMergeSameBook)
+	}
+
+	if _, err := s.duplicateRepo.Merge(ctx, survivorID, duplicateID); err != nil {
+		if strings.Contains(err.Error(), constants.ErrBookNotFound) {
+			return nil, errors.New(constants.ErrBookNotFound)
+		}
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	s.log.Info("Merged duplicate book",
+		zap.String("survivor_id", survivorID.String()),
+		zap.String("duplicate_id", duplicateID.String()))
+
+	book, err := s.bookRepo.GetByID(ctx, survivorID)
+	if err != nil {
+		s.log.Error("Failed to reload merged book", zap.Error(err), zap.String("id", survivorID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	return dao.NewBookResponse(book), nil
+}
+
+// RunScanner rescans the catalogue every interval until ctx is cancelled. A non-positive interval disables it.
+func (s *duplicateService) RunScanner(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		s.log.Info("Duplicate scanner disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ScanDuplicates(ctx); err != nil {
+				s.log.Error("Scheduled duplicate scan failed", zap.Error(err))
+			}
+		}
+	}
+}
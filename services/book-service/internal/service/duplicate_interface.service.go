@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/dto"
+	"github.com/google/uuid"
+)
+
+type DuplicateService interface {
+	ScanDuplicates(ctx context.Context) (*dao.DuplicateScanResponse, error)
+	ListDuplicates(ctx context.Context, filter *dto.BookDuplicateFilter) (*dao.BookDuplicateListResponse, error)
+	DismissDuplicate(ctx context.Context, id uuid.UUID) error
+	MergeBooks(ctx context.Context, survivorID uuid.UUID, req *dto.BookMerge) (*dao.BookResponse, error)
+	RunScanner(ctx context.Context, interval time.Duration)
+}
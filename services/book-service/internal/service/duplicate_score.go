@@ -0,0 +1,211 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/fairuzald/library-system/services/book-service/internal/entity/model"
+)
+
+// Weights of the fuzzy signals; an ISBN match short-circuits to a full score
+const (
+	titleWeight     = 0.55
+	authorWeight    = 0.30
+	publisherWeight = 0.10
+	yearWeight      = 0.05
+
+	// duplicateThreshold is the lowest score stored as a candidate pair
+	duplicateThreshold = 0.85
+	// minTitleSimilarity prunes pairs before the weighted score is computed
+	minTitleSimilarity = 0.6
+	// strongSignal marks a field as a reason for the match
+	strongSignal = 0.9
+
+	minBlockTokenLength = 3
+	// maxBlockSize skips title words so common that comparing every book sharing them is pointless
+	maxBlockSize = 500
+)
+
+// noiseWords are dropped from titles and names so edition markers and articles do not affect similarity
+var noiseWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "of": true,
+	"edition": true, "ed": true, "revised": true, "rev": true, "reprint": true,
+	"paperback": true, "hardcover": true, "hardback": true, "illustrated": true,
+	"anniversary": true, "unabridged": true, "abridged": true, "vol": true, "volume": true,
+	"first": true, "second": true, "third": true, "fourth": true, "fifth": true,
+}
+
+type bookFingerprint struct {
+	book      *model.Book
+	isbn      string
+	title     string
+	author    string
+	publisher string
+}
+
+func newBookFingerprint(book *model.Book) *bookFingerprint {
+	return &bookFingerprint{
+		book:      book,
+		isbn:      normalizeISBN(book.ISBN),
+		title:     normalizeText(book.Title),
+		author:    normalizeText(book.Author),
+		publisher: normalizeText(book.Publisher),
+	}
+}
+
+// blockKeys groups fingerprints that are worth comparing so the scan is not quadratic in the catalogue size.
+// Books share a block when they have the same ISBN or any significant title word.
+func (f *bookFingerprint) blockKeys() []string {
+	keys := make([]string, 0, 4)
+	if f.isbn != "" {
+		keys = append(keys, "isbn:"+f.isbn)
+	}
+	for _, token := range strings.Fields(f.title) {
+		if len(token) >= minBlockTokenLength {
+			keys = append(keys, "title:"+token)
+		}
+	}
+	return keys
+}
+
+// scoreDuplicate rates how likely two books are the same title, returning the score and the matching signals
+func scoreDuplicate(a, b *bookFingerprint) (float64, []string) {
+	if a.isbn != "" && a.isbn == b.isbn {
+		return 1, []string{"isbn"}
+	}
+
+	titleSim := similarity(a.title, b.title)
+	if titleSim < minTitleSimilarity {
+		return 0, nil
+	}
+
+	authorSim := similarity(a.author, b.author)
+	publisherSim := similarity(a.publisher, b.publisher)
+
+	yearSim := 0.0
+	switch diff := a.book.PublishedYear - b.book.PublishedYear; {
+	case diff == 0:
+		yearSim = 1
+	case diff >= -1 && diff <= 1:
+		yearSim = 0.5
+	}
+
+	var reasons []string
+	if titleSim >= strongSignal {
+		reasons = append(reasons, "title")
+	}
+	if authorSim >= strongSignal {
+		reasons = append(reasons, "author")
+	}
+	if publisherSim >= strongSignal {
+		reasons = append(reasons, "publisher")
+	}
+	if yearSim == 1 {
+		reasons = append(reasons, "published_year")
+	}
+
+	score := titleWeight*titleSim + authorWeight*authorSim + publisherWeight*publisherSim + yearWeight*yearSim
+	return score, reasons
+}
+
+// normalizeISBN strips formatting and converts ISBN-10 to ISBN-13 so both forms compare equal
+func normalizeISBN(isbn string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(isbn) {
+		if unicode.IsDigit(r) || r == 'X' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+
+	if len(digits) != 10 {
+		return digits
+	}
+
+	isbn13 := "978" + digits[:9]
+	sum := 0
+	for i, r := range isbn13 {
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	check := (10 - sum%10) % 10
+
+	return isbn13 + string(rune('0'+check))
+}
+
+// normalizeText lowercases, strips punctuation, drops noise words and ordinals, and sorts the remaining tokens
+func normalizeText(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if noiseWords[field] || isOrdinal(field) {
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	sort.Strings(tokens)
+
+	return strings.Join(tokens, " ")
+}
+
+func isOrdinal(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	digits := strings.TrimRightFunc(s, unicode.IsLetter)
+	if digits == "" || strings.TrimFunc(digits, unicode.IsDigit) != "" {
+		return false
+	}
+	switch s[len(digits):] {
+	case "st", "nd", "rd", "th":
+		return true
+	}
+	return false
+}
+
+// similarity returns a 0..1 Levenshtein ratio
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
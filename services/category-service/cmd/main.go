@@ -81,17 +81,27 @@ func main() {
 		db,
 		redisClient,
 		cfg.JWTSecret,
+		cfg.TokenKeysURL(),
+		cfg.TokenVersionURL,
+		cfg.TokenVersionFailOpen,
+		cfg.BookServiceURL,
 		log,
 	)
 	if err != nil {
 		log.Fatal("Failed to initialize category service module", zap.Error(err))
 	}
+	defer categoryModule.Close()
 
 	router := mux.NewRouter()
 
+	trustedProxies, err := middleware.NewTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
 	requestLogger := middleware.NewRequestLogger(log)
 	recoveryMiddleware := middleware.NewRecoveryMiddleware(log)
-	router.Use(recoveryMiddleware.Middleware, requestLogger.Middleware)
+	router.Use(trustedProxies.Middleware, recoveryMiddleware.Middleware, requestLogger.Middleware)
 
 	router.HandleFunc("/health", categoryModule.HealthHandler.HandleHealth).Methods("GET")
 
@@ -100,6 +110,7 @@ func main() {
 		router,
 		categoryModule.CategoryHandler,
 		categoryModule.JWTAuth,
+		categoryModule.Policy,
 		log,
 	)
 
@@ -115,10 +126,12 @@ func main() {
 		grpc.ChainUnaryInterceptor(
 			middleware.UnaryRecoveryInterceptor(log),
 			middleware.UnaryLoggingInterceptor(log),
+			categoryModule.Policy.UnaryInterceptor(categoryModule.JWTAuth, routes.GRPCAccess()),
 		),
 		grpc.ChainStreamInterceptor(
 			middleware.StreamRecoveryInterceptor(log),
 			middleware.StreamLoggingInterceptor(log),
+			categoryModule.Policy.StreamInterceptor(categoryModule.JWTAuth, routes.GRPCAccess()),
 		),
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			MaxConnectionIdle:     15 * time.Minute,
@@ -8,29 +8,136 @@ import (
 )
 
 type CategoryResponse struct {
-	ID          uuid.UUID  `json:"id"`
-	Name        string     `json:"name"`
-	Description string     `json:"description"`
-	ParentID    *uuid.UUID `json:"parent_id,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID                   uuid.UUID  `json:"id"`
+	Name                 string     `json:"name"`
+	Slug                 string     `json:"slug"`
+	Description          string     `json:"description"`
+	ParentID             *uuid.UUID `json:"parent_id,omitempty"`
+	ClassificationScheme string     `json:"classification_scheme,omitempty"`
+	ClassNumber          string     `json:"class_number,omitempty"`
+	BookCount            *int64     `json:"book_count,omitempty"`
+	TotalBookCount       *int64     `json:"total_book_count,omitempty"`
+	ChildCount           *int64     `json:"child_count,omitempty"`
+	TotalChildCount      *int64     `json:"total_child_count,omitempty"`
+	// Locale is the locale of Name and Description, the default when no translation exists
+	Locale       string                                 `json:"locale,omitempty"`
+	Translations map[string]CategoryTranslationResponse `json:"translations,omitempty"`
+	CreatedAt    time.Time                              `json:"created_at"`
+	UpdatedAt    time.Time                              `json:"updated_at"`
 }
 
 func NewCategoryResponse(category *model.Category) *CategoryResponse {
 	return &CategoryResponse{
-		ID:          category.ID,
-		Name:        category.Name,
-		Description: category.Description,
-		ParentID:    category.ParentID,
-		CreatedAt:   category.CreatedAt,
-		UpdatedAt:   category.UpdatedAt,
+		ID:                   category.ID,
+		Name:                 category.Name,
+		Slug:                 category.Slug,
+		Description:          category.Description,
+		ParentID:             category.ParentID,
+		ClassificationScheme: category.ClassificationScheme,
+		ClassNumber:          category.ClassNumber,
+		CreatedAt:            category.CreatedAt,
+		UpdatedAt:            category.UpdatedAt,
 	}
 }
 
+// ClassificationImportError describes a row of an imported classification file that was not applied
+type ClassificationImportError struct {
+	Row         int    `json:"row"`
+	ClassNumber string `json:"class_number,omitempty"`
+	Error       string `json:"error"`
+}
+
+type ClassificationImportResponse struct {
+	Scheme  string                      `json:"scheme"`
+	Created int                         `json:"created"`
+	Updated int                         `json:"updated"`
+	Skipped int                         `json:"skipped"`
+	Errors  []ClassificationImportError `json:"errors"`
+}
+
+type CategoryTranslationResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type CategoryBatchResponse struct {
+	Categories []CategoryResponse `json:"categories"`
+	MissingIDs []string           `json:"missing_ids"`
+}
+
+type CategoryPathResponse struct {
+	Path  []CategoryResponse `json:"path"`
+	Depth int                `json:"depth"`
+}
+
+type CategoryTreeNode struct {
+	CategoryResponse
+	Depth    int                 `json:"depth"`
+	Children []*CategoryTreeNode `json:"children"`
+}
+
+type CategoryTreeResponse struct {
+	Categories []*CategoryTreeNode `json:"categories"`
+	TotalItems int                 `json:"total_items"`
+}
+
+// Nodes returns the category of every node in the tree, parents before children
+func (t *CategoryTreeResponse) Nodes() []*CategoryResponse {
+	nodes := make([]*CategoryResponse, 0, t.TotalItems)
+
+	var walk func([]*CategoryTreeNode)
+	walk = func(level []*CategoryTreeNode) {
+		for _, node := range level {
+			nodes = append(nodes, &node.CategoryResponse)
+			walk(node.Children)
+		}
+	}
+	walk(t.Categories)
+
+	return nodes
+}
+
+// NewCategoryTreeResponse nests categories under their parents. The input must be ordered by depth so parents
+// are seen before their children; categories whose parent is not in the set become top-level nodes.
+func NewCategoryTreeResponse(categories []*model.Category) *CategoryTreeResponse {
+	response := &CategoryTreeResponse{
+		Categories: make([]*CategoryTreeNode, 0),
+		TotalItems: len(categories),
+	}
+
+	nodes := make(map[uuid.UUID]*CategoryTreeNode, len(categories))
+	for _, category := range categories {
+		node := &CategoryTreeNode{
+			CategoryResponse: *NewCategoryResponse(category),
+			Depth:            category.Depth,
+			Children:         make([]*CategoryTreeNode, 0),
+		}
+		nodes[category.ID] = node
+
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		response.Categories = append(response.Categories, node)
+	}
+
+	return response
+}
+
+type CategoryExistsResponse struct {
+	Exists  bool   `json:"exists"`
+	Deleted bool   `json:"deleted"`
+	Name    string `json:"name,omitempty"`
+}
+
 type CategoryListResponse struct {
 	Categories  []CategoryResponse `json:"categories"`
 	TotalItems  int64              `json:"total_items"`
 	TotalPages  int                `json:"total_pages"`
 	CurrentPage int                `json:"current_page"`
 	PageSize    int                `json:"page_size"`
+	NextCursor  string             `json:"next_cursor,omitempty"`
+	PrevCursor  string             `json:"prev_cursor,omitempty"`
 }
@@ -4,16 +4,48 @@ import (
 	"github.com/fairuzald/library-system/pkg/constants"
 )
 
+// CategoryTranslationInput is a category's name and description in one non-default locale
+type CategoryTranslationInput struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description"`
+}
+
 type CategoryCreate struct {
 	Name        string  `json:"name" validate:"required"`
 	Description string  `json:"description"`
 	ParentID    *string `json:"parent_id,omitempty"`
+	// Slug is generated from Name when omitted
+	Slug         string                              `json:"slug,omitempty" validate:"omitempty,max=120"`
+	Translations map[string]CategoryTranslationInput `json:"translations,omitempty" validate:"omitempty,dive"`
+	// ClassificationScheme is detected from ClassNumber when omitted
+	ClassificationScheme string `json:"classification_scheme,omitempty" validate:"omitempty,oneof=ddc lcc"`
+	ClassNumber          string `json:"class_number,omitempty" validate:"omitempty,max=50"`
 }
 
 type CategoryUpdate struct {
 	Name        *string `json:"name,omitempty"`
 	Description *string `json:"description,omitempty"`
 	ParentID    *string `json:"parent_id,omitempty"`
+	// Slugs stay stable across renames; an empty Slug regenerates it from the name
+	Slug *string `json:"slug,omitempty" validate:"omitempty,max=120"`
+	// Translations are merged by locale; a null entry removes that locale
+	Translations map[string]*CategoryTranslationInput `json:"translations,omitempty" validate:"omitempty,dive"`
+	// An empty ClassNumber clears the classification
+	ClassificationScheme *string `json:"classification_scheme,omitempty" validate:"omitempty,oneof=ddc lcc"`
+	ClassNumber          *string `json:"class_number,omitempty" validate:"omitempty,max=50"`
+}
+
+type CategoryMove struct {
+	ParentID *string `json:"parent_id"`
+}
+
+type CategoryMerge struct {
+	TargetID string `json:"target_id" validate:"required"`
+}
+
+type CategoryDelete struct {
+	Force      bool
+	ReassignTo *string
 }
 
 type CategoryFilter struct {
@@ -22,6 +54,11 @@ type CategoryFilter struct {
 	SortBy   string  `form:"sort_by,default=name" query:"sort_by,default=name"`
 	Desc     bool    `form:"desc" query:"desc"`
 	ParentID *string `form:"parent_id" query:"parent_id"`
+	Filter   string  `form:"filter" query:"filter"`
+	Cursor   string  `form:"cursor" query:"cursor"`
+
+	IncludeBookCount  bool `form:"include_book_count" query:"include_book_count"`
+	IncludeChildCount bool `form:"include_child_count" query:"include_child_count"`
 }
 
 func (f *CategoryFilter) Validate() {
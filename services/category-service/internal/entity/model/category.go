@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"github.com/fairuzald/library-system/pkg/models"
@@ -10,16 +11,42 @@ import (
 type Category struct {
 	models.Base
 	Name        string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Slug        string     `gorm:"type:varchar(120);uniqueIndex;not null" json:"slug"`
 	Description string     `gorm:"type:text" json:"description"`
 	ParentID    *uuid.UUID `gorm:"type:uuid" json:"parent_id,omitempty"`
+	Path        string     `gorm:"type:text;not null;index" json:"path"`
+	Depth       int        `gorm:"not null;default:0" json:"depth"`
+	// ClassificationScheme and ClassNumber tie the category to a class in a standard scheme such as DDC
+	ClassificationScheme string `gorm:"type:varchar(10)" json:"classification_scheme,omitempty"`
+	ClassNumber          string `gorm:"type:varchar(50)" json:"class_number,omitempty"`
 }
 
 func (Category) TableName() string {
 	return "categories"
 }
 
-func NewCategory(name, description string, parentID *uuid.UUID) *Category {
-	return &Category{
+// CategoryTranslation holds a category's name and description in a locale other than the default.
+// The name and description on Category itself are in constants.DefaultLocale.
+type CategoryTranslation struct {
+	models.Base
+	CategoryID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_category_translations_category_locale" json:"category_id"`
+	Locale      string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_category_translations_category_locale" json:"locale"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+}
+
+func (CategoryTranslation) TableName() string {
+	return "category_translations"
+}
+
+// CategoryCount holds a count for a category's direct members and for its whole subtree
+type CategoryCount struct {
+	Direct int64 `json:"direct"`
+	Total  int64 `json:"total"`
+}
+
+func NewCategory(name, description string, parent *Category) *Category {
+	category := &Category{
 		Base: models.Base{
 			ID:        uuid.New(),
 			CreatedAt: time.Now(),
@@ -27,6 +54,27 @@ func NewCategory(name, description string, parentID *uuid.UUID) *Category {
 		},
 		Name:        name,
 		Description: description,
-		ParentID:    parentID,
 	}
+	category.SetParent(parent)
+
+	return category
+}
+
+// SetParent re-homes the category, recomputing its materialized path and depth. A nil parent makes it a root.
+func (c *Category) SetParent(parent *Category) {
+	if parent == nil {
+		c.ParentID = nil
+		c.Path = "/" + c.ID.String() + "/"
+		c.Depth = 0
+		return
+	}
+
+	c.ParentID = &parent.ID
+	c.Path = parent.Path + c.ID.String() + "/"
+	c.Depth = parent.Depth + 1
+}
+
+// IsAncestorOf reports whether other sits somewhere below c in the tree
+func (c *Category) IsAncestorOf(other *Category) bool {
+	return other.ID != c.ID && strings.HasPrefix(other.Path, c.Path)
 }
@@ -2,12 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
-	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/category-service/internal/service"
@@ -28,20 +29,7 @@ func NewCategoryHandler(categoryService service.CategoryService, log *logger.Log
 	}
 }
 
-func (h *CategoryHandler) isAdminOrLibrarian(r *http.Request) bool {
-	role, ok := r.Context().Value(middleware.UserRoleKey).(string)
-	if !ok {
-		return false
-	}
-	return role == constants.RoleAdmin || role == constants.RoleLibrarian
-}
-
 func (h *CategoryHandler) HandleCreateCategory(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdminOrLibrarian(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
-		return
-	}
-
 	var req dto.CategoryCreate
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.log.Error("Failed to decode request body", zap.Error(err))
@@ -117,12 +105,25 @@ func (h *CategoryHandler) HandleGetCategoryByName(w http.ResponseWriter, r *http
 	utils.RespondWithSuccess(w, http.StatusOK, "Category retrieved successfully", category)
 }
 
-func (h *CategoryHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdminOrLibrarian(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
+func (h *CategoryHandler) HandleGetCategoryBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	category, err := h.categoryService.GetCategoryBySlug(r.Context(), slug)
+	if err != nil {
+		if err.Error() == constants.ErrCategoryNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrCategoryNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to get category by slug", zap.Error(err), zap.String("slug", slug))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
 		return
 	}
 
+	utils.RespondWithSuccess(w, http.StatusOK, "Category retrieved successfully", category)
+}
+
+func (h *CategoryHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
@@ -166,11 +167,6 @@ func (h *CategoryHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Re
 }
 
 func (h *CategoryHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdminOrLibrarian(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
-		return
-	}
-
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
@@ -180,12 +176,25 @@ func (h *CategoryHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if err := h.categoryService.DeleteCategory(r.Context(), id); err != nil {
+	req := &dto.CategoryDelete{
+		Force: r.URL.Query().Get("force") == "true",
+	}
+
+	if reassignTo := r.URL.Query().Get("reassign_to"); reassignTo != "" {
+		req.ReassignTo = &reassignTo
+	}
+
+	if err := h.categoryService.DeleteCategory(r.Context(), id, req); err != nil {
 		if err.Error() == constants.ErrCategoryNotFound {
 			utils.RespondWithError(w, http.StatusNotFound, constants.ErrCategoryNotFound, nil)
 			return
 		}
 
+		if err.Error() == constants.ErrBookServiceUnavailable {
+			utils.RespondWithError(w, http.StatusServiceUnavailable, constants.ErrBookServiceUnavailable, nil)
+			return
+		}
+
 		h.log.Error("Failed to delete category", zap.Error(err), zap.String("id", id.String()))
 
 		if err.Error() == constants.ErrInternalServer {
@@ -202,6 +211,12 @@ func (h *CategoryHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Re
 func (h *CategoryHandler) HandleListCategories(w http.ResponseWriter, r *http.Request) {
 	filter := &dto.CategoryFilter{
 		SortBy: r.URL.Query().Get("sort_by"),
+		Filter: r.URL.Query().Get("filter"),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		filter.SortBy = sort
 	}
 
 	if page := r.URL.Query().Get("page"); page != "" {
@@ -224,8 +239,31 @@ func (h *CategoryHandler) HandleListCategories(w http.ResponseWriter, r *http.Re
 		filter.ParentID = &parentID
 	}
 
+	if includeBookCount := r.URL.Query().Get("include_book_count"); includeBookCount == "true" {
+		filter.IncludeBookCount = true
+	}
+
+	if includeChildCount := r.URL.Query().Get("include_child_count"); includeChildCount == "true" {
+		filter.IncludeChildCount = true
+	}
+
 	categories, err := h.categoryService.ListCategories(r.Context(), filter)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidCursor, nil)
+			return
+		}
+
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidFilter) {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidFilter, err)
+			return
+		}
+
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidSort) {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidSort, err)
+			return
+		}
+
 		h.log.Error("Failed to list categories", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
 		return
@@ -258,3 +296,210 @@ func (h *CategoryHandler) HandleGetCategoryChildren(w http.ResponseWriter, r *ht
 
 	utils.RespondWithSuccess(w, http.StatusOK, "Category children retrieved successfully", children)
 }
+
+func (h *CategoryHandler) HandleGetCategoryPath(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid category ID", err)
+		return
+	}
+
+	path, err := h.categoryService.GetCategoryPath(r.Context(), id)
+	if err != nil {
+		if err.Error() == constants.ErrCategoryNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrCategoryNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to get category path", zap.Error(err), zap.String("id", id.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Category path retrieved successfully", path)
+}
+
+func (h *CategoryHandler) HandleCheckCategoryExists(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid category ID", err)
+		return
+	}
+
+	exists, err := h.categoryService.CheckCategoryExists(r.Context(), id)
+	if err != nil {
+		h.log.Error("Failed to check category existence", zap.Error(err), zap.String("id", id.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Category existence checked successfully", exists)
+}
+
+func (h *CategoryHandler) HandleGetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	var rootID *uuid.UUID
+	if root := r.URL.Query().Get("root_id"); root != "" {
+		id, err := uuid.Parse(root)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid root category ID", err)
+			return
+		}
+		rootID = &id
+	}
+
+	// max_depth counts levels from the top of the tree, which is root_id when given and the top-level categories
+	// otherwise: 1 returns just that level, 2 adds their children, and 0 or no value returns every level
+	maxDepth := 0
+	if depth := r.URL.Query().Get("max_depth"); depth != "" {
+		depthNum, err := strconv.Atoi(depth)
+		if err != nil || depthNum < 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid max_depth parameter", err)
+			return
+		}
+		maxDepth = depthNum
+	}
+
+	tree, err := h.categoryService.GetCategoryTree(r.Context(), rootID, maxDepth)
+	if err != nil {
+		if err.Error() == constants.ErrCategoryNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrCategoryNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to get category tree", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Category tree retrieved successfully", tree)
+}
+
+// maxClassificationFileSize bounds the body of a classification import
+const maxClassificationFileSize = 10 << 20
+
+// HandleImportClassification accepts a classification CSV either as the raw request body or as the "file" field
+// of a multipart form
+func (h *CategoryHandler) HandleImportClassification(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxClassificationFileSize)
+
+	var file io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxClassificationFileSize); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidClassificationFile, err)
+			return
+		}
+
+		upload, _, err := r.FormFile("file")
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidClassificationFile, err)
+			return
+		}
+		defer upload.Close()
+		file = upload
+	}
+
+	result, err := h.categoryService.ImportClassification(r.Context(), r.URL.Query().Get("scheme"), file)
+	if err != nil {
+		h.log.Error("Failed to import classification", zap.Error(err))
+
+		if err.Error() == constants.ErrInternalServer {
+			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		} else {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Classification imported successfully", result)
+}
+
+func (h *CategoryHandler) HandleMoveCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid category ID", err)
+		return
+	}
+
+	var req dto.CategoryMove
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	category, err := h.categoryService.MoveCategory(r.Context(), id, &req)
+	if err != nil {
+		if err.Error() == constants.ErrCategoryNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrCategoryNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to move category", zap.Error(err), zap.String("id", id.String()))
+
+		if err.Error() == constants.ErrInternalServer {
+			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		} else {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Category moved successfully", category)
+}
+
+func (h *CategoryHandler) HandleMergeCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid category ID", err)
+		return
+	}
+
+	var req dto.CategoryMerge
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for merge category request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	category, err := h.categoryService.MergeCategory(r.Context(), id, &req)
+	if err != nil {
+		if err.Error() == constants.ErrCategoryNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrCategoryNotFound, nil)
+			return
+		}
+
+		if err.Error() == constants.ErrBookServiceUnavailable {
+			utils.RespondWithError(w, http.StatusServiceUnavailable, constants.ErrBookServiceUnavailable, nil)
+			return
+		}
+
+		h.log.Error("Failed to merge category", zap.Error(err), zap.String("id", id.String()))
+
+		if err.Error() == constants.ErrInternalServer {
+			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, nil)
+		} else {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Category merged successfully", category)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/proto/category"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dto"
@@ -51,6 +52,28 @@ func (h *CategoryGRPCHandler) GetCategory(ctx context.Context, req *category.Get
 	}, nil
 }
 
+func (h *CategoryGRPCHandler) BatchGetCategories(ctx context.Context, req *category.BatchGetCategoriesRequest) (*category.BatchGetCategoriesResponse, error) {
+	response, err := h.categoryService.BatchGetCategories(ctx, req.GetIds())
+	if err != nil {
+		if err.Error() == constants.ErrBatchTooLarge {
+			return nil, status.Error(codes.InvalidArgument, constants.ErrBatchTooLarge)
+		}
+		h.log.Error("Failed to batch get categories", zap.Error(err))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	protoResponse := &category.BatchGetCategoriesResponse{
+		Categories: make([]*category.Category, 0, len(response.Categories)),
+		MissingIds: response.MissingIDs,
+	}
+
+	for _, c := range response.Categories {
+		protoResponse.Categories = append(protoResponse.Categories, convertCategoryResponseToProtoCategory(&c))
+	}
+
+	return protoResponse, nil
+}
+
 func (h *CategoryGRPCHandler) GetCategoryByName(ctx context.Context, req *category.GetCategoryByNameRequest) (*category.CategoryResponse, error) {
 	name := req.GetName()
 	if name == "" {
@@ -71,12 +94,46 @@ func (h *CategoryGRPCHandler) GetCategoryByName(ctx context.Context, req *catego
 	}, nil
 }
 
+func (h *CategoryGRPCHandler) GetCategoryBySlug(ctx context.Context, req *category.GetCategoryBySlugRequest) (*category.CategoryResponse, error) {
+	if req.GetSlug() == "" {
+		return nil, status.Error(codes.InvalidArgument, "slug is required")
+	}
+
+	ctx, err := withRequestLocale(ctx, req.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryResponse, err := h.categoryService.GetCategoryBySlug(ctx, req.GetSlug())
+	if err != nil {
+		if err.Error() == constants.ErrCategoryNotFound {
+			return nil, status.Error(codes.NotFound, constants.ErrCategoryNotFound)
+		}
+		h.log.Error("Failed to get category by slug", zap.Error(err), zap.String("slug", req.GetSlug()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return &category.CategoryResponse{
+		Category: convertDaoCategoryToProtoCategory(categoryResponse),
+	}, nil
+}
+
 func (h *CategoryGRPCHandler) ListCategories(ctx context.Context, req *category.ListCategoriesRequest) (*category.ListCategoriesResponse, error) {
+	ctx, err := withRequestLocale(ctx, req.Locale)
+	if err != nil {
+		return nil, err
+	}
+
 	filter := &dto.CategoryFilter{
 		Page:   int(req.GetPage()),
 		Limit:  int(req.GetPageSize()),
 		SortBy: req.GetSortBy(),
 		Desc:   req.GetSortDesc(),
+		Filter: req.GetFilter(),
+		Cursor: req.GetCursor(),
+
+		IncludeBookCount:  req.GetIncludeBookCount(),
+		IncludeChildCount: req.GetIncludeChildCount(),
 	}
 
 	if req.ParentId != nil {
@@ -86,6 +143,12 @@ func (h *CategoryGRPCHandler) ListCategories(ctx context.Context, req *category.
 
 	response, err := h.categoryService.ListCategories(ctx, filter)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			return nil, status.Error(codes.InvalidArgument, constants.ErrInvalidCursor)
+		}
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidFilter) || strings.HasPrefix(err.Error(), constants.ErrInvalidSort) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		h.log.Error("Failed to list categories", zap.Error(err))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -96,6 +159,8 @@ func (h *CategoryGRPCHandler) ListCategories(ctx context.Context, req *category.
 		TotalPages:  int32(response.TotalPages),
 		CurrentPage: int32(response.CurrentPage),
 		PageSize:    int32(response.PageSize),
+		NextCursor:  response.NextCursor,
+		PrevCursor:  response.PrevCursor,
 	}
 
 	for _, c := range response.Categories {
@@ -116,14 +181,35 @@ func (h *CategoryGRPCHandler) CreateCategory(ctx context.Context, req *category.
 		createDTO.ParentID = &parentID
 	}
 
+	createDTO.ClassificationScheme = req.GetClassificationScheme()
+	createDTO.ClassNumber = req.GetClassNumber()
+	createDTO.Slug = req.GetSlug()
+
+	if len(req.GetTranslations()) > 0 {
+		createDTO.Translations = make(map[string]dto.CategoryTranslationInput, len(req.GetTranslations()))
+		for locale, translation := range req.GetTranslations() {
+			createDTO.Translations[locale] = dto.CategoryTranslationInput{
+				Name:        translation.GetName(),
+				Description: translation.GetDescription(),
+			}
+		}
+	}
+
 	categoryResponse, err := h.categoryService.CreateCategory(ctx, createDTO)
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
+		if strings.Contains(err.Error(), "already exists") || err.Error() == constants.ErrClassNumberTaken || err.Error() == constants.ErrSlugTaken {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
 		if strings.Contains(err.Error(), "invalid parent ID") || strings.Contains(err.Error(), "parent category not found") {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidClassNumber) || err.Error() == constants.ErrUnknownScheme {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err.Error() == constants.ErrInvalidSlug || strings.HasPrefix(err.Error(), constants.ErrUnsupportedLocale) ||
+			strings.HasPrefix(err.Error(), "translation name is required") {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		h.log.Error("Failed to create category", zap.Error(err))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -156,17 +242,52 @@ func (h *CategoryGRPCHandler) UpdateCategory(ctx context.Context, req *category.
 		updateDTO.ParentID = &parentID
 	}
 
+	if req.ClassificationScheme != nil {
+		scheme := req.GetClassificationScheme()
+		updateDTO.ClassificationScheme = &scheme
+	}
+
+	if req.ClassNumber != nil {
+		classNumber := req.GetClassNumber()
+		updateDTO.ClassNumber = &classNumber
+	}
+
+	if req.Slug != nil {
+		slug := req.GetSlug()
+		updateDTO.Slug = &slug
+	}
+
+	if len(req.GetTranslations()) > 0 || len(req.GetRemoveTranslations()) > 0 {
+		updateDTO.Translations = make(map[string]*dto.CategoryTranslationInput)
+		for _, locale := range req.GetRemoveTranslations() {
+			updateDTO.Translations[locale] = nil
+		}
+		for locale, translation := range req.GetTranslations() {
+			updateDTO.Translations[locale] = &dto.CategoryTranslationInput{
+				Name:        translation.GetName(),
+				Description: translation.GetDescription(),
+			}
+		}
+	}
+
 	categoryResponse, err := h.categoryService.UpdateCategory(ctx, id, updateDTO)
 	if err != nil {
 		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
 			return nil, status.Error(codes.NotFound, constants.ErrCategoryNotFound)
 		}
-		if strings.Contains(err.Error(), "already exists") {
+		if strings.Contains(err.Error(), "already exists") || err.Error() == constants.ErrClassNumberTaken || err.Error() == constants.ErrSlugTaken {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
 		if strings.Contains(err.Error(), "invalid parent ID") || strings.Contains(err.Error(), "parent category not found") {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidClassNumber) || err.Error() == constants.ErrUnknownScheme {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err.Error() == constants.ErrInvalidSlug || strings.HasPrefix(err.Error(), constants.ErrUnsupportedLocale) ||
+			strings.HasPrefix(err.Error(), "translation name is required") {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		if strings.Contains(err.Error(), "cannot be its own parent") || strings.Contains(err.Error(), "create a category cycle") {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
@@ -185,13 +306,28 @@ func (h *CategoryGRPCHandler) DeleteCategory(ctx context.Context, req *category.
 		return nil, status.Error(codes.InvalidArgument, "invalid category ID")
 	}
 
-	if err := h.categoryService.DeleteCategory(ctx, id); err != nil {
+	deleteDTO := &dto.CategoryDelete{
+		Force: req.GetForce(),
+	}
+
+	if req.ReassignTo != nil {
+		reassignTo := req.GetReassignTo()
+		deleteDTO.ReassignTo = &reassignTo
+	}
+
+	if err := h.categoryService.DeleteCategory(ctx, id, deleteDTO); err != nil {
 		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
 			return nil, status.Error(codes.NotFound, constants.ErrCategoryNotFound)
 		}
 		if strings.Contains(err.Error(), "child categories") || strings.Contains(err.Error(), "associated books") {
 			return nil, status.Error(codes.FailedPrecondition, err.Error())
 		}
+		if err.Error() == constants.ErrBookServiceUnavailable {
+			return nil, status.Error(codes.Unavailable, constants.ErrBookServiceUnavailable)
+		}
+		if strings.Contains(err.Error(), "reassignment target") {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		h.log.Error("Failed to delete category", zap.Error(err), zap.String("id", id.String()))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -199,6 +335,65 @@ func (h *CategoryGRPCHandler) DeleteCategory(ctx context.Context, req *category.
 	return &emptypb.Empty{}, nil
 }
 
+func (h *CategoryGRPCHandler) MoveCategory(ctx context.Context, req *category.MoveCategoryRequest) (*category.CategoryResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid category ID")
+	}
+
+	moveDTO := &dto.CategoryMove{}
+
+	if req.ParentId != nil {
+		parentID := req.GetParentId()
+		moveDTO.ParentID = &parentID
+	}
+
+	categoryResponse, err := h.categoryService.MoveCategory(ctx, id, moveDTO)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return nil, status.Error(codes.NotFound, constants.ErrCategoryNotFound)
+		}
+		if strings.Contains(err.Error(), "invalid parent ID") || strings.Contains(err.Error(), "parent category not found") {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if strings.Contains(err.Error(), "cannot be its own parent") || strings.Contains(err.Error(), "create a category cycle") {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		h.log.Error("Failed to move category", zap.Error(err), zap.String("id", id.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return &category.CategoryResponse{
+		Category: convertDaoCategoryToProtoCategory(categoryResponse),
+	}, nil
+}
+
+func (h *CategoryGRPCHandler) MergeCategory(ctx context.Context, req *category.MergeCategoryRequest) (*category.CategoryResponse, error) {
+	sourceID, err := uuid.Parse(req.GetSourceId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid source category ID")
+	}
+
+	categoryResponse, err := h.categoryService.MergeCategory(ctx, sourceID, &dto.CategoryMerge{TargetID: req.GetTargetId()})
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), constants.ErrCategoryNotFound):
+			return nil, status.Error(codes.NotFound, constants.ErrCategoryNotFound)
+		case err.Error() == constants.ErrBookServiceUnavailable:
+			return nil, status.Error(codes.Unavailable, constants.ErrBookServiceUnavailable)
+		case err.Error() == constants.ErrMergeSameCategory, err.Error() == constants.ErrMergeIntoDescendant,
+			strings.Contains(err.Error(), "invalid target ID"):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		h.log.Error("Failed to merge category", zap.Error(err), zap.String("source_id", sourceID.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return &category.CategoryResponse{
+		Category: convertDaoCategoryToProtoCategory(categoryResponse),
+	}, nil
+}
+
 func (h *CategoryGRPCHandler) GetCategoryChildren(ctx context.Context, req *category.GetCategoryChildrenRequest) (*category.ListCategoriesResponse, error) {
 	parentID, err := uuid.Parse(req.GetParentId())
 	if err != nil {
@@ -220,6 +415,8 @@ func (h *CategoryGRPCHandler) GetCategoryChildren(ctx context.Context, req *cate
 		TotalPages:  int32(response.TotalPages),
 		CurrentPage: int32(response.CurrentPage),
 		PageSize:    int32(response.PageSize),
+		NextCursor:  response.NextCursor,
+		PrevCursor:  response.PrevCursor,
 	}
 
 	for _, c := range response.Categories {
@@ -229,6 +426,83 @@ func (h *CategoryGRPCHandler) GetCategoryChildren(ctx context.Context, req *cate
 	return protoResponse, nil
 }
 
+func (h *CategoryGRPCHandler) GetCategoryPath(ctx context.Context, req *category.GetCategoryPathRequest) (*category.CategoryPathResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid category ID")
+	}
+
+	response, err := h.categoryService.GetCategoryPath(ctx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return nil, status.Error(codes.NotFound, constants.ErrCategoryNotFound)
+		}
+		h.log.Error("Failed to get category path", zap.Error(err), zap.String("id", id.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	protoResponse := &category.CategoryPathResponse{
+		Path:  make([]*category.Category, 0, len(response.Path)),
+		Depth: int32(response.Depth),
+	}
+
+	for _, c := range response.Path {
+		protoResponse.Path = append(protoResponse.Path, convertCategoryResponseToProtoCategory(&c))
+	}
+
+	return protoResponse, nil
+}
+
+func (h *CategoryGRPCHandler) GetCategoryDescendants(ctx context.Context, req *category.GetCategoryDescendantsRequest) (*category.CategoryDescendantsResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid category ID")
+	}
+
+	ids, err := h.categoryService.GetCategoryDescendantIDs(ctx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return nil, status.Error(codes.NotFound, constants.ErrCategoryNotFound)
+		}
+		h.log.Error("Failed to get category descendants", zap.Error(err), zap.String("id", id.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	protoResponse := &category.CategoryDescendantsResponse{
+		Ids: make([]string, 0, len(ids)),
+	}
+
+	for _, descendantID := range ids {
+		protoResponse.Ids = append(protoResponse.Ids, descendantID.String())
+	}
+
+	return protoResponse, nil
+}
+
+func (h *CategoryGRPCHandler) CheckCategoryExists(ctx context.Context, req *category.CheckCategoryExistsRequest) (*category.CategoryExistsResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid category ID")
+	}
+
+	response, err := h.categoryService.CheckCategoryExists(ctx, id)
+	if err != nil {
+		h.log.Error("Failed to check category existence", zap.Error(err), zap.String("id", id.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	protoResponse := &category.CategoryExistsResponse{
+		Exists:  response.Exists,
+		Deleted: response.Deleted,
+	}
+
+	if response.Name != "" {
+		protoResponse.Name = &response.Name
+	}
+
+	return protoResponse, nil
+}
+
 func (h *CategoryGRPCHandler) Health(ctx context.Context, _ *emptypb.Empty) (*category.HealthResponse, error) {
 	return &category.HealthResponse{
 		Status:  "ok",
@@ -236,10 +510,24 @@ func (h *CategoryGRPCHandler) Health(ctx context.Context, _ *emptypb.Empty) (*ca
 	}, nil
 }
 
+// withRequestLocale sets the locale requested over gRPC on the context, rejecting unsupported locales
+func withRequestLocale(ctx context.Context, locale *string) (context.Context, error) {
+	if locale == nil || *locale == "" {
+		return ctx, nil
+	}
+
+	if middleware.NormalizeLocale(*locale) == "" {
+		return nil, status.Error(codes.InvalidArgument, constants.ErrUnsupportedLocale)
+	}
+
+	return middleware.WithLocale(ctx, *locale), nil
+}
+
 func convertCategoryResponseToProtoCategory(c *dao.CategoryResponse) *category.Category {
 	protoCategory := &category.Category{
 		Id:          c.ID.String(),
 		Name:        c.Name,
+		Slug:        c.Slug,
 		Description: c.Description,
 		CreatedAt:   timestamppb.New(c.CreatedAt),
 		UpdatedAt:   timestamppb.New(c.UpdatedAt),
@@ -250,6 +538,42 @@ func convertCategoryResponseToProtoCategory(c *dao.CategoryResponse) *category.C
 		protoCategory.ParentId = &parentID
 	}
 
+	if c.Locale != "" {
+		locale := c.Locale
+		protoCategory.Locale = &locale
+	}
+
+	if len(c.Translations) > 0 {
+		protoCategory.Translations = make(map[string]*category.CategoryTranslation, len(c.Translations))
+		for locale, translation := range c.Translations {
+			protoCategory.Translations[locale] = &category.CategoryTranslation{
+				Name:        translation.Name,
+				Description: translation.Description,
+			}
+		}
+	}
+
+	if c.ClassNumber != "" {
+		scheme := c.ClassificationScheme
+		classNumber := c.ClassNumber
+		protoCategory.ClassificationScheme = &scheme
+		protoCategory.ClassNumber = &classNumber
+	}
+
+	if c.BookCount != nil {
+		bookCount := int32(*c.BookCount)
+		totalBookCount := int32(*c.TotalBookCount)
+		protoCategory.BookCount = &bookCount
+		protoCategory.TotalBookCount = &totalBookCount
+	}
+
+	if c.ChildCount != nil {
+		childCount := int32(*c.ChildCount)
+		totalChildCount := int32(*c.TotalChildCount)
+		protoCategory.ChildCount = &childCount
+		protoCategory.TotalChildCount = &totalChildCount
+	}
+
 	return protoCategory
 }
 
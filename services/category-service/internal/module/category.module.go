@@ -4,6 +4,7 @@ import (
 	"database/sql"
 
 	"github.com/fairuzald/library-system/pkg/cache"
+	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
 	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/proto/category"
@@ -23,6 +24,9 @@ type Module struct {
 	Redis *cache.Redis
 
 	JWTAuth *middleware.JWTAuth
+	Policy  *middleware.Policy
+
+	BookClient service.BookClient
 
 	CategoryRepo    repository.CategoryRepository
 	CategoryService service.CategoryService
@@ -38,6 +42,10 @@ func New(
 	db *sql.DB,
 	redis *cache.Redis,
 	jwtSecret string,
+	jwksURL string,
+	tokenVersionURL string,
+	tokenVersionFailOpen bool,
+	bookServiceURL string,
 	log *logger.Logger,
 ) (*Module, error) {
 	m := &Module{
@@ -56,9 +64,25 @@ func New(
 	}
 
 	m.JWTAuth = middleware.NewJWTAuth(jwtSecret, 0) // JWT duration not needed for this service
+	if jwksURL != "" {
+		m.JWTAuth.WithKeyResolver(middleware.NewRemoteJWKS(jwksURL, constants.JWKSCacheTTL))
+	}
+	var versionSource middleware.TokenVersionStore
+	if tokenVersionURL != "" {
+		versionSource = middleware.NewRemoteTokenVersions(tokenVersionURL)
+	}
+	if versions := middleware.TokenVersions(redis, versionSource); versions != nil {
+		m.JWTAuth.WithTokenVersions(versions, tokenVersionFailOpen)
+	}
+	m.Policy = middleware.DefaultPolicy()
+
+	m.BookClient, err = service.NewBookClient(bookServiceURL, log)
+	if err != nil {
+		log.Warn("Failed to create book client, using mock client", zap.Error(err))
+	}
 
 	m.CategoryRepo = repository.NewCategoryRepository(m.GormDB, redis, log)
-	m.CategoryService = service.NewCategoryService(m.CategoryRepo, log)
+	m.CategoryService = service.NewCategoryService(m.CategoryRepo, m.BookClient, log)
 
 	m.CategoryHandler = handler.NewCategoryHandler(m.CategoryService, log)
 	m.HealthHandler = handler.NewHealthHandler(db, log)
@@ -70,3 +94,11 @@ func New(
 func (m *Module) RegisterGRPCHandlers(grpcServer *grpc.Server) {
 	category.RegisterCategoryServiceServer(grpcServer, m.CategoryGRPCHandler)
 }
+
+func (m *Module) Close() error {
+	var err error
+	if m.BookClient != nil {
+		err = m.BookClient.Close()
+	}
+	return err
+}
@@ -7,23 +7,56 @@ import (
 
 	"github.com/fairuzald/library-system/pkg/cache"
 	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/listquery"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/pagination"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/model"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CategoryRepository interface {
 	Create(ctx context.Context, category *model.Category) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Category, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Category, error)
+	GetByIDWithDeleted(ctx context.Context, id uuid.UUID) (*model.Category, error)
+	GetPath(ctx context.Context, id uuid.UUID) ([]*model.Category, error)
+	GetSubtree(ctx context.Context, rootID *uuid.UUID, maxDepth int) ([]*model.Category, error)
 	GetByName(ctx context.Context, name string) (*model.Category, error)
+	GetBySlug(ctx context.Context, slug string) (*model.Category, error)
+	SlugExists(ctx context.Context, slug string, excludeID uuid.UUID) (bool, error)
+	GetByClassNumber(ctx context.Context, scheme, classNumber string) (*model.Category, error)
+	ListByScheme(ctx context.Context, scheme string) ([]*model.Category, error)
 	Update(ctx context.Context, category *model.Category) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	List(ctx context.Context, filter *dto.CategoryFilter) ([]*model.Category, int64, error)
+	List(ctx context.Context, filter *dto.CategoryFilter) ([]*model.Category, int64, *pagination.Cursors, error)
 	GetChildren(ctx context.Context, parentID uuid.UUID) ([]*model.Category, error)
-	HasBooks(ctx context.Context, id uuid.UUID) (bool, error)
+	Merge(ctx context.Context, source, target *model.Category) error
+	DeleteSubtree(ctx context.Context, root *model.Category) error
+	GetDescendantIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error)
+	CountChildren(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]model.CategoryCount, error)
+	GetCachedBookCounts(ctx context.Context, ids []uuid.UUID) map[uuid.UUID]model.CategoryCount
+	CacheBookCounts(ctx context.Context, counts map[uuid.UUID]model.CategoryCount)
+	GetTranslations(ctx context.Context, id uuid.UUID) ([]*model.CategoryTranslation, error)
+	GetTranslationsForLocale(ctx context.Context, ids []uuid.UUID, locale string) (map[uuid.UUID]*model.CategoryTranslation, error)
+	SaveTranslations(ctx context.Context, categoryID uuid.UUID, upserts []*model.CategoryTranslation, removeLocales []string) error
+}
+
+// categoryListSchema whitelists the fields clients can filter and sort categories on
+var categoryListSchema = listquery.Schema{
+	"id":                    {Column: "id", Type: listquery.TypeUUID, Unsortable: true},
+	"name":                  {Column: "name", Type: listquery.TypeString},
+	"slug":                  {Column: "slug", Type: listquery.TypeString},
+	"description":           {Column: "description", Type: listquery.TypeString, Nullable: true},
+	"parent_id":             {Column: "parent_id", Type: listquery.TypeUUID, Unsortable: true},
+	"depth":                 {Column: "depth", Type: listquery.TypeInt},
+	"classification_scheme": {Column: "classification_scheme", Type: listquery.TypeString, Nullable: true},
+	"class_number":          {Column: "class_number", Type: listquery.TypeString, Nullable: true},
+	"created_at":            {Column: "created_at", Type: listquery.TypeTime},
+	"updated_at":            {Column: "updated_at", Type: listquery.TypeTime},
 }
 
 type categoryRepository struct {
@@ -51,6 +84,7 @@ func (r *categoryRepository) Create(ctx context.Context, category *model.Categor
 		cacheKey := fmt.Sprintf("%slist", constants.CacheKeyCategories)
 		_ = r.cache.Delete(ctx, cacheKey)
 	}
+	r.invalidateCounts(ctx)
 
 	return nil
 }
@@ -82,6 +116,81 @@ func (r *categoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.
 	return &category, nil
 }
 
+func (r *categoryRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Category, error) {
+	var categories []*model.Category
+
+	if len(ids) == 0 {
+		return categories, nil
+	}
+
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		r.log.Error("Failed to batch get categories", zap.Error(err), zap.Int("count", len(ids)))
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// GetByIDWithDeleted also returns soft-deleted categories so callers can tell deleted from unknown IDs
+func (r *categoryRepository) GetByIDWithDeleted(ctx context.Context, id uuid.UUID) (*model.Category, error) {
+	var category model.Category
+
+	err := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).First(&category).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrCategoryNotFound, err)
+		}
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// GetPath returns the category and its live ancestors ordered from the root down
+func (r *categoryRepository) GetPath(ctx context.Context, id uuid.UUID) ([]*model.Category, error) {
+	var categories []*model.Category
+
+	err := r.db.WithContext(ctx).
+		Where("(SELECT path FROM categories WHERE id = ? AND deleted_at IS NULL) LIKE path || '%'", id).
+		Order("depth").
+		Find(&categories).Error
+	if err != nil {
+		r.log.Error("Failed to get category path", zap.Error(err), zap.String("id", id.String()))
+		return nil, err
+	}
+
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("%s: %w", constants.ErrCategoryNotFound, gorm.ErrRecordNotFound)
+	}
+
+	return categories, nil
+}
+
+// GetSubtree returns the live categories under rootID, including the root itself, or the whole forest when
+// rootID is nil. A positive maxDepth limits the result to that many levels, counting the root (or the forest's
+// roots) as the first, so a maxDepth of 1 loads only the top level either way.
+func (r *categoryRepository) GetSubtree(ctx context.Context, rootID *uuid.UUID, maxDepth int) ([]*model.Category, error) {
+	var categories []*model.Category
+
+	query := r.db.WithContext(ctx).Model(&model.Category{})
+
+	if rootID != nil {
+		query = query.Where("path LIKE (SELECT path FROM categories WHERE id = ? AND deleted_at IS NULL) || '%'", *rootID)
+		if maxDepth > 0 {
+			query = query.Where("depth < (SELECT depth FROM categories WHERE id = ?) + ?", *rootID, maxDepth)
+		}
+	} else if maxDepth > 0 {
+		query = query.Where("depth < ?", maxDepth)
+	}
+
+	if err := query.Order("depth").Order("name").Find(&categories).Error; err != nil {
+		r.log.Error("Failed to get category subtree", zap.Error(err))
+		return nil, err
+	}
+
+	return categories, nil
+}
+
 func (r *categoryRepository) GetByName(ctx context.Context, name string) (*model.Category, error) {
 	var category model.Category
 
@@ -109,8 +218,102 @@ func (r *categoryRepository) GetByName(ctx context.Context, name string) (*model
 	return &category, nil
 }
 
+func (r *categoryRepository) GetBySlug(ctx context.Context, slug string) (*model.Category, error) {
+	var category model.Category
+
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&category).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrCategoryNotFound, err)
+		}
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// SlugExists reports whether a live category other than excludeID already uses the slug
+func (r *categoryRepository) SlugExists(ctx context.Context, slug string, excludeID uuid.UUID) (bool, error) {
+	var count int64
+
+	err := r.db.WithContext(ctx).Model(&model.Category{}).
+		Where("slug = ? AND id <> ?", slug, excludeID).
+		Count(&count).Error
+	if err != nil {
+		r.log.Error("Failed to check category slug", zap.Error(err), zap.String("slug", slug))
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *categoryRepository) GetByClassNumber(ctx context.Context, scheme, classNumber string) (*model.Category, error) {
+	var category model.Category
+
+	err := r.db.WithContext(ctx).
+		Where("classification_scheme = ? AND class_number = ?", scheme, classNumber).
+		First(&category).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrCategoryNotFound, err)
+		}
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// ListByScheme returns every category carrying a class number of the given scheme
+func (r *categoryRepository) ListByScheme(ctx context.Context, scheme string) ([]*model.Category, error) {
+	var categories []*model.Category
+
+	err := r.db.WithContext(ctx).
+		Where("classification_scheme = ? AND class_number <> ''", scheme).
+		Order("depth ASC").
+		Find(&categories).Error
+	if err != nil {
+		r.log.Error("Failed to list categories by scheme", zap.Error(err), zap.String("scheme", scheme))
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// Update saves the category and, when its path changed because it moved, rewrites the paths and depths of
+// everything below it in the same transaction
 func (r *categoryRepository) Update(ctx context.Context, category *model.Category) error {
-	err := r.db.WithContext(ctx).Save(category).Error
+	var movedIDs []uuid.UUID
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current model.Category
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "path", "depth").
+			Where("id = ?", category.ID).
+			First(&current).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Save(category).Error; err != nil {
+			return err
+		}
+
+		if current.Path == category.Path {
+			return nil
+		}
+
+		descendants := tx.Unscoped().Model(&model.Category{}).
+			Where("path LIKE ? AND id <> ?", current.Path+"%", category.ID)
+
+		if err := descendants.Session(&gorm.Session{}).Pluck("id", &movedIDs).Error; err != nil {
+			return err
+		}
+
+		return descendants.Updates(map[string]interface{}{
+			"path":  gorm.Expr("? || substr(path, ?)", category.Path, len(current.Path)+1),
+			"depth": gorm.Expr("depth + ?", category.Depth-current.Depth),
+		}).Error
+	})
 	if err != nil {
 		r.log.Error("Failed to update category", zap.Error(err), zap.String("id", category.ID.String()))
 		return err
@@ -123,10 +326,16 @@ func (r *categoryRepository) Update(ctx context.Context, category *model.Categor
 		// Clear cache for category by name
 		cacheKey = fmt.Sprintf("%sname:%s", constants.CacheKeyCategory, category.Name)
 		_ = r.cache.Delete(ctx, cacheKey)
+		// Clear cache for descendants whose path changed
+		for _, id := range movedIDs {
+			cacheKey = fmt.Sprintf("%s%s", constants.CacheKeyCategory, id.String())
+			_ = r.cache.Delete(ctx, cacheKey)
+		}
 		// Clear list cache
 		cacheKey = fmt.Sprintf("%slist", constants.CacheKeyCategories)
 		_ = r.cache.Delete(ctx, cacheKey)
 	}
+	r.invalidateCounts(ctx)
 
 	return nil
 }
@@ -148,16 +357,6 @@ func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
 		return fmt.Errorf("cannot delete category with child categories")
 	}
 
-	// Check if there are any books associated with this category
-	hasBooks, err := r.HasBooks(ctx, id)
-	if err != nil {
-		return err
-	}
-
-	if hasBooks {
-		return fmt.Errorf("cannot delete category with associated books")
-	}
-
 	if err := r.db.WithContext(ctx).Delete(&model.Category{}, id).Error; err != nil {
 		r.log.Error("Failed to delete category", zap.Error(err), zap.String("id", id.String()))
 		return err
@@ -171,16 +370,37 @@ func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
 		cacheKey = fmt.Sprintf("%slist", constants.CacheKeyCategories)
 		_ = r.cache.Delete(ctx, cacheKey)
 	}
+	r.invalidateCounts(ctx)
 
 	return nil
 }
 
-func (r *categoryRepository) List(ctx context.Context, filter *dto.CategoryFilter) ([]*model.Category, int64, error) {
+func (r *categoryRepository) List(ctx context.Context, filter *dto.CategoryFilter) ([]*model.Category, int64, *pagination.Cursors, error) {
 	var categories []*model.Category
 	var count int64
 
+	sortKeys, err := categoryListSchema.ParseSort(listquery.SortExpr(filter.SortBy, filter.Desc),
+		pagination.SortKey{Column: "name"})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	cond, err := categoryListSchema.ParseFilter(filter.Filter)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	page, err := pagination.NewParams(sortKeys, filter.Cursor, filter.GetOffset(), filter.Limit)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
 	query := r.db.WithContext(ctx).Model(&model.Category{})
 
+	if cond != nil {
+		query = query.Where(cond)
+	}
+
 	if filter.ParentID != nil {
 		if *filter.ParentID == "null" {
 			query = query.Where("parent_id IS NULL")
@@ -194,27 +414,21 @@ func (r *categoryRepository) List(ctx context.Context, filter *dto.CategoryFilte
 
 	if err := query.Count(&count).Error; err != nil {
 		r.log.Error("Failed to count categories", zap.Error(err))
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
-	if filter.SortBy != "" {
-		if filter.Desc {
-			query = query.Order(fmt.Sprintf("%s DESC", filter.SortBy))
-		} else {
-			query = query.Order(filter.SortBy)
-		}
-	} else {
-		query = query.Order("name ASC")
+	if err := page.Apply(query).Find(&categories).Error; err != nil {
+		r.log.Error("Failed to list categories", zap.Error(err))
+		return nil, 0, nil, err
 	}
 
-	query = query.Offset(filter.GetOffset()).Limit(filter.Limit)
-
-	if err := query.Find(&categories).Error; err != nil {
-		r.log.Error("Failed to list categories", zap.Error(err))
-		return nil, 0, err
+	categories, cursors, err := pagination.Build(ctx, r.db, page, categories)
+	if err != nil {
+		r.log.Error("Failed to build category cursors", zap.Error(err))
+		return nil, 0, nil, err
 	}
 
-	return categories, count, nil
+	return categories, count, cursors, nil
 }
 
 func (r *categoryRepository) GetChildren(ctx context.Context, parentID uuid.UUID) ([]*model.Category, error) {
@@ -229,21 +443,265 @@ func (r *categoryRepository) GetChildren(ctx context.Context, parentID uuid.UUID
 	return categories, nil
 }
 
-func (r *categoryRepository) HasBooks(ctx context.Context, id uuid.UUID) (bool, error) {
-	// This would typically check in the books_categories table in the book service
-	// However, since we're in a microservice architecture, this has to be handled differently
-	// For now, we'll assume there's a local books_categories table we can check
+// Merge re-homes the direct children of source under target, rewriting the paths of their subtrees, and
+// soft-deletes source. Book assignments live in book-service and must be moved before calling this.
+func (r *categoryRepository) Merge(ctx context.Context, source, target *model.Category) error {
+	var moved []model.Category
 
-	var count int64
-	err := r.db.WithContext(ctx).Table("books_categories").Where("category_id = ?", id).Count(&count).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		descendants := tx.Unscoped().Model(&model.Category{}).
+			Where("path LIKE ? AND id <> ?", source.Path+"%", source.ID)
+
+		if err := descendants.Session(&gorm.Session{}).Select("id", "name").Find(&moved).Error; err != nil {
+			return err
+		}
+
+		err := descendants.Session(&gorm.Session{}).Updates(map[string]interface{}{
+			"path":  gorm.Expr("? || substr(path, ?)", target.Path, len(source.Path)+1),
+			"depth": gorm.Expr("depth + ?", target.Depth-source.Depth),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Model(&model.Category{}).
+			Where("parent_id = ?", source.ID).
+			Update("parent_id", target.ID).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&model.Category{}, source.ID).Error
+	})
 	if err != nil {
-		// If the table doesn't exist, we assume there are no books
-		if errors.Is(err, gorm.ErrUnsupportedRelation) {
-			return false, nil
+		r.log.Error("Failed to merge categories", zap.Error(err),
+			zap.String("source_id", source.ID.String()),
+			zap.String("target_id", target.ID.String()))
+		return err
+	}
+
+	r.clearCategoryCache(ctx, append(moved, *source, *target)...)
+	r.invalidateCounts(ctx)
+
+	return nil
+}
+
+// DeleteSubtree soft-deletes root and every category below it
+func (r *categoryRepository) DeleteSubtree(ctx context.Context, root *model.Category) error {
+	var deleted []model.Category
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		subtree := tx.Model(&model.Category{}).Where("path LIKE ?", root.Path+"%")
+
+		if err := subtree.Session(&gorm.Session{}).Select("id", "name").Find(&deleted).Error; err != nil {
+			return err
 		}
-		r.log.Error("Failed to check if category has books", zap.Error(err), zap.String("id", id.String()))
-		return false, err
+
+		return subtree.Session(&gorm.Session{}).Delete(&model.Category{}).Error
+	})
+	if err != nil {
+		r.log.Error("Failed to delete category subtree", zap.Error(err), zap.String("id", root.ID.String()))
+		return err
 	}
 
-	return count > 0, nil
+	r.clearCategoryCache(ctx, deleted...)
+	r.invalidateCounts(ctx)
+
+	return nil
+}
+
+// clearCategoryCache drops the by-id and by-name entries of the given categories and the list cache
+func (r *categoryRepository) clearCategoryCache(ctx context.Context, categories ...model.Category) {
+	if r.cache == nil {
+		return
+	}
+
+	for _, category := range categories {
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyCategory, category.ID.String()))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%sname:%s", constants.CacheKeyCategory, category.Name))
+	}
+	_ = r.cache.Delete(ctx, fmt.Sprintf("%slist", constants.CacheKeyCategories))
+}
+
+// GetDescendantIDs maps each category to the ids of every live category in its subtree, itself included
+func (r *categoryRepository) GetDescendantIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	descendants := make(map[uuid.UUID][]uuid.UUID, len(ids))
+
+	if len(ids) == 0 {
+		return descendants, nil
+	}
+
+	var rows []struct {
+		RootID uuid.UUID
+		ID     uuid.UUID
+	}
+
+	err := r.db.WithContext(ctx).Table("categories AS root").
+		Select("root.id AS root_id, d.id AS id").
+		Joins("JOIN categories d ON d.path LIKE root.path || '%' AND d.deleted_at IS NULL").
+		Where("root.id IN ? AND root.deleted_at IS NULL", ids).
+		Scan(&rows).Error
+	if err != nil {
+		r.log.Error("Failed to get category descendants", zap.Error(err), zap.Int("count", len(ids)))
+		return nil, err
+	}
+
+	for _, row := range rows {
+		descendants[row.RootID] = append(descendants[row.RootID], row.ID)
+	}
+
+	return descendants, nil
+}
+
+// CountChildren returns the number of live direct children and of all live descendants for each category
+func (r *categoryRepository) CountChildren(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]model.CategoryCount, error) {
+	counts := make(map[uuid.UUID]model.CategoryCount, len(ids))
+
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ID     uuid.UUID
+		Direct int64
+		Total  int64
+	}
+
+	err := r.db.WithContext(ctx).Table("categories AS c").
+		Select("c.id, COUNT(d.id) FILTER (WHERE d.parent_id = c.id) AS direct, COUNT(d.id) AS total").
+		Joins("LEFT JOIN categories d ON d.path LIKE c.path || '%' AND d.id <> c.id AND d.deleted_at IS NULL").
+		Where("c.id IN ?", ids).
+		Group("c.id").
+		Scan(&rows).Error
+	if err != nil {
+		r.log.Error("Failed to count child categories", zap.Error(err), zap.Int("count", len(ids)))
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ID] = model.CategoryCount{Direct: row.Direct, Total: row.Total}
+	}
+
+	return counts, nil
+}
+
+// GetCachedBookCounts returns the book counts cached under the current counts version; misses are left out
+func (r *categoryRepository) GetCachedBookCounts(ctx context.Context, ids []uuid.UUID) map[uuid.UUID]model.CategoryCount {
+	counts := make(map[uuid.UUID]model.CategoryCount, len(ids))
+
+	if r.cache == nil {
+		return counts
+	}
+
+	version := r.countsVersion(ctx)
+	for _, id := range ids {
+		var count model.CategoryCount
+		if err := r.cache.Get(ctx, bookCountCacheKey(version, id), &count); err == nil {
+			counts[id] = count
+		}
+	}
+
+	return counts
+}
+
+func (r *categoryRepository) CacheBookCounts(ctx context.Context, counts map[uuid.UUID]model.CategoryCount) {
+	if r.cache == nil {
+		return
+	}
+
+	version := r.countsVersion(ctx)
+	for id, count := range counts {
+		_ = r.cache.Set(ctx, bookCountCacheKey(version, id), count, constants.CacheDefaultTTL)
+	}
+}
+
+// countsVersion reads the shared counts version that book-service and this repository bump on writes
+func (r *categoryRepository) countsVersion(ctx context.Context) int64 {
+	var version int64
+	_ = r.cache.Get(ctx, constants.CacheKeyCategoryCountsVersion, &version)
+	return version
+}
+
+// invalidateCounts bumps the shared counts version; moving, adding or removing a category changes subtree totals
+func (r *categoryRepository) invalidateCounts(ctx context.Context) {
+	if r.cache == nil {
+		return
+	}
+
+	if _, err := r.cache.Incr(ctx, constants.CacheKeyCategoryCountsVersion); err != nil {
+		r.log.Warn("Failed to invalidate category counts", zap.Error(err))
+	}
+}
+
+func bookCountCacheKey(version int64, id uuid.UUID) string {
+	return fmt.Sprintf("%sbookcount:%d:%s", constants.CacheKeyCategory, version, id.String())
+}
+
+func (r *categoryRepository) GetTranslations(ctx context.Context, id uuid.UUID) ([]*model.CategoryTranslation, error) {
+	var translations []*model.CategoryTranslation
+
+	err := r.db.WithContext(ctx).
+		Where("category_id = ?", id).
+		Order("locale ASC").
+		Find(&translations).Error
+	if err != nil {
+		r.log.Error("Failed to get category translations", zap.Error(err), zap.String("id", id.String()))
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+// GetTranslationsForLocale returns the translations of the given categories in one locale, keyed by category id.
+// Categories without a translation are absent from the map.
+func (r *categoryRepository) GetTranslationsForLocale(ctx context.Context, ids []uuid.UUID, locale string) (map[uuid.UUID]*model.CategoryTranslation, error) {
+	result := make(map[uuid.UUID]*model.CategoryTranslation, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var translations []*model.CategoryTranslation
+	err := r.db.WithContext(ctx).
+		Where("category_id IN ? AND locale = ?", ids, locale).
+		Find(&translations).Error
+	if err != nil {
+		r.log.Error("Failed to get category translations", zap.Error(err), zap.String("locale", locale))
+		return nil, err
+	}
+
+	for _, translation := range translations {
+		result[translation.CategoryID] = translation
+	}
+
+	return result, nil
+}
+
+// SaveTranslations upserts translations by locale and removes the listed locales in one transaction
+func (r *categoryRepository) SaveTranslations(ctx context.Context, categoryID uuid.UUID, upserts []*model.CategoryTranslation, removeLocales []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(removeLocales) > 0 {
+			err := tx.Unscoped().
+				Where("category_id = ? AND locale IN ?", categoryID, removeLocales).
+				Delete(&model.CategoryTranslation{}).Error
+			if err != nil {
+				r.log.Error("Failed to remove category translations", zap.Error(err), zap.String("id", categoryID.String()))
+				return err
+			}
+		}
+
+		if len(upserts) == 0 {
+			return nil
+		}
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "category_id"}, {Name: "locale"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "description", "updated_at"}),
+		}).Create(&upserts).Error
+		if err != nil {
+			r.log.Error("Failed to save category translations", zap.Error(err), zap.String("id", categoryID.String()))
+			return err
+		}
+
+		return nil
+	})
 }
@@ -11,22 +11,31 @@ func SetupRoutes(
 	router *mux.Router,
 	categoryHandler *handler.CategoryHandler,
 	jwtAuth *middleware.JWTAuth,
+	policy *middleware.Policy,
 	log *logger.Logger,
 ) {
 	apiRouter := router.PathPrefix("/api").Subrouter()
 	categoriesRouter := apiRouter.PathPrefix("/categories").Subrouter()
+	categoriesRouter.Use(middleware.LocaleMiddleware)
 
 	// Public routes (no auth required)
 	categoriesRouter.HandleFunc("", categoryHandler.HandleListCategories).Methods("GET")
 	categoriesRouter.HandleFunc("/name", categoryHandler.HandleGetCategoryByName).Methods("GET")
+	categoriesRouter.HandleFunc("/tree", categoryHandler.HandleGetCategoryTree).Methods("GET")
+	categoriesRouter.HandleFunc("/slug/{slug}", categoryHandler.HandleGetCategoryBySlug).Methods("GET")
 	categoriesRouter.HandleFunc("/{id}", categoryHandler.HandleGetCategory).Methods("GET")
 	categoriesRouter.HandleFunc("/{id}/children", categoryHandler.HandleGetCategoryChildren).Methods("GET")
+	categoriesRouter.HandleFunc("/{id}/path", categoryHandler.HandleGetCategoryPath).Methods("GET")
+	categoriesRouter.HandleFunc("/{id}/exists", categoryHandler.HandleCheckCategoryExists).Methods("GET")
 
-	// Protected routes (auth required)
+	// Protected routes (category:write required)
 	protectedRouter := categoriesRouter.NewRoute().Subrouter()
-	protectedRouter.Use(jwtAuth.HTTPMiddleware)
+	protectedRouter.Use(jwtAuth.HTTPMiddleware, policy.Require(middleware.PermCategoryWrite))
 
 	protectedRouter.HandleFunc("", categoryHandler.HandleCreateCategory).Methods("POST")
+	protectedRouter.HandleFunc("/import", categoryHandler.HandleImportClassification).Methods("POST")
 	protectedRouter.HandleFunc("/{id}", categoryHandler.HandleUpdateCategory).Methods("PUT", "PATCH")
 	protectedRouter.HandleFunc("/{id}", categoryHandler.HandleDeleteCategory).Methods("DELETE")
+	protectedRouter.HandleFunc("/{id}/move", categoryHandler.HandleMoveCategory).Methods("POST")
+	protectedRouter.HandleFunc("/{id}/merge", categoryHandler.HandleMergeCategory).Methods("POST")
 }
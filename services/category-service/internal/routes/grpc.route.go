@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/proto/category"
+)
+
+// GRPCAccess lists who may call each gRPC method; reads stay open to other services
+func GRPCAccess() middleware.MethodAccess {
+	return middleware.MethodAccess{
+		Public: []string{
+			category.CategoryService_GetCategory_FullMethodName,
+			category.CategoryService_BatchGetCategories_FullMethodName,
+			category.CategoryService_ListCategories_FullMethodName,
+			category.CategoryService_GetCategoryByName_FullMethodName,
+			category.CategoryService_GetCategoryBySlug_FullMethodName,
+			category.CategoryService_GetCategoryChildren_FullMethodName,
+			category.CategoryService_GetCategoryPath_FullMethodName,
+			category.CategoryService_GetCategoryDescendants_FullMethodName,
+			category.CategoryService_CheckCategoryExists_FullMethodName,
+			category.CategoryService_Health_FullMethodName,
+		},
+		Permissions: middleware.MethodPermissions{
+			category.CategoryService_CreateCategory_FullMethodName: {middleware.PermCategoryWrite},
+			category.CategoryService_UpdateCategory_FullMethodName: {middleware.PermCategoryWrite},
+			category.CategoryService_DeleteCategory_FullMethodName: {middleware.PermCategoryWrite},
+			category.CategoryService_MoveCategory_FullMethodName:   {middleware.PermCategoryWrite},
+			category.CategoryService_MergeCategory_FullMethodName:  {middleware.PermCategoryWrite},
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/proto/book"
+	"github.com/fairuzald/library-system/services/category-service/internal/entity/model"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type grpcBookClient struct {
+	conn   *grpc.ClientConn
+	client book.BookServiceClient
+	log    *logger.Logger
+}
+
+// NewBookClient creates a new client for the Book service
+func NewBookClient(serviceURL string, log *logger.Logger) (BookClient, error) {
+	if serviceURL == "" {
+		log.Warn("Book service URL is empty, creating mock client")
+		return &mockBookClient{log: log}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log.Info("Connecting to book service", zap.String("url", serviceURL))
+	conn, err := grpc.DialContext(
+		ctx,
+		serviceURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		log.Error("Failed to connect to book service", zap.Error(err), zap.String("url", serviceURL))
+		return &mockBookClient{log: log}, nil
+	}
+
+	client := book.NewBookServiceClient(conn)
+
+	return &grpcBookClient{
+		conn:   conn,
+		client: client,
+		log:    log,
+	}, nil
+}
+
+func (c *grpcBookClient) CountBooksByCategory(ctx context.Context, subtrees map[string][]string) (map[string]model.CategoryCount, error) {
+	req := &book.CountBooksByCategoryRequest{
+		Queries: make([]*book.CategoryBookCountQuery, 0, len(subtrees)),
+	}
+
+	for categoryID, descendantIDs := range subtrees {
+		req.Queries = append(req.Queries, &book.CategoryBookCountQuery{
+			CategoryId:    categoryID,
+			DescendantIds: descendantIDs,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	resp, err := c.client.CountBooksByCategory(ctx, req)
+	if err != nil {
+		c.log.Error("Failed to count books by category",
+			zap.Error(err),
+			zap.Int("categories", len(subtrees)))
+		return nil, err
+	}
+
+	counts := make(map[string]model.CategoryCount, len(resp.GetCounts()))
+	for categoryID, count := range resp.GetCounts() {
+		counts[categoryID] = model.CategoryCount{
+			Direct: count.GetDirect(),
+			Total:  count.GetTotal(),
+		}
+	}
+
+	return counts, nil
+}
+
+func (c *grpcBookClient) ReassignCategory(ctx context.Context, fromIDs []string, toID string) (int64, error) {
+	req := &book.ReassignCategoryRequest{
+		FromCategoryIds: fromIDs,
+		ToCategoryId:    toID,
+	}
+
+	// Reassigning books needs book:write, which the book service checks against the caller's own token
+	ctx, cancel := context.WithTimeout(middleware.ForwardAuthToken(ctx), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.client.ReassignCategory(ctx, req)
+	if err != nil {
+		c.log.Error("Failed to reassign category books",
+			zap.Error(err),
+			zap.Strings("from_category_ids", fromIDs),
+			zap.String("to_category_id", toID))
+		return 0, err
+	}
+
+	return resp.GetBooksUpdated(), nil
+}
+
+func (c *grpcBookClient) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// Mock implementation for when book service is unavailable
+type mockBookClient struct {
+	log *logger.Logger
+}
+
+// CountBooksByCategory reports book-service as unavailable rather than inventing zero counts, which would let
+// a category that still holds books be deleted
+func (m *mockBookClient) CountBooksByCategory(ctx context.Context, subtrees map[string][]string) (map[string]model.CategoryCount, error) {
+	m.log.Warn("Using mock book client, book counts unavailable",
+		zap.Int("categories", len(subtrees)))
+	return nil, errors.New(constants.ErrBookServiceUnavailable)
+}
+
+// ReassignCategory refuses rather than pretending, since a merge or delete would otherwise orphan book assignments
+func (m *mockBookClient) ReassignCategory(ctx context.Context, fromIDs []string, toID string) (int64, error) {
+	m.log.Warn("Using mock book client, refusing to reassign category books",
+		zap.Strings("from_category_ids", fromIDs),
+		zap.String("to_category_id", toID))
+	return 0, errors.New(constants.ErrBookServiceUnavailable)
+}
+
+func (m *mockBookClient) Close() error {
+	return nil
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"github.com/fairuzald/library-system/services/category-service/internal/entity/model"
+)
+
+type BookClient interface {
+	// CountBooksByCategory takes category IDs mapped to the IDs of their whole subtree
+	CountBooksByCategory(ctx context.Context, subtrees map[string][]string) (map[string]model.CategoryCount, error)
+
+	// ReassignCategory files every book in fromIDs under toID and returns how many books changed
+	ReassignCategory(ctx context.Context, fromIDs []string, toID string) (int64, error)
+
+	Close() error
+}
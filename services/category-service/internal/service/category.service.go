@@ -7,7 +7,10 @@ import (
 	"strings"
 
 	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/listquery"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/pagination"
+	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/model"
@@ -18,12 +21,14 @@ import (
 
 type categoryService struct {
 	categoryRepo repository.CategoryRepository
+	bookClient   BookClient
 	log          *logger.Logger
 }
 
-func NewCategoryService(categoryRepo repository.CategoryRepository, log *logger.Logger) CategoryService {
+func NewCategoryService(categoryRepo repository.CategoryRepository, bookClient BookClient, log *logger.Logger) CategoryService {
 	return &categoryService{
 		categoryRepo: categoryRepo,
+		bookClient:   bookClient,
 		log:          log,
 	}
 }
@@ -34,14 +39,14 @@ func (s *categoryService) CreateCategory(ctx context.Context, req *dto.CategoryC
 		return nil, fmt.Errorf("category with name %s already exists", req.Name)
 	}
 
-	var parentID *uuid.UUID
+	var parent *model.Category
 	if req.ParentID != nil && *req.ParentID != "" {
 		id, err := uuid.Parse(*req.ParentID)
 		if err != nil {
 			return nil, fmt.Errorf("invalid parent ID format: %s", *req.ParentID)
 		}
 
-		parent, err := s.categoryRepo.GetByID(ctx, id)
+		parent, err = s.categoryRepo.GetByID(ctx, id)
 		if err != nil {
 			if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
 				return nil, fmt.Errorf("parent category not found")
@@ -49,18 +54,43 @@ func (s *categoryService) CreateCategory(ctx context.Context, req *dto.CategoryC
 			s.log.Error("Failed to get parent category", zap.Error(err), zap.String("parent_id", id.String()))
 			return nil, errors.New(constants.ErrInternalServer)
 		}
+	}
+
+	category := model.NewCategory(req.Name, req.Description, parent)
+
+	if err := s.setClassNumber(ctx, category, req.ClassificationScheme, req.ClassNumber); err != nil {
+		return nil, err
+	}
 
-		parentID = &parent.ID
+	translations := make(map[string]*dto.CategoryTranslationInput, len(req.Translations))
+	for locale, translation := range req.Translations {
+		translation := translation
+		translations[locale] = &translation
 	}
 
-	category := model.NewCategory(req.Name, req.Description, parentID)
+	upserts, _, err := translationChanges(category.ID, translations)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.assignSlug(ctx, category, req.Slug); err != nil {
+		return nil, err
+	}
 
 	if err := s.categoryRepo.Create(ctx, category); err != nil {
 		s.log.Error("Failed to create category", zap.Error(err))
 		return nil, errors.New(constants.ErrInternalServer)
 	}
 
-	return dao.NewCategoryResponse(category), nil
+	if err := s.saveTranslations(ctx, category.ID, upserts, nil); err != nil {
+		return nil, err
+	}
+
+	response := dao.NewCategoryResponse(category)
+	s.localize(ctx, response)
+	s.attachTranslations(ctx, response)
+
+	return response, nil
 }
 
 func (s *categoryService) GetCategoryByID(ctx context.Context, id uuid.UUID) (*dao.CategoryResponse, error) {
@@ -73,7 +103,47 @@ func (s *categoryService) GetCategoryByID(ctx context.Context, id uuid.UUID) (*d
 		return nil, errors.New(constants.ErrInternalServer)
 	}
 
-	return dao.NewCategoryResponse(category), nil
+	response := dao.NewCategoryResponse(category)
+	s.localize(ctx, response)
+	s.attachTranslations(ctx, response)
+
+	return response, nil
+}
+
+func (s *categoryService) BatchGetCategories(ctx context.Context, ids []string) (*dao.CategoryBatchResponse, error) {
+	if len(ids) > constants.MaxBatchSize {
+		return nil, errors.New(constants.ErrBatchTooLarge)
+	}
+
+	categoryIDs, invalid := utils.ParseUUIDs(ids)
+
+	categories, err := s.categoryRepo.GetByIDs(ctx, categoryIDs)
+	if err != nil {
+		s.log.Error("Failed to batch get categories", zap.Error(err))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	byID := make(map[uuid.UUID]*model.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	response := &dao.CategoryBatchResponse{
+		Categories: make([]dao.CategoryResponse, 0, len(categories)),
+		MissingIDs: append([]string{}, invalid...),
+	}
+
+	for _, id := range categoryIDs {
+		category, ok := byID[id]
+		if !ok {
+			response.MissingIDs = append(response.MissingIDs, id.String())
+			continue
+		}
+		response.Categories = append(response.Categories, *dao.NewCategoryResponse(category))
+	}
+	s.localizeList(ctx, response.Categories)
+
+	return response, nil
 }
 
 func (s *categoryService) GetCategoryByName(ctx context.Context, name string) (*dao.CategoryResponse, error) {
@@ -116,7 +186,7 @@ func (s *categoryService) UpdateCategory(ctx context.Context, id uuid.UUID, req
 
 	if req.ParentID != nil {
 		if *req.ParentID == "" {
-			category.ParentID = nil
+			category.SetParent(nil)
 		} else {
 			parentID, err := uuid.Parse(*req.ParentID)
 			if err != nil {
@@ -136,11 +206,32 @@ func (s *categoryService) UpdateCategory(ctx context.Context, id uuid.UUID, req
 				return nil, errors.New(constants.ErrInternalServer)
 			}
 
-			if err := s.ensureNoCycle(ctx, parentID, id); err != nil {
+			if err := ensureNoCycle(parent, category); err != nil {
 				return nil, err
 			}
 
-			category.ParentID = &parent.ID
+			category.SetParent(parent)
+		}
+	}
+
+	upserts, removed, err := translationChanges(category.ID, req.Translations)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Slug != nil {
+		if err := s.assignSlug(ctx, category, *req.Slug); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.ClassNumber != nil {
+		scheme := ""
+		if req.ClassificationScheme != nil {
+			scheme = *req.ClassificationScheme
+		}
+		if err := s.setClassNumber(ctx, category, scheme, *req.ClassNumber); err != nil {
+			return nil, err
 		}
 	}
 
@@ -149,11 +240,21 @@ func (s *categoryService) UpdateCategory(ctx context.Context, id uuid.UUID, req
 		return nil, errors.New(constants.ErrInternalServer)
 	}
 
-	return dao.NewCategoryResponse(category), nil
+	if err := s.saveTranslations(ctx, category.ID, upserts, removed); err != nil {
+		return nil, err
+	}
+
+	response := dao.NewCategoryResponse(category)
+	s.localize(ctx, response)
+	s.attachTranslations(ctx, response)
+
+	return response, nil
 }
 
-func (s *categoryService) DeleteCategory(ctx context.Context, id uuid.UUID) error {
-	_, err := s.categoryRepo.GetByID(ctx, id)
+// DeleteCategory removes an empty category. With req.Force it removes the whole subtree instead, first moving
+// every book filed anywhere in it to the req.ReassignTo category in book-service.
+func (s *categoryService) DeleteCategory(ctx context.Context, id uuid.UUID, req *dto.CategoryDelete) error {
+	category, err := s.categoryRepo.GetByID(ctx, id)
 	if err != nil {
 		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
 			return errors.New(constants.ErrCategoryNotFound)
@@ -162,6 +263,14 @@ func (s *categoryService) DeleteCategory(ctx context.Context, id uuid.UUID) erro
 		return errors.New(constants.ErrInternalServer)
 	}
 
+	if req != nil && req.Force {
+		return s.forceDeleteCategory(ctx, category, req.ReassignTo)
+	}
+
+	if err := s.ensureNoBooks(ctx, category.ID); err != nil {
+		return err
+	}
+
 	if err := s.categoryRepo.Delete(ctx, id); err != nil {
 		if strings.Contains(err.Error(), "child categories") {
 			return errors.New("cannot delete category with child categories")
@@ -176,11 +285,167 @@ func (s *categoryService) DeleteCategory(ctx context.Context, id uuid.UUID) erro
 	return nil
 }
 
+// ensureNoBooks asks book-service whether any live book is still filed under the category. It fails closed:
+// when book-service cannot answer, the category is treated as in use so deletion cannot orphan assignments.
+func (s *categoryService) ensureNoBooks(ctx context.Context, id uuid.UUID) error {
+	if s.bookClient == nil {
+		return errors.New(constants.ErrBookServiceUnavailable)
+	}
+
+	counts, err := s.bookClient.CountBooksByCategory(ctx, map[string][]string{id.String(): {id.String()}})
+	if err != nil {
+		s.log.Error("Failed to check category books before deletion", zap.Error(err), zap.String("id", id.String()))
+		return errors.New(constants.ErrBookServiceUnavailable)
+	}
+
+	if counts[id.String()].Direct > 0 {
+		return errors.New("cannot delete category with associated books")
+	}
+
+	return nil
+}
+
+func (s *categoryService) forceDeleteCategory(ctx context.Context, category *model.Category, reassignTo *string) error {
+	if reassignTo == nil || *reassignTo == "" {
+		return errors.New(constants.ErrReassignTargetRequired)
+	}
+
+	targetID, err := uuid.Parse(*reassignTo)
+	if err != nil {
+		return fmt.Errorf("invalid reassignment target ID format: %s", *reassignTo)
+	}
+
+	target, err := s.categoryRepo.GetByID(ctx, targetID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return fmt.Errorf("reassignment target category not found")
+		}
+		s.log.Error("Failed to get reassignment target", zap.Error(err), zap.String("id", targetID.String()))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	if target.ID == category.ID || category.IsAncestorOf(target) {
+		return errors.New(constants.ErrReassignTargetInSubtree)
+	}
+
+	descendants, err := s.categoryRepo.GetDescendantIDs(ctx, []uuid.UUID{category.ID})
+	if err != nil {
+		s.log.Error("Failed to get category subtree", zap.Error(err), zap.String("id", category.ID.String()))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	subtreeIDs := make([]string, 0, len(descendants[category.ID]))
+	for _, descendantID := range descendants[category.ID] {
+		subtreeIDs = append(subtreeIDs, descendantID.String())
+	}
+
+	if err := s.reassignBooks(ctx, subtreeIDs, target.ID); err != nil {
+		return err
+	}
+
+	if err := s.categoryRepo.DeleteSubtree(ctx, category); err != nil {
+		s.log.Error("Failed to delete category subtree after reassigning books",
+			zap.Error(err), zap.String("id", category.ID.String()))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	s.log.Info("Force deleted category subtree",
+		zap.String("id", category.ID.String()),
+		zap.Int("categories", len(subtreeIDs)),
+		zap.String("reassigned_to", target.ID.String()))
+
+	return nil
+}
+
+// MoveCategory re-parents a category, carrying its whole subtree along. A nil or empty parent makes it a root.
+func (s *categoryService) MoveCategory(ctx context.Context, id uuid.UUID, req *dto.CategoryMove) (*dao.CategoryResponse, error) {
+	parentID := ""
+	if req.ParentID != nil {
+		parentID = *req.ParentID
+	}
+
+	return s.UpdateCategory(ctx, id, &dto.CategoryUpdate{ParentID: &parentID})
+}
+
+// MergeCategory folds source into the target category: books are moved in book-service first, then the
+// children of source are re-homed under the target and source is deleted. Moving books is idempotent, so a
+// failure after that step leaves source empty and the merge can simply be retried.
+func (s *categoryService) MergeCategory(ctx context.Context, sourceID uuid.UUID, req *dto.CategoryMerge) (*dao.CategoryResponse, error) {
+	targetID, err := uuid.Parse(req.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target ID format: %s", req.TargetID)
+	}
+
+	if targetID == sourceID {
+		return nil, errors.New(constants.ErrMergeSameCategory)
+	}
+
+	source, err := s.categoryRepo.GetByID(ctx, sourceID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return nil, errors.New(constants.ErrCategoryNotFound)
+		}
+		s.log.Error("Failed to get category for merge", zap.Error(err), zap.String("id", sourceID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	target, err := s.categoryRepo.GetByID(ctx, targetID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return nil, fmt.Errorf("target category not found")
+		}
+		s.log.Error("Failed to get merge target", zap.Error(err), zap.String("id", targetID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	if source.IsAncestorOf(target) {
+		return nil, errors.New(constants.ErrMergeIntoDescendant)
+	}
+
+	if err := s.reassignBooks(ctx, []string{source.ID.String()}, target.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.categoryRepo.Merge(ctx, source, target); err != nil {
+		s.log.Error("Failed to merge categories after reassigning books",
+			zap.Error(err), zap.String("source_id", sourceID.String()), zap.String("target_id", targetID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	s.log.Info("Merged category",
+		zap.String("source_id", sourceID.String()),
+		zap.String("target_id", targetID.String()))
+
+	return dao.NewCategoryResponse(target), nil
+}
+
+func (s *categoryService) reassignBooks(ctx context.Context, fromIDs []string, toID uuid.UUID) error {
+	if s.bookClient == nil {
+		return errors.New(constants.ErrBookServiceUnavailable)
+	}
+
+	updated, err := s.bookClient.ReassignCategory(ctx, fromIDs, toID.String())
+	if err != nil {
+		s.log.Error("Failed to reassign books", zap.Error(err), zap.String("to_category_id", toID.String()))
+		return errors.New(constants.ErrBookServiceUnavailable)
+	}
+
+	s.log.Info("Reassigned category books", zap.Int64("books_updated", updated), zap.String("to_category_id", toID.String()))
+
+	return nil
+}
+
 func (s *categoryService) ListCategories(ctx context.Context, filter *dto.CategoryFilter) (*dao.CategoryListResponse, error) {
 	filter.Validate()
 
-	categories, count, err := s.categoryRepo.List(ctx, filter)
+	categories, count, cursors, err := s.categoryRepo.List(ctx, filter)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, errors.New(constants.ErrInvalidCursor)
+		}
+		if errors.Is(err, listquery.ErrInvalidFilter) || errors.Is(err, listquery.ErrInvalidSort) {
+			return nil, err
+		}
 		s.log.Error("Failed to list categories", zap.Error(err))
 		return nil, errors.New(constants.ErrInternalServer)
 	}
@@ -191,15 +456,124 @@ func (s *categoryService) ListCategories(ctx context.Context, filter *dto.Catego
 		TotalPages:  (int(count) + filter.Limit - 1) / filter.Limit,
 		CurrentPage: filter.Page,
 		PageSize:    filter.Limit,
+		NextCursor:  cursors.Next,
+		PrevCursor:  cursors.Prev,
 	}
 
 	for _, category := range categories {
 		response.Categories = append(response.Categories, *dao.NewCategoryResponse(category))
 	}
+	s.localizeList(ctx, response.Categories)
+
+	if filter.IncludeChildCount {
+		if err := s.attachChildCounts(ctx, response.Categories); err != nil {
+			return nil, errors.New(constants.ErrInternalServer)
+		}
+	}
+
+	if filter.IncludeBookCount {
+		s.attachBookCounts(ctx, response.Categories)
+	}
 
 	return response, nil
 }
 
+func (s *categoryService) attachChildCounts(ctx context.Context, categories []dao.CategoryResponse) error {
+	if len(categories) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(categories))
+	for _, category := range categories {
+		ids = append(ids, category.ID)
+	}
+
+	counts, err := s.categoryRepo.CountChildren(ctx, ids)
+	if err != nil {
+		s.log.Error("Failed to count child categories", zap.Error(err))
+		return err
+	}
+
+	for i := range categories {
+		count := counts[categories[i].ID]
+		categories[i].ChildCount = &count.Direct
+		categories[i].TotalChildCount = &count.Total
+	}
+
+	return nil
+}
+
+// attachBookCounts fills book counts from the cache, asking book-service only for the misses. Counts are
+// best effort: when book-service cannot be reached they are left out rather than failing the listing.
+func (s *categoryService) attachBookCounts(ctx context.Context, categories []dao.CategoryResponse) {
+	if len(categories) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(categories))
+	for _, category := range categories {
+		ids = append(ids, category.ID)
+	}
+
+	counts := s.categoryRepo.GetCachedBookCounts(ctx, ids)
+
+	var missing []uuid.UUID
+	for _, id := range ids {
+		if _, ok := counts[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) > 0 && s.bookClient != nil {
+		fetched, err := s.fetchBookCounts(ctx, missing)
+		if err != nil {
+			s.log.Warn("Failed to load category book counts", zap.Error(err))
+		} else {
+			s.categoryRepo.CacheBookCounts(ctx, fetched)
+			for id, count := range fetched {
+				counts[id] = count
+			}
+		}
+	}
+
+	for i := range categories {
+		if count, ok := counts[categories[i].ID]; ok {
+			categories[i].BookCount = &count.Direct
+			categories[i].TotalBookCount = &count.Total
+		}
+	}
+}
+
+func (s *categoryService) fetchBookCounts(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]model.CategoryCount, error) {
+	descendants, err := s.categoryRepo.GetDescendantIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	subtrees := make(map[string][]string, len(descendants))
+	for id, subtree := range descendants {
+		descendantIDs := make([]string, 0, len(subtree))
+		for _, descendantID := range subtree {
+			descendantIDs = append(descendantIDs, descendantID.String())
+		}
+		subtrees[id.String()] = descendantIDs
+	}
+
+	remote, err := s.bookClient.CountBooksByCategory(ctx, subtrees)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]model.CategoryCount, len(remote))
+	for categoryID, count := range remote {
+		if id, err := uuid.Parse(categoryID); err == nil {
+			counts[id] = count
+		}
+	}
+
+	return counts, nil
+}
+
 func (s *categoryService) GetCategoryChildren(ctx context.Context, parentID uuid.UUID) (*dao.CategoryListResponse, error) {
 	_, err := s.categoryRepo.GetByID(ctx, parentID)
 	if err != nil {
@@ -227,36 +601,91 @@ func (s *categoryService) GetCategoryChildren(ctx context.Context, parentID uuid
 	for _, child := range children {
 		response.Categories = append(response.Categories, *dao.NewCategoryResponse(child))
 	}
+	s.localizeList(ctx, response.Categories)
 
 	return response, nil
 }
 
-func (s *categoryService) ensureNoCycle(ctx context.Context, parentID, childID uuid.UUID) error {
-	visited := make(map[uuid.UUID]bool)
-
-	var checkCycle func(current uuid.UUID) error
-	checkCycle = func(current uuid.UUID) error {
-		if current == childID {
-			return errors.New("operation would create a category cycle")
+func (s *categoryService) GetCategoryPath(ctx context.Context, id uuid.UUID) (*dao.CategoryPathResponse, error) {
+	path, err := s.categoryRepo.GetPath(ctx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return nil, errors.New(constants.ErrCategoryNotFound)
 		}
+		s.log.Error("Failed to get category path", zap.Error(err), zap.String("id", id.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
 
-		if visited[current] {
-			return nil
-		}
+	response := &dao.CategoryPathResponse{
+		Path:  make([]dao.CategoryResponse, 0, len(path)),
+		Depth: len(path) - 1,
+	}
 
-		visited[current] = true
+	for _, category := range path {
+		response.Path = append(response.Path, *dao.NewCategoryResponse(category))
+	}
+	s.localizeList(ctx, response.Path)
 
-		category, err := s.categoryRepo.GetByID(ctx, current)
-		if err != nil {
-			return err
-		}
+	return response, nil
+}
 
-		if category.ParentID != nil {
-			return checkCycle(*category.ParentID)
+// GetCategoryDescendantIDs returns the ids of the category and every live category below it
+func (s *categoryService) GetCategoryDescendantIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	descendants, err := s.categoryRepo.GetDescendantIDs(ctx, []uuid.UUID{id})
+	if err != nil {
+		s.log.Error("Failed to get category descendants", zap.Error(err), zap.String("id", id.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	ids, ok := descendants[id]
+	if !ok {
+		return nil, errors.New(constants.ErrCategoryNotFound)
+	}
+
+	return ids, nil
+}
+
+// GetCategoryTree nests the subtree under rootID, or every category when rootID is nil, in a single query
+func (s *categoryService) GetCategoryTree(ctx context.Context, rootID *uuid.UUID, maxDepth int) (*dao.CategoryTreeResponse, error) {
+	categories, err := s.categoryRepo.GetSubtree(ctx, rootID, maxDepth)
+	if err != nil {
+		s.log.Error("Failed to get category tree", zap.Error(err))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	if rootID != nil && len(categories) == 0 {
+		return nil, errors.New(constants.ErrCategoryNotFound)
+	}
+
+	response := dao.NewCategoryTreeResponse(categories)
+	s.localize(ctx, response.Nodes()...)
+
+	return response, nil
+}
+
+// CheckCategoryExists reports soft-deleted categories as not existing, flagging them as deleted
+func (s *categoryService) CheckCategoryExists(ctx context.Context, id uuid.UUID) (*dao.CategoryExistsResponse, error) {
+	category, err := s.categoryRepo.GetByIDWithDeleted(ctx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return &dao.CategoryExistsResponse{Exists: false}, nil
 		}
+		s.log.Error("Failed to check category existence", zap.Error(err), zap.String("id", id.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
 
-		return nil
+	if category.DeletedAt.Valid {
+		return &dao.CategoryExistsResponse{Exists: false, Deleted: true, Name: category.Name}, nil
 	}
 
-	return checkCycle(parentID)
+	return &dao.CategoryExistsResponse{Exists: true, Name: category.Name}, nil
+}
+
+// ensureNoCycle rejects moving a category underneath one of its own descendants
+func ensureNoCycle(parent, child *model.Category) error {
+	if child.IsAncestorOf(parent) {
+		return errors.New("operation would create a category cycle")
+	}
+
+	return nil
 }
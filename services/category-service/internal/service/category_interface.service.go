@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"io"
 
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/category-service/internal/entity/dto"
@@ -12,8 +13,17 @@ type CategoryService interface {
 	CreateCategory(ctx context.Context, req *dto.CategoryCreate) (*dao.CategoryResponse, error)
 	GetCategoryByID(ctx context.Context, id uuid.UUID) (*dao.CategoryResponse, error)
 	GetCategoryByName(ctx context.Context, name string) (*dao.CategoryResponse, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (*dao.CategoryResponse, error)
+	BatchGetCategories(ctx context.Context, ids []string) (*dao.CategoryBatchResponse, error)
 	UpdateCategory(ctx context.Context, id uuid.UUID, req *dto.CategoryUpdate) (*dao.CategoryResponse, error)
-	DeleteCategory(ctx context.Context, id uuid.UUID) error
+	DeleteCategory(ctx context.Context, id uuid.UUID, req *dto.CategoryDelete) error
+	MoveCategory(ctx context.Context, id uuid.UUID, req *dto.CategoryMove) (*dao.CategoryResponse, error)
+	MergeCategory(ctx context.Context, sourceID uuid.UUID, req *dto.CategoryMerge) (*dao.CategoryResponse, error)
 	ListCategories(ctx context.Context, filter *dto.CategoryFilter) (*dao.CategoryListResponse, error)
 	GetCategoryChildren(ctx context.Context, parentID uuid.UUID) (*dao.CategoryListResponse, error)
+	GetCategoryPath(ctx context.Context, id uuid.UUID) (*dao.CategoryPathResponse, error)
+	GetCategoryDescendantIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+	GetCategoryTree(ctx context.Context, rootID *uuid.UUID, maxDepth int) (*dao.CategoryTreeResponse, error)
+	CheckCategoryExists(ctx context.Context, id uuid.UUID) (*dao.CategoryExistsResponse, error)
+	ImportClassification(ctx context.Context, scheme string, r io.Reader) (*dao.ClassificationImportResponse, error)
 }
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fairuzald/library-system/pkg/classification"
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/services/category-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/category-service/internal/entity/model"
+	"go.uber.org/zap"
+)
+
+// maxImportRows caps the size of a classification file; the full DDC summaries are well under this
+const maxImportRows = 20000
+
+// maxCategoryNameLength matches the width of categories.name
+const maxCategoryNameLength = 100
+
+type classificationRow struct {
+	line        int
+	classNumber string
+	name        string
+	description string
+	parentClass string
+}
+
+// setClassNumber validates and assigns a class number, rejecting one already held by another category.
+// An empty class number clears the classification.
+func (s *categoryService) setClassNumber(ctx context.Context, category *model.Category, scheme, classNumber string) error {
+	if strings.TrimSpace(classNumber) == "" {
+		category.ClassificationScheme, category.ClassNumber = "", ""
+		return nil
+	}
+
+	scheme, classNumber, err := classification.Normalize(scheme, classNumber)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.categoryRepo.GetByClassNumber(ctx, scheme, classNumber)
+	if err == nil && existing.ID != category.ID {
+		return errors.New(constants.ErrClassNumberTaken)
+	}
+	if err != nil && !strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+		s.log.Error("Failed to look up class number", zap.Error(err), zap.String("class_number", classNumber))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	category.ClassificationScheme, category.ClassNumber = scheme, classNumber
+	return nil
+}
+
+// ImportClassification loads a scheme outline from CSV with the columns class_number, name and optionally
+// description and parent_class_number. Rows are upserted by class number, so re-importing a file is safe.
+// A row without an explicit parent is filed under the nearest broader class present in the file or the
+// catalog; an existing unclassified category with the same name is adopted instead of duplicated.
+// Each row is applied on its own, and rows that cannot be applied are reported rather than aborting the import.
+func (s *categoryService) ImportClassification(ctx context.Context, scheme string, r io.Reader) (*dao.ClassificationImportResponse, error) {
+	scheme, err := classification.ParseScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dao.ClassificationImportResponse{
+		Scheme: scheme,
+		Errors: make([]dao.ClassificationImportError, 0),
+	}
+
+	rows, err := parseClassificationCSV(scheme, r, response)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.categoryRepo.ListByScheme(ctx, scheme)
+	if err != nil {
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	byClass := make(map[string]*model.Category, len(existing)+len(rows))
+	for _, category := range existing {
+		byClass[category.ClassNumber] = category
+	}
+
+	inFile := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		inFile[row.classNumber] = true
+	}
+
+	for _, row := range rows {
+		if row.parentClass != "" {
+			continue
+		}
+		for parent := classification.Parent(scheme, row.classNumber); parent != ""; parent = classification.Parent(scheme, parent) {
+			if inFile[parent] || byClass[parent] != nil {
+				row.parentClass = parent
+				break
+			}
+		}
+	}
+
+	// Apply rows in passes so every parent exists before its children, whatever the file order
+	pending := rows
+	for len(pending) > 0 {
+		var next []*classificationRow
+		for _, row := range pending {
+			var parent *model.Category
+			if row.parentClass != "" {
+				if parent = byClass[row.parentClass]; parent == nil {
+					next = append(next, row)
+					continue
+				}
+			}
+
+			if err := s.importClassificationRow(ctx, scheme, row, parent, byClass, response); err != nil {
+				if err.Error() == constants.ErrInternalServer {
+					return nil, err
+				}
+				response.Errors = append(response.Errors, dao.ClassificationImportError{
+					Row:         row.line,
+					ClassNumber: row.classNumber,
+					Error:       err.Error(),
+				})
+			}
+		}
+
+		if len(next) == len(pending) {
+			for _, row := range next {
+				response.Errors = append(response.Errors, dao.ClassificationImportError{
+					Row:         row.line,
+					ClassNumber: row.classNumber,
+					Error:       fmt.Sprintf("parent class %s not found", row.parentClass),
+				})
+			}
+			break
+		}
+		pending = next
+	}
+
+	return response, nil
+}
+
+func (s *categoryService) importClassificationRow(
+	ctx context.Context,
+	scheme string,
+	row *classificationRow,
+	parent *model.Category,
+	byClass map[string]*model.Category,
+	response *dao.ClassificationImportResponse,
+) error {
+	if category, ok := byClass[row.classNumber]; ok {
+		changed, err := s.applyClassificationRow(ctx, category, row, parent)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			response.Skipped++
+			return nil
+		}
+		if err := s.categoryRepo.Update(ctx, category); err != nil {
+			s.log.Error("Failed to update classified category", zap.Error(err), zap.String("class_number", row.classNumber))
+			return errors.New(constants.ErrInternalServer)
+		}
+		response.Updated++
+		return nil
+	}
+
+	name := row.name
+	if named, err := s.categoryRepo.GetByName(ctx, row.name); err == nil && named != nil {
+		if named.ClassNumber == "" {
+			return s.adoptCategory(ctx, scheme, named, row, parent, byClass, response)
+		}
+		// The name belongs to another class, so qualify this one with its class number
+		name = fmt.Sprintf("%s (%s)", row.name, row.classNumber)
+		if len(name) > maxCategoryNameLength {
+			return fmt.Errorf("category with name %s already exists", row.name)
+		}
+		if _, err := s.categoryRepo.GetByName(ctx, name); err == nil {
+			return fmt.Errorf("category with name %s already exists", name)
+		}
+	}
+
+	category := model.NewCategory(name, row.description, parent)
+	category.ClassificationScheme, category.ClassNumber = scheme, row.classNumber
+
+	if err := s.assignSlug(ctx, category, ""); err != nil {
+		return err
+	}
+
+	if err := s.categoryRepo.Create(ctx, category); err != nil {
+		s.log.Error("Failed to create classified category", zap.Error(err), zap.String("class_number", row.classNumber))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	byClass[row.classNumber] = category
+	response.Created++
+	return nil
+}
+
+// adoptCategory attaches a class number to an existing unclassified category of the same name. Its description
+// is only filled in when empty, and it is only moved when it is currently a root.
+func (s *categoryService) adoptCategory(
+	ctx context.Context,
+	scheme string,
+	category *model.Category,
+	row *classificationRow,
+	parent *model.Category,
+	byClass map[string]*model.Category,
+	response *dao.ClassificationImportResponse,
+) error {
+	category.ClassificationScheme, category.ClassNumber = scheme, row.classNumber
+
+	if category.Description == "" {
+		category.Description = row.description
+	}
+
+	if parent != nil && category.ParentID == nil {
+		if err := ensureNoCycle(parent, category); err != nil {
+			return err
+		}
+		category.SetParent(parent)
+	}
+
+	if err := s.categoryRepo.Update(ctx, category); err != nil {
+		s.log.Error("Failed to classify category", zap.Error(err), zap.String("id", category.ID.String()))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	byClass[row.classNumber] = category
+	response.Updated++
+	return nil
+}
+
+// applyClassificationRow copies the row's name, description and parent onto an already classified category
+// and reports whether anything changed
+func (s *categoryService) applyClassificationRow(ctx context.Context, category *model.Category, row *classificationRow, parent *model.Category) (bool, error) {
+	changed := false
+
+	if row.name != category.Name {
+		if named, err := s.categoryRepo.GetByName(ctx, row.name); err == nil && named.ID != category.ID {
+			return false, fmt.Errorf("category with name %s already exists", row.name)
+		}
+		category.Name = row.name
+		changed = true
+	}
+
+	if row.description != "" && row.description != category.Description {
+		category.Description = row.description
+		changed = true
+	}
+
+	switch {
+	case parent == nil && category.ParentID != nil:
+		category.SetParent(nil)
+		changed = true
+	case parent != nil && (category.ParentID == nil || *category.ParentID != parent.ID):
+		if parent.ID == category.ID {
+			return false, errors.New("category cannot be its own parent")
+		}
+		if err := ensureNoCycle(parent, category); err != nil {
+			return false, err
+		}
+		category.SetParent(parent)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// parseClassificationCSV reads and validates the rows of a classification file. Malformed rows are recorded in
+// response.Errors; a missing header or unreadable file fails the whole import.
+func parseClassificationCSV(scheme string, r io.Reader, response *dao.ClassificationImportResponse) ([]*classificationRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: missing header row", constants.ErrInvalidClassificationFile)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	for _, required := range []string{"class_number", "name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%s: missing %s column", constants.ErrInvalidClassificationFile, required)
+		}
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]*classificationRow, 0)
+	seen := make(map[string]int)
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", constants.ErrInvalidClassificationFile, err)
+		}
+
+		if len(rows)+len(response.Errors) >= maxImportRows {
+			return nil, fmt.Errorf("%s: more than %d rows", constants.ErrInvalidClassificationFile, maxImportRows)
+		}
+
+		rowError := func(classNumber, message string) {
+			response.Errors = append(response.Errors, dao.ClassificationImportError{
+				Row:         line,
+				ClassNumber: classNumber,
+				Error:       message,
+			})
+		}
+
+		raw := field(record, "class_number")
+		_, classNumber, err := classification.Normalize(scheme, raw)
+		if err != nil {
+			rowError(raw, err.Error())
+			continue
+		}
+
+		name := field(record, "name")
+		if name == "" {
+			rowError(classNumber, "name is required")
+			continue
+		}
+		if len(name) > maxCategoryNameLength {
+			rowError(classNumber, fmt.Sprintf("name exceeds %d characters", maxCategoryNameLength))
+			continue
+		}
+
+		if first, ok := seen[classNumber]; ok {
+			rowError(classNumber, fmt.Sprintf("class number already defined on row %d", first))
+			continue
+		}
+
+		row := &classificationRow{
+			line:        line,
+			classNumber: classNumber,
+			name:        name,
+			description: field(record, "description"),
+		}
+
+		if rawParent := field(record, "parent_class_number"); rawParent != "" {
+			_, parentClass, err := classification.Normalize(scheme, rawParent)
+			if err != nil {
+				rowError(classNumber, err.Error())
+				continue
+			}
+			if parentClass == classNumber {
+				rowError(classNumber, "category cannot be its own parent")
+				continue
+			}
+			row.parentClass = parentClass
+		}
+
+		seen[classNumber] = line
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/category-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/category-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/category-service/internal/entity/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	maxSlugLength = 120
+	// maxSlugSuffix is how many numbered variants of a generated slug are tried before falling back to the id
+	maxSlugSuffix = 20
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func (s *categoryService) GetCategoryBySlug(ctx context.Context, slug string) (*dao.CategoryResponse, error) {
+	category, err := s.categoryRepo.GetBySlug(ctx, strings.ToLower(strings.TrimSpace(slug)))
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrCategoryNotFound) {
+			return nil, errors.New(constants.ErrCategoryNotFound)
+		}
+		s.log.Error("Failed to get category by slug", zap.Error(err), zap.String("slug", slug))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	response := dao.NewCategoryResponse(category)
+	s.localize(ctx, response)
+	s.attachTranslations(ctx, response)
+
+	return response, nil
+}
+
+// assignSlug sets a requested slug after checking it is well formed and free. Without one it derives a slug
+// from the name, numbering it when the plain form is taken.
+func (s *categoryService) assignSlug(ctx context.Context, category *model.Category, requested string) error {
+	if requested = strings.TrimSpace(requested); requested != "" {
+		if len(requested) > maxSlugLength || !slugPattern.MatchString(requested) {
+			return errors.New(constants.ErrInvalidSlug)
+		}
+
+		taken, err := s.categoryRepo.SlugExists(ctx, requested, category.ID)
+		if err != nil {
+			return errors.New(constants.ErrInternalServer)
+		}
+		if taken {
+			return errors.New(constants.ErrSlugTaken)
+		}
+
+		category.Slug = requested
+		return nil
+	}
+
+	base := utils.Slugify(category.Name)
+	if base == "" {
+		base = "category"
+	}
+	// Leave room for a numeric or id suffix
+	if len(base) > maxSlugLength-10 {
+		base = strings.TrimRight(base[:maxSlugLength-10], "-")
+	}
+
+	candidate := base
+	for i := 2; i <= maxSlugSuffix+1; i++ {
+		taken, err := s.categoryRepo.SlugExists(ctx, candidate, category.ID)
+		if err != nil {
+			return errors.New(constants.ErrInternalServer)
+		}
+		if !taken {
+			category.Slug = candidate
+			return nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	category.Slug = base + "-" + category.ID.String()[:8]
+	return nil
+}
+
+// translationChanges validates translation input and splits it into rows to upsert and locales to remove.
+// The default locale is rejected because it is stored on the category itself.
+func translationChanges(categoryID uuid.UUID, translations map[string]*dto.CategoryTranslationInput) ([]*model.CategoryTranslation, []string, error) {
+	var (
+		upserts []*model.CategoryTranslation
+		removed []string
+	)
+
+	for tag, input := range translations {
+		locale := middleware.NormalizeLocale(tag)
+		if locale == "" || locale == constants.DefaultLocale {
+			return nil, nil, fmt.Errorf("%s: %s", constants.ErrUnsupportedLocale, tag)
+		}
+
+		if input == nil {
+			removed = append(removed, locale)
+			continue
+		}
+
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("translation name is required for locale %s", locale)
+		}
+
+		upserts = append(upserts, &model.CategoryTranslation{
+			CategoryID:  categoryID,
+			Locale:      locale,
+			Name:        name,
+			Description: input.Description,
+		})
+	}
+
+	return upserts, removed, nil
+}
+
+func (s *categoryService) saveTranslations(ctx context.Context, categoryID uuid.UUID, upserts []*model.CategoryTranslation, removed []string) error {
+	if len(upserts) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	if err := s.categoryRepo.SaveTranslations(ctx, categoryID, upserts, removed); err != nil {
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	return nil
+}
+
+// localize swaps in the name and description for the request locale where a translation exists.
+// Translations are best effort: on failure the default locale is served.
+func (s *categoryService) localize(ctx context.Context, categories ...*dao.CategoryResponse) {
+	locale := middleware.LocaleFromContext(ctx)
+
+	for _, category := range categories {
+		category.Locale = constants.DefaultLocale
+	}
+
+	if locale == constants.DefaultLocale || len(categories) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(categories))
+	for _, category := range categories {
+		ids = append(ids, category.ID)
+	}
+
+	translations, err := s.categoryRepo.GetTranslationsForLocale(ctx, ids, locale)
+	if err != nil {
+		s.log.Warn("Serving categories untranslated", zap.Error(err), zap.String("locale", locale))
+		return
+	}
+
+	for _, category := range categories {
+		translation, ok := translations[category.ID]
+		if !ok {
+			continue
+		}
+
+		category.Name = translation.Name
+		if translation.Description != "" {
+			category.Description = translation.Description
+		}
+		category.Locale = locale
+	}
+}
+
+// localizeList localizes a slice of responses in place
+func (s *categoryService) localizeList(ctx context.Context, categories []dao.CategoryResponse) {
+	pointers := make([]*dao.CategoryResponse, 0, len(categories))
+	for i := range categories {
+		pointers = append(pointers, &categories[i])
+	}
+
+	s.localize(ctx, pointers...)
+}
+
+// attachTranslations adds every stored translation to a single category response
+func (s *categoryService) attachTranslations(ctx context.Context, category *dao.CategoryResponse) {
+	translations, err := s.categoryRepo.GetTranslations(ctx, category.ID)
+	if err != nil {
+		return
+	}
+
+	if len(translations) == 0 {
+		return
+	}
+
+	category.Translations = make(map[string]dao.CategoryTranslationResponse, len(translations))
+	for _, translation := range translations {
+		category.Translations[translation.Locale] = dao.CategoryTranslationResponse{
+			Name:        translation.Name,
+			Description: translation.Description,
+		}
+	}
+}
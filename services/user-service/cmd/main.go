@@ -14,9 +14,12 @@ import (
 	"github.com/fairuzald/library-system/pkg/cache"
 	"github.com/fairuzald/library-system/pkg/config"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/mail"
 	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/password"
 	"github.com/fairuzald/library-system/services/user-service/internal/module"
 	routes "github.com/fairuzald/library-system/services/user-service/internal/route"
+	"github.com/fairuzald/library-system/services/user-service/internal/service"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
@@ -85,10 +88,78 @@ func main() {
 		refreshTokenExpiry = 7 * 24 * time.Hour // 1 week
 	}
 
+	hasher, err := password.NewHasher(password.HashConfig{
+		Algorithm:         cfg.PasswordHashAlgorithm,
+		BcryptCost:        cfg.PasswordBcryptCost,
+		Argon2Memory:      uint32(cfg.PasswordArgon2Memory),
+		Argon2Iterations:  uint32(cfg.PasswordArgon2Iterations),
+		Argon2Parallelism: uint8(cfg.PasswordArgon2Parallelism),
+	})
+	if err != nil {
+		log.Fatal("Invalid password hashing configuration", zap.Error(err))
+	}
+
+	// bcrypt ignores everything past its input limit, so longer passwords are refused rather than truncated
+	passwordMaxLength := cfg.PasswordMaxLength
+	if limit := hasher.MaxPasswordBytes(); limit > 0 && (passwordMaxLength <= 0 || passwordMaxLength > limit) {
+		passwordMaxLength = limit
+	}
+
+	passwordPolicy := password.NewPolicy(password.PolicyConfig{
+		MinLength:      cfg.PasswordMinLength,
+		MaxLength:      passwordMaxLength,
+		MinCharClasses: cfg.PasswordMinCharClasses,
+		RejectPersonal: cfg.PasswordRejectPersonal,
+		RejectCommon:   cfg.PasswordRejectCommon,
+	})
+
+	var mailSender mail.Sender = mail.NewLogSender(log)
+	if cfg.SMTPHost != "" {
+		smtpSender, err := mail.NewSMTPSender(mail.SMTPConfig{
+			Host:       cfg.SMTPHost,
+			Port:       cfg.SMTPPort,
+			Username:   cfg.SMTPUsername,
+			Password:   cfg.SMTPPassword,
+			From:       cfg.SMTPFrom,
+			RequireTLS: cfg.SMTPRequireTLS,
+		})
+		if err != nil {
+			log.Fatal("Invalid SMTP configuration", zap.Error(err))
+		}
+		mailSender = smtpSender
+		log.Info("Sending mail through SMTP", zap.String("host", cfg.SMTPHost))
+	} else {
+		log.Warn("SMTP_HOST is not set, emails will only be logged")
+	}
+
+	trustedProxies, err := middleware.NewTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
 	userModule, err := module.New(
 		db,
 		redisClient,
 		cfg.JWTSecret,
+		cfg.JWTAlgorithm,
+		cfg.JWTKeyEncryptionKey,
+		cfg.JWTKeyRotationInterval,
+		cfg.TokenVersionFailOpen,
+		cfg.OIDCIssuer,
+		cfg.OIDCAuthorizationURL,
+		service.MFAConfig{
+			Issuer:        cfg.MFAIssuer,
+			RequiredRoles: cfg.MFARequiredRoles,
+		},
+		service.LoginProtectionConfig{
+			Account: service.LoginLimit{MaxAttempts: cfg.LoginMaxAttempts, Lockout: cfg.LoginLockout},
+			IP:      service.LoginLimit{MaxAttempts: cfg.LoginIPMaxAttempts, Lockout: cfg.LoginIPLockout},
+		},
+		trustedProxies,
+		service.PasswordConfig{Hasher: hasher, Policy: passwordPolicy},
+		mailSender,
+		cfg.PasswordResetURL,
+		cfg.EmailVerificationURL,
 		accessTokenExpiry,
 		refreshTokenExpiry,
 		log,
@@ -101,7 +172,7 @@ func main() {
 
 	requestLogger := middleware.NewRequestLogger(log)
 	recoveryMiddleware := middleware.NewRecoveryMiddleware(log)
-	router.Use(recoveryMiddleware.Middleware, requestLogger.Middleware)
+	router.Use(trustedProxies.Middleware, recoveryMiddleware.Middleware, requestLogger.Middleware)
 
 	router.HandleFunc("/health", userModule.HealthHandler.HandleHealth).Methods("GET")
 
@@ -109,7 +180,10 @@ func main() {
 		router,
 		userModule.UserHandler,
 		userModule.AuthHandler,
+		userModule.KeyHandler,
+		userModule.OAuthHandler,
 		userModule.JWTAuth,
+		userModule.Policy,
 		log,
 		cfg,
 	)
@@ -126,12 +200,12 @@ func main() {
 		grpc.ChainUnaryInterceptor(
 			middleware.UnaryRecoveryInterceptor(log),
 			middleware.UnaryLoggingInterceptor(log),
-			userModule.JWTAuth.UnaryInterceptor(),
+			userModule.Policy.UnaryInterceptor(userModule.JWTAuth, routes.GRPCAccess()),
 		),
 		grpc.ChainStreamInterceptor(
 			middleware.StreamRecoveryInterceptor(log),
 			middleware.StreamLoggingInterceptor(log),
-			userModule.JWTAuth.StreamInterceptor(),
+			userModule.Policy.StreamInterceptor(userModule.JWTAuth, routes.GRPCAccess()),
 		),
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			MaxConnectionIdle:     15 * time.Minute,
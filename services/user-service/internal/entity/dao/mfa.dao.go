@@ -0,0 +1,19 @@
+package dao
+
+// MFAEnrollmentResponse holds a new TOTP secret. ProvisioningURI is meant to be shown as a QR code; the
+// secret is for typing in by hand.
+type MFAEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RecoveryCodesResponse holds freshly generated recovery codes, which are only ever shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
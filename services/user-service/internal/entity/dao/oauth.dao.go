@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/google/uuid"
+)
+
+// OAuth error codes from RFC 6749 and RFC 6750
+const (
+	OAuthErrInvalidRequest       = "invalid_request"
+	OAuthErrInvalidClient        = "invalid_client"
+	OAuthErrInvalidGrant         = "invalid_grant"
+	OAuthErrUnauthorizedClient   = "unauthorized_client"
+	OAuthErrUnsupportedGrantType = "unsupported_grant_type"
+	OAuthErrUnsupportedResponse  = "unsupported_response_type"
+	OAuthErrInvalidScope         = "invalid_scope"
+	OAuthErrAccessDenied         = "access_denied"
+	OAuthErrInvalidToken         = "invalid_token"
+	OAuthErrInsufficientScope    = "insufficient_scope"
+	OAuthErrServerError          = "server_error"
+)
+
+// OAuthError is an error in the form the OAuth specifications prescribe, returned as the response body
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+type OAuthClientResponse struct {
+	ID           uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	Public       bool      `json:"public"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	// ClientSecret is only returned when the client is registered; it cannot be retrieved later
+	ClientSecret string    `json:"client_secret,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func NewOAuthClientResponse(client *model.OAuthClient) *OAuthClientResponse {
+	return &OAuthClientResponse{
+		ID:           client.ID,
+		Name:         client.Name,
+		Public:       client.Public,
+		RedirectURIs: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+type OAuthClientListResponse struct {
+	Clients []OAuthClientResponse `json:"clients"`
+}
+
+// OAuthAuthorizationResponse tells the consent page what the client is asking for
+type OAuthAuthorizationResponse struct {
+	ClientID        uuid.UUID `json:"client_id"`
+	ClientName      string    `json:"client_name"`
+	Scopes          []string  `json:"scopes"`
+	ConsentRequired bool      `json:"consent_required"`
+}
+
+// OAuthRedirectResponse is where the consent page sends the browser next
+type OAuthRedirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthIntrospectionResponse follows RFC 7662; inactive tokens only report active=false
+type OAuthIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+}
+
+type OAuthConsentResponse struct {
+	ClientID   uuid.UUID `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+type OAuthConsentListResponse struct {
+	Consents []OAuthConsentResponse `json:"consents"`
+}
+
+// OIDCDiscovery is the OpenID Provider metadata served at /.well-known/openid-configuration
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	AuthorizationResponseISSSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
@@ -0,0 +1,27 @@
+package dao
+
+import "time"
+
+// ThrottledError turns a request away until RetryAfter has passed. Its message is one of the constants
+// errors, so handlers that compare messages still recognise it.
+type ThrottledError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func NewThrottledError(message string, retryAfter time.Duration) *ThrottledError {
+	return &ThrottledError{Message: message, RetryAfter: retryAfter}
+}
+
+func (e *ThrottledError) Error() string {
+	return e.Message
+}
+
+// RetryAfterSeconds rounds the wait up to whole seconds, for Retry-After headers
+func (e *ThrottledError) RetryAfterSeconds() int64 {
+	seconds := int64((e.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
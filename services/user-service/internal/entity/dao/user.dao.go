@@ -8,31 +8,34 @@ import (
 )
 
 type UserResponse struct {
-	ID        uuid.UUID  `json:"id"`
-	Email     string     `json:"email"`
-	Username  string     `json:"username"`
-	FirstName string     `json:"first_name"`
-	LastName  string     `json:"last_name"`
-	Role      string     `json:"role"`
-	Status    string     `json:"status"`
-	Phone     string     `json:"phone,omitempty"`
-	Address   string     `json:"address,omitempty"`
-	LastLogin *time.Time `json:"last_login,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	// EmailVerified is set once the user follows the link in their verification email
+	EmailVerified bool       `json:"email_verified"`
+	Phone         string     `json:"phone,omitempty"`
+	Address       string     `json:"address,omitempty"`
+	LastLogin     *time.Time `json:"last_login,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 func NewUserResponse(user *model.User) *UserResponse {
 	response := &UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Username:  user.Username,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Role:      user.Role,
-		Status:    user.Status,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Role:          user.Role,
+		Status:        user.Status,
+		EmailVerified: user.EmailVerified(),
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
 	}
 
 	if user.Phone != "" {
@@ -51,18 +54,71 @@ func NewUserResponse(user *model.User) *UserResponse {
 	return response
 }
 
+type UserBatchResponse struct {
+	Users      []UserResponse `json:"users"`
+	MissingIDs []string       `json:"missing_ids"`
+}
+
 type UserListResponse struct {
 	Users       []UserResponse `json:"users"`
 	TotalItems  int64          `json:"total_items"`
 	TotalPages  int            `json:"total_pages"`
 	CurrentPage int            `json:"current_page"`
 	PageSize    int            `json:"page_size"`
+	NextCursor  string         `json:"next_cursor,omitempty"`
+	PrevCursor  string         `json:"prev_cursor,omitempty"`
 }
 
+// TokenResponse is the result of a login or refresh. When login needs a second factor it carries only the
+// MFA fields, and the tokens are issued once the challenge is answered.
 type TokenResponse struct {
-	AccessToken  string        `json:"access_token"`
+	AccessToken  string        `json:"access_token,omitempty"`
 	RefreshToken string        `json:"refresh_token,omitempty"`
-	TokenType    string        `json:"token_type"`
-	ExpiresIn    int64         `json:"expires_in"` // in seconds
+	TokenType    string        `json:"token_type,omitempty"`
+	ExpiresIn    int64         `json:"expires_in,omitempty"` // in seconds
+	SessionID    string        `json:"session_id,omitempty"`
 	User         *UserResponse `json:"user,omitempty"`
+
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAToken              string `json:"mfa_token,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	// RecoveryCodes are returned once, when two-factor authentication is set up during login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func NewSessionResponse(session *model.Session, currentSessionID string) *SessionResponse {
+	return &SessionResponse{
+		ID:         session.ID,
+		DeviceName: session.DeviceName,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		Current:    session.ID.String() == currentSessionID,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+type TokenVersionResponse struct {
+	UserID       string `json:"user_id"`
+	TokenVersion int64  `json:"token_version"`
 }
@@ -0,0 +1,20 @@
+package dto
+
+// MFALogin completes a login that returned an MFA challenge. Code is a TOTP code or a recovery code.
+type MFALogin struct {
+	MFAToken   string `json:"mfa_token" validate:"required"`
+	Code       string `json:"code" validate:"required,max=32"`
+	DeviceName string `json:"device_name,omitempty" validate:"omitempty,max=100"`
+	ClientInfo
+}
+
+// MFAEnrollWithToken starts enrollment for a user whose role requires two-factor authentication but who has
+// not set it up, using the challenge returned by login
+type MFAEnrollWithToken struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+// MFACode confirms an action with a TOTP code, or a recovery code where the action allows one
+type MFACode struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
@@ -0,0 +1,40 @@
+package dto
+
+type OAuthClientCreate struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes,omitempty" validate:"omitempty,dive,oneof=openid profile email offline_access"`
+	Public       bool     `json:"public"`
+}
+
+// OAuthAuthorize is an authorization request as the client sent it to the consent page, which passes it on
+// together with the user's decision
+type OAuthAuthorize struct {
+	ResponseType        string `json:"response_type" validate:"required"`
+	ClientID            string `json:"client_id" validate:"required"`
+	RedirectURI         string `json:"redirect_uri" validate:"required"`
+	Scope               string `json:"scope" validate:"required"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty" validate:"omitempty,max=255"`
+	CodeChallenge       string `json:"code_challenge,omitempty" validate:"omitempty,min=43,max=128"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+	Approve             bool   `json:"approve"`
+}
+
+// OAuthClientCredentials identifies the client calling the token, introspection or revocation endpoint,
+// from HTTP Basic authentication or the client_id and client_secret form fields
+type OAuthClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// OAuthTokenRequest is a token endpoint request, form-encoded as RFC 6749 requires
+type OAuthTokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	OAuthClientCredentials
+}
@@ -7,7 +7,7 @@ import (
 type UserCreate struct {
 	Email     string `json:"email" validate:"required,email"`
 	Username  string `json:"username" validate:"required,min=3,max=30"`
-	Password  string `json:"password" validate:"required,min=8"`
+	Password  string `json:"password" validate:"required"`
 	FirstName string `json:"first_name" validate:"required"`
 	LastName  string `json:"last_name" validate:"required"`
 	Role      string `json:"role" validate:"required,oneof=admin librarian member guest"`
@@ -26,18 +26,53 @@ type UserUpdate struct {
 	Address   *string `json:"address,omitempty"`
 }
 
+// ChangesAccess reports whether the update touches the role or status, which only administrators may change
+func (u *UserUpdate) ChangesAccess() bool {
+	return u.Role != nil || u.Status != nil
+}
+
 type UserLogin struct {
 	UsernameOrEmail string `json:"username_or_email" validate:"required"`
 	Password        string `json:"password" validate:"required"`
+	DeviceName      string `json:"device_name,omitempty" validate:"omitempty,max=100"`
+	ClientInfo
+}
+
+// ClientInfo describes the client a session is used from; it is filled from the request, not the body
+type ClientInfo struct {
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
 type ChangePassword struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
-	NewPassword     string `json:"new_password" validate:"required,min=8"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// VerifyEmail confirms an email address with the token from a verification email
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required,max=2048"`
+}
+
+// ResendVerification asks for a new verification email
+type ResendVerification struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPassword sets a new password with the token from a password reset email
+type ResetPassword struct {
+	Token       string `json:"token" validate:"required,max=128"`
+	NewPassword string `json:"new_password" validate:"required"`
+	ClientInfo
 }
 
 type RefreshToken struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
+	ClientInfo
 }
 
 type UserFilter struct {
@@ -47,12 +82,14 @@ type UserFilter struct {
 	Limit  int    `form:"limit,default=10" query:"limit,default=10"`
 	SortBy string `form:"sort_by,default=created_at" query:"sort_by,default=created_at"`
 	Desc   bool   `form:"desc" query:"desc"`
+	Filter string `form:"filter" query:"filter"`
+	Cursor string `form:"cursor" query:"cursor"`
 }
 
 type UserRegister struct {
 	Email     string `json:"email" validate:"required,email"`
 	Username  string `json:"username" validate:"required,min=3,max=30"`
-	Password  string `json:"password" validate:"required,min=8"`
+	Password  string `json:"password" validate:"required"`
 	FirstName string `json:"first_name" validate:"required"`
 	LastName  string `json:"last_name" validate:"required"`
 	Phone     string `json:"phone,omitempty"`
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Account token purposes
+const (
+	AccountTokenPasswordReset = "password_reset"
+)
+
+// AccountToken is a single-use, expiring token sent to a user by email to prove they own the address. Only
+// a hash of the token is stored.
+type AccountToken struct {
+	models.Base
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Purpose   string     `gorm:"type:varchar(30);not null" json:"purpose"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"type:timestamp" json:"used_at,omitempty"`
+}
+
+func (AccountToken) TableName() string {
+	return "account_tokens"
+}
+
+func NewAccountToken(userID uuid.UUID, purpose, tokenHash string, expiresAt time.Time) *AccountToken {
+	return &AccountToken{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	}
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+// MFACredential is a user's TOTP authenticator. It is pending until the user proves they set it up by
+// entering a code, and only then required at login.
+type MFACredential struct {
+	models.Base
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	Secret      string     `gorm:"type:varchar(64);not null" json:"-"`
+	ConfirmedAt *time.Time `gorm:"type:timestamp" json:"confirmed_at,omitempty"`
+	// LastUsedStep is the TOTP time step of the last accepted code; codes for it or earlier steps are rejected
+	LastUsedStep   int64      `gorm:"not null;default:0" json:"-"`
+	FailedAttempts int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil    *time.Time `gorm:"type:timestamp" json:"-"`
+}
+
+func (MFACredential) TableName() string {
+	return "mfa_credentials"
+}
+
+func NewMFACredential(userID uuid.UUID, secret string) *MFACredential {
+	return &MFACredential{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		UserID: userID,
+		Secret: secret,
+	}
+}
+
+// Confirmed reports whether the credential is in use
+func (c *MFACredential) Confirmed() bool {
+	return c.ConfirmedAt != nil
+}
+
+// Locked reports whether too many wrong codes were entered recently
+func (c *MFACredential) Locked() bool {
+	return c.LockedUntil != nil && c.LockedUntil.After(time.Now())
+}
+
+// MFARecoveryCode is a single-use code that stands in for a TOTP code when the authenticator is lost. Only a
+// hash of the code is stored.
+type MFARecoveryCode struct {
+	models.Base
+	UserID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CodeHash string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt   *time.Time `gorm:"type:timestamp" json:"used_at,omitempty"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+func NewMFARecoveryCode(userID uuid.UUID, codeHash string) *MFARecoveryCode {
+	return &MFARecoveryCode{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		UserID:   userID,
+		CodeHash: codeHash,
+	}
+}
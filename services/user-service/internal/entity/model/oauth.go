@@ -0,0 +1,144 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OAuth scopes the provider understands
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// SupportedScopes lists every scope a client may be allowed
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// OAuthClient is a partner application users can sign in to with their library account. Public clients,
+// such as apps installed on a device, cannot keep a secret and must use PKCE instead.
+type OAuthClient struct {
+	models.Base
+	Name         string         `gorm:"type:varchar(100);not null" json:"name"`
+	SecretHash   string         `gorm:"type:varchar(64)" json:"-"`
+	Public       bool           `gorm:"not null;default:false" json:"public"`
+	RedirectURIs pq.StringArray `gorm:"type:text[];not null" json:"redirect_uris"`
+	Scopes       pq.StringArray `gorm:"type:text[];not null" json:"scopes"`
+	CreatedBy    uuid.UUID      `gorm:"type:uuid;not null" json:"created_by"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func NewOAuthClient(name string, redirectURIs, scopes []string, public bool, createdBy uuid.UUID) *OAuthClient {
+	return &OAuthClient{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		Name:         name,
+		Public:       public,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		CreatedBy:    createdBy,
+	}
+}
+
+// HasRedirectURI reports whether uri is registered exactly as given
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScopes reports whether the client may request every one of the scopes
+func (c *OAuthClient) AllowsScopes(scopes []string) bool {
+	return ScopesCover(c.Scopes, scopes)
+}
+
+// OAuthAuthorizationCode is a single-use code handed to a client through its redirect URI. Only a hash of
+// the code is stored.
+type OAuthAuthorizationCode struct {
+	models.Base
+	CodeHash      string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ClientID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"client_id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	RedirectURI   string     `gorm:"type:text;not null" json:"redirect_uri"`
+	Scope         string     `gorm:"type:text;not null" json:"scope"`
+	Nonce         string     `gorm:"type:varchar(255)" json:"-"`
+	CodeChallenge string     `gorm:"type:varchar(128)" json:"-"`
+	AuthTime      time.Time  `gorm:"type:timestamp;not null" json:"auth_time"`
+	ExpiresAt     time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`
+	UsedAt        *time.Time `gorm:"type:timestamp" json:"used_at,omitempty"`
+}
+
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// OAuthConsent records the scopes a user has agreed to share with a client
+type OAuthConsent struct {
+	models.Base
+	UserID   uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_oauth_consents_user_client" json:"user_id"`
+	ClientID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_oauth_consents_user_client" json:"client_id"`
+	Scope    string       `gorm:"type:text;not null" json:"scope"`
+	Client   *OAuthClient `gorm:"foreignKey:ClientID" json:"-"`
+}
+
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// OAuthRefreshToken lets a client that was granted offline_access get new access tokens. Each use replaces
+// it with a new one. Only a hash of the token is stored.
+type OAuthRefreshToken struct {
+	models.Base
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ClientID  uuid.UUID `gorm:"type:uuid;not null;index" json:"client_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Scope     string    `gorm:"type:text;not null" json:"scope"`
+	AuthTime  time.Time `gorm:"type:timestamp;not null" json:"auth_time"`
+	// TokenVersion is the user's token version at issue; revoking all of the user's tokens ends the grant
+	TokenVersion int64      `gorm:"not null;default:0" json:"-"`
+	ExpiresAt    time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`
+	RevokedAt    *time.Time `gorm:"type:timestamp" json:"revoked_at,omitempty"`
+}
+
+func (OAuthRefreshToken) TableName() string {
+	return "oauth_refresh_tokens"
+}
+
+// Active reports whether the token can still be exchanged
+func (t *OAuthRefreshToken) Active() bool {
+	return t.RevokedAt == nil && t.ExpiresAt.After(time.Now())
+}
+
+// ParseScope splits a space-separated scope string
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// ScopesCover reports whether granted includes every one of the requested scopes
+func ScopesCover(granted, requested []string) bool {
+	for _, scope := range requested {
+		found := false
+		for _, g := range granted {
+			if g == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"github.com/fairuzald/library-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Security event types
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	SecurityEventMFALocked         = "mfa_locked"
+	SecurityEventMFARecoveryUsed   = "mfa_recovery_code_used"
+	SecurityEventMFADisabled       = "mfa_disabled"
+	SecurityEventPasswordReset     = "password_reset"
+	SecurityEventLoginLocked       = "login_locked"
+	SecurityEventLoginUnlocked     = "login_unlocked"
+)
+
+// SecurityEvent records suspicious activity on an account for later review
+type SecurityEvent struct {
+	models.Base
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	SessionID *uuid.UUID `gorm:"type:uuid" json:"session_id,omitempty"`
+	Type      string     `gorm:"type:varchar(50);not null;index" json:"type"`
+	IPAddress string     `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+	UserAgent string     `gorm:"type:varchar(255)" json:"user_agent,omitempty"`
+	Details   string     `gorm:"type:text" json:"details,omitempty"`
+}
+
+func (SecurityEvent) TableName() string {
+	return "security_events"
+}
+
+func NewSecurityEvent(userID uuid.UUID, eventType string) *SecurityEvent {
+	return &SecurityEvent{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		UserID: userID,
+		Type:   eventType,
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Session is one signed-in device. Its refresh tokens form a rotation family: each refresh replaces the
+// current token, and the used ones are kept until the session expires so that replaying one can be detected.
+type Session struct {
+	models.Base
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	DeviceName string     `gorm:"type:varchar(100)" json:"device_name,omitempty"`
+	UserAgent  string     `gorm:"type:varchar(255)" json:"user_agent,omitempty"`
+	IPAddress  string     `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+	LastUsedAt time.Time  `gorm:"type:timestamp;not null" json:"last_used_at"`
+	ExpiresAt  time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"type:timestamp" json:"revoked_at,omitempty"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+func NewSession(userID uuid.UUID, expiresAt time.Time) *Session {
+	return &Session{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		UserID:     userID,
+		LastUsedAt: time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+}
+
+// Active reports whether the session can still be refreshed
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+// RefreshToken is one token of a session's rotation family. Only a hash of the token is stored.
+type RefreshToken struct {
+	models.Base
+	SessionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"type:timestamp" json:"used_at,omitempty"`
+	Session   *Session   `gorm:"foreignKey:SessionID" json:"-"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+func NewRefreshToken(sessionID uuid.UUID, tokenHash string) *RefreshToken {
+	return &RefreshToken{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		SessionID: sessionID,
+		TokenHash: tokenHash,
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+// SigningKey is a key pair access tokens are signed with; its ID is the kid in token headers. A key is in
+// the JWKS from creation, signs tokens from ActivatesAt until a newer key activates, and is deleted once
+// every token it signed has expired. PrivateKey is encrypted under the key encryption key.
+type SigningKey struct {
+	models.Base
+	Algorithm   string    `gorm:"type:varchar(10);not null" json:"algorithm"`
+	PrivateKey  []byte    `gorm:"type:bytea;not null" json:"-"`
+	PublicKey   []byte    `gorm:"type:bytea;not null" json:"-"`
+	ActivatesAt time.Time `gorm:"type:timestamp;not null;index" json:"activates_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
+
+// NewSigningKey wraps an encrypted PKCS #8 private key and a PKIX DER encoded public key
+func NewSigningKey(algorithm string, privateKey, publicKey []byte, activatesAt time.Time) *SigningKey {
+	return &SigningKey{
+		Base: models.Base{
+			ID: uuid.New(),
+		},
+		Algorithm:   algorithm,
+		PrivateKey:  privateKey,
+		PublicKey:   publicKey,
+		ActivatesAt: activatesAt,
+	}
+}
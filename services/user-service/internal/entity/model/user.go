@@ -10,18 +10,26 @@ import (
 
 type User struct {
 	models.Base
-	Email           string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
-	Username        string    `gorm:"type:varchar(30);uniqueIndex;not null" json:"username"`
-	Password        string    `gorm:"type:varchar(255);not null" json:"-"`
-	FirstName       string    `gorm:"type:varchar(100);not null" json:"first_name"`
-	LastName        string    `gorm:"type:varchar(100);not null" json:"last_name"`
-	Role            string    `gorm:"type:varchar(20);not null;default:'member'" json:"role"`
-	Status          string    `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
-	Phone           string    `gorm:"type:varchar(20)" json:"phone,omitempty"`
-	Address         string    `gorm:"type:text" json:"address,omitempty"`
-	LastLogin       time.Time `gorm:"type:timestamp" json:"last_login,omitempty"`
-	RefreshToken    string    `gorm:"type:varchar(255)" json:"-"`
-	RefreshTokenExp time.Time `gorm:"type:timestamp" json:"-"`
+	Email    string `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
+	Username string `gorm:"type:varchar(30);uniqueIndex;not null" json:"username"`
+	// Password is left out of the cached JSON, so it only changes through UserRepository.UpdatePassword and
+	// ReplacePasswordHash; a Save of a cached user would otherwise blank it
+	Password  string    `gorm:"type:varchar(255);not null;<-:create" json:"-"`
+	FirstName string    `gorm:"type:varchar(100);not null" json:"first_name"`
+	LastName  string    `gorm:"type:varchar(100);not null" json:"last_name"`
+	Role      string    `gorm:"type:varchar(20);not null;default:'member'" json:"role"`
+	Status    string    `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
+	Phone     string    `gorm:"type:varchar(20)" json:"phone,omitempty"`
+	Address   string    `gorm:"type:text" json:"address,omitempty"`
+	LastLogin time.Time `gorm:"type:timestamp" json:"last_login,omitempty"`
+	// EmailVerifiedAt is when the user proved they own Email; it is cleared when the address changes
+	EmailVerifiedAt *time.Time `gorm:"type:timestamp" json:"email_verified_at,omitempty"`
+	// VerificationSentAt is when the last verification email was sent, to throttle resends
+	VerificationSentAt *time.Time `gorm:"type:timestamp" json:"-"`
+	// PasswordResetSentAt is when the last password reset email was sent, to throttle repeated requests
+	PasswordResetSentAt *time.Time `gorm:"type:timestamp" json:"-"`
+	// TokenVersion only changes through UserRepository.IncrementTokenVersion, so a stale Save cannot roll it back
+	TokenVersion int64 `gorm:"not null;default:0;<-:create" json:"token_version"`
 }
 
 func (User) TableName() string {
@@ -46,3 +54,8 @@ func NewUser(email, username, password, firstName, lastName, role string) *User
 		Status:    constants.UserStatusActive,
 	}
 }
+
+// EmailVerified reports whether the user has confirmed their email address
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
@@ -2,17 +2,52 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/models"
+	"github.com/fairuzald/library-system/pkg/password"
 	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/user-service/internal/service"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
+// clientInfo describes the client making the request, for recording on its session
+func clientInfo(r *http.Request) dto.ClientInfo {
+	return dto.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: middleware.ClientIP(r),
+	}
+}
+
+// accountRefused reports whether err turns away an account that is pending, blocked or inactive
+func accountRefused(err error) bool {
+	switch err.Error() {
+	case constants.ErrAccountPending, constants.ErrAccountBlocked, constants.ErrAccountInactive:
+		return true
+	default:
+		return false
+	}
+}
+
+// respondWithWeakPassword rejects a password the policy turned down, listing the rules it broke
+func respondWithWeakPassword(w http.ResponseWriter, weak *password.PolicyError) {
+	utils.RespondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{
+		Status:  http.StatusBadRequest,
+		Message: weak.Error(),
+		Details: weak.Violations,
+	})
+}
+
 type AuthHandler struct {
 	authService service.AuthService
 	log         *logger.Logger
@@ -40,8 +75,20 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.ClientInfo = clientInfo(r)
+
 	response, err := h.authService.Login(r.Context(), &req)
 	if err != nil {
+		var throttled *dao.ThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.FormatInt(throttled.RetryAfterSeconds(), 10))
+			utils.RespondWithError(w, http.StatusTooManyRequests, throttled.Error(), nil)
+			return
+		}
+		if accountRefused(err) {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error(), nil)
+			return
+		}
 		if err.Error() == constants.ErrInvalidCredentials {
 			utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrInvalidCredentials, nil)
 			return
@@ -52,6 +99,11 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if response.MFARequired {
+		utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication required", response)
+		return
+	}
+
 	utils.RespondWithSuccess(w, http.StatusOK, "Login successful", response)
 }
 
@@ -72,6 +124,11 @@ func (h *AuthHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.authService.Register(r.Context(), &req)
 	if err != nil {
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			respondWithWeakPassword(w, weak)
+			return
+		}
 		if err.Error() == constants.ErrEmailTaken || err.Error() == constants.ErrUsernameTaken {
 			utils.RespondWithError(w, http.StatusConflict, err.Error(), nil)
 			return
@@ -82,7 +139,7 @@ func (h *AuthHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusCreated, "User registered successfully", user)
+	utils.RespondWithSuccess(w, http.StatusCreated, "User registered; follow the link sent to your email address to activate the account", user)
 }
 
 func (h *AuthHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
@@ -100,10 +157,16 @@ func (h *AuthHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	response, err := h.authService.RefreshToken(r.Context(), req.RefreshToken)
+	req.ClientInfo = clientInfo(r)
+
+	response, err := h.authService.RefreshToken(r.Context(), &req)
 	if err != nil {
-		if err.Error() == constants.ErrInvalidToken {
-			utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrInvalidToken, nil)
+		if err.Error() == constants.ErrInvalidToken || err.Error() == constants.ErrTokenRevoked {
+			utils.RespondWithError(w, http.StatusUnauthorized, err.Error(), nil)
+			return
+		}
+		if accountRefused(err) {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error(), nil)
 			return
 		}
 
@@ -143,3 +206,143 @@ func (h *AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondWithSuccess(w, http.StatusOK, "Logout successful", nil)
 }
+
+// sessionOwner returns the authenticated user and the session their token was issued for
+func sessionOwner(r *http.Request) (uuid.UUID, string, bool) {
+	userIDStr, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		return uuid.Nil, "", false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+
+	sessionID, _ := r.Context().Value(middleware.SessionIDKey).(string)
+	return userID, sessionID, true
+}
+
+func (h *AuthHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, currentSessionID, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	response, err := h.authService.ListSessions(r.Context(), userID, currentSessionID)
+	if err != nil {
+		h.log.Error("Failed to list sessions", zap.Error(err), zap.String("user_id", userID.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Sessions retrieved successfully", response)
+}
+
+func (h *AuthHandler) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	sessionID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	if err := h.authService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if err.Error() == constants.ErrSessionNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrSessionNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to revoke session", zap.Error(err), zap.String("session_id", sessionID.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Session revoked successfully", nil)
+}
+
+// HandleRevokeSessions signs the user out everywhere, invalidating their access tokens as well as their
+// sessions. With keep_current=true only the other sessions are revoked and access tokens are left alone.
+func (h *AuthHandler) HandleRevokeSessions(w http.ResponseWriter, r *http.Request) {
+	userID, currentSessionID, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	var (
+		response *dao.RevokeSessionsResponse
+		err      error
+	)
+
+	if keepCurrent, _ := utils.ParseBool(r.URL.Query().Get("keep_current")); keepCurrent {
+		sessionID, parseErr := uuid.Parse(currentSessionID)
+		if parseErr != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Token is not bound to a session", nil)
+			return
+		}
+		response, err = h.authService.RevokeSessions(r.Context(), userID, &sessionID)
+	} else {
+		response, err = h.authService.RevokeAllTokens(r.Context(), userID)
+	}
+
+	if err != nil {
+		h.log.Error("Failed to revoke sessions", zap.Error(err), zap.String("user_id", userID.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Sessions revoked successfully", response)
+}
+
+// HandleTokenVersion returns the caller's current token version. Other services call it with the token they
+// are checking when their published copy of the version is missing.
+func (h *AuthHandler) HandleTokenVersion(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	response, err := h.authService.GetTokenVersion(r.Context(), userID)
+	if err != nil {
+		if err.Error() == constants.ErrUserNotFound {
+			utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+			return
+		}
+
+		h.log.Error("Failed to get token version", zap.Error(err), zap.String("user_id", userID.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Token version retrieved successfully", response)
+}
+
+// HandleUnlockLogin clears a user's failed logins and lockout
+func (h *AuthHandler) HandleUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	if err := h.authService.UnlockLogin(r.Context(), userID); err != nil {
+		if strings.Contains(err.Error(), constants.ErrUserNotFound) {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrUserNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to unlock login", zap.Error(err), zap.String("user_id", userID.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Login lockout cleared", nil)
+}
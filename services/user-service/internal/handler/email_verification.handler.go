@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"go.uber.org/zap"
+)
+
+// HandleVerifyEmail confirms an email address with the token from a verification link, activating a pending
+// account
+func (h *AuthHandler) HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req dto.VerifyEmail
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode verify email request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for verify email request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	if err := h.authService.VerifyEmail(r.Context(), req.Token); err != nil {
+		if err.Error() == constants.ErrInvalidAccountToken {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+
+		h.log.Error("Failed to verify email", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Email address verified", nil)
+}
+
+// HandleResendVerificationEmail sends a new verification link to an account that has not verified its email
+func (h *AuthHandler) HandleResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	var req dto.ResendVerification
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode resend verification request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for resend verification request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	if err := h.authService.ResendVerificationEmail(r.Context(), req.Email); err != nil {
+		if err.Error() == constants.ErrVerificationThrottled {
+			utils.RespondWithError(w, http.StatusTooManyRequests, err.Error(), nil)
+			return
+		}
+
+		h.log.Error("Failed to resend verification email", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusAccepted, "If the account is waiting for verification, a new link has been sent", nil)
+}
@@ -2,37 +2,86 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"strconv"
 	"strings"
 
 	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/password"
+	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/proto/user"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/user-service/internal/service"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// throttledStatus reports a throttled request as ResourceExhausted, with the wait as RetryInfo details and a
+// retry-after header for clients that do not read details
+func throttledStatus(ctx context.Context, throttled *dao.ThrottledError) error {
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.FormatInt(throttled.RetryAfterSeconds(), 10)))
+
+	st := status.New(codes.ResourceExhausted, throttled.Error())
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(throttled.RetryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// weakPasswordStatus reports a password the policy turned down as InvalidArgument, with each broken rule as
+// a field violation
+func weakPasswordStatus(field string, weak *password.PolicyError) error {
+	st := status.New(codes.InvalidArgument, weak.Error())
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(weak.Violations))
+	for _, violation := range weak.Violations {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: violation})
+	}
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
 type UserService struct {
 	user.UnimplementedUserServiceServer
 	userService service.UserService
 	authService service.AuthService
+	policy      *middleware.Policy
+	proxies     *middleware.TrustedProxies
 	log         *logger.Logger
 }
 
-func NewUserService(userService service.UserService, authService service.AuthService, log *logger.Logger) *UserService {
+func NewUserService(userService service.UserService, authService service.AuthService, policy *middleware.Policy, proxies *middleware.TrustedProxies, log *logger.Logger) *UserService {
 	return &UserService{
 		userService: userService,
 		authService: authService,
+		policy:      policy,
+		proxies:     proxies,
 		log:         log,
 	}
 }
 
+// clientInfo describes the client of a call. The IP address in the request is only believed from a
+// trusted proxy, as it decides which login throttle the call counts against.
+func (s *UserService) clientInfo(ctx context.Context, userAgent, ipAddress string) dto.ClientInfo {
+	return dto.ClientInfo{
+		UserAgent: userAgent,
+		IPAddress: s.proxies.GRPCClientIP(ctx, ipAddress),
+	}
+}
+
 func (s *UserService) GetUser(ctx context.Context, req *user.GetUserRequest) (*user.UserResponse, error) {
 	id, err := uuid.Parse(req.GetId())
 	if err != nil {
@@ -51,6 +100,28 @@ func (s *UserService) GetUser(ctx context.Context, req *user.GetUserRequest) (*u
 	return convertUserToProto(userResponse), nil
 }
 
+func (s *UserService) BatchGetUsers(ctx context.Context, req *user.BatchGetUsersRequest) (*user.BatchGetUsersResponse, error) {
+	response, err := s.userService.BatchGetUsers(ctx, req.GetIds())
+	if err != nil {
+		if err.Error() == constants.ErrBatchTooLarge {
+			return nil, status.Error(codes.InvalidArgument, constants.ErrBatchTooLarge)
+		}
+		s.log.Error("Failed to batch get users", zap.Error(err))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	protoResponse := &user.BatchGetUsersResponse{
+		Users:      make([]*user.User, 0, len(response.Users)),
+		MissingIds: response.MissingIDs,
+	}
+
+	for _, u := range response.Users {
+		protoResponse.Users = append(protoResponse.Users, convertDaoUserToProtoUser(&u))
+	}
+
+	return protoResponse, nil
+}
+
 func (s *UserService) GetUserByEmail(ctx context.Context, req *user.GetUserByEmailRequest) (*user.UserResponse, error) {
 	if req.GetEmail() == "" {
 		return nil, status.Error(codes.InvalidArgument, "email is required")
@@ -107,8 +178,22 @@ func (s *UserService) ListUsers(ctx context.Context, req *user.ListUsersRequest)
 		filter.Desc = req.GetSortDesc()
 	}
 
+	if req.Filter != nil {
+		filter.Filter = req.GetFilter()
+	}
+
+	if req.Cursor != nil {
+		filter.Cursor = req.GetCursor()
+	}
+
 	response, err := s.userService.ListUsers(ctx, filter)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			return nil, status.Error(codes.InvalidArgument, constants.ErrInvalidCursor)
+		}
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidFilter) || strings.HasPrefix(err.Error(), constants.ErrInvalidSort) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		s.log.Error("Failed to list users", zap.Error(err))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
@@ -119,6 +204,8 @@ func (s *UserService) ListUsers(ctx context.Context, req *user.ListUsersRequest)
 		TotalPages:  int32(response.TotalPages),
 		CurrentPage: int32(response.CurrentPage),
 		PageSize:    int32(response.PageSize),
+		NextCursor:  response.NextCursor,
+		PrevCursor:  response.PrevCursor,
 	}
 
 	for _, u := range response.Users {
@@ -148,6 +235,10 @@ func (s *UserService) CreateUser(ctx context.Context, req *user.CreateUserReques
 
 	userResponse, err := s.userService.CreateUser(ctx, createDTO)
 	if err != nil {
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			return nil, weakPasswordStatus("password", weak)
+		}
 		if err.Error() == constants.ErrEmailTaken || err.Error() == constants.ErrUsernameTaken {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
@@ -244,6 +335,10 @@ func (s *UserService) ChangePassword(ctx context.Context, req *user.ChangePasswo
 		return nil, status.Error(codes.InvalidArgument, "invalid user ID")
 	}
 
+	if !middleware.IsSubject(ctx, id.String()) {
+		return nil, status.Error(codes.PermissionDenied, constants.ErrForbidden)
+	}
+
 	changePasswordDTO := &dto.ChangePassword{
 		CurrentPassword: req.GetCurrentPassword(),
 		NewPassword:     req.GetNewPassword(),
@@ -256,8 +351,9 @@ func (s *UserService) ChangePassword(ctx context.Context, req *user.ChangePasswo
 		if err.Error() == constants.ErrInvalidCredentials {
 			return nil, status.Error(codes.Unauthenticated, constants.ErrInvalidCredentials)
 		}
-		if err.Error() == constants.ErrWeakPassword {
-			return nil, status.Error(codes.InvalidArgument, constants.ErrWeakPassword)
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			return nil, weakPasswordStatus("new_password", weak)
 		}
 		s.log.Error("Failed to change password", zap.Error(err), zap.String("id", id.String()))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
@@ -270,10 +366,19 @@ func (s *UserService) Login(ctx context.Context, req *user.LoginRequest) (*user.
 	loginDTO := &dto.UserLogin{
 		UsernameOrEmail: req.GetUsernameOrEmail(),
 		Password:        req.GetPassword(),
+		DeviceName:      req.GetDeviceName(),
+		ClientInfo:      s.clientInfo(ctx, req.GetUserAgent(), req.GetIpAddress()),
 	}
 
 	tokenResponse, err := s.authService.Login(ctx, loginDTO)
 	if err != nil {
+		var throttled *dao.ThrottledError
+		if errors.As(err, &throttled) {
+			return nil, throttledStatus(ctx, throttled)
+		}
+		if accountRefused(err) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
 		if err.Error() == constants.ErrInvalidCredentials {
 			return nil, status.Error(codes.Unauthenticated, constants.ErrInvalidCredentials)
 		}
@@ -281,22 +386,107 @@ func (s *UserService) Login(ctx context.Context, req *user.LoginRequest) (*user.
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
 	}
 
+	return convertTokenResponseToLoginResponse(tokenResponse), nil
+}
+
+func (s *UserService) CompleteMFALogin(ctx context.Context, req *user.CompleteMFALoginRequest) (*user.LoginResponse, error) {
+	mfaDTO := &dto.MFALogin{
+		MFAToken:   req.GetMfaToken(),
+		Code:       req.GetCode(),
+		DeviceName: req.GetDeviceName(),
+		ClientInfo: s.clientInfo(ctx, req.GetUserAgent(), req.GetIpAddress()),
+	}
+
+	if _, err := utils.Validate(mfaDTO); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tokenResponse, err := s.authService.CompleteMFALogin(ctx, mfaDTO)
+	if err != nil {
+		switch err.Error() {
+		case constants.ErrInvalidMFAToken, constants.ErrInvalidMFACode:
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		case constants.ErrMFALocked:
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		case constants.ErrMFANotEnabled, constants.ErrMFAAlreadyEnabled:
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if accountRefused(err) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		s.log.Error("MFA login failed", zap.Error(err))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return convertTokenResponseToLoginResponse(tokenResponse), nil
+}
+
+// convertTokenResponseToLoginResponse converts a login result, which has no user while an MFA challenge is
+// pending
+func convertTokenResponseToLoginResponse(tokenResponse *dao.TokenResponse) *user.LoginResponse {
 	response := &user.LoginResponse{
-		AccessToken:  tokenResponse.AccessToken,
-		RefreshToken: tokenResponse.RefreshToken,
-		TokenType:    tokenResponse.TokenType,
-		ExpiresIn:    tokenResponse.ExpiresIn,
-		User:         convertDaoUserToProtoUser(tokenResponse.User),
+		AccessToken:           tokenResponse.AccessToken,
+		RefreshToken:          tokenResponse.RefreshToken,
+		TokenType:             tokenResponse.TokenType,
+		ExpiresIn:             tokenResponse.ExpiresIn,
+		SessionId:             tokenResponse.SessionID,
+		MfaRequired:           tokenResponse.MFARequired,
+		MfaToken:              tokenResponse.MFAToken,
+		MfaEnrollmentRequired: tokenResponse.MFAEnrollmentRequired,
+		RecoveryCodes:         tokenResponse.RecoveryCodes,
 	}
 
-	return response, nil
+	if tokenResponse.User != nil {
+		response.User = convertDaoUserToProtoUser(tokenResponse.User)
+	}
+
+	return response
+}
+
+func (s *UserService) Register(ctx context.Context, req *user.RegisterRequest) (*user.UserResponse, error) {
+	registerDTO := &dto.UserRegister{
+		Email:     req.GetEmail(),
+		Username:  req.GetUsername(),
+		Password:  req.GetPassword(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Phone:     req.GetPhone(),
+		Address:   req.GetAddress(),
+	}
+
+	if _, err := utils.Validate(registerDTO); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	userResponse, err := s.authService.Register(ctx, registerDTO)
+	if err != nil {
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			return nil, weakPasswordStatus("password", weak)
+		}
+		if err.Error() == constants.ErrEmailTaken || err.Error() == constants.ErrUsernameTaken {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		s.log.Error("Failed to register user", zap.Error(err))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return convertUserToProto(userResponse), nil
 }
 
 func (s *UserService) RefreshToken(ctx context.Context, req *user.RefreshTokenRequest) (*user.TokenResponse, error) {
-	tokenResponse, err := s.authService.RefreshToken(ctx, req.GetRefreshToken())
+	refreshDTO := &dto.RefreshToken{
+		RefreshToken: req.GetRefreshToken(),
+		ClientInfo:   s.clientInfo(ctx, req.GetUserAgent(), req.GetIpAddress()),
+	}
+
+	tokenResponse, err := s.authService.RefreshToken(ctx, refreshDTO)
 	if err != nil {
-		if err.Error() == constants.ErrInvalidToken {
-			return nil, status.Error(codes.Unauthenticated, constants.ErrInvalidToken)
+		if err.Error() == constants.ErrInvalidToken || err.Error() == constants.ErrTokenRevoked {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if accountRefused(err) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
 		}
 		s.log.Error("Token refresh failed", zap.Error(err))
 		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
@@ -307,13 +497,14 @@ func (s *UserService) RefreshToken(ctx context.Context, req *user.RefreshTokenRe
 		RefreshToken: tokenResponse.RefreshToken,
 		TokenType:    tokenResponse.TokenType,
 		ExpiresIn:    tokenResponse.ExpiresIn,
+		SessionId:    tokenResponse.SessionID,
 	}
 
 	return response, nil
 }
 
 func (s *UserService) Logout(ctx context.Context, req *user.LogoutRequest) (*emptypb.Empty, error) {
-	if err := s.authService.Logout(ctx, req.GetRefreshToken()); err != nil {
+	if err := s.authService.EndSession(ctx, req.GetRefreshToken()); err != nil {
 		if err.Error() == constants.ErrInvalidToken {
 			return nil, status.Error(codes.Unauthenticated, constants.ErrInvalidToken)
 		}
@@ -324,6 +515,103 @@ func (s *UserService) Logout(ctx context.Context, req *user.LogoutRequest) (*emp
 	return &emptypb.Empty{}, nil
 }
 
+func (s *UserService) RevokeAllTokens(ctx context.Context, req *user.RevokeAllTokensRequest) (*emptypb.Empty, error) {
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+	}
+
+	if _, err := s.authService.RevokeAllTokens(ctx, userID); err != nil {
+		if err.Error() == constants.ErrUserNotFound {
+			return nil, status.Error(codes.NotFound, constants.ErrUserNotFound)
+		}
+		s.log.Error("Failed to revoke tokens", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *UserService) ListSessions(ctx context.Context, req *user.ListSessionsRequest) (*user.ListSessionsResponse, error) {
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+	}
+
+	if !s.policy.CanAccess(ctx, userID.String(), middleware.PermUserAdmin) {
+		return nil, status.Error(codes.PermissionDenied, constants.ErrForbidden)
+	}
+
+	sessions, err := s.authService.ListSessions(ctx, userID, req.GetCurrentSessionId())
+	if err != nil {
+		s.log.Error("Failed to list sessions", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	response := &user.ListSessionsResponse{
+		Sessions: make([]*user.Session, 0, len(sessions.Sessions)),
+	}
+	for i := range sessions.Sessions {
+		response.Sessions = append(response.Sessions, convertSessionToProto(&sessions.Sessions[i]))
+	}
+
+	return response, nil
+}
+
+func (s *UserService) RevokeSession(ctx context.Context, req *user.RevokeSessionRequest) (*emptypb.Empty, error) {
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+	}
+
+	if !s.policy.CanAccess(ctx, userID.String(), middleware.PermUserAdmin) {
+		return nil, status.Error(codes.PermissionDenied, constants.ErrForbidden)
+	}
+
+	sessionID, err := uuid.Parse(req.GetSessionId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid session ID")
+	}
+
+	if err := s.authService.RevokeSession(ctx, userID, sessionID); err != nil {
+		if err.Error() == constants.ErrSessionNotFound {
+			return nil, status.Error(codes.NotFound, constants.ErrSessionNotFound)
+		}
+		s.log.Error("Failed to revoke session", zap.Error(err), zap.String("session_id", sessionID.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *UserService) RevokeSessions(ctx context.Context, req *user.RevokeSessionsRequest) (*user.RevokeSessionsResponse, error) {
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+	}
+
+	if !s.policy.CanAccess(ctx, userID.String(), middleware.PermUserAdmin) {
+		return nil, status.Error(codes.PermissionDenied, constants.ErrForbidden)
+	}
+
+	var except *uuid.UUID
+	if req.ExceptSessionId != nil {
+		sessionID, err := uuid.Parse(req.GetExceptSessionId())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid session ID")
+		}
+		except = &sessionID
+	}
+
+	response, err := s.authService.RevokeSessions(ctx, userID, except)
+	if err != nil {
+		s.log.Error("Failed to revoke sessions", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, status.Error(codes.Internal, constants.ErrInternalServer)
+	}
+
+	return &user.RevokeSessionsResponse{Revoked: response.Revoked}, nil
+}
+
 func (s *UserService) Health(ctx context.Context, _ *emptypb.Empty) (*user.HealthResponse, error) {
 	return &user.HealthResponse{
 		Status:  "ok",
@@ -364,3 +652,27 @@ func convertDaoUserToProtoUser(u *dao.UserResponse) *user.User {
 
 	return protoUser
 }
+
+func convertSessionToProto(session *dao.SessionResponse) *user.Session {
+	protoSession := &user.Session{
+		Id:         session.ID.String(),
+		Current:    session.Current,
+		CreatedAt:  timestamppb.New(session.CreatedAt),
+		LastUsedAt: timestamppb.New(session.LastUsedAt),
+		ExpiresAt:  timestamppb.New(session.ExpiresAt),
+	}
+
+	if session.DeviceName != "" {
+		protoSession.DeviceName = &session.DeviceName
+	}
+
+	if session.UserAgent != "" {
+		protoSession.UserAgent = &session.UserAgent
+	}
+
+	if session.IPAddress != "" {
+		protoSession.IpAddress = &session.IPAddress
+	}
+
+	return protoSession
+}
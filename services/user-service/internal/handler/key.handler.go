@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/service"
+	"go.uber.org/zap"
+)
+
+type KeyHandler struct {
+	keyService service.KeyService
+	log        *logger.Logger
+}
+
+func NewKeyHandler(keyService service.KeyService, log *logger.Logger) *KeyHandler {
+	return &KeyHandler{
+		keyService: keyService,
+		log:        log,
+	}
+}
+
+// HandleJWKS serves the token verification keys as a bare JWK set, without the usual response envelope,
+// since verifiers expect the standard format
+func (h *KeyHandler) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := h.keyService.JWKS(r.Context())
+	if err != nil {
+		h.log.Error("Failed to load signing keys", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(constants.JWKSCacheTTL.Seconds())))
+	utils.RespondWithJSON(w, http.StatusOK, set)
+}
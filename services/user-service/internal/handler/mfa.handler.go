@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// mfaErrorStatus maps the errors of the two-factor endpoints to their status codes
+func mfaErrorStatus(err error) (int, bool) {
+	switch err.Error() {
+	case constants.ErrInvalidMFAToken, constants.ErrInvalidMFACode:
+		return http.StatusUnauthorized, true
+	case constants.ErrMFALocked:
+		return http.StatusTooManyRequests, true
+	case constants.ErrMFANotEnabled:
+		return http.StatusBadRequest, true
+	case constants.ErrMFAAlreadyEnabled:
+		return http.StatusConflict, true
+	case constants.ErrMFARequired, constants.ErrAccountPending, constants.ErrAccountBlocked, constants.ErrAccountInactive:
+		return http.StatusForbidden, true
+	default:
+		return 0, false
+	}
+}
+
+func (h *AuthHandler) respondWithMFAError(w http.ResponseWriter, message string, err error) {
+	if status, ok := mfaErrorStatus(err); ok {
+		utils.RespondWithError(w, status, err.Error(), nil)
+		return
+	}
+
+	h.log.Error(message, zap.Error(err))
+	utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+}
+
+// decodeMFACode reads and validates a request body holding a verification code
+func (h *AuthHandler) decodeMFACode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req dto.MFACode
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode verification code request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return "", false
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for verification code request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return "", false
+	}
+
+	return req.Code, true
+}
+
+// HandleCompleteMFALogin answers the challenge returned by login with a TOTP or recovery code
+func (h *AuthHandler) HandleCompleteMFALogin(w http.ResponseWriter, r *http.Request) {
+	var req dto.MFALogin
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode MFA login request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for MFA login request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	req.ClientInfo = clientInfo(r)
+
+	response, err := h.authService.CompleteMFALogin(r.Context(), &req)
+	if err != nil {
+		h.respondWithMFAError(w, "MFA login failed", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Login successful", response)
+}
+
+// HandleEnrollMFAWithToken starts enrollment for a user whose role requires two-factor authentication and
+// who was sent to set it up by login
+func (h *AuthHandler) HandleEnrollMFAWithToken(w http.ResponseWriter, r *http.Request) {
+	var req dto.MFAEnrollWithToken
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode MFA enrollment request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for MFA enrollment request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	response, err := h.authService.EnrollMFAWithToken(r.Context(), req.MFAToken)
+	if err != nil {
+		h.respondWithMFAError(w, "MFA enrollment failed", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Scan the code with your authenticator app, then log in with a code from it", response)
+}
+
+func (h *AuthHandler) HandleMFAStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	response, err := h.authService.MFAStatus(r.Context(), userID)
+	if err != nil {
+		h.respondWithMFAError(w, "Failed to load MFA status", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication status retrieved successfully", response)
+}
+
+func (h *AuthHandler) HandleEnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	response, err := h.authService.EnrollMFA(r.Context(), userID)
+	if err != nil {
+		h.respondWithMFAError(w, "MFA enrollment failed", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Scan the code with your authenticator app, then confirm with a code from it", response)
+}
+
+func (h *AuthHandler) HandleConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	code, ok := h.decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := h.authService.ConfirmMFA(r.Context(), userID, code)
+	if err != nil {
+		h.respondWithMFAError(w, "MFA confirmation failed", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication enabled; store the recovery codes somewhere safe", response)
+}
+
+func (h *AuthHandler) HandleDisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	code, ok := h.decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DisableMFA(r.Context(), userID, code); err != nil {
+		h.respondWithMFAError(w, "Failed to disable MFA", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+func (h *AuthHandler) HandleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := sessionOwner(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	code, ok := h.decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := h.authService.RegenerateRecoveryCodes(r.Context(), userID, code)
+	if err != nil {
+		h.respondWithMFAError(w, "Failed to regenerate recovery codes", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Recovery codes regenerated; the old ones no longer work", response)
+}
+
+// HandleResetMFA removes another user's second factor, for staff who lost their authenticator
+func (h *AuthHandler) HandleResetMFA(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	if err := h.authService.ResetMFA(r.Context(), userID); err != nil {
+		h.respondWithMFAError(w, "Failed to reset MFA", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication reset", nil)
+}
@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/user-service/internal/service"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// OAuthHandler serves the OpenID Connect provider. The endpoints called by clients (/oauth/*) answer in the
+// formats the OAuth and OpenID Connect specifications define; those called by the library's own web app
+// (/api/oauth/*) use the usual response envelope.
+type OAuthHandler struct {
+	oauthService service.OAuthService
+	log          *logger.Logger
+}
+
+func NewOAuthHandler(oauthService service.OAuthService, log *logger.Logger) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		log:          log,
+	}
+}
+
+func (h *OAuthHandler) HandleDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	utils.RespondWithJSON(w, http.StatusOK, h.oauthService.Discovery())
+}
+
+func (h *OAuthHandler) HandleRegisterClient(w http.ResponseWriter, r *http.Request) {
+	userID, ok := subjectUUID(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	var req dto.OAuthClientCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode client registration request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for client registration request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	response, err := h.oauthService.RegisterClient(r.Context(), userID, &req)
+	if err != nil {
+		if err.Error() == constants.ErrInvalidRedirectURI {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRedirectURI, nil)
+			return
+		}
+
+		h.log.Error("Failed to register OAuth client", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Client registered successfully", response)
+}
+
+func (h *OAuthHandler) HandleListClients(w http.ResponseWriter, r *http.Request) {
+	response, err := h.oauthService.ListClients(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Clients retrieved successfully", response)
+}
+
+func (h *OAuthHandler) HandleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid client ID", err)
+		return
+	}
+
+	if err := h.oauthService.DeleteClient(r.Context(), id); err != nil {
+		if err.Error() == constants.ErrOAuthClientNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrOAuthClientNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to delete OAuth client", zap.Error(err), zap.String("client_id", id.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Client deleted successfully", nil)
+}
+
+// HandleCheckAuthorization validates the authorization request the consent page received and describes
+// the client and scopes to show the user
+func (h *OAuthHandler) HandleCheckAuthorization(w http.ResponseWriter, r *http.Request) {
+	userID, ok := subjectUUID(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	query := r.URL.Query()
+	req := dto.OAuthAuthorize{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for authorization request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	response, err := h.oauthService.CheckAuthorization(r.Context(), userID, &req)
+	if err != nil {
+		h.respondWithServiceError(w, "Authorization request rejected", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Authorization request is valid", response)
+}
+
+// HandleAuthorize records the user's decision on the consent page and returns where to send the browser
+func (h *OAuthHandler) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	userID, ok := subjectUUID(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	var req dto.OAuthAuthorize
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode authorization request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for authorization request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	response, err := h.oauthService.Authorize(r.Context(), userID, &req)
+	if err != nil {
+		h.respondWithServiceError(w, "Authorization failed", err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Authorization completed", response)
+}
+
+func (h *OAuthHandler) HandleListConsents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := subjectUUID(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	response, err := h.oauthService.ListConsents(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Consents retrieved successfully", response)
+}
+
+func (h *OAuthHandler) HandleRevokeConsent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := subjectUUID(r)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+		return
+	}
+
+	clientID, err := uuid.Parse(mux.Vars(r)["client_id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid client ID", err)
+		return
+	}
+
+	if err := h.oauthService.RevokeConsent(r.Context(), userID, clientID); err != nil {
+		if err.Error() == constants.ErrConsentNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrConsentNotFound, nil)
+			return
+		}
+
+		h.log.Error("Failed to revoke consent", zap.Error(err), zap.String("client_id", clientID.String()))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Consent revoked successfully", nil)
+}
+
+func (h *OAuthHandler) HandleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.respondWithOAuthError(w, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "malformed form body"))
+		return
+	}
+
+	req := dto.OAuthTokenRequest{
+		GrantType:              r.PostForm.Get("grant_type"),
+		Code:                   r.PostForm.Get("code"),
+		RedirectURI:            r.PostForm.Get("redirect_uri"),
+		CodeVerifier:           r.PostForm.Get("code_verifier"),
+		RefreshToken:           r.PostForm.Get("refresh_token"),
+		Scope:                  r.PostForm.Get("scope"),
+		OAuthClientCredentials: clientCredentials(r),
+	}
+
+	response, err := h.oauthService.Token(r.Context(), &req)
+	if err != nil {
+		h.respondWithOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *OAuthHandler) HandleUserInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		h.respondWithOAuthError(w, dao.NewOAuthError(dao.OAuthErrInvalidToken, "an access token is required"))
+		return
+	}
+
+	claims, err := h.oauthService.UserInfo(r.Context(), token)
+	if err != nil {
+		h.respondWithOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, http.StatusOK, claims)
+}
+
+func (h *OAuthHandler) HandleIntrospect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		h.respondWithOAuthError(w, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "token is required"))
+		return
+	}
+
+	response, err := h.oauthService.Introspect(r.Context(), clientCredentials(r), r.PostForm.Get("token"))
+	if err != nil {
+		h.respondWithOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *OAuthHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		h.respondWithOAuthError(w, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "token is required"))
+		return
+	}
+
+	if err := h.oauthService.Revoke(r.Context(), clientCredentials(r), r.PostForm.Get("token")); err != nil {
+		h.respondWithOAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// respondWithOAuthError writes an error in the format of RFC 6749 section 5.2, or RFC 6750 for bad
+// access tokens
+func (h *OAuthHandler) respondWithOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *dao.OAuthError
+	if !errors.As(err, &oauthErr) {
+		h.log.Error("OAuth request failed", zap.Error(err))
+		oauthErr = dao.NewOAuthError(dao.OAuthErrServerError, "")
+	}
+
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case dao.OAuthErrInvalidClient:
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	case dao.OAuthErrInvalidToken:
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	case dao.OAuthErrInsufficientScope:
+		status = http.StatusForbidden
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+	case dao.OAuthErrServerError:
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, status, oauthErr)
+}
+
+// respondWithServiceError answers the consent page, which uses the usual envelope, for a rejected
+// authorization request
+func (h *OAuthHandler) respondWithServiceError(w http.ResponseWriter, message string, err error) {
+	var oauthErr *dao.OAuthError
+	if errors.As(err, &oauthErr) {
+		utils.RespondWithError(w, http.StatusBadRequest, message, oauthErr)
+		return
+	}
+
+	h.log.Error(message, zap.Error(err))
+	utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+}
+
+// clientCredentials reads the client's credentials from HTTP Basic authentication, falling back to the
+// form fields. ParseForm must have been called.
+func clientCredentials(r *http.Request) dto.OAuthClientCredentials {
+	if clientID, clientSecret, ok := r.BasicAuth(); ok {
+		return dto.OAuthClientCredentials{ClientID: clientID, ClientSecret: clientSecret}
+	}
+
+	return dto.OAuthClientCredentials{
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	parts := strings.Split(r.Header.Get(constants.HeaderAuthorization), " ")
+	if len(parts) != 2 || parts[0] != constants.TokenTypBearer || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func subjectUUID(r *http.Request) (uuid.UUID, bool) {
+	subject, ok := middleware.SubjectID(r.Context())
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(subject)
+	return id, err == nil
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/password"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"go.uber.org/zap"
+)
+
+// HandleForgotPassword emails a password reset link. The response is the same whether or not the address
+// belongs to an account.
+func (h *AuthHandler) HandleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.ForgotPassword
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode forgot password request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for forgot password request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		h.log.Error("Failed to request password reset", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusAccepted, "If an account uses that email, a password reset link has been sent to it", nil)
+}
+
+// HandleResetPassword sets a new password with the token from a reset email
+func (h *AuthHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.ResetPassword
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.log.Error("Failed to decode reset password request", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidRequest, err)
+		return
+	}
+
+	if validationErrors, err := utils.Validate(req); err != nil {
+		h.log.Info("Validation failed for reset password request", zap.Any("errors", validationErrors))
+		utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidField, err)
+		return
+	}
+
+	req.ClientInfo = clientInfo(r)
+
+	if err := h.authService.ResetPassword(r.Context(), &req); err != nil {
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			respondWithWeakPassword(w, weak)
+			return
+		}
+
+		switch err.Error() {
+		case constants.ErrInvalidAccountToken:
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		default:
+			h.log.Error("Failed to reset password", zap.Error(err))
+			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Password reset; log in with the new password", nil)
+}
@@ -2,11 +2,14 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
 	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/password"
 	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/user-service/internal/service"
@@ -17,40 +20,23 @@ import (
 
 type UserHandler struct {
 	userService service.UserService
+	policy      *middleware.Policy
 	log         *logger.Logger
 }
 
-func (h *UserHandler) isAdmin(r *http.Request) bool {
-	role, ok := r.Context().Value(middleware.UserRoleKey).(string)
+// requestUserID resolves the account a request targets: the {id} path variable, or the caller on /me routes
+func (h *UserHandler) requestUserID(r *http.Request) (uuid.UUID, error) {
+	idStr, ok := mux.Vars(r)["id"]
 	if !ok {
-		return false
+		idStr, _ = middleware.SubjectID(r.Context())
 	}
-	return role == constants.RoleAdmin
+	return uuid.Parse(idStr)
 }
 
-func (h *UserHandler) isSameUser(r *http.Request, id uuid.UUID) bool {
-	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
-	if !ok {
-		return false
-	}
-	return userID == id.String()
-}
-
-func (h *UserHandler) isAdminOrSameUser(r *http.Request, id uuid.UUID) bool {
-	return h.isAdmin(r) || h.isSameUser(r, id)
-}
-
-func (h *UserHandler) isLibrarianOrAbove(r *http.Request) bool {
-	role, ok := r.Context().Value(middleware.UserRoleKey).(string)
-	if !ok {
-		return false
-	}
-	return role == constants.RoleAdmin || role == constants.RoleLibrarian
-}
-
-func NewUserHandler(userService service.UserService, log *logger.Logger) *UserHandler {
+func NewUserHandler(userService service.UserService, policy *middleware.Policy, log *logger.Logger) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		policy:      policy,
 		log:         log,
 	}
 }
@@ -72,6 +58,11 @@ func (h *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.userService.CreateUser(r.Context(), &req)
 	if err != nil {
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			respondWithWeakPassword(w, weak)
+			return
+		}
 		if err.Error() == constants.ErrEmailTaken || err.Error() == constants.ErrUsernameTaken {
 			utils.RespondWithError(w, http.StatusConflict, err.Error(), nil)
 			return
@@ -86,15 +77,17 @@ func (h *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	id, err := uuid.Parse(idStr)
+	id, err := h.requestUserID(r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
 		return
 	}
 
+	if !h.policy.CanAccess(r.Context(), id.String(), middleware.PermUserRead) {
+		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
+		return
+	}
+
 	user, err := h.userService.GetUserByID(r.Context(), id)
 	if err != nil {
 		if err.Error() == constants.ErrUserNotFound {
@@ -155,10 +148,7 @@ func (h *UserHandler) HandleGetUserByUsername(w http.ResponseWriter, r *http.Req
 }
 
 func (h *UserHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	id, err := uuid.Parse(idStr)
+	id, err := h.requestUserID(r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
 		return
@@ -177,11 +167,17 @@ func (h *UserHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !h.isAdminOrSameUser(r, id) {
+	if !h.policy.CanAccess(r.Context(), id.String(), middleware.PermUserAdmin) {
 		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
 		return
 	}
 
+	// Owners may edit their profile but not grant themselves a different role or status
+	if req.ChangesAccess() && !h.policy.Can(r.Context(), middleware.PermUserAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, constants.ErrAccessChangeForbidden, nil)
+		return
+	}
+
 	user, err := h.userService.UpdateUser(r.Context(), id, &req)
 	if err != nil {
 		if err.Error() == constants.ErrUserNotFound {
@@ -212,11 +208,6 @@ func (h *UserHandler) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !h.isAdmin(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
-		return
-	}
-
 	if err := h.userService.DeleteUser(r.Context(), id); err != nil {
 		if err.Error() == constants.ErrUserNotFound {
 			utils.RespondWithError(w, http.StatusNotFound, constants.ErrUserNotFound, nil)
@@ -231,11 +222,42 @@ func (h *UserHandler) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithSuccess(w, http.StatusOK, "User deleted successfully", nil)
 }
 
+// HandleApproveUser activates a pending account, for users who cannot verify their email address
+func (h *UserHandler) HandleApproveUser(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := h.userService.ApproveUser(r.Context(), id)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), constants.ErrUserNotFound):
+			utils.RespondWithError(w, http.StatusNotFound, constants.ErrUserNotFound, nil)
+		case err.Error() == constants.ErrAccountNotPending:
+			utils.RespondWithError(w, http.StatusConflict, err.Error(), nil)
+		default:
+			h.log.Error("Failed to approve user", zap.Error(err), zap.String("id", id.String()))
+			utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User approved successfully", user)
+}
+
 func (h *UserHandler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
 	filter := &dto.UserFilter{
 		Role:   r.URL.Query().Get("role"),
 		Status: r.URL.Query().Get("status"),
 		SortBy: r.URL.Query().Get("sort_by"),
+		Filter: r.URL.Query().Get("filter"),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		filter.SortBy = sort
 	}
 
 	if page := r.URL.Query().Get("page"); page != "" {
@@ -254,13 +276,23 @@ func (h *UserHandler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
 		filter.Desc = true
 	}
 
-	if !h.isAdmin(r) {
-		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
-		return
-	}
-
 	response, err := h.userService.ListUsers(r.Context(), filter)
 	if err != nil {
+		if err.Error() == constants.ErrInvalidCursor {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidCursor, nil)
+			return
+		}
+
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidFilter) {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidFilter, err)
+			return
+		}
+
+		if strings.HasPrefix(err.Error(), constants.ErrInvalidSort) {
+			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrInvalidSort, err)
+			return
+		}
+
 		h.log.Error("Failed to list users", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, constants.ErrInternalServer, err)
 		return
@@ -270,10 +302,7 @@ func (h *UserHandler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	id, err := uuid.Parse(idStr)
+	id, err := h.requestUserID(r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
 		return
@@ -292,7 +321,7 @@ func (h *UserHandler) HandleChangePassword(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if !h.isSameUser(r, id) {
+	if !middleware.IsSubject(r.Context(), id.String()) {
 		utils.RespondWithError(w, http.StatusForbidden, constants.ErrForbidden, nil)
 		return
 	}
@@ -308,8 +337,9 @@ func (h *UserHandler) HandleChangePassword(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		if err.Error() == constants.ErrWeakPassword {
-			utils.RespondWithError(w, http.StatusBadRequest, constants.ErrWeakPassword, nil)
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			respondWithWeakPassword(w, weak)
 			return
 		}
 
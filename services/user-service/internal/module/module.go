@@ -7,6 +7,7 @@ import (
 
 	"github.com/fairuzald/library-system/pkg/cache"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/mail"
 	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/proto/user"
 	"github.com/fairuzald/library-system/services/user-service/internal/handler"
@@ -26,15 +27,25 @@ type Module struct {
 	Redis *cache.Redis
 
 	JWTAuth *middleware.JWTAuth
+	Policy  *middleware.Policy
 
-	UserRepo repository.UserRepository
-	AuthRepo repository.AuthRepository
+	UserRepo         repository.UserRepository
+	AuthRepo         repository.AuthRepository
+	MFARepo          repository.MFARepository
+	AccountTokenRepo repository.AccountTokenRepository
+	LoginAttemptRepo repository.LoginAttemptRepository
+	KeyRepo          repository.KeyRepository
+	OAuthRepo        repository.OAuthRepository
 
-	UserService service.UserService
-	AuthService service.AuthService
+	UserService  service.UserService
+	AuthService  service.AuthService
+	KeyService   service.KeyService
+	OAuthService service.OAuthService
 
 	UserHandler   *handler.UserHandler
 	AuthHandler   *handler.AuthHandler
+	KeyHandler    *handler.KeyHandler
+	OAuthHandler  *handler.OAuthHandler
 	HealthHandler *handler.HealthHandler
 
 	UserGRPCService *grpcHandler.UserService
@@ -48,6 +59,19 @@ func New(
 	db *sql.DB,
 	redis *cache.Redis,
 	jwtSecret string,
+	jwtAlgorithm string,
+	keyEncryptionKey string,
+	keyRotationInterval time.Duration,
+	tokenVersionFailOpen bool,
+	oidcIssuer string,
+	oidcAuthorizationURL string,
+	mfaConfig service.MFAConfig,
+	loginProtection service.LoginProtectionConfig,
+	trustedProxies *middleware.TrustedProxies,
+	passwordConfig service.PasswordConfig,
+	mailSender mail.Sender,
+	passwordResetURL string,
+	emailVerificationURL string,
 	accessTokenExpiry time.Duration,
 	refreshTokenExpiry time.Duration,
 	log *logger.Logger,
@@ -70,18 +94,57 @@ func New(
 	}
 
 	m.JWTAuth = middleware.NewJWTAuth(jwtSecret, accessTokenExpiry)
+	if jwtAlgorithm != middleware.AlgorithmHS256 {
+		m.KeyRepo = repository.NewKeyRepository(m.GormDB, log)
+		m.KeyService, err = service.NewKeyService(m.KeyRepo, jwtAlgorithm, keyEncryptionKey, keyRotationInterval, service.SignedTokenLifetime(accessTokenExpiry), log)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := m.KeyService.RotateKeys(context.Background()); err != nil {
+			log.Error("Failed to prepare token signing keys", zap.Error(err))
+			return nil, err
+		}
+
+		m.JWTAuth.WithSigner(m.KeyService).WithKeyResolver(m.KeyService)
+		m.KeyHandler = handler.NewKeyHandler(m.KeyService, log)
+	}
+	m.Policy = middleware.DefaultPolicy()
 
 	m.UserRepo = repository.NewUserRepository(m.GormDB, redis, log)
 	m.AuthRepo = repository.NewAuthRepository(m.GormDB, redis, log)
+	m.MFARepo = repository.NewMFARepository(m.GormDB, log)
+	m.AccountTokenRepo = repository.NewAccountTokenRepository(m.GormDB, log)
+	m.LoginAttemptRepo = repository.NewLoginAttemptRepository(redis, log)
 
-	m.UserService = service.NewUserService(m.UserRepo, log)
-	m.AuthService = service.NewAuthService(m.UserRepo, m.AuthRepo, m.JWTAuth, log, accessTokenExpiry, refreshTokenExpiry)
+	// The users table holds every token version, so a version Redis lost is read back from it
+	m.JWTAuth.WithTokenVersions(middleware.TokenVersions(redis, repository.NewTokenVersionSource(m.UserRepo)), tokenVersionFailOpen)
 
-	m.UserHandler = handler.NewUserHandler(m.UserService, log)
+	m.UserService = service.NewUserService(m.UserRepo, m.AuthRepo, log, passwordConfig)
+	m.AuthService = service.NewAuthService(m.UserRepo, m.AuthRepo, m.MFARepo, m.AccountTokenRepo, m.LoginAttemptRepo, m.JWTAuth, log, accessTokenExpiry, refreshTokenExpiry, mfaConfig, service.AccountMailConfig{
+		Sender:               mailSender,
+		PasswordResetURL:     passwordResetURL,
+		EmailVerificationURL: emailVerificationURL,
+	}, loginProtection, passwordConfig)
+
+	m.UserHandler = handler.NewUserHandler(m.UserService, m.Policy, log)
 	m.AuthHandler = handler.NewAuthHandler(m.AuthService, log)
 	m.HealthHandler = handler.NewHealthHandler(db, log)
 
-	m.UserGRPCService = grpcHandler.NewUserService(m.UserService, m.AuthService, log)
+	// The OpenID Connect provider needs published keys, so that clients can verify ID tokens
+	if m.KeyService != nil && oidcIssuer != "" {
+		m.OAuthRepo = repository.NewOAuthRepository(m.GormDB, log)
+		m.OAuthService = service.NewOAuthService(m.OAuthRepo, m.UserRepo, m.AuthRepo, m.JWTAuth, service.OAuthConfig{
+			Issuer:             oidcIssuer,
+			AuthorizationURL:   oidcAuthorizationURL,
+			SigningAlgorithm:   jwtAlgorithm,
+			AccessTokenExpiry:  accessTokenExpiry,
+			RefreshTokenExpiry: refreshTokenExpiry,
+		}, log)
+		m.OAuthHandler = handler.NewOAuthHandler(m.OAuthService, log)
+	}
+
+	m.UserGRPCService = grpcHandler.NewUserService(m.UserService, m.AuthService, m.Policy, trustedProxies, log)
 
 	return m, nil
 }
@@ -92,6 +155,22 @@ func (m *Module) RegisterGRPCHandlers(grpcServer *grpc.Server) {
 
 func (m *Module) StartBackgroundTasks() {
 	go m.startTokenCleanupTask()
+	if m.KeyService != nil {
+		go m.startKeyRotationTask()
+	}
+}
+
+func (m *Module) startKeyRotationTask() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := m.KeyService.RotateKeys(ctx); err != nil {
+			m.Log.Error("Failed to rotate token signing keys", zap.Error(err))
+		}
+		cancel()
+	}
 }
 
 func (m *Module) startTokenCleanupTask() {
@@ -103,6 +182,14 @@ func (m *Module) startTokenCleanupTask() {
 		if err := m.AuthRepo.CleanupExpiredTokens(ctx); err != nil {
 			m.Log.Error("Failed to cleanup expired tokens", zap.Error(err))
 		}
+		if err := m.AccountTokenRepo.CleanupExpiredTokens(ctx); err != nil {
+			m.Log.Error("Failed to cleanup expired account tokens", zap.Error(err))
+		}
+		if m.OAuthRepo != nil {
+			if err := m.OAuthRepo.CleanupExpiredGrants(ctx); err != nil {
+				m.Log.Error("Failed to cleanup expired OAuth grants", zap.Error(err))
+			}
+		}
 		cancel()
 	}
 }
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type AccountTokenRepository interface {
+	CreateToken(ctx context.Context, token *model.AccountToken) error
+	GetToken(ctx context.Context, purpose, tokenHash string) (*model.AccountToken, error)
+	CleanupExpiredTokens(ctx context.Context) error
+}
+
+type accountTokenRepository struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewAccountTokenRepository(db *gorm.DB, log *logger.Logger) AccountTokenRepository {
+	return &accountTokenRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// CreateToken stores a new token and invalidates the user's earlier unused tokens for the same purpose, so
+// only the most recently sent link works
+func (r *accountTokenRepository) CreateToken(ctx context.Context, token *model.AccountToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.AccountToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(token).Error
+	})
+}
+
+// GetToken returns an unused, unexpired token without spending it. Whatever the token authorizes spends
+// it in the same transaction, so a token that was only looked up stays usable.
+func (r *accountTokenRepository) GetToken(ctx context.Context, purpose, tokenHash string) (*model.AccountToken, error) {
+	var token model.AccountToken
+	err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, time.Now()).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrInvalidAccountToken, err)
+		}
+		r.log.Error("Failed to get account token", zap.Error(err))
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CleanupExpiredTokens deletes tokens that can no longer be used
+func (r *accountTokenRepository) CleanupExpiredTokens(ctx context.Context) error {
+	return r.db.WithContext(ctx).
+		Unscoped().
+		Where("expires_at < ?", time.Now()).
+		Delete(&model.AccountToken{}).Error
+}
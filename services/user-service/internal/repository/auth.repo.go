@@ -2,11 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/fairuzald/library-system/pkg/cache"
+	"github.com/fairuzald/library-system/pkg/constants"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -14,12 +17,17 @@ import (
 )
 
 type AuthRepository interface {
-	StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiration time.Time) error
-	GetUserByRefreshToken(ctx context.Context, token string) (*model.User, error)
-	RevokeRefreshToken(ctx context.Context, userID uuid.UUID) error
+	CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, session *model.Session, usedTokenID uuid.UUID, next *model.RefreshToken) error
+	ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]*model.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeSessions(ctx context.Context, userID uuid.UUID, except *uuid.UUID) (int64, error)
 	StoreTokenInBlacklist(ctx context.Context, token string, expiration time.Duration) error
 	IsTokenBlacklisted(ctx context.Context, token string) (bool, error)
 	CleanupExpiredTokens(ctx context.Context) error
+	RecordSecurityEvent(ctx context.Context, event *model.SecurityEvent) error
+	PublishTokenVersion(ctx context.Context, userID uuid.UUID, version int64) error
 }
 
 type authRepository struct {
@@ -36,38 +44,123 @@ func NewAuthRepository(db *gorm.DB, cache *cache.Redis, log *logger.Logger) Auth
 	}
 }
 
-func (r *authRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiration time.Time) error {
-	return r.db.WithContext(ctx).
-		Model(&model.User{}).
-		Where("id = ?", userID).
-		Updates(map[string]interface{}{
-			"refresh_token":     token,
-			"refresh_token_exp": expiration,
-		}).Error
+// CreateSession stores a new session together with the first token of its family
+func (r *authRepository) CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
 }
 
-func (r *authRepository) GetUserByRefreshToken(ctx context.Context, token string) (*model.User, error) {
-	var user model.User
+// GetRefreshToken looks up a token by hash with its session, whether or not it has been used
+func (r *authRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
 
 	err := r.db.WithContext(ctx).
-		Where("refresh_token = ? AND refresh_token_exp > ?", token, time.Now()).
-		First(&user).Error
+		Preload("Session").
+		Where("token_hash = ?", tokenHash).
+		First(&token).Error
 
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrInvalidToken, err)
+		}
 		return nil, err
 	}
 
-	return &user, nil
+	if token.Session == nil {
+		return nil, errors.New(constants.ErrSessionNotFound)
+	}
+
+	return &token, nil
 }
 
-func (r *authRepository) RevokeRefreshToken(ctx context.Context, userID uuid.UUID) error {
-	return r.db.WithContext(ctx).
-		Model(&model.User{}).
-		Where("id = ?", userID).
-		Updates(map[string]interface{}{
-			"refresh_token":     "",
-			"refresh_token_exp": time.Now(),
-		}).Error
+// RotateRefreshToken marks a token used and stores its successor along with the session's new activity.
+// Marking is conditional, so of two concurrent refreshes with the same token only one succeeds and the
+// other gets ErrRefreshTokenReused. Only the activity columns are written, and only while the session is
+// still active, so a revoke that lands after the session was loaded is neither undone nor refreshed past;
+// the rotation then fails with ErrInvalidToken.
+func (r *authRepository) RotateRefreshToken(ctx context.Context, session *model.Session, usedTokenID uuid.UUID, next *model.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		touched := tx.Model(&model.Session{}).
+			Where("id = ? AND revoked_at IS NULL", session.ID).
+			Updates(map[string]interface{}{
+				"user_agent":   session.UserAgent,
+				"ip_address":   session.IPAddress,
+				"last_used_at": session.LastUsedAt,
+				"expires_at":   session.ExpiresAt,
+			})
+
+		if touched.Error != nil {
+			return touched.Error
+		}
+
+		if touched.RowsAffected == 0 {
+			return errors.New(constants.ErrInvalidToken)
+		}
+
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", usedTokenID).
+			Update("used_at", time.Now())
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New(constants.ErrRefreshTokenReused)
+		}
+
+		return tx.Create(next).Error
+	})
+}
+
+func (r *authRepository) ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]*model.Session, error) {
+	var sessions []*model.Session
+
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *authRepository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New(constants.ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes every active session of a user, optionally keeping one, and returns how many were revoked
+func (r *authRepository) RevokeSessions(ctx context.Context, userID uuid.UUID, except *uuid.UUID) (int64, error) {
+	query := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID)
+
+	if except != nil {
+		query = query.Where("id <> ?", *except)
+	}
+
+	result := query.Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
 }
 
 func (r *authRepository) StoreTokenInBlacklist(ctx context.Context, token string, expiration time.Duration) error {
@@ -96,19 +189,36 @@ func (r *authRepository) IsTokenBlacklisted(ctx context.Context, token string) (
 	return blacklisted, nil
 }
 
+// CleanupExpiredTokens deletes sessions past their expiry along with their token families. Revoked sessions
+// are kept until then so that replayed tokens are still recognised.
 func (r *authRepository) CleanupExpiredTokens(ctx context.Context) error {
-	err := r.db.WithContext(ctx).
-		Model(&model.User{}).
-		Where("refresh_token_exp < ?", time.Now()).
-		Updates(map[string]interface{}{
-			"refresh_token":     "",
-			"refresh_token_exp": time.Now(),
-		}).Error
+	result := r.db.WithContext(ctx).
+		Unscoped().
+		Where("expires_at < ?", time.Now()).
+		Delete(&model.Session{})
+
+	if result.Error != nil {
+		r.log.Error("Failed to cleanup expired sessions", zap.Error(result.Error))
+		return result.Error
+	}
 
-	if err != nil {
-		r.log.Error("Failed to cleanup expired tokens", zap.Error(err))
-		return err
+	if result.RowsAffected > 0 {
+		r.log.Info("Cleaned up expired sessions", zap.Int64("count", result.RowsAffected))
 	}
 
 	return nil
 }
+
+func (r *authRepository) RecordSecurityEvent(ctx context.Context, event *model.SecurityEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// PublishTokenVersion shares the user's token version with every service that validates access tokens
+func (r *authRepository) PublishTokenVersion(ctx context.Context, userID uuid.UUID, version int64) error {
+	if r.cache == nil {
+		r.log.Warn("Cache not available for token versions")
+		return nil
+	}
+
+	return middleware.NewRedisTokenVersions(r.cache).SetTokenVersion(ctx, userID.String(), version)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type KeyRepository interface {
+	ListSigningKeys(ctx context.Context) ([]*model.SigningKey, error)
+	CreateSigningKey(ctx context.Context, key *model.SigningKey) error
+	DeleteSigningKeys(ctx context.Context, ids []uuid.UUID) error
+}
+
+type keyRepository struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewKeyRepository(db *gorm.DB, log *logger.Logger) KeyRepository {
+	return &keyRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// ListSigningKeys returns every stored key, oldest activation first
+func (r *keyRepository) ListSigningKeys(ctx context.Context) ([]*model.SigningKey, error) {
+	var keys []*model.SigningKey
+	err := r.db.WithContext(ctx).
+		Order("activates_at ASC").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *keyRepository) CreateSigningKey(ctx context.Context, key *model.SigningKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *keyRepository) DeleteSigningKeys(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Unscoped().
+		Where("id IN ?", ids).
+		Delete(&model.SigningKey{}).Error
+}
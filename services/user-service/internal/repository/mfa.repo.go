@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MFARepository interface {
+	GetCredential(ctx context.Context, userID uuid.UUID) (*model.MFACredential, error)
+	SavePendingCredential(ctx context.Context, credential *model.MFACredential) error
+	ConfirmCredential(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	DeleteCredential(ctx context.Context, userID uuid.UUID) error
+
+	RecordCodeUse(ctx context.Context, userID uuid.UUID, step int64) error
+	RecordFailedAttempt(ctx context.Context, userID uuid.UUID, maxAttempts int, lockout time.Duration) (*model.MFACredential, error)
+
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type mfaRepository struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewMFARepository(db *gorm.DB, log *logger.Logger) MFARepository {
+	return &mfaRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *mfaRepository) GetCredential(ctx context.Context, userID uuid.UUID) (*model.MFACredential, error) {
+	var credential model.MFACredential
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrMFANotEnabled, err)
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// SavePendingCredential stores a new, unconfirmed credential, replacing an earlier one that was never
+// confirmed. A confirmed credential is left alone and ErrMFAAlreadyEnabled returned.
+func (r *mfaRepository) SavePendingCredential(ctx context.Context, credential *model.MFACredential) error {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"secret", "last_used_step", "failed_attempts", "locked_until", "updated_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "mfa_credentials.confirmed_at IS NULL"}}},
+		}).
+		Create(credential)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New(constants.ErrMFAAlreadyEnabled)
+	}
+	return nil
+}
+
+// ConfirmCredential puts a pending credential in use, recording the step of the code that confirmed it,
+// and replaces the user's recovery codes
+func (r *mfaRepository) ConfirmCredential(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.MFACredential{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]interface{}{
+				"confirmed_at":    time.Now(),
+				"last_used_step":  step,
+				"failed_attempts": 0,
+				"locked_until":    nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New(constants.ErrMFAAlreadyEnabled)
+		}
+
+		return replaceRecoveryCodes(tx, userID, recoveryCodeHashes)
+	})
+}
+
+// DeleteCredential turns two-factor authentication off, removing the recovery codes with the credential
+func (r *mfaRepository) DeleteCredential(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.MFACredential{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New(constants.ErrMFANotEnabled)
+		}
+
+		return tx.Unscoped().Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error
+	})
+}
+
+// RecordCodeUse accepts a TOTP code for the given step and clears the failed attempts. The update only
+// applies to a later step than the last one accepted, so of two uses of the same code only one succeeds.
+func (r *mfaRepository) RecordCodeUse(ctx context.Context, userID uuid.UUID, step int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.MFACredential{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Updates(map[string]interface{}{
+			"last_used_step":  step,
+			"failed_attempts": 0,
+			"locked_until":    nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New(constants.ErrInvalidMFACode)
+	}
+	return nil
+}
+
+// RecordFailedAttempt counts a wrong code. Reaching maxAttempts locks the credential for the lockout period
+// and starts the count again.
+func (r *mfaRepository) RecordFailedAttempt(ctx context.Context, userID uuid.UUID, maxAttempts int, lockout time.Duration) (*model.MFACredential, error) {
+	var credential model.MFACredential
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).
+			First(&credential).Error; err != nil {
+			return err
+		}
+
+		credential.FailedAttempts++
+		if credential.FailedAttempts >= maxAttempts {
+			lockedUntil := time.Now().Add(lockout)
+			credential.LockedUntil = &lockedUntil
+			credential.FailedAttempts = 0
+		}
+
+		return tx.Model(&credential).Updates(map[string]interface{}{
+			"failed_attempts": credential.FailedAttempts,
+			"locked_until":    credential.LockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// UseRecoveryCode spends a recovery code. Each code works once.
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New(constants.ErrInvalidMFACode)
+	}
+	return nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID, codeHashes []string) error {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]*model.MFARecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, model.NewMFARecoveryCode(userID, hash))
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+
+	return tx.Create(&codes).Error
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (r *mfaRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
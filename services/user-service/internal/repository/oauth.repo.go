@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *model.OAuthClient) error
+	GetClient(ctx context.Context, id uuid.UUID) (*model.OAuthClient, error)
+	ListClients(ctx context.Context) ([]*model.OAuthClient, error)
+	DeleteClient(ctx context.Context, id uuid.UUID) error
+
+	GetConsent(ctx context.Context, userID, clientID uuid.UUID) (*model.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent *model.OAuthConsent) error
+	ListConsents(ctx context.Context, userID uuid.UUID) ([]*model.OAuthConsent, error)
+	DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) error
+
+	CreateAuthorizationCode(ctx context.Context, code *model.OAuthAuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string, clientID uuid.UUID) (*model.OAuthAuthorizationCode, error)
+
+	CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error)
+	RotateRefreshToken(ctx context.Context, usedTokenID uuid.UUID, next *model.OAuthRefreshToken) error
+	RevokeRefreshToken(ctx context.Context, id uuid.UUID) error
+	RevokeRefreshTokens(ctx context.Context, userID, clientID uuid.UUID) error
+
+	CleanupExpiredGrants(ctx context.Context) error
+}
+
+type oauthRepository struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewOAuthRepository(db *gorm.DB, log *logger.Logger) OAuthRepository {
+	return &oauthRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *oauthRepository) CreateClient(ctx context.Context, client *model.OAuthClient) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+func (r *oauthRepository) GetClient(ctx context.Context, id uuid.UUID) (*model.OAuthClient, error) {
+	var client model.OAuthClient
+	if err := r.db.WithContext(ctx).First(&client, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrOAuthClientNotFound, err)
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthRepository) ListClients(ctx context.Context) ([]*model.OAuthClient, error) {
+	var clients []*model.OAuthClient
+	err := r.db.WithContext(ctx).Order("created_at ASC").Find(&clients).Error
+	return clients, err
+}
+
+// DeleteClient removes the client along with its codes, consents and refresh tokens, which the foreign
+// keys cascade to
+func (r *oauthRepository) DeleteClient(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&model.OAuthClient{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New(constants.ErrOAuthClientNotFound)
+	}
+	return nil
+}
+
+func (r *oauthRepository) GetConsent(ctx context.Context, userID, clientID uuid.UUID) (*model.OAuthConsent, error) {
+	var consent model.OAuthConsent
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND client_id = ?", userID, clientID).
+		First(&consent).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrConsentNotFound, err)
+		}
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// SaveConsent stores the consent, replacing the scopes of an earlier one for the same user and client
+func (r *oauthRepository) SaveConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+		}).
+		Create(consent).Error
+}
+
+func (r *oauthRepository) ListConsents(ctx context.Context, userID uuid.UUID) ([]*model.OAuthConsent, error) {
+	var consents []*model.OAuthConsent
+	err := r.db.WithContext(ctx).
+		Preload("Client").
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&consents).Error
+	return consents, err
+}
+
+// DeleteConsent withdraws the consent and revokes the refresh tokens the client holds for the user
+func (r *oauthRepository) DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().
+			Where("user_id = ? AND client_id = ?", userID, clientID).
+			Delete(&model.OAuthConsent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New(constants.ErrConsentNotFound)
+		}
+
+		return revokeRefreshTokens(tx, userID, clientID)
+	})
+}
+
+func (r *oauthRepository) CreateAuthorizationCode(ctx context.Context, code *model.OAuthAuthorizationCode) error {
+	return r.db.WithContext(ctx).Create(code).Error
+}
+
+// ConsumeAuthorizationCode marks a code issued to the client used and returns it as it was before. UsedAt is
+// only set on the returned code when it had already been exchanged, which the caller treats as a replay. The
+// row is locked while it is checked, and a code presented by another client is reported as unknown without
+// being spent, so it neither burns the real client's code nor trips the replay handling.
+func (r *oauthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string, clientID uuid.UUID) (*model.OAuthAuthorizationCode, error) {
+	var code model.OAuthAuthorizationCode
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ?", codeHash).
+			First(&code).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%s: %w", constants.ErrInvalidToken, err)
+			}
+			return err
+		}
+
+		if code.ClientID != clientID {
+			return errors.New(constants.ErrInvalidToken)
+		}
+
+		if code.UsedAt != nil {
+			return nil
+		}
+
+		return tx.Model(&model.OAuthAuthorizationCode{}).
+			Where("id = ?", code.ID).
+			Update("used_at", time.Now()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *oauthRepository) CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// GetRefreshToken looks up a token by hash, whether or not it is still active
+func (r *oauthRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error) {
+	var token model.OAuthRefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s: %w", constants.ErrInvalidToken, err)
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken revokes a token and stores its successor. The revocation only applies to a token that
+// is still active, so of two concurrent exchanges of the same token only one succeeds.
+func (r *oauthRepository) RotateRefreshToken(ctx context.Context, usedTokenID uuid.UUID, next *model.OAuthRefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.OAuthRefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", usedTokenID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New(constants.ErrRefreshTokenReused)
+		}
+
+		return tx.Create(next).Error
+	})
+}
+
+func (r *oauthRepository) RevokeRefreshToken(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&model.OAuthRefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *oauthRepository) RevokeRefreshTokens(ctx context.Context, userID, clientID uuid.UUID) error {
+	return revokeRefreshTokens(r.db.WithContext(ctx), userID, clientID)
+}
+
+func revokeRefreshTokens(db *gorm.DB, userID, clientID uuid.UUID) error {
+	return db.Model(&model.OAuthRefreshToken{}).
+		Where("user_id = ? AND client_id = ? AND revoked_at IS NULL", userID, clientID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// CleanupExpiredGrants deletes expired authorization codes and refresh tokens
+func (r *oauthRepository) CleanupExpiredGrants(ctx context.Context) error {
+	now := time.Now()
+
+	if err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("expires_at < ?", now).
+		Delete(&model.OAuthAuthorizationCode{}).Error; err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).
+		Unscoped().
+		Where("expires_at < ?", now).
+		Delete(&model.OAuthRefreshToken{}).Error
+}
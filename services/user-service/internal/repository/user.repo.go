@@ -4,28 +4,57 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/fairuzald/library-system/pkg/cache"
 	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/listquery"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/pagination"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *model.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
 	GetByUsernameOrEmail(ctx context.Context, usernameOrEmail string) (*model.User, error)
 	Update(ctx context.Context, user *model.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	List(ctx context.Context, filter *dto.UserFilter) ([]*model.User, int64, error)
+	List(ctx context.Context, filter *dto.UserFilter) ([]*model.User, int64, *pagination.Cursors, error)
 	UpdateLastLogin(ctx context.Context, id uuid.UUID) error
+	IncrementTokenVersion(ctx context.Context, id uuid.UUID) (int64, error)
+	GetTokenVersion(ctx context.Context, id uuid.UUID) (int64, error)
+	GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error
+	ResetPassword(ctx context.Context, tokenHash string, id uuid.UUID, hash string) error
+	ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) (bool, error)
+	MarkVerificationSent(ctx context.Context, id uuid.UUID, interval time.Duration) (bool, error)
+	MarkPasswordResetSent(ctx context.Context, id uuid.UUID, interval time.Duration) (bool, error)
+	MarkEmailVerified(ctx context.Context, user *model.User) (bool, error)
+}
+
+// userListSchema whitelists the fields clients can filter and sort users on
+var userListSchema = listquery.Schema{
+	"id":         {Column: "id", Type: listquery.TypeUUID, Unsortable: true},
+	"email":      {Column: "email", Type: listquery.TypeString},
+	"username":   {Column: "username", Type: listquery.TypeString},
+	"first_name": {Column: "first_name", Type: listquery.TypeString},
+	"last_name":  {Column: "last_name", Type: listquery.TypeString},
+	"role":       {Column: "role", Type: listquery.TypeString},
+	"status":     {Column: "status", Type: listquery.TypeString},
+	"last_login": {Column: "last_login", Type: listquery.TypeTime, Nullable: true},
+	"created_at": {Column: "created_at", Type: listquery.TypeTime},
+	"updated_at": {Column: "updated_at", Type: listquery.TypeTime},
 }
 
 type userRepository struct {
@@ -83,6 +112,21 @@ func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User
 	return &user, nil
 }
 
+func (r *userRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.User, error) {
+	var users []*model.User
+
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		r.log.Error("Failed to batch get users", zap.Error(err), zap.Int("count", len(ids)))
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
 
@@ -190,12 +234,32 @@ func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (r *userRepository) List(ctx context.Context, filter *dto.UserFilter) ([]*model.User, int64, error) {
+func (r *userRepository) List(ctx context.Context, filter *dto.UserFilter) ([]*model.User, int64, *pagination.Cursors, error) {
 	var users []*model.User
 	var count int64
 
+	sortKeys, err := userListSchema.ParseSort(listquery.SortExpr(filter.SortBy, filter.Desc),
+		pagination.SortKey{Column: "created_at", Desc: true})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	cond, err := userListSchema.ParseFilter(filter.Filter)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	page, err := pagination.NewParams(sortKeys, filter.Cursor, filter.GetOffset(), filter.Limit)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
 	query := r.db.WithContext(ctx).Model(&model.User{})
 
+	if cond != nil {
+		query = query.Where(cond)
+	}
+
 	if filter.Role != "" {
 		query = query.Where("role = ?", filter.Role)
 	}
@@ -206,27 +270,21 @@ func (r *userRepository) List(ctx context.Context, filter *dto.UserFilter) ([]*m
 
 	if err := query.Count(&count).Error; err != nil {
 		r.log.Error("Failed to count users", zap.Error(err))
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
-	if filter.SortBy != "" {
-		if filter.Desc {
-			query = query.Order(fmt.Sprintf("%s DESC", filter.SortBy))
-		} else {
-			query = query.Order(filter.SortBy)
-		}
-	} else {
-		query = query.Order("created_at DESC")
+	if err := page.Apply(query).Find(&users).Error; err != nil {
+		r.log.Error("Failed to list users", zap.Error(err))
+		return nil, 0, nil, err
 	}
 
-	query = query.Offset(filter.GetOffset()).Limit(filter.Limit)
-
-	if err := query.Find(&users).Error; err != nil {
-		r.log.Error("Failed to list users", zap.Error(err))
-		return nil, 0, err
+	users, cursors, err := pagination.Build(ctx, r.db, page, users)
+	if err != nil {
+		r.log.Error("Failed to build user cursors", zap.Error(err))
+		return nil, 0, nil, err
 	}
 
-	return users, count, nil
+	return users, count, cursors, nil
 }
 
 func (r *userRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID) error {
@@ -243,3 +301,239 @@ func (r *userRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID) erro
 
 	return nil
 }
+
+// IncrementTokenVersion bumps the user's token version in a single statement and returns the new value
+func (r *userRepository) IncrementTokenVersion(ctx context.Context, id uuid.UUID) (int64, error) {
+	var user model.User
+
+	err := r.db.WithContext(ctx).
+		Raw("UPDATE users SET token_version = token_version + 1, updated_at = ? WHERE id = ? RETURNING token_version, email, username",
+			time.Now(), id).
+		Scan(&user).Error
+	if err != nil {
+		r.log.Error("Failed to increment token version", zap.Error(err), zap.String("id", id.String()))
+		return 0, err
+	}
+
+	if user.Email == "" {
+		return 0, fmt.Errorf("%s: %w", constants.ErrUserNotFound, gorm.ErrRecordNotFound)
+	}
+
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, id.String()))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Email))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Username))
+		_ = r.cache.Delete(ctx, constants.CacheKeyUsers)
+	}
+
+	return user.TokenVersion, nil
+}
+
+// GetTokenVersion reads the user's token version straight from the database, the copy every published one
+// comes from
+func (r *userRepository) GetTokenVersion(ctx context.Context, id uuid.UUID) (int64, error) {
+	var user model.User
+
+	err := r.db.WithContext(ctx).Select("token_version").Where("id = ?", id).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("%s: %w", constants.ErrUserNotFound, err)
+		}
+		return 0, err
+	}
+
+	return user.TokenVersion, nil
+}
+
+// userTokenVersions serves token versions from the users table, behind the copies published in Redis
+type userTokenVersions struct {
+	users UserRepository
+}
+
+// NewTokenVersionSource reads token versions from the users table for the JWT middleware
+func NewTokenVersionSource(users UserRepository) middleware.TokenVersionStore {
+	return &userTokenVersions{users: users}
+}
+
+func (s *userTokenVersions) TokenVersion(ctx context.Context, userID string) (int64, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+	return s.users.GetTokenVersion(ctx, id)
+}
+
+// GetPasswordHash reads the user's password hash straight from the database; cached users do not carry it
+func (r *userRepository) GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	var user model.User
+
+	err := r.db.WithContext(ctx).Select("password").Where("id = ?", id).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("%s: %w", constants.ErrUserNotFound, err)
+		}
+		return "", err
+	}
+
+	return user.Password, nil
+}
+
+// UpdatePassword stores a new password hash for the user
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error {
+	var user model.User
+
+	err := r.db.WithContext(ctx).
+		Raw("UPDATE users SET password = ?, updated_at = ? WHERE id = ? RETURNING email, username", hash, time.Now(), id).
+		Scan(&user).Error
+	if err != nil {
+		r.log.Error("Failed to update password", zap.Error(err), zap.String("id", id.String()))
+		return err
+	}
+
+	if user.Email == "" {
+		return fmt.Errorf("%s: %w", constants.ErrUserNotFound, gorm.ErrRecordNotFound)
+	}
+
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, id.String()))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Email))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Username))
+		_ = r.cache.Delete(ctx, constants.CacheKeyUsers)
+	}
+
+	return nil
+}
+
+// ResetPassword spends the user's password reset token and sets the new password hash in one transaction,
+// so a failed update leaves the link usable. The token row is locked while it is checked, so of two
+// concurrent resets with the same link only one succeeds.
+func (r *userRepository) ResetPassword(ctx context.Context, tokenHash string, id uuid.UUID, hash string) error {
+	var user model.User
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token model.AccountToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND purpose = ? AND user_id = ?", tokenHash, model.AccountTokenPasswordReset, id).
+			First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%s: %w", constants.ErrInvalidAccountToken, err)
+			}
+			return err
+		}
+
+		now := time.Now()
+		if token.UsedAt != nil || !token.ExpiresAt.After(now) {
+			return errors.New(constants.ErrInvalidAccountToken)
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Raw("UPDATE users SET password = ?, updated_at = ? WHERE id = ? RETURNING email, username", hash, now, id).
+			Scan(&user).Error; err != nil {
+			return err
+		}
+		if user.Email == "" {
+			return fmt.Errorf("%s: %w", constants.ErrUserNotFound, gorm.ErrRecordNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		if !strings.Contains(err.Error(), constants.ErrInvalidAccountToken) {
+			r.log.Error("Failed to reset password", zap.Error(err), zap.String("id", id.String()))
+		}
+		return err
+	}
+
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, id.String()))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Email))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Username))
+		_ = r.cache.Delete(ctx, constants.CacheKeyUsers)
+	}
+
+	return nil
+}
+
+// ReplacePasswordHash swaps the stored hash for an equivalent one, such as a rehash with current parameters.
+// It only applies while oldHash is still stored, so it cannot undo a password change made in the meantime.
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND password = ?", id, oldHash).
+		Update("password", newHash)
+	if result.Error != nil {
+		r.log.Error("Failed to replace password hash", zap.Error(result.Error), zap.String("id", id.String()))
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// MarkVerificationSent records that a verification email is being sent, unless one was sent within interval
+// or the address is already verified. The check and update are one statement, so concurrent resends cannot
+// both pass.
+func (r *userRepository) MarkVerificationSent(ctx context.Context, id uuid.UUID, interval time.Duration) (bool, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND email_verified_at IS NULL AND (verification_sent_at IS NULL OR verification_sent_at < ?)", id, now.Add(-interval)).
+		Update("verification_sent_at", now)
+	if result.Error != nil {
+		r.log.Error("Failed to record verification email", zap.Error(result.Error), zap.String("id", id.String()))
+		return false, result.Error
+	}
+
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, id.String()))
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// MarkPasswordResetSent records that a password reset email is being sent, unless one was sent within
+// interval. Like MarkVerificationSent, the check and update are one statement.
+func (r *userRepository) MarkPasswordResetSent(ctx context.Context, id uuid.UUID, interval time.Duration) (bool, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND (password_reset_sent_at IS NULL OR password_reset_sent_at < ?)", id, now.Add(-interval)).
+		Update("password_reset_sent_at", now)
+	if result.Error != nil {
+		r.log.Error("Failed to record password reset email", zap.Error(result.Error), zap.String("id", id.String()))
+		return false, result.Error
+	}
+
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, id.String()))
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// MarkEmailVerified verifies the user's current email address and activates the account if it was pending.
+// It only applies while the address is the one the user was loaded with, so a link sent to an old address
+// cannot verify a new one.
+func (r *userRepository) MarkEmailVerified(ctx context.Context, user *model.User) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", user.ID, user.Email).
+		Updates(map[string]interface{}{
+			"email_verified_at": time.Now(),
+			"status":            gorm.Expr("CASE WHEN status = ? THEN ? ELSE status END", constants.UserStatusPending, constants.UserStatusActive),
+		})
+	if result.Error != nil {
+		r.log.Error("Failed to mark email verified", zap.Error(result.Error), zap.String("id", user.ID.String()))
+		return false, result.Error
+	}
+
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.ID.String()))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Email))
+		_ = r.cache.Delete(ctx, fmt.Sprintf("%s%s", constants.CacheKeyUser, user.Username))
+		_ = r.cache.Delete(ctx, constants.CacheKeyUsers)
+	}
+
+	return result.RowsAffected > 0, nil
+}
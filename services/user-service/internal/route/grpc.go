@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/proto/user"
+)
+
+// GRPCAccess lists who may call each gRPC method. Login (including its two-factor step), registration,
+// token refresh, logout and health checks are public; every other method needs an authenticated caller.
+func GRPCAccess() middleware.MethodAccess {
+	return middleware.MethodAccess{
+		Public: []string{
+			user.UserService_Login_FullMethodName,
+			user.UserService_CompleteMFALogin_FullMethodName,
+			user.UserService_Register_FullMethodName,
+			user.UserService_RefreshToken_FullMethodName,
+			user.UserService_Logout_FullMethodName,
+			user.UserService_Health_FullMethodName,
+		},
+		Permissions: middleware.MethodPermissions{
+			user.UserService_GetUser_FullMethodName:           {middleware.PermUserRead},
+			user.UserService_BatchGetUsers_FullMethodName:     {middleware.PermUserRead},
+			user.UserService_GetUserByEmail_FullMethodName:    {middleware.PermUserRead},
+			user.UserService_GetUserByUsername_FullMethodName: {middleware.PermUserRead},
+			user.UserService_ListUsers_FullMethodName:         {middleware.PermUserRead},
+			user.UserService_CreateUser_FullMethodName:        {middleware.PermUserAdmin},
+			user.UserService_UpdateUser_FullMethodName:        {middleware.PermUserAdmin},
+			user.UserService_DeleteUser_FullMethodName:        {middleware.PermUserAdmin},
+			user.UserService_ChangePassword_FullMethodName:    {},
+			user.UserService_RevokeAllTokens_FullMethodName:   {middleware.PermUserAdmin},
+			user.UserService_ListSessions_FullMethodName:      {},
+			user.UserService_RevokeSession_FullMethodName:     {},
+			user.UserService_RevokeSessions_FullMethodName:    {},
+		},
+	}
+}
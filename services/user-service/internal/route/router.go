@@ -12,34 +12,102 @@ func SetupRoutes(
 	router *mux.Router,
 	userHandler *handler.UserHandler,
 	authHandler *handler.AuthHandler,
+	keyHandler *handler.KeyHandler,
+	oauthHandler *handler.OAuthHandler,
 	jwtAuth *middleware.JWTAuth,
+	policy *middleware.Policy,
 	log *logger.Logger,
 	cfg *config.Config,
 ) {
+	// Only served when tokens are signed with asymmetric keys
+	if keyHandler != nil {
+		router.HandleFunc("/.well-known/jwks.json", keyHandler.HandleJWKS).Methods("GET")
+	}
+
+	// Only served when the OpenID Connect provider is configured
+	if oauthHandler != nil {
+		router.HandleFunc("/.well-known/openid-configuration", oauthHandler.HandleDiscovery).Methods("GET")
+
+		// Called by client applications, which authenticate themselves rather than a user
+		oauthRouter := router.PathPrefix("/oauth").Subrouter()
+		oauthRouter.HandleFunc("/token", oauthHandler.HandleToken).Methods("POST")
+		oauthRouter.HandleFunc("/userinfo", oauthHandler.HandleUserInfo).Methods("GET", "POST")
+		oauthRouter.HandleFunc("/introspect", oauthHandler.HandleIntrospect).Methods("POST")
+		oauthRouter.HandleFunc("/revoke", oauthHandler.HandleRevoke).Methods("POST")
+	}
+
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
 	authRouter := apiRouter.PathPrefix("/auth").Subrouter()
 	authRouter.HandleFunc("/login", authHandler.HandleLogin).Methods("POST")
 	authRouter.HandleFunc("/register", authHandler.HandleRegister).Methods("POST")
 	authRouter.HandleFunc("/refresh", authHandler.HandleRefreshToken).Methods("POST")
+	authRouter.HandleFunc("/login/mfa", authHandler.HandleCompleteMFALogin).Methods("POST")
+	authRouter.HandleFunc("/login/mfa/enroll", authHandler.HandleEnrollMFAWithToken).Methods("POST")
+	authRouter.HandleFunc("/password/forgot", authHandler.HandleForgotPassword).Methods("POST")
+	authRouter.HandleFunc("/password/reset", authHandler.HandleResetPassword).Methods("POST")
+	authRouter.HandleFunc("/verify-email", authHandler.HandleVerifyEmail).Methods("POST")
+	authRouter.HandleFunc("/verify-email/resend", authHandler.HandleResendVerificationEmail).Methods("POST")
 
 	authProtectedRouter := authRouter.NewRoute().Subrouter()
 	authProtectedRouter.Use(jwtAuth.HTTPMiddleware)
 	authProtectedRouter.HandleFunc("/logout", authHandler.HandleLogout).Methods("POST")
+	authProtectedRouter.HandleFunc("/token-version", authHandler.HandleTokenVersion).Methods("GET")
+	authProtectedRouter.HandleFunc("/sessions", authHandler.HandleListSessions).Methods("GET")
+	authProtectedRouter.HandleFunc("/sessions", authHandler.HandleRevokeSessions).Methods("DELETE")
+	authProtectedRouter.HandleFunc("/sessions/{id}", authHandler.HandleRevokeSession).Methods("DELETE")
+	authProtectedRouter.HandleFunc("/mfa", authHandler.HandleMFAStatus).Methods("GET")
+	authProtectedRouter.HandleFunc("/mfa", authHandler.HandleDisableMFA).Methods("DELETE")
+	authProtectedRouter.HandleFunc("/mfa/enroll", authHandler.HandleEnrollMFA).Methods("POST")
+	authProtectedRouter.HandleFunc("/mfa/confirm", authHandler.HandleConfirmMFA).Methods("POST")
+	authProtectedRouter.HandleFunc("/mfa/recovery-codes", authHandler.HandleRegenerateRecoveryCodes).Methods("POST")
+
+	if oauthHandler != nil {
+		// Consent page and the user's own grants
+		oauthUserRouter := apiRouter.PathPrefix("/oauth").Subrouter()
+		oauthUserRouter.Use(jwtAuth.HTTPMiddleware)
+		oauthUserRouter.HandleFunc("/authorize", oauthHandler.HandleCheckAuthorization).Methods("GET")
+		oauthUserRouter.HandleFunc("/authorize", oauthHandler.HandleAuthorize).Methods("POST")
+		oauthUserRouter.HandleFunc("/consents", oauthHandler.HandleListConsents).Methods("GET")
+		oauthUserRouter.HandleFunc("/consents/{client_id}", oauthHandler.HandleRevokeConsent).Methods("DELETE")
+
+		// Client registration (oauth_client:admin required)
+		oauthAdminRouter := apiRouter.PathPrefix("/oauth/clients").Subrouter()
+		oauthAdminRouter.Use(jwtAuth.HTTPMiddleware, policy.Require(middleware.PermOAuthClientAdmin))
+		oauthAdminRouter.HandleFunc("", oauthHandler.HandleRegisterClient).Methods("POST")
+		oauthAdminRouter.HandleFunc("", oauthHandler.HandleListClients).Methods("GET")
+		oauthAdminRouter.HandleFunc("/{id}", oauthHandler.HandleDeleteClient).Methods("DELETE")
+	}
 
 	userRouter := apiRouter.PathPrefix("/users").Subrouter()
 
+	// Lookups across users (user:read required), registered before /{id} so "email" is not taken as a user ID
+	userReadRouter := userRouter.NewRoute().Subrouter()
+	userReadRouter.Use(jwtAuth.HTTPMiddleware, policy.Require(middleware.PermUserRead))
+
+	userReadRouter.HandleFunc("", userHandler.HandleListUsers).Methods("GET")
+	userReadRouter.HandleFunc("/email", userHandler.HandleGetUserByEmail).Methods("GET")
+	userReadRouter.HandleFunc("/username", userHandler.HandleGetUserByUsername).Methods("GET")
+
+	// User administration (user:admin required)
+	userAdminRouter := userRouter.NewRoute().Subrouter()
+	userAdminRouter.Use(jwtAuth.HTTPMiddleware, policy.Require(middleware.PermUserAdmin))
+
+	userAdminRouter.HandleFunc("", userHandler.HandleCreateUser).Methods("POST")
+	userAdminRouter.HandleFunc("/{id}", userHandler.HandleDeleteUser).Methods("DELETE")
+	userAdminRouter.HandleFunc("/{id}/mfa", authHandler.HandleResetMFA).Methods("DELETE")
+	userAdminRouter.HandleFunc("/{id}/approve", userHandler.HandleApproveUser).Methods("POST")
+	userAdminRouter.HandleFunc("/{id}/unlock", authHandler.HandleUnlockLogin).Methods("POST")
+
+	// Own account, or any account with the matching permission (checked by the handlers). The /me
+	// routes resolve to the caller and are registered before /{id}.
 	userProtectedRouter := userRouter.NewRoute().Subrouter()
 	userProtectedRouter.Use(jwtAuth.HTTPMiddleware)
 
-	userProtectedRouter.HandleFunc("", userHandler.HandleCreateUser).Methods("POST")
-	userProtectedRouter.HandleFunc("", userHandler.HandleListUsers).Methods("GET")
-
+	userProtectedRouter.HandleFunc("/me", userHandler.HandleGetUser).Methods("GET")
+	userProtectedRouter.HandleFunc("/me", userHandler.HandleUpdateUser).Methods("PUT", "PATCH")
+	userProtectedRouter.HandleFunc("/me/password", userHandler.HandleChangePassword).Methods("PUT")
 	userProtectedRouter.HandleFunc("/{id}", userHandler.HandleGetUser).Methods("GET")
 	userProtectedRouter.HandleFunc("/{id}", userHandler.HandleUpdateUser).Methods("PUT", "PATCH")
-	userProtectedRouter.HandleFunc("/{id}", userHandler.HandleDeleteUser).Methods("DELETE")
 	userProtectedRouter.HandleFunc("/{id}/password", userHandler.HandleChangePassword).Methods("PUT")
-
-	userProtectedRouter.HandleFunc("/email", userHandler.HandleGetUserByEmail).Methods("GET")
-	userProtectedRouter.HandleFunc("/username", userHandler.HandleGetUserByUsername).Methods("GET")
 }
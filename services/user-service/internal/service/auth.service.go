@@ -19,78 +19,190 @@ import (
 )
 
 type authService struct {
-	userRepo   repository.UserRepository
-	authRepo   repository.AuthRepository
-	jwtAuth    *middleware.JWTAuth
-	log        *logger.Logger
-	accessExp  time.Duration
-	refreshExp time.Duration
+	userRepo         repository.UserRepository
+	authRepo         repository.AuthRepository
+	mfaRepo          repository.MFARepository
+	accountTokenRepo repository.AccountTokenRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	jwtAuth          *middleware.JWTAuth
+	log              *logger.Logger
+	accessExp        time.Duration
+	refreshExp       time.Duration
+	mfa              MFAConfig
+	accountMail      AccountMailConfig
+	loginProtection  LoginProtectionConfig
+	password         PasswordConfig
 }
 
 func NewAuthService(
 	userRepo repository.UserRepository,
 	authRepo repository.AuthRepository,
+	mfaRepo repository.MFARepository,
+	accountTokenRepo repository.AccountTokenRepository,
+	loginAttemptRepo repository.LoginAttemptRepository,
 	jwtAuth *middleware.JWTAuth,
 	log *logger.Logger,
 	accessExp time.Duration,
 	refreshExp time.Duration,
+	mfa MFAConfig,
+	accountMail AccountMailConfig,
+	loginProtection LoginProtectionConfig,
+	password PasswordConfig,
 ) AuthService {
 	return &authService{
-		userRepo:   userRepo,
-		authRepo:   authRepo,
-		jwtAuth:    jwtAuth,
-		log:        log,
-		accessExp:  accessExp,
-		refreshExp: refreshExp,
+		userRepo:         userRepo,
+		authRepo:         authRepo,
+		mfaRepo:          mfaRepo,
+		accountTokenRepo: accountTokenRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		jwtAuth:          jwtAuth,
+		log:              log,
+		accessExp:        accessExp,
+		refreshExp:       refreshExp,
+		mfa:              mfa,
+		accountMail:      accountMail,
+		loginProtection:  loginProtection,
+		password:         password,
 	}
 }
 
+// Login checks the user's password. Users with two-factor authentication, or whose role requires it, get
+// an MFA challenge instead of tokens. Repeated failures for an account or from a client IP are throttled,
+// and the throttle applies before the password is checked, so a locked-out login fails even when it is right.
 func (s *authService) Login(ctx context.Context, req *dto.UserLogin) (*dao.TokenResponse, error) {
+	if err := s.checkLoginBlocked(ctx, loginIPKey(req.ClientInfo.IPAddress)); err != nil {
+		s.log.Info("Login throttled for client IP", zap.String("ip_address", req.ClientInfo.IPAddress))
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByUsernameOrEmail(ctx, req.UsernameOrEmail)
 	if err != nil {
+		user = nil
+	}
+
+	accountKey := loginAccountKey(user, req.UsernameOrEmail)
+	if err := s.checkLoginBlocked(ctx, accountKey); err != nil {
+		s.log.Info("Login throttled for account", zap.String("username_or_email", req.UsernameOrEmail))
+		return nil, err
+	}
+
+	if user == nil {
 		s.log.Info("Login failed: user not found", zap.String("username_or_email", req.UsernameOrEmail))
+		s.loginFailed(ctx, nil, accountKey, req.ClientInfo)
 		return nil, errors.New(constants.ErrInvalidCredentials)
 	}
 
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
+	match, rehash := s.password.Hasher.Verify(req.Password, user.Password)
+	if !match {
 		s.log.Info("Login failed: invalid password", zap.String("user_id", user.ID.String()))
+		s.loginFailed(ctx, user, accountKey, req.ClientInfo)
 		return nil, errors.New(constants.ErrInvalidCredentials)
 	}
 
+	if rehash {
+		s.upgradePasswordHash(ctx, user, req.Password)
+	}
+
+	if err := s.loginAttemptRepo.Reset(ctx, accountKey); err != nil {
+		s.log.Warn("Failed to clear failed logins", zap.Error(err), zap.String("user_id", user.ID.String()))
+	}
+
+	// Checked after the password, so the error does not tell strangers which accounts are pending or blocked
+	if err := accountStatusError(user); err != nil {
+		s.log.Info("Login failed: account cannot sign in", zap.String("user_id", user.ID.String()), zap.String("status", user.Status))
+		return nil, err
+	}
+
+	challenge, err := s.mfaChallenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	return s.completeLogin(ctx, user, req.DeviceName, req.ClientInfo)
+}
+
+// accountStatusError returns the error for an account that may not sign in or keep its sessions going, or
+// nil for an active account
+func accountStatusError(user *model.User) error {
+	switch user.Status {
+	case constants.UserStatusPending:
+		return errors.New(constants.ErrAccountPending)
+	case constants.UserStatusBlocked:
+		return errors.New(constants.ErrAccountBlocked)
+	case constants.UserStatusInactive:
+		return errors.New(constants.ErrAccountInactive)
+	default:
+		return nil
+	}
+}
+
+// upgradePasswordHash rehashes a password whose stored hash uses an older algorithm or weaker parameters.
+// The password was just verified, so a failure only postpones the upgrade to the next login.
+func (s *authService) upgradePasswordHash(ctx context.Context, user *model.User, plain string) {
+	hash, err := s.password.Hasher.Hash(plain)
+	if err != nil {
+		s.log.Warn("Failed to rehash password", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return
+	}
+
+	if _, err := s.userRepo.ReplacePasswordHash(ctx, user.ID, user.Password, hash); err != nil {
+		s.log.Warn("Failed to store rehashed password", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return
+	}
+
+	user.Password = hash
+}
+
+// completeLogin opens a session for a user who has proved who they are
+func (s *authService) completeLogin(ctx context.Context, user *model.User, deviceName string, client dto.ClientInfo) (*dao.TokenResponse, error) {
 	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
 		s.log.Warn("Failed to update last login time", zap.Error(err), zap.String("user_id", user.ID.String()))
 	}
 
-	accessToken, err := s.jwtAuth.GenerateToken(
-		user.ID.String(),
-		user.Email,
-		user.Role,
-		user.Username,
-	)
+	session, refreshToken, err := s.startSession(ctx, user.ID, deviceName, client)
 	if err != nil {
-		s.log.Error("Failed to generate access token", zap.Error(err))
 		return nil, err
 	}
 
-	refreshTokenValue := utils.GenerateRandomString(32)
-	refreshTokenExpiry := time.Now().Add(s.refreshExp)
-
-	if err := s.authRepo.StoreRefreshToken(ctx, user.ID, refreshTokenValue, refreshTokenExpiry); err != nil {
-		s.log.Error("Failed to store refresh token", zap.Error(err))
+	accessToken, err := s.generateAccessToken(ctx, user, session.ID)
+	if err != nil {
 		return nil, err
 	}
 
 	response := &dao.TokenResponse{
 		AccessToken:  accessToken,
-		RefreshToken: refreshTokenValue,
+		RefreshToken: refreshToken,
 		TokenType:    constants.TokenTypBearer,
 		ExpiresIn:    int64(s.accessExp.Seconds()),
+		SessionID:    session.ID.String(),
 		User:         dao.NewUserResponse(user),
 	}
 
 	return response, nil
 }
 
+// generateAccessToken issues an access token at the user's current token version. Services that find the
+// version missing from Redis read it from the users table or this service and publish it again.
+func (s *authService) generateAccessToken(ctx context.Context, user *model.User, sessionID uuid.UUID) (string, error) {
+	accessToken, err := s.jwtAuth.GenerateToken(ctx, middleware.Claims{
+		UserID:       user.ID.String(),
+		Email:        user.Email,
+		Role:         user.Role,
+		Username:     user.Username,
+		SessionID:    sessionID.String(),
+		TokenVersion: user.TokenVersion,
+	})
+	if err != nil {
+		s.log.Error("Failed to generate access token", zap.Error(err))
+		return "", err
+	}
+
+	return accessToken, nil
+}
+
 func (s *authService) Register(ctx context.Context, req *dto.UserRegister) (*dao.UserResponse, error) {
 	_, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err == nil {
@@ -102,9 +214,8 @@ func (s *authService) Register(ctx context.Context, req *dto.UserRegister) (*dao
 		return nil, errors.New(constants.ErrUsernameTaken)
 	}
 
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := s.password.hashNew(req.Password, req.Username, req.Email, req.FirstName, req.LastName)
 	if err != nil {
-		s.log.Error("Failed to hash password", zap.Error(err))
 		return nil, err
 	}
 
@@ -125,52 +236,95 @@ func (s *authService) Register(ctx context.Context, req *dto.UserRegister) (*dao
 		user.Address = req.Address
 	}
 
+	// Self-registered accounts stay pending until the email address is verified or an administrator
+	// approves them
+	user.Status = constants.UserStatusPending
+
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		s.log.Error("Failed to create user", zap.Error(err))
 		return nil, err
 	}
 
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		s.log.Warn("Failed to send verification email", zap.Error(err), zap.String("user_id", user.ID.String()))
+	}
+
 	return dao.NewUserResponse(user), nil
 }
 
-func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*dao.TokenResponse, error) {
-	user, err := s.authRepo.GetUserByRefreshToken(ctx, refreshToken)
+// RefreshToken exchanges a refresh token for a new access token and the next token of its session's family.
+// Presenting a token that was already exchanged means it leaked, so the whole session is revoked.
+func (s *authService) RefreshToken(ctx context.Context, req *dto.RefreshToken) (*dao.TokenResponse, error) {
+	token, err := s.authRepo.GetRefreshToken(ctx, utils.HashToken(req.RefreshToken))
 	if err != nil {
-		s.log.Info("Refresh token failed: invalid token", zap.Error(err))
+		if strings.Contains(err.Error(), constants.ErrInvalidToken) || err.Error() == constants.ErrSessionNotFound {
+			s.log.Info("Refresh token failed: invalid token")
+			return nil, errors.New(constants.ErrInvalidToken)
+		}
+		s.log.Error("Failed to look up refresh token", zap.Error(err))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	session := token.Session
+	if token.UsedAt != nil {
+		return nil, s.handleRefreshTokenReuse(ctx, session, req.ClientInfo)
+	}
+
+	if !session.Active() {
+		s.log.Info("Refresh token failed: session ended", zap.String("session_id", session.ID.String()))
 		return nil, errors.New(constants.ErrInvalidToken)
 	}
 
-	accessToken, err := s.jwtAuth.GenerateToken(
-		user.ID.String(),
-		user.Email,
-		user.Role,
-		user.Username,
-	)
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
 	if err != nil {
-		s.log.Error("Failed to generate access token", zap.Error(err))
+		s.log.Info("Refresh token failed: user not found", zap.Error(err), zap.String("session_id", session.ID.String()))
+		return nil, errors.New(constants.ErrInvalidToken)
+	}
+
+	if err := accountStatusError(user); err != nil {
+		s.log.Info("Refresh token failed: account cannot sign in", zap.String("user_id", user.ID.String()), zap.String("status", user.Status))
 		return nil, err
 	}
 
-	newRefreshTokenValue := utils.GenerateRandomString(32)
-	newRefreshTokenExpiry := time.Now().Add(s.refreshExp)
+	newRefreshToken, err := utils.GenerateSecureToken(refreshTokenBytes)
+	if err != nil {
+		s.log.Error("Failed to generate refresh token", zap.Error(err))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	session.LastUsedAt = time.Now()
+	session.ExpiresAt = session.LastUsedAt.Add(s.refreshExp)
+	setClientInfo(session, req.ClientInfo)
+
+	next := model.NewRefreshToken(session.ID, utils.HashToken(newRefreshToken))
+	if err := s.authRepo.RotateRefreshToken(ctx, session, token.ID, next); err != nil {
+		if err.Error() == constants.ErrRefreshTokenReused {
+			return nil, s.handleRefreshTokenReuse(ctx, session, req.ClientInfo)
+		}
+		if err.Error() == constants.ErrInvalidToken {
+			s.log.Info("Refresh token failed: session revoked during refresh", zap.String("session_id", session.ID.String()))
+			return nil, errors.New(constants.ErrInvalidToken)
+		}
+		s.log.Error("Failed to rotate refresh token", zap.Error(err), zap.String("session_id", session.ID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
 
-	if err := s.authRepo.StoreRefreshToken(ctx, user.ID, newRefreshTokenValue, newRefreshTokenExpiry); err != nil {
-		s.log.Error("Failed to store refresh token", zap.Error(err))
-		return &dao.TokenResponse{
-			AccessToken: accessToken,
-			TokenType:   constants.TokenTypBearer,
-			ExpiresIn:   int64(s.accessExp.Seconds()),
-		}, nil
+	accessToken, err := s.generateAccessToken(ctx, user, session.ID)
+	if err != nil {
+		return nil, err
 	}
 
 	return &dao.TokenResponse{
 		AccessToken:  accessToken,
-		RefreshToken: newRefreshTokenValue,
+		RefreshToken: newRefreshToken,
 		TokenType:    constants.TokenTypBearer,
 		ExpiresIn:    int64(s.accessExp.Seconds()),
+		SessionID:    session.ID.String(),
 	}, nil
 }
 
+// Logout blacklists the access token and revokes the session it was issued for. Tokens issued before
+// sessions existed carry no session ID, so all of the user's sessions are revoked for them.
 func (s *authService) Logout(ctx context.Context, accessToken string) error {
 	claims, err := s.jwtAuth.ValidateToken(accessToken)
 	if err != nil {
@@ -191,8 +345,15 @@ func (s *authService) Logout(ctx context.Context, accessToken string) error {
 		return err
 	}
 
-	if err := s.authRepo.RevokeRefreshToken(ctx, userID); err != nil {
-		s.log.Warn("Failed to revoke refresh token", zap.Error(err))
+	if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+		if err := s.authRepo.RevokeSession(ctx, userID, sessionID); err != nil && err.Error() != constants.ErrSessionNotFound {
+			s.log.Warn("Failed to revoke session", zap.Error(err), zap.String("session_id", sessionID.String()))
+		}
+		return nil
+	}
+
+	if _, err := s.authRepo.RevokeSessions(ctx, userID, nil); err != nil {
+		s.log.Warn("Failed to revoke sessions", zap.Error(err))
 	}
 
 	return nil
@@ -208,13 +369,46 @@ func (s *authService) ValidateToken(token string) (*middleware.Claims, error) {
 		return nil, errors.New(constants.ErrInvalidToken)
 	}
 
-	claims, err := s.jwtAuth.ValidateToken(token)
+	claims, err := s.jwtAuth.Authenticate(context.Background(), token)
 	if err != nil {
 		if strings.Contains(err.Error(), "token has expired") {
 			return nil, errors.New(constants.ErrExpiredToken)
 		}
+		if err.Error() == constants.ErrTokenRevoked {
+			return nil, errors.New(constants.ErrTokenRevoked)
+		}
 		return nil, errors.New(constants.ErrInvalidToken)
 	}
 
 	return claims, nil
 }
+
+// GetTokenVersion returns the user's current token version from the database, for services whose
+// published copy was lost
+func (s *authService) GetTokenVersion(ctx context.Context, userID uuid.UUID) (*dao.TokenVersionResponse, error) {
+	version, err := s.userRepo.GetTokenVersion(ctx, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrUserNotFound) {
+			return nil, errors.New(constants.ErrUserNotFound)
+		}
+		s.log.Error("Failed to get token version", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	return &dao.TokenVersionResponse{UserID: userID.String(), TokenVersion: version}, nil
+}
+
+// RevokeAllTokens logs the user out everywhere: every access token issued so far stops being accepted
+// and every session is revoked
+func (s *authService) RevokeAllTokens(ctx context.Context, userID uuid.UUID) (*dao.RevokeSessionsResponse, error) {
+	revoked, err := revokeUserTokens(ctx, s.userRepo, s.authRepo, userID, true)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrUserNotFound) {
+			return nil, errors.New(constants.ErrUserNotFound)
+		}
+		s.log.Error("Failed to revoke tokens", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	return &dao.RevokeSessionsResponse{Revoked: revoked}, nil
+}
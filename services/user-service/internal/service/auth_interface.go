@@ -6,12 +6,37 @@ import (
 	"github.com/fairuzald/library-system/pkg/middleware"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/google/uuid"
 )
 
 type AuthService interface {
 	Login(ctx context.Context, req *dto.UserLogin) (*dao.TokenResponse, error)
 	Register(ctx context.Context, req *dto.UserRegister) (*dao.UserResponse, error)
-	RefreshToken(ctx context.Context, refreshToken string) (*dao.TokenResponse, error)
+	RefreshToken(ctx context.Context, req *dto.RefreshToken) (*dao.TokenResponse, error)
 	Logout(ctx context.Context, accessToken string) error
+	EndSession(ctx context.Context, refreshToken string) error
 	ValidateToken(token string) (*middleware.Claims, error)
+
+	ListSessions(ctx context.Context, userID uuid.UUID, currentSessionID string) (*dao.SessionListResponse, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeSessions(ctx context.Context, userID uuid.UUID, except *uuid.UUID) (*dao.RevokeSessionsResponse, error)
+	RevokeAllTokens(ctx context.Context, userID uuid.UUID) (*dao.RevokeSessionsResponse, error)
+	GetTokenVersion(ctx context.Context, userID uuid.UUID) (*dao.TokenVersionResponse, error)
+
+	CompleteMFALogin(ctx context.Context, req *dto.MFALogin) (*dao.TokenResponse, error)
+	EnrollMFAWithToken(ctx context.Context, mfaToken string) (*dao.MFAEnrollmentResponse, error)
+	MFAStatus(ctx context.Context, userID uuid.UUID) (*dao.MFAStatusResponse, error)
+	EnrollMFA(ctx context.Context, userID uuid.UUID) (*dao.MFAEnrollmentResponse, error)
+	ConfirmMFA(ctx context.Context, userID uuid.UUID, code string) (*dao.RecoveryCodesResponse, error)
+	DisableMFA(ctx context.Context, userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) (*dao.RecoveryCodesResponse, error)
+	ResetMFA(ctx context.Context, userID uuid.UUID) error
+
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, req *dto.ResetPassword) error
+
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+
+	UnlockLogin(ctx context.Context, userID uuid.UUID) error
 }
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/fairuzald/library-system/services/user-service/internal/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// rsaKeyBits is the modulus size of generated RS256 keys
+	rsaKeyBits = 2048
+	// keyReloadInterval bounds how long a replica keeps using its key list after another replica rotated
+	keyReloadInterval = time.Minute
+	// keyMissReloadInterval spaces out reloads triggered by tokens with unknown kids
+	keyMissReloadInterval = 10 * time.Second
+)
+
+// loadedKey is a stored signing key with its DER encodings parsed
+type loadedKey struct {
+	id          uuid.UUID
+	algorithm   string
+	activatesAt time.Time
+	private     crypto.Signer
+	public      crypto.PublicKey
+}
+
+// keyService keeps the stored keys in memory and reloads them periodically, so replicas converge on keys
+// created by whichever replica rotated. Replicas that rotate at the same moment each add a key; both are
+// published, and the later activation wins. Private keys are stored sealed with AES-GCM under the key
+// encryption key, bound to their kid.
+type keyService struct {
+	keyRepo          repository.KeyRepository
+	algorithm        string
+	aead             cipher.AEAD
+	rotationInterval time.Duration
+	tokenLifetime    time.Duration
+	log              *logger.Logger
+
+	mu       sync.RWMutex
+	keys     []*loadedKey
+	loadedAt time.Time
+}
+
+// SignedTokenLifetime is the longest any token the service signs stays valid: access and OAuth tokens,
+// MFA challenges and email verification links. A retired key must be kept at least this long.
+func SignedTokenLifetime(accessTokenExpiry time.Duration) time.Duration {
+	return max(accessTokenExpiry, mfaChallengeTTL, emailVerificationTTL)
+}
+
+// NewKeyService takes the key encryption key as 32 base64-encoded bytes and the lifetime from
+// SignedTokenLifetime
+func NewKeyService(
+	keyRepo repository.KeyRepository,
+	algorithm string,
+	encryptionKey string,
+	rotationInterval time.Duration,
+	tokenLifetime time.Duration,
+	log *logger.Logger,
+) (KeyService, error) {
+	if algorithm != middleware.AlgorithmRS256 && algorithm != middleware.AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	kek, err := base64.StdEncoding.DecodeString(encryptionKey)
+	if err != nil || len(kek) != 32 {
+		return nil, errors.New("key encryption key must be 32 base64-encoded bytes")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyService{
+		keyRepo:          keyRepo,
+		algorithm:        algorithm,
+		aead:             aead,
+		rotationInterval: rotationInterval,
+		tokenLifetime:    tokenLifetime,
+		log:              log,
+	}, nil
+}
+
+// SigningKey returns the most recently activated key
+func (s *keyService) SigningKey(ctx context.Context) (*middleware.SigningKey, error) {
+	keys, err := s.currentKeys(ctx, keyReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].activatesAt.After(now) {
+			return &middleware.SigningKey{
+				ID:        keys[i].id.String(),
+				Algorithm: keys[i].algorithm,
+				Key:       keys[i].private,
+			}, nil
+		}
+	}
+
+	return nil, errors.New("no active signing key")
+}
+
+// VerificationKey returns the public key for a kid, reloading early when the kid is unknown
+func (s *keyService) VerificationKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	id, err := uuid.Parse(kid)
+	if err != nil {
+		return nil, errors.New(constants.ErrInvalidToken)
+	}
+
+	keys, err := s.currentKeys(ctx, keyReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := findKey(keys, id); key != nil {
+		return key.public, nil
+	}
+
+	keys, err = s.currentKeys(ctx, keyMissReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := findKey(keys, id); key != nil {
+		return key.public, nil
+	}
+
+	return nil, errors.New(constants.ErrInvalidToken)
+}
+
+// JWKS lists the public half of every stored key, including keys that are not yet signing
+func (s *keyService) JWKS(ctx context.Context) (*middleware.JWKS, error) {
+	keys, err := s.currentKeys(ctx, keyReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &middleware.JWKS{Keys: make([]middleware.JWK, 0, len(keys))}
+	for _, key := range keys {
+		jwk, err := middleware.NewJWK(key.id.String(), key.algorithm, key.public)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// RotateKeys creates a key when none exists or the newest one is due for replacement, and deletes keys whose
+// tokens have all expired. Replacement keys are published JWKSCacheTTL before they activate, so verifiers
+// already know them when the first token signed with them arrives.
+func (s *keyService) RotateKeys(ctx context.Context) error {
+	stored, err := s.keyRepo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if len(stored) == 0 {
+		// Nothing can have cached a key set yet, so the first key signs right away
+		if err := s.createKey(ctx, now); err != nil {
+			return err
+		}
+	} else if newest := stored[len(stored)-1]; !newest.ActivatesAt.After(now) &&
+		newest.ActivatesAt.Add(s.rotationInterval).Before(now.Add(constants.JWKSCacheTTL)) {
+		if err := s.createKey(ctx, now.Add(constants.JWKSCacheTTL)); err != nil {
+			return err
+		}
+	}
+
+	// A key stops signing when its successor activates; its last tokens expire one token lifetime later
+	var obsolete []uuid.UUID
+	for i := 0; i+1 < len(stored); i++ {
+		if stored[i+1].ActivatesAt.Add(s.tokenLifetime).Before(now) {
+			obsolete = append(obsolete, stored[i].ID)
+		}
+	}
+
+	if err := s.keyRepo.DeleteSigningKeys(ctx, obsolete); err != nil {
+		return err
+	}
+
+	_, err = s.currentKeys(ctx, 0)
+	return err
+}
+
+func (s *keyService) createKey(ctx context.Context, activatesAt time.Time) error {
+	var (
+		private crypto.Signer
+		err     error
+	)
+
+	switch s.algorithm {
+	case middleware.AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case middleware.AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return err
+	}
+
+	key := model.NewSigningKey(s.algorithm, nil, publicDER, activatesAt)
+	key.PrivateKey, err = s.sealPrivateKey(key.ID, privateDER)
+	if err != nil {
+		return err
+	}
+
+	if err := s.keyRepo.CreateSigningKey(ctx, key); err != nil {
+		return err
+	}
+
+	s.log.Info("Created token signing key",
+		zap.String("kid", key.ID.String()),
+		zap.String("algorithm", key.Algorithm),
+		zap.Time("activates_at", activatesAt),
+	)
+
+	return nil
+}
+
+// currentKeys returns the cached keys, reloading them when they are older than maxAge. A failed reload
+// keeps the previous keys so that a database hiccup does not stop token issuance.
+func (s *keyService) currentKeys(ctx context.Context, maxAge time.Duration) ([]*loadedKey, error) {
+	s.mu.RLock()
+	keys, loadedAt := s.keys, s.loadedAt
+	s.mu.RUnlock()
+
+	if keys != nil && time.Since(loadedAt) < maxAge {
+		return keys, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys != nil && time.Since(s.loadedAt) < maxAge {
+		return s.keys, nil
+	}
+
+	loaded, err := s.loadKeys(ctx)
+	if err != nil {
+		if s.keys == nil {
+			return nil, err
+		}
+		s.log.Warn("Failed to reload signing keys, using cached keys", zap.Error(err))
+		s.loadedAt = time.Now()
+		return s.keys, nil
+	}
+
+	s.keys, s.loadedAt = loaded, time.Now()
+	return loaded, nil
+}
+
+func (s *keyService) loadKeys(ctx context.Context) ([]*loadedKey, error) {
+	stored, err := s.keyRepo.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*loadedKey, 0, len(stored))
+	for _, key := range stored {
+		privateDER, err := s.openPrivateKey(key.ID, key.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt signing key %s: %w", key.ID, err)
+		}
+
+		private, err := x509.ParsePKCS8PrivateKey(privateDER)
+		if err != nil {
+			return nil, fmt.Errorf("parse signing key %s: %w", key.ID, err)
+		}
+
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s cannot sign", key.ID)
+		}
+
+		public, err := x509.ParsePKIXPublicKey(key.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key %s: %w", key.ID, err)
+		}
+
+		keys = append(keys, &loadedKey{
+			id:          key.ID,
+			algorithm:   key.Algorithm,
+			activatesAt: key.ActivatesAt,
+			private:     signer,
+			public:      public,
+		})
+	}
+
+	return keys, nil
+}
+
+// sealPrivateKey encrypts a DER private key as nonce || ciphertext, with the kid as additional data so a
+// sealed key cannot be moved to another row
+func (s *keyService) sealPrivateKey(id uuid.UUID, der []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(der)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, der, id[:]), nil
+}
+
+func (s *keyService) openPrivateKey(id uuid.UUID, sealed []byte) ([]byte, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, errors.New("sealed key is too short")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	return s.aead.Open(nil, nonce, ciphertext, id[:])
+}
+
+func findKey(keys []*loadedKey, id uuid.UUID) *loadedKey {
+	for _, key := range keys {
+		if key.id == id {
+			return key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"github.com/fairuzald/library-system/pkg/middleware"
+)
+
+// KeyService manages the key pairs access tokens are signed with. It signs and resolves keys for the
+// service's own JWTAuth and publishes the public halves for every other verifier.
+type KeyService interface {
+	middleware.TokenSigner
+	middleware.KeyResolver
+
+	JWKS(ctx context.Context) (*middleware.JWKS, error)
+	RotateKeys(ctx context.Context) error
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// mfaChallengeAudience marks MFA challenge tokens. They carry no user_id, so they are never accepted as
+	// access tokens.
+	mfaChallengeAudience = "mfa"
+	mfaChallengeTTL      = 5 * time.Minute
+
+	// totpSkew accepts codes from one step either side of the current one, for clock drift
+	totpSkew = 1
+
+	// maxMFAAttempts wrong codes in a row lock the second factor for mfaLockout. Six digits are only a
+	// million codes, so without a limit they could be guessed.
+	maxMFAAttempts = 5
+	mfaLockout     = 15 * time.Minute
+
+	recoveryCodeCount = 10
+	// recoveryCodeBytes gives 10 base32 characters, 50 bits
+	recoveryCodeBytes = 6
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAConfig sets how two-factor authentication is presented and who must use it
+type MFAConfig struct {
+	// Issuer is the account name prefix authenticator apps show
+	Issuer string
+	// RequiredRoles must set up two-factor authentication before they can log in
+	RequiredRoles []string
+}
+
+// mfaRequired reports whether the role must use two-factor authentication
+func (s *authService) mfaRequired(role string) bool {
+	return utils.SliceContains(s.mfa.RequiredRoles, role)
+}
+
+// mfaChallenge returns the challenge to answer before a login is completed, or nil when the user needs no
+// second factor
+func (s *authService) mfaChallenge(ctx context.Context, user *model.User) (*dao.TokenResponse, error) {
+	credential, err := s.mfaRepo.GetCredential(ctx, user.ID)
+	if err != nil && !strings.Contains(err.Error(), constants.ErrMFANotEnabled) {
+		s.log.Error("Failed to load MFA credential", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return nil, err
+	}
+
+	enabled := credential != nil && credential.Confirmed()
+	if !enabled && !s.mfaRequired(user.Role) {
+		return nil, nil
+	}
+
+	now := time.Now()
+	token, err := s.jwtAuth.Sign(ctx, middleware.Claims{
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+		},
+	})
+	if err != nil {
+		s.log.Error("Failed to sign MFA challenge", zap.Error(err))
+		return nil, err
+	}
+
+	return &dao.TokenResponse{
+		MFARequired:           true,
+		MFAToken:              token,
+		MFAEnrollmentRequired: !enabled,
+	}, nil
+}
+
+// challengeUser returns the user an MFA challenge was issued to. Challenges issued before the user's
+// tokens were revoked are rejected, and so are users whose account was blocked since.
+func (s *authService) challengeUser(ctx context.Context, mfaToken string) (*model.User, error) {
+	claims, err := s.jwtAuth.ValidateToken(mfaToken)
+	if err != nil || claims.UserID != "" || claims.ClientID != "" || !claims.VerifyAudience(mfaChallengeAudience, true) {
+		return nil, errors.New(constants.ErrInvalidMFAToken)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, errors.New(constants.ErrInvalidMFAToken)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || user.TokenVersion > claims.TokenVersion {
+		return nil, errors.New(constants.ErrInvalidMFAToken)
+	}
+
+	if err := accountStatusError(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CompleteMFALogin answers an MFA challenge and issues the login's tokens. A user who had to set up two-factor
+// authentication during login confirms it here, and gets their recovery codes with the tokens.
+func (s *authService) CompleteMFALogin(ctx context.Context, req *dto.MFALogin) (*dao.TokenResponse, error) {
+	user, err := s.challengeUser(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.mfaRepo.GetCredential(ctx, user.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrMFANotEnabled) {
+			return nil, errors.New(constants.ErrMFANotEnabled)
+		}
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if credential.Confirmed() {
+		if err := s.verifyMFACode(ctx, credential, req.Code, req.ClientInfo); err != nil {
+			return nil, err
+		}
+	} else {
+		if recoveryCodes, err = s.confirmCredential(ctx, credential, req.Code); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := s.completeLogin(ctx, user, req.DeviceName, req.ClientInfo)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+
+	return response, nil
+}
+
+// EnrollMFAWithToken starts enrollment for a user who was told at login that their role requires it
+func (s *authService) EnrollMFAWithToken(ctx context.Context, mfaToken string) (*dao.MFAEnrollmentResponse, error) {
+	user, err := s.challengeUser(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.enroll(ctx, user)
+}
+
+func (s *authService) MFAStatus(ctx context.Context, userID uuid.UUID) (*dao.MFAStatusResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dao.MFAStatusResponse{Required: s.mfaRequired(user.Role)}
+
+	credential, err := s.mfaRepo.GetCredential(ctx, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrMFANotEnabled) {
+			return response, nil
+		}
+		return nil, err
+	}
+
+	if response.Enabled = credential.Confirmed(); response.Enabled {
+		if response.RecoveryCodesRemaining, err = s.mfaRepo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// EnrollMFA starts setting up two-factor authentication. The credential stays pending, and login unchanged,
+// until ConfirmMFA is called with a code from it.
+func (s *authService) EnrollMFA(ctx context.Context, userID uuid.UUID) (*dao.MFAEnrollmentResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.enroll(ctx, user)
+}
+
+func (s *authService) enroll(ctx context.Context, user *model.User) (*dao.MFAEnrollmentResponse, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		s.log.Error("Failed to generate TOTP secret", zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SavePendingCredential(ctx, model.NewMFACredential(user.ID, secret)); err != nil {
+		if err.Error() != constants.ErrMFAAlreadyEnabled {
+			s.log.Error("Failed to save MFA credential", zap.Error(err), zap.String("user_id", user.ID.String()))
+		}
+		return nil, err
+	}
+
+	return &dao.MFAEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.mfa.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA puts a pending credential in use once the user shows a code from it, and returns their
+// recovery codes
+func (s *authService) ConfirmMFA(ctx context.Context, userID uuid.UUID, code string) (*dao.RecoveryCodesResponse, error) {
+	credential, err := s.mfaRepo.GetCredential(ctx, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrMFANotEnabled) {
+			return nil, errors.New(constants.ErrMFANotEnabled)
+		}
+		return nil, err
+	}
+
+	recoveryCodes, err := s.confirmCredential(ctx, credential, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dao.RecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// DisableMFA turns two-factor authentication off after checking a current code. Users whose role
+// requires it cannot turn it off.
+func (s *authService) DisableMFA(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if s.mfaRequired(user.Role) {
+		return errors.New(constants.ErrMFARequired)
+	}
+
+	credential, err := s.enabledCredential(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyMFACode(ctx, credential, code, dto.ClientInfo{}); err != nil {
+		return err
+	}
+
+	if err := s.mfaRepo.DeleteCredential(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordSecurityEvent(ctx, userID, model.SecurityEventMFADisabled, dto.ClientInfo{}, "two-factor authentication was turned off by the user")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a current TOTP code
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) (*dao.RecoveryCodesResponse, error) {
+	credential, err := s.enabledCredential(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A recovery code cannot be used to mint new ones
+	if len(strings.TrimSpace(code)) != utils.TOTPDigits {
+		return nil, errors.New(constants.ErrInvalidMFACode)
+	}
+
+	if err := s.verifyMFACode(ctx, credential, code, dto.ClientInfo{}); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		s.log.Error("Failed to generate recovery codes", zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		s.log.Error("Failed to store recovery codes", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, err
+	}
+
+	return &dao.RecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// ResetMFA removes a user's second factor, for staff who lost their authenticator and recovery codes. The
+// user's sessions are ended, and a role that requires two-factor authentication sets it up again at login.
+func (s *authService) ResetMFA(ctx context.Context, userID uuid.UUID) error {
+	if err := s.mfaRepo.DeleteCredential(ctx, userID); err != nil {
+		return err
+	}
+
+	if _, err := revokeUserTokens(ctx, s.userRepo, s.authRepo, userID, true); err != nil {
+		s.log.Error("Failed to revoke tokens after MFA reset", zap.Error(err), zap.String("user_id", userID.String()))
+		return err
+	}
+
+	s.recordSecurityEvent(ctx, userID, model.SecurityEventMFADisabled, dto.ClientInfo{}, "two-factor authentication was reset by an administrator")
+	return nil
+}
+
+func (s *authService) enabledCredential(ctx context.Context, userID uuid.UUID) (*model.MFACredential, error) {
+	credential, err := s.mfaRepo.GetCredential(ctx, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrMFANotEnabled) {
+			return nil, errors.New(constants.ErrMFANotEnabled)
+		}
+		return nil, err
+	}
+
+	if !credential.Confirmed() {
+		return nil, errors.New(constants.ErrMFANotEnabled)
+	}
+
+	return credential, nil
+}
+
+// confirmCredential checks a code from a pending credential, puts the credential in use and returns new
+// recovery codes
+func (s *authService) confirmCredential(ctx context.Context, credential *model.MFACredential, code string) ([]string, error) {
+	if credential.Confirmed() {
+		return nil, errors.New(constants.ErrMFAAlreadyEnabled)
+	}
+
+	if credential.Locked() {
+		return nil, errors.New(constants.ErrMFALocked)
+	}
+
+	step, ok := utils.ValidateTOTP(credential.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, s.recordFailedMFAAttempt(ctx, credential.UserID, dto.ClientInfo{})
+	}
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		s.log.Error("Failed to generate recovery codes", zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ConfirmCredential(ctx, credential.UserID, step, hashes); err != nil {
+		if err.Error() != constants.ErrMFAAlreadyEnabled {
+			s.log.Error("Failed to confirm MFA credential", zap.Error(err), zap.String("user_id", credential.UserID.String()))
+		}
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// verifyMFACode accepts a TOTP code, which cannot be used twice, or an unused recovery code
+func (s *authService) verifyMFACode(ctx context.Context, credential *model.MFACredential, code string, client dto.ClientInfo) error {
+	if credential.Locked() {
+		return errors.New(constants.ErrMFALocked)
+	}
+
+	if step, ok := utils.ValidateTOTP(credential.Secret, code, time.Now(), totpSkew); ok {
+		err := s.mfaRepo.RecordCodeUse(ctx, credential.UserID, step)
+		if err == nil {
+			return nil
+		}
+		if err.Error() != constants.ErrInvalidMFACode {
+			return err
+		}
+		return s.recordFailedMFAAttempt(ctx, credential.UserID, client)
+	}
+
+	if normalized := normalizeRecoveryCode(code); len(normalized) == recoveryCodeEncoding.EncodedLen(recoveryCodeBytes) {
+		err := s.mfaRepo.UseRecoveryCode(ctx, credential.UserID, utils.HashToken(normalized))
+		if err == nil {
+			s.recordSecurityEvent(ctx, credential.UserID, model.SecurityEventMFARecoveryUsed, client, "a recovery code was used in place of a TOTP code")
+			return nil
+		}
+		if err.Error() != constants.ErrInvalidMFACode {
+			return err
+		}
+	}
+
+	return s.recordFailedMFAAttempt(ctx, credential.UserID, client)
+}
+
+// recordFailedMFAAttempt counts a wrong code and returns the error to report for it
+func (s *authService) recordFailedMFAAttempt(ctx context.Context, userID uuid.UUID, client dto.ClientInfo) error {
+	credential, err := s.mfaRepo.RecordFailedAttempt(ctx, userID, maxMFAAttempts, mfaLockout)
+	if err != nil {
+		s.log.Error("Failed to record MFA attempt", zap.Error(err), zap.String("user_id", userID.String()))
+		return err
+	}
+
+	if credential.Locked() {
+		s.log.Warn("MFA locked after repeated invalid codes", zap.String("user_id", userID.String()))
+		s.recordSecurityEvent(ctx, userID, model.SecurityEventMFALocked, client, "too many invalid verification codes; two-factor authentication was locked")
+		return errors.New(constants.ErrMFALocked)
+	}
+
+	return errors.New(constants.ErrInvalidMFACode)
+}
+
+func (s *authService) recordSecurityEvent(ctx context.Context, userID uuid.UUID, eventType string, client dto.ClientInfo, details string) {
+	event := model.NewSecurityEvent(userID, eventType)
+	event.IPAddress = client.IPAddress
+	event.UserAgent = truncate(strings.TrimSpace(client.UserAgent), maxUserAgentLength)
+	event.Details = details
+
+	if err := s.authRepo.RecordSecurityEvent(ctx, event); err != nil {
+		s.log.Error("Failed to record security event", zap.Error(err), zap.String("user_id", userID.String()))
+	}
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted as xxxxx-xxxxx, with the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, utils.HashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes typed with or without the dash, in either case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
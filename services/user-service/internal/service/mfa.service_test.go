@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/fairuzald/library-system/services/user-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeMFARepo keeps one credential and its recovery codes in memory, with the repository's rules for
+// spending codes. Methods verifyMFACode does not call are left to the nil embedded interface.
+type fakeMFARepo struct {
+	repository.MFARepository
+	credential    *model.MFACredential
+	recoveryCodes map[string]bool
+	failures      int
+}
+
+func (r *fakeMFARepo) RecordCodeUse(_ context.Context, _ uuid.UUID, step int64) error {
+	if step <= r.credential.LastUsedStep {
+		return errors.New(constants.ErrInvalidMFACode)
+	}
+	r.credential.LastUsedStep = step
+	return nil
+}
+
+func (r *fakeMFARepo) RecordFailedAttempt(context.Context, uuid.UUID, int, time.Duration) (*model.MFACredential, error) {
+	r.failures++
+	return r.credential, nil
+}
+
+func (r *fakeMFARepo) UseRecoveryCode(_ context.Context, _ uuid.UUID, codeHash string) error {
+	if !r.recoveryCodes[codeHash] {
+		return errors.New(constants.ErrInvalidMFACode)
+	}
+	delete(r.recoveryCodes, codeHash)
+	return nil
+}
+
+type fakeSecurityEvents struct {
+	repository.AuthRepository
+	events []string
+}
+
+func (r *fakeSecurityEvents) RecordSecurityEvent(_ context.Context, event *model.SecurityEvent) error {
+	r.events = append(r.events, event.Type)
+	return nil
+}
+
+func newMFATestService(t *testing.T) (*authService, *fakeMFARepo, *fakeSecurityEvents, []string) {
+	t.Helper()
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("generateRecoveryCodes: %v", err)
+	}
+
+	mfaRepo := &fakeMFARepo{
+		credential:    model.NewMFACredential(uuid.New(), secret),
+		recoveryCodes: make(map[string]bool, len(hashes)),
+	}
+	for _, hash := range hashes {
+		mfaRepo.recoveryCodes[hash] = true
+	}
+
+	events := &fakeSecurityEvents{}
+	s := &authService{
+		mfaRepo:  mfaRepo,
+		authRepo: events,
+		log:      logger.New(logger.Config{Output: io.Discard}),
+	}
+	return s, mfaRepo, events, codes
+}
+
+func totpCodeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(at))
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	return code
+}
+
+func TestVerifyMFACodeRejectsReplayedTOTP(t *testing.T) {
+	s, mfaRepo, _, _ := newMFATestService(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	code := totpCodeAt(t, mfaRepo.credential.Secret, now)
+	if err := s.verifyMFACode(ctx, mfaRepo.credential, code, dto.ClientInfo{}); err != nil {
+		t.Fatalf("verifyMFACode(current code) = %v", err)
+	}
+
+	if err := s.verifyMFACode(ctx, mfaRepo.credential, code, dto.ClientInfo{}); err == nil || err.Error() != constants.ErrInvalidMFACode {
+		t.Errorf("verifyMFACode(same code again) = %v, want %s", err, constants.ErrInvalidMFACode)
+	}
+
+	// The previous step is inside the skew window, but older than the code just accepted
+	previous := totpCodeAt(t, mfaRepo.credential.Secret, now.Add(-utils.TOTPPeriod))
+	if previous != code {
+		if err := s.verifyMFACode(ctx, mfaRepo.credential, previous, dto.ClientInfo{}); err == nil {
+			t.Error("verifyMFACode accepted a code older than the last one used")
+		}
+	}
+
+	if mfaRepo.failures == 0 {
+		t.Error("replayed codes were not counted as failed attempts")
+	}
+}
+
+func TestVerifyMFACodeAcceptsRecoveryCodeOnce(t *testing.T) {
+	variants := map[string]func(string) string{
+		"as issued":         func(c string) string { return c },
+		"uppercase":         strings.ToUpper,
+		"without the dash":  func(c string) string { return strings.ReplaceAll(c, "-", "") },
+		"spaced and padded": func(c string) string { return "  " + strings.ReplaceAll(c, "-", " ") + "\n" },
+	}
+
+	for name, typed := range variants {
+		t.Run(name, func(t *testing.T) {
+			s, mfaRepo, events, codes := newMFATestService(t)
+			ctx := context.Background()
+
+			if err := s.verifyMFACode(ctx, mfaRepo.credential, typed(codes[0]), dto.ClientInfo{}); err != nil {
+				t.Fatalf("verifyMFACode(%q) = %v", typed(codes[0]), err)
+			}
+			if len(events.events) != 1 || events.events[0] != model.SecurityEventMFARecoveryUsed {
+				t.Errorf("security events = %v, want one %s", events.events, model.SecurityEventMFARecoveryUsed)
+			}
+
+			if err := s.verifyMFACode(ctx, mfaRepo.credential, codes[0], dto.ClientInfo{}); err == nil || err.Error() != constants.ErrInvalidMFACode {
+				t.Errorf("verifyMFACode(spent recovery code) = %v, want %s", err, constants.ErrInvalidMFACode)
+			}
+		})
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{code: "abcde-fghij", want: "abcdefghij"},
+		{code: "ABCDE-FGHIJ", want: "abcdefghij"},
+		{code: " abcde fghij\t", want: "abcdefghij"},
+		{code: "abcdefghij", want: "abcdefghij"},
+		{code: "123456", want: "123456"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
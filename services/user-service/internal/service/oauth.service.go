@@ -0,0 +1,809 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/middleware"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/fairuzald/library-system/services/user-service/internal/repository"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// authorizationCodeTTL is how long a client has to exchange a code, as recommended by RFC 6749
+	authorizationCodeTTL = 10 * time.Minute
+	// authorizationCodeBytes and clientSecretBytes are the randomness in codes and secrets, 256 bits
+	authorizationCodeBytes = 32
+	clientSecretBytes      = 32
+
+	responseTypeCode           = "code"
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeRefreshToken      = "refresh_token"
+	codeChallengeMethodS256    = "S256"
+)
+
+// OAuthConfig describes how the provider presents itself to clients
+type OAuthConfig struct {
+	// Issuer is the public base URL of the provider, normally the API gateway
+	Issuer string
+	// AuthorizationURL is the web page that signs the user in and asks for consent; it defaults to
+	// Issuer + "/authorize"
+	AuthorizationURL string
+	// SigningAlgorithm is the algorithm ID tokens are signed with
+	SigningAlgorithm   string
+	AccessTokenExpiry  time.Duration
+	RefreshTokenExpiry time.Duration
+}
+
+type oauthService struct {
+	oauthRepo repository.OAuthRepository
+	userRepo  repository.UserRepository
+	authRepo  repository.AuthRepository
+	jwtAuth   *middleware.JWTAuth
+	cfg       OAuthConfig
+	log       *logger.Logger
+}
+
+func NewOAuthService(
+	oauthRepo repository.OAuthRepository,
+	userRepo repository.UserRepository,
+	authRepo repository.AuthRepository,
+	jwtAuth *middleware.JWTAuth,
+	cfg OAuthConfig,
+	log *logger.Logger,
+) OAuthService {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if cfg.AuthorizationURL == "" {
+		cfg.AuthorizationURL = cfg.Issuer + "/authorize"
+	}
+
+	return &oauthService{
+		oauthRepo: oauthRepo,
+		userRepo:  userRepo,
+		authRepo:  authRepo,
+		jwtAuth:   jwtAuth,
+		cfg:       cfg,
+		log:       log,
+	}
+}
+
+// RegisterClient registers a partner application. Confidential clients get a secret, which is returned
+// once and only stored hashed.
+func (s *oauthService) RegisterClient(ctx context.Context, createdBy uuid.UUID, req *dto.OAuthClientCreate) (*dao.OAuthClientResponse, error) {
+	for _, redirectURI := range req.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return nil, errors.New(constants.ErrInvalidRedirectURI)
+		}
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = model.SupportedScopes
+	}
+	if !model.ScopesCover(scopes, []string{model.ScopeOpenID}) {
+		scopes = append([]string{model.ScopeOpenID}, scopes...)
+	}
+
+	client := model.NewOAuthClient(strings.TrimSpace(req.Name), req.RedirectURIs, scopes, req.Public, createdBy)
+
+	var secret string
+	if !req.Public {
+		var err error
+		secret, err = utils.GenerateSecureToken(clientSecretBytes)
+		if err != nil {
+			s.log.Error("Failed to generate client secret", zap.Error(err))
+			return nil, err
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if err := s.oauthRepo.CreateClient(ctx, client); err != nil {
+		s.log.Error("Failed to create OAuth client", zap.Error(err))
+		return nil, err
+	}
+
+	response := dao.NewOAuthClientResponse(client)
+	response.ClientSecret = secret
+	return response, nil
+}
+
+func (s *oauthService) ListClients(ctx context.Context) (*dao.OAuthClientListResponse, error) {
+	clients, err := s.oauthRepo.ListClients(ctx)
+	if err != nil {
+		s.log.Error("Failed to list OAuth clients", zap.Error(err))
+		return nil, err
+	}
+
+	response := &dao.OAuthClientListResponse{Clients: make([]dao.OAuthClientResponse, 0, len(clients))}
+	for _, client := range clients {
+		response.Clients = append(response.Clients, *dao.NewOAuthClientResponse(client))
+	}
+
+	return response, nil
+}
+
+func (s *oauthService) DeleteClient(ctx context.Context, id uuid.UUID) error {
+	return s.oauthRepo.DeleteClient(ctx, id)
+}
+
+// CheckAuthorization validates an authorization request for the consent page and tells it whether the
+// user has already agreed to every requested scope
+func (s *oauthService) CheckAuthorization(ctx context.Context, userID uuid.UUID, req *dto.OAuthAuthorize) (*dao.OAuthAuthorizationResponse, error) {
+	client, scopes, err := s.resolveAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	consentRequired := true
+	if consent, err := s.oauthRepo.GetConsent(ctx, userID, client.ID); err == nil {
+		consentRequired = !model.ScopesCover(model.ParseScope(consent.Scope), scopes)
+	}
+
+	return &dao.OAuthAuthorizationResponse{
+		ClientID:        client.ID,
+		ClientName:      client.Name,
+		Scopes:          scopes,
+		ConsentRequired: consentRequired,
+	}, nil
+}
+
+// Authorize records the user's decision and returns the client redirect carrying either an authorization
+// code or an access_denied error
+func (s *oauthService) Authorize(ctx context.Context, userID uuid.UUID, req *dto.OAuthAuthorize) (*dao.OAuthRedirectResponse, error) {
+	client, scopes, err := s.resolveAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Approve {
+		return s.redirect(req.RedirectURI, req.State, map[string]string{
+			"error":             dao.OAuthErrAccessDenied,
+			"error_description": "the user denied the request",
+		})
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := scopes
+	if consent, err := s.oauthRepo.GetConsent(ctx, userID, client.ID); err == nil {
+		granted = mergeScopes(model.ParseScope(consent.Scope), scopes)
+	}
+
+	if err := s.oauthRepo.SaveConsent(ctx, &model.OAuthConsent{
+		UserID:   userID,
+		ClientID: client.ID,
+		Scope:    strings.Join(granted, " "),
+	}); err != nil {
+		s.log.Error("Failed to save consent", zap.Error(err), zap.String("client_id", client.ID.String()))
+		return nil, err
+	}
+
+	code, err := utils.GenerateSecureToken(authorizationCodeBytes)
+	if err != nil {
+		s.log.Error("Failed to generate authorization code", zap.Error(err))
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.oauthRepo.CreateAuthorizationCode(ctx, &model.OAuthAuthorizationCode{
+		CodeHash:      utils.HashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime(user),
+		ExpiresAt:     now.Add(authorizationCodeTTL),
+	}); err != nil {
+		s.log.Error("Failed to store authorization code", zap.Error(err), zap.String("client_id", client.ID.String()))
+		return nil, err
+	}
+
+	return s.redirect(req.RedirectURI, req.State, map[string]string{"code": code})
+}
+
+// resolveAuthorization checks an authorization request against the client's registration and returns the
+// client with the requested scopes
+func (s *oauthService) resolveAuthorization(ctx context.Context, req *dto.OAuthAuthorize) (*model.OAuthClient, []string, error) {
+	if req.ResponseType != responseTypeCode {
+		return nil, nil, dao.NewOAuthError(dao.OAuthErrUnsupportedResponse, "only the code response type is supported")
+	}
+
+	client, err := s.findClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, nil, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "unknown client_id")
+	}
+
+	if !client.HasRedirectURI(req.RedirectURI) {
+		return nil, nil, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	scopes := model.ParseScope(req.Scope)
+	if !model.ScopesCover(scopes, []string{model.ScopeOpenID}) {
+		return nil, nil, dao.NewOAuthError(dao.OAuthErrInvalidScope, "the openid scope is required")
+	}
+	if !model.ScopesCover(model.SupportedScopes, scopes) || !client.AllowsScopes(scopes) {
+		return nil, nil, dao.NewOAuthError(dao.OAuthErrInvalidScope, "the client may not request these scopes")
+	}
+
+	if req.CodeChallenge == "" && client.Public {
+		return nil, nil, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "public clients must use PKCE")
+	}
+	if req.CodeChallenge != "" && req.CodeChallengeMethod != codeChallengeMethodS256 {
+		return nil, nil, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "only the S256 code_challenge_method is supported")
+	}
+
+	return client, scopes, nil
+}
+
+// redirect builds the client redirect, adding the issuer so clients can tell providers apart (RFC 9207)
+func (s *oauthService) redirect(redirectURI, state string, params map[string]string) (*dao.OAuthRedirectResponse, error) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "invalid redirect_uri")
+	}
+
+	query := target.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	query.Set("iss", s.cfg.Issuer)
+	target.RawQuery = query.Encode()
+
+	return &dao.OAuthRedirectResponse{RedirectTo: target.String()}, nil
+}
+
+// Token serves the token endpoint for the authorization code and refresh token grants
+func (s *oauthService) Token(ctx context.Context, req *dto.OAuthTokenRequest) (*dao.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req.OAuthClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case grantTypeAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, client, req)
+	case grantTypeRefreshToken:
+		return s.exchangeRefreshToken(ctx, client, req)
+	default:
+		return nil, dao.NewOAuthError(dao.OAuthErrUnsupportedGrantType, "")
+	}
+}
+
+// exchangeAuthorizationCode redeems a code. A code presented twice was intercepted, so the tokens already
+// issued from the grant are revoked as RFC 6749 recommends.
+func (s *oauthService) exchangeAuthorizationCode(ctx context.Context, client *model.OAuthClient, req *dto.OAuthTokenRequest) (*dao.OAuthTokenResponse, error) {
+	if req.Code == "" {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "code is required")
+	}
+
+	code, err := s.oauthRepo.ConsumeAuthorizationCode(ctx, utils.HashToken(req.Code), client.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrInvalidToken) {
+			return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "invalid authorization code")
+		}
+		s.log.Error("Failed to redeem authorization code", zap.Error(err))
+		return nil, dao.NewOAuthError(dao.OAuthErrServerError, "")
+	}
+
+	if code.UsedAt != nil {
+		s.log.Warn("Authorization code replayed, revoking grant",
+			zap.String("client_id", client.ID.String()),
+			zap.String("user_id", code.UserID.String()),
+		)
+		if err := s.oauthRepo.RevokeRefreshTokens(ctx, code.UserID, client.ID); err != nil {
+			s.log.Error("Failed to revoke refresh tokens", zap.Error(err))
+		}
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "authorization code has already been used")
+	}
+
+	if code.ExpiresAt.Before(time.Now()) {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "authorization code has expired")
+	}
+
+	if req.RedirectURI != code.RedirectURI {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+
+	if !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "code_verifier does not match the code_challenge")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, code.UserID)
+	if err != nil {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "the user no longer exists")
+	}
+
+	response, err := s.issueTokens(ctx, client, user, code.Scope, code.Nonce, code.AuthTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if model.ScopesCover(model.ParseScope(code.Scope), []string{model.ScopeOfflineAccess}) {
+		refreshToken, record, err := s.newRefreshToken(client, user, code.Scope, code.AuthTime)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.oauthRepo.CreateRefreshToken(ctx, record); err != nil {
+			s.log.Error("Failed to store refresh token", zap.Error(err))
+			return nil, dao.NewOAuthError(dao.OAuthErrServerError, "")
+		}
+		response.RefreshToken = refreshToken
+	}
+
+	return response, nil
+}
+
+// exchangeRefreshToken rotates a refresh token. Presenting a revoked token means it leaked, so every token
+// the client holds for the user is revoked.
+func (s *oauthService) exchangeRefreshToken(ctx context.Context, client *model.OAuthClient, req *dto.OAuthTokenRequest) (*dao.OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidRequest, "refresh_token is required")
+	}
+
+	record, err := s.oauthRepo.GetRefreshToken(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrInvalidToken) {
+			return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "invalid refresh token")
+		}
+		s.log.Error("Failed to look up refresh token", zap.Error(err))
+		return nil, dao.NewOAuthError(dao.OAuthErrServerError, "")
+	}
+
+	if record.ClientID != client.ID {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "invalid refresh token")
+	}
+
+	if record.RevokedAt != nil {
+		return nil, s.handleRefreshTokenReplay(ctx, record)
+	}
+
+	if !record.Active() {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "refresh token has expired")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, record.UserID)
+	if err != nil || user.TokenVersion > record.TokenVersion {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidGrant, "the grant has been revoked")
+	}
+
+	// A client may ask for fewer scopes than it was granted; the new refresh token keeps the full grant
+	scope := record.Scope
+	if req.Scope != "" {
+		if !model.ScopesCover(model.ParseScope(record.Scope), model.ParseScope(req.Scope)) {
+			return nil, dao.NewOAuthError(dao.OAuthErrInvalidScope, "scope exceeds the original grant")
+		}
+		scope = req.Scope
+	}
+
+	refreshToken, next, err := s.newRefreshToken(client, user, record.Scope, record.AuthTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.oauthRepo.RotateRefreshToken(ctx, record.ID, next); err != nil {
+		if err.Error() == constants.ErrRefreshTokenReused {
+			return nil, s.handleRefreshTokenReplay(ctx, record)
+		}
+		s.log.Error("Failed to rotate refresh token", zap.Error(err))
+		return nil, dao.NewOAuthError(dao.OAuthErrServerError, "")
+	}
+
+	response, err := s.issueTokens(ctx, client, user, scope, "", record.AuthTime)
+	if err != nil {
+		return nil, err
+	}
+	response.RefreshToken = refreshToken
+
+	return response, nil
+}
+
+func (s *oauthService) handleRefreshTokenReplay(ctx context.Context, record *model.OAuthRefreshToken) error {
+	s.log.Warn("Revoked OAuth refresh token presented, revoking grant",
+		zap.String("client_id", record.ClientID.String()),
+		zap.String("user_id", record.UserID.String()),
+	)
+
+	if err := s.oauthRepo.RevokeRefreshTokens(ctx, record.UserID, record.ClientID); err != nil {
+		s.log.Error("Failed to revoke refresh tokens", zap.Error(err))
+	}
+
+	return dao.NewOAuthError(dao.OAuthErrInvalidGrant, "refresh token has been revoked")
+}
+
+// issueTokens signs an access token limited to the scope and, for openid requests, an ID token
+func (s *oauthService) issueTokens(ctx context.Context, client *model.OAuthClient, user *model.User, scope, nonce string, authTime time.Time) (*dao.OAuthTokenResponse, error) {
+	now := time.Now()
+	scopes := model.ParseScope(scope)
+
+	accessClaims := middleware.Claims{
+		UserID:       user.ID.String(),
+		Username:     user.Username,
+		ClientID:     client.ID.String(),
+		Scope:        scope,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.Issuer,
+			Subject:   user.ID.String(),
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTokenExpiry)),
+		},
+	}
+	if model.ScopesCover(scopes, []string{model.ScopeEmail}) {
+		accessClaims.Email = user.Email
+	}
+
+	accessToken, err := s.jwtAuth.Sign(ctx, accessClaims)
+	if err != nil {
+		s.log.Error("Failed to sign OAuth access token", zap.Error(err))
+		return nil, dao.NewOAuthError(dao.OAuthErrServerError, "")
+	}
+
+	response := &dao.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   constants.TokenTypBearer,
+		ExpiresIn:   int64(s.cfg.AccessTokenExpiry.Seconds()),
+		Scope:       scope,
+	}
+
+	if model.ScopesCover(scopes, []string{model.ScopeOpenID}) {
+		idClaims := jwt.MapClaims{
+			"iss":       s.cfg.Issuer,
+			"aud":       client.ID.String(),
+			"azp":       client.ID.String(),
+			"iat":       now.Unix(),
+			"exp":       now.Add(s.cfg.AccessTokenExpiry).Unix(),
+			"auth_time": authTime.Unix(),
+		}
+		if nonce != "" {
+			idClaims["nonce"] = nonce
+		}
+		for key, value := range userClaims(user, scopes) {
+			idClaims[key] = value
+		}
+
+		response.IDToken, err = s.jwtAuth.Sign(ctx, idClaims)
+		if err != nil {
+			s.log.Error("Failed to sign ID token", zap.Error(err))
+			return nil, dao.NewOAuthError(dao.OAuthErrServerError, "")
+		}
+	}
+
+	return response, nil
+}
+
+func (s *oauthService) newRefreshToken(client *model.OAuthClient, user *model.User, scope string, authTime time.Time) (string, *model.OAuthRefreshToken, error) {
+	token, err := utils.GenerateSecureToken(refreshTokenBytes)
+	if err != nil {
+		s.log.Error("Failed to generate refresh token", zap.Error(err))
+		return "", nil, dao.NewOAuthError(dao.OAuthErrServerError, "")
+	}
+
+	return token, &model.OAuthRefreshToken{
+		TokenHash:    utils.HashToken(token),
+		ClientID:     client.ID,
+		UserID:       user.ID,
+		Scope:        scope,
+		AuthTime:     authTime,
+		TokenVersion: user.TokenVersion,
+		ExpiresAt:    time.Now().Add(s.cfg.RefreshTokenExpiry),
+	}, nil
+}
+
+// UserInfo returns the claims about the token's user that its scopes allow
+func (s *oauthService) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	claims, user, err := s.clientAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidToken, "invalid or expired access token")
+	}
+
+	scopes := model.ParseScope(claims.Scope)
+	if !model.ScopesCover(scopes, []string{model.ScopeOpenID}) {
+		return nil, dao.NewOAuthError(dao.OAuthErrInsufficientScope, "the openid scope is required")
+	}
+
+	return userClaims(user, scopes), nil
+}
+
+// Introspect reports on a token the calling client holds. Tokens issued to other clients are reported as
+// inactive, so clients cannot probe each other's tokens.
+func (s *oauthService) Introspect(ctx context.Context, credentials dto.OAuthClientCredentials, token string) (*dao.OAuthIntrospectionResponse, error) {
+	client, err := s.authenticateClient(ctx, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	inactive := &dao.OAuthIntrospectionResponse{Active: false}
+
+	if record, err := s.oauthRepo.GetRefreshToken(ctx, utils.HashToken(token)); err == nil {
+		if record.ClientID != client.ID || !record.Active() {
+			return inactive, nil
+		}
+
+		user, err := s.userRepo.GetByID(ctx, record.UserID)
+		if err != nil || user.TokenVersion > record.TokenVersion {
+			return inactive, nil
+		}
+
+		return &dao.OAuthIntrospectionResponse{
+			Active:   true,
+			Scope:    record.Scope,
+			ClientID: client.ID.String(),
+			Username: user.Username,
+			Exp:      record.ExpiresAt.Unix(),
+			Iat:      record.CreatedAt.Unix(),
+			Sub:      user.ID.String(),
+			Iss:      s.cfg.Issuer,
+		}, nil
+	}
+
+	claims, user, err := s.clientAccessToken(ctx, token)
+	if err != nil || claims.ClientID != client.ID.String() {
+		return inactive, nil
+	}
+
+	return &dao.OAuthIntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Username:  user.Username,
+		TokenType: constants.TokenTypBearer,
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Sub:       user.ID.String(),
+		Iss:       s.cfg.Issuer,
+	}, nil
+}
+
+// Revoke revokes a refresh token or blacklists an access token held by the calling client. As RFC 7009
+// requires, unknown and foreign tokens are silently ignored.
+func (s *oauthService) Revoke(ctx context.Context, credentials dto.OAuthClientCredentials, token string) error {
+	client, err := s.authenticateClient(ctx, credentials)
+	if err != nil {
+		return err
+	}
+
+	if record, err := s.oauthRepo.GetRefreshToken(ctx, utils.HashToken(token)); err == nil {
+		if record.ClientID != client.ID {
+			return nil
+		}
+		if err := s.oauthRepo.RevokeRefreshToken(ctx, record.ID); err != nil {
+			s.log.Error("Failed to revoke refresh token", zap.Error(err))
+			return dao.NewOAuthError(dao.OAuthErrServerError, "")
+		}
+		return nil
+	}
+
+	claims, err := s.jwtAuth.ValidateToken(token)
+	if err != nil || claims.ClientID != client.ID.String() {
+		return nil
+	}
+
+	if remaining := time.Until(claims.ExpiresAt.Time); remaining > 0 {
+		if err := s.authRepo.StoreTokenInBlacklist(ctx, token, remaining); err != nil {
+			s.log.Error("Failed to blacklist access token", zap.Error(err))
+			return dao.NewOAuthError(dao.OAuthErrServerError, "")
+		}
+	}
+
+	return nil
+}
+
+// clientAccessToken validates an access token issued to an OAuth client and loads its user. Tokens that
+// were revoked, blacklisted or outlived a token version bump are rejected.
+func (s *oauthService) clientAccessToken(ctx context.Context, token string) (*middleware.Claims, *model.User, error) {
+	claims, err := s.jwtAuth.ValidateToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if claims.ClientID == "" {
+		return nil, nil, errors.New(constants.ErrInvalidToken)
+	}
+
+	blacklisted, err := s.authRepo.IsTokenBlacklisted(ctx, token)
+	if err != nil {
+		s.log.Warn("Failed to check token blacklist", zap.Error(err))
+	}
+	if blacklisted {
+		return nil, nil, errors.New(constants.ErrTokenBlacklisted)
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, nil, errors.New(constants.ErrInvalidToken)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.TokenVersion > claims.TokenVersion {
+		return nil, nil, errors.New(constants.ErrTokenRevoked)
+	}
+
+	return claims, user, nil
+}
+
+// authenticateClient checks the caller's credentials. Confidential clients must present their secret;
+// public clients only identify themselves.
+func (s *oauthService) authenticateClient(ctx context.Context, credentials dto.OAuthClientCredentials) (*model.OAuthClient, error) {
+	client, err := s.findClient(ctx, credentials.ClientID)
+	if err != nil {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidClient, "client authentication failed")
+	}
+
+	if client.Public {
+		if credentials.ClientSecret != "" {
+			return nil, dao.NewOAuthError(dao.OAuthErrInvalidClient, "public clients have no secret")
+		}
+		return client, nil
+	}
+
+	presented := utils.HashToken(credentials.ClientSecret)
+	if credentials.ClientSecret == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(client.SecretHash)) != 1 {
+		return nil, dao.NewOAuthError(dao.OAuthErrInvalidClient, "client authentication failed")
+	}
+
+	return client, nil
+}
+
+func (s *oauthService) findClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, errors.New(constants.ErrOAuthClientNotFound)
+	}
+	return s.oauthRepo.GetClient(ctx, id)
+}
+
+func (s *oauthService) ListConsents(ctx context.Context, userID uuid.UUID) (*dao.OAuthConsentListResponse, error) {
+	consents, err := s.oauthRepo.ListConsents(ctx, userID)
+	if err != nil {
+		s.log.Error("Failed to list consents", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, err
+	}
+
+	response := &dao.OAuthConsentListResponse{Consents: make([]dao.OAuthConsentResponse, 0, len(consents))}
+	for _, consent := range consents {
+		item := dao.OAuthConsentResponse{
+			ClientID:  consent.ClientID,
+			Scopes:    model.ParseScope(consent.Scope),
+			GrantedAt: consent.UpdatedAt,
+		}
+		if consent.Client != nil {
+			item.ClientName = consent.Client.Name
+		}
+		response.Consents = append(response.Consents, item)
+	}
+
+	return response, nil
+}
+
+// RevokeConsent withdraws the user's consent for a client and ends the client's offline access
+func (s *oauthService) RevokeConsent(ctx context.Context, userID, clientID uuid.UUID) error {
+	return s.oauthRepo.DeleteConsent(ctx, userID, clientID)
+}
+
+func (s *oauthService) Discovery() *dao.OIDCDiscovery {
+	return &dao.OIDCDiscovery{
+		Issuer:                            s.cfg.Issuer,
+		AuthorizationEndpoint:             s.cfg.AuthorizationURL,
+		TokenEndpoint:                     s.cfg.Issuer + "/oauth/token",
+		UserinfoEndpoint:                  s.cfg.Issuer + "/oauth/userinfo",
+		JWKSURI:                           s.cfg.Issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:                s.cfg.Issuer + "/oauth/revoke",
+		IntrospectionEndpoint:             s.cfg.Issuer + "/oauth/introspect",
+		ResponseTypesSupported:            []string{responseTypeCode},
+		GrantTypesSupported:               []string{grantTypeAuthorizationCode, grantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.cfg.SigningAlgorithm},
+		ScopesSupported:                   model.SupportedScopes,
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "given_name", "family_name", "preferred_username", "updated_at", "email", "email_verified"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		AuthorizationResponseISSSupported: true,
+	}
+}
+
+// userClaims returns the standard claims about the user that the scopes release
+func userClaims(user *model.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": user.ID.String(),
+	}
+
+	if model.ScopesCover(scopes, []string{model.ScopeProfile}) {
+		claims["name"] = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		claims["given_name"] = user.FirstName
+		claims["family_name"] = user.LastName
+		claims["preferred_username"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+
+	if model.ScopesCover(scopes, []string{model.ScopeEmail}) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified()
+	}
+
+	return claims
+}
+
+// validRedirectURI accepts absolute https URLs without a fragment, and plain http only for loopback hosts
+// where a native app listens locally, so codes are never sent over the network in the clear
+func validRedirectURI(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" || parsed.Fragment != "" {
+		return false
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := parsed.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	default:
+		return false
+	}
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against the S256 challenge from the authorization request.
+// A verifier is only accepted when a challenge was made, and required when one was.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if challenge == "" {
+		return verifier == ""
+	}
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// authTime is when the user last signed in with their password, which the consent step relies on
+func authTime(user *model.User) time.Time {
+	if user.LastLogin.IsZero() {
+		return time.Now()
+	}
+	return user.LastLogin
+}
+
+// mergeScopes returns the scopes in either list, keeping the order of first appearance
+func mergeScopes(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, scope := range b {
+		if !model.ScopesCover(merged, []string{scope}) {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/google/uuid"
+)
+
+// OAuthService is the OpenID Connect provider partner applications sign users in through. Protocol
+// failures are returned as *dao.OAuthError so they can be reported in the form the specifications require.
+type OAuthService interface {
+	RegisterClient(ctx context.Context, createdBy uuid.UUID, req *dto.OAuthClientCreate) (*dao.OAuthClientResponse, error)
+	ListClients(ctx context.Context) (*dao.OAuthClientListResponse, error)
+	DeleteClient(ctx context.Context, id uuid.UUID) error
+
+	CheckAuthorization(ctx context.Context, userID uuid.UUID, req *dto.OAuthAuthorize) (*dao.OAuthAuthorizationResponse, error)
+	Authorize(ctx context.Context, userID uuid.UUID, req *dto.OAuthAuthorize) (*dao.OAuthRedirectResponse, error)
+	Token(ctx context.Context, req *dto.OAuthTokenRequest) (*dao.OAuthTokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error)
+	Introspect(ctx context.Context, client dto.OAuthClientCredentials, token string) (*dao.OAuthIntrospectionResponse, error)
+	Revoke(ctx context.Context, client dto.OAuthClientCredentials, token string) error
+
+	ListConsents(ctx context.Context, userID uuid.UUID) (*dao.OAuthConsentListResponse, error)
+	RevokeConsent(ctx context.Context, userID, clientID uuid.UUID) error
+
+	Discovery() *dao.OIDCDiscovery
+}
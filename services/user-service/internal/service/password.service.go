@@ -0,0 +1,22 @@
+package service
+
+import (
+	"github.com/fairuzald/library-system/pkg/password"
+)
+
+// PasswordConfig holds how passwords are hashed and which new passwords are accepted
+type PasswordConfig struct {
+	Hasher *password.Hasher
+	Policy *password.Policy
+}
+
+// hashNew checks a new password against the policy and hashes it. The personal values are the user's
+// username, email address and names, which the password must not be built from. A rejected password
+// comes back as a *password.PolicyError.
+func (c PasswordConfig) hashNew(newPassword string, personal ...string) (string, error) {
+	if err := c.Policy.Check(newPassword, personal...); err != nil {
+		return "", err
+	}
+
+	return c.Hasher.Hash(newPassword)
+}
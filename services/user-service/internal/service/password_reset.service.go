@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/mail"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// accountTokenBytes is the amount of randomness in an emailed token, 256 bits
+	accountTokenBytes = 32
+	passwordResetTTL  = time.Hour
+	// passwordResetResendInterval is how long an account waits before another reset email is sent
+	passwordResetResendInterval = 5 * time.Minute
+
+	// mailTimeout bounds a send that runs after the request has been answered
+	mailTimeout = 30 * time.Second
+)
+
+// AccountMailConfig sets how account emails, such as password reset and verification links, are delivered
+type AccountMailConfig struct {
+	Sender mail.Sender
+	// PasswordResetURL is the web app page that reads a reset token from its token query parameter
+	PasswordResetURL string
+	// EmailVerificationURL is the web app page that reads a verification token the same way
+	EmailVerificationURL string
+}
+
+// RequestPasswordReset emails a reset link to the account with the given address. It succeeds whether or not
+// there is such an account, and sends in the background, so the response tells nothing about who is
+// registered. An account is sent at most one email per passwordResetResendInterval; further requests in that
+// time succeed without sending, so they cannot be used to flood a mailbox.
+func (s *authService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrUserNotFound) {
+			s.log.Info("Password reset requested for unknown email")
+			return nil
+		}
+		return err
+	}
+
+	sent, err := s.userRepo.MarkPasswordResetSent(ctx, user.ID, passwordResetResendInterval)
+	if err != nil {
+		return err
+	}
+	if !sent {
+		s.log.Info("Password reset email sent recently, not sending another", zap.String("user_id", user.ID.String()))
+		return nil
+	}
+
+	token, err := utils.GenerateSecureToken(accountTokenBytes)
+	if err != nil {
+		s.log.Error("Failed to generate password reset token", zap.Error(err))
+		return err
+	}
+
+	resetToken := model.NewAccountToken(user.ID, model.AccountTokenPasswordReset, utils.HashToken(token), time.Now().Add(passwordResetTTL))
+	if err := s.accountTokenRepo.CreateToken(ctx, resetToken); err != nil {
+		s.log.Error("Failed to store password reset token", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return err
+	}
+
+	s.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Someone asked to reset the password of your Library System account. To choose a new password, open:\n\n"+
+			"%s\n\n"+
+			"The link works once and expires in %d minutes. If you did not ask for this, ignore this email; "+
+			"your password has not changed.\n",
+			user.Username, s.linkWithToken(s.accountMail.PasswordResetURL, token), int(passwordResetTTL.Minutes())),
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password with a token from a reset email, then signs the user out everywhere.
+// The password is checked and hashed before the token is spent, so a password the policy rejects leaves
+// the link usable and the slow hash does not hold the token's row lock.
+func (s *authService) ResetPassword(ctx context.Context, req *dto.ResetPassword) error {
+	tokenHash := utils.HashToken(strings.TrimSpace(req.Token))
+
+	token, err := s.accountTokenRepo.GetToken(ctx, model.AccountTokenPasswordReset, tokenHash)
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrInvalidAccountToken) {
+			return errors.New(constants.ErrInvalidAccountToken)
+		}
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.password.hashNew(req.NewPassword, user.Username, user.Email, user.FirstName, user.LastName)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.ResetPassword(ctx, tokenHash, user.ID, hashedPassword); err != nil {
+		if strings.Contains(err.Error(), constants.ErrInvalidAccountToken) {
+			return errors.New(constants.ErrInvalidAccountToken)
+		}
+		return err
+	}
+
+	// Whoever was locked out may have been locked out by someone holding their sessions
+	if _, err := revokeUserTokens(ctx, s.userRepo, s.authRepo, user.ID, true); err != nil {
+		s.log.Error("Failed to revoke tokens after password reset", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return err
+	}
+
+	s.recordSecurityEvent(ctx, user.ID, model.SecurityEventPasswordReset, req.ClientInfo, "the password was reset from an emailed link; all sessions were revoked")
+	return nil
+}
+
+// linkWithToken adds the token to a web app URL. Without a URL the bare token is sent, for clients that ask
+// the user to paste it.
+func (s *authService) linkWithToken(rawURL, token string) string {
+	if rawURL == "" {
+		return token
+	}
+
+	link, err := url.Parse(rawURL)
+	if err != nil {
+		s.log.Error("Invalid account link URL", zap.Error(err), zap.String("url", rawURL))
+		return token
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// sendMail delivers a message after the request returns, so slow mail servers neither hold up the response
+// nor reveal through its timing whether a message was sent
+func (s *authService) sendMail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		if err := s.accountMail.Sender.Send(ctx, msg); err != nil {
+			s.log.Error("Failed to send email", zap.Error(err), zap.String("subject", msg.Subject))
+		}
+	}()
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/utils"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
+	"github.com/fairuzald/library-system/services/user-service/internal/entity/model"
+	"github.com/fairuzald/library-system/services/user-service/internal/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// refreshTokenBytes is the amount of randomness in a refresh token, 256 bits
+	refreshTokenBytes = 32
+	// maxUserAgentLength matches the width of sessions.user_agent
+	maxUserAgentLength = 255
+)
+
+// revokeUserTokens bumps the user's token version so that every access token issued so far is rejected.
+// With endSessions it also revokes their sessions, so the tokens cannot simply be refreshed.
+func revokeUserTokens(ctx context.Context, userRepo repository.UserRepository, authRepo repository.AuthRepository, userID uuid.UUID, endSessions bool) (int64, error) {
+	version, err := userRepo.IncrementTokenVersion(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := authRepo.PublishTokenVersion(ctx, userID, version); err != nil {
+		return 0, err
+	}
+
+	if !endSessions {
+		return 0, nil
+	}
+
+	return authRepo.RevokeSessions(ctx, userID, nil)
+}
+
+// startSession opens a session for a new login and returns it with its refresh token
+func (s *authService) startSession(ctx context.Context, userID uuid.UUID, deviceName string, client dto.ClientInfo) (*model.Session, string, error) {
+	refreshToken, err := utils.GenerateSecureToken(refreshTokenBytes)
+	if err != nil {
+		s.log.Error("Failed to generate refresh token", zap.Error(err))
+		return nil, "", err
+	}
+
+	session := model.NewSession(userID, time.Now().Add(s.refreshExp))
+	session.DeviceName = strings.TrimSpace(deviceName)
+	setClientInfo(session, client)
+
+	token := model.NewRefreshToken(session.ID, utils.HashToken(refreshToken))
+	if err := s.authRepo.CreateSession(ctx, session, token); err != nil {
+		s.log.Error("Failed to create session", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+// setClientInfo records where a session was last used from, keeping earlier values the client did not send
+func setClientInfo(session *model.Session, client dto.ClientInfo) {
+	if userAgent := strings.TrimSpace(client.UserAgent); userAgent != "" {
+		session.UserAgent = truncate(userAgent, maxUserAgentLength)
+	}
+
+	if client.IPAddress != "" {
+		session.IPAddress = client.IPAddress
+	}
+}
+
+// handleRefreshTokenReuse revokes a session whose used refresh token was presented again and records the
+// event. Either the legitimate client or an attacker holds a stolen token, and there is no telling which.
+func (s *authService) handleRefreshTokenReuse(ctx context.Context, session *model.Session, client dto.ClientInfo) error {
+	s.log.Warn("Refresh token reuse detected, revoking session",
+		zap.String("user_id", session.UserID.String()),
+		zap.String("session_id", session.ID.String()),
+		zap.String("ip_address", client.IPAddress),
+	)
+
+	if session.RevokedAt == nil {
+		if err := s.authRepo.RevokeSession(ctx, session.UserID, session.ID); err != nil && err.Error() != constants.ErrSessionNotFound {
+			s.log.Error("Failed to revoke session after token reuse", zap.Error(err), zap.String("session_id", session.ID.String()))
+			return errors.New(constants.ErrInternalServer)
+		}
+	}
+
+	event := model.NewSecurityEvent(session.UserID, model.SecurityEventRefreshTokenReuse)
+	event.SessionID = &session.ID
+	event.IPAddress = client.IPAddress
+	event.UserAgent = truncate(strings.TrimSpace(client.UserAgent), maxUserAgentLength)
+	event.Details = "a rotated refresh token was presented again; the session was revoked"
+
+	if err := s.authRepo.RecordSecurityEvent(ctx, event); err != nil {
+		s.log.Error("Failed to record security event", zap.Error(err), zap.String("session_id", session.ID.String()))
+	}
+
+	return errors.New(constants.ErrTokenRevoked)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// EndSession revokes the session holding a refresh token, for clients that log out without an access token
+func (s *authService) EndSession(ctx context.Context, refreshToken string) error {
+	token, err := s.authRepo.GetRefreshToken(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if strings.Contains(err.Error(), constants.ErrInvalidToken) || err.Error() == constants.ErrSessionNotFound {
+			return errors.New(constants.ErrInvalidToken)
+		}
+		s.log.Error("Failed to look up refresh token", zap.Error(err))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	session := token.Session
+	if token.UsedAt != nil || !session.Active() {
+		return errors.New(constants.ErrInvalidToken)
+	}
+
+	if err := s.authRepo.RevokeSession(ctx, session.UserID, session.ID); err != nil && err.Error() != constants.ErrSessionNotFound {
+		s.log.Error("Failed to revoke session", zap.Error(err), zap.String("session_id", session.ID.String()))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	return nil
+}
+
+// ListSessions returns the user's active sessions, most recently used first, flagging the one
+// identified by currentSessionID
+func (s *authService) ListSessions(ctx context.Context, userID uuid.UUID, currentSessionID string) (*dao.SessionListResponse, error) {
+	sessions, err := s.authRepo.ListActiveSessions(ctx, userID)
+	if err != nil {
+		s.log.Error("Failed to list sessions", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	response := &dao.SessionListResponse{
+		Sessions: make([]dao.SessionResponse, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, *dao.NewSessionResponse(session, currentSessionID))
+	}
+
+	return response, nil
+}
+
+// RevokeSession ends one of the user's sessions. Access tokens already issued for it stay valid until they expire.
+func (s *authService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	if err := s.authRepo.RevokeSession(ctx, userID, sessionID); err != nil {
+		if err.Error() == constants.ErrSessionNotFound {
+			return err
+		}
+		s.log.Error("Failed to revoke session", zap.Error(err), zap.String("session_id", sessionID.String()))
+		return errors.New(constants.ErrInternalServer)
+	}
+
+	return nil
+}
+
+// RevokeSessions ends all of the user's sessions except the optional one given, e.g. the caller's own
+func (s *authService) RevokeSessions(ctx context.Context, userID uuid.UUID, except *uuid.UUID) (*dao.RevokeSessionsResponse, error) {
+	revoked, err := s.authRepo.RevokeSessions(ctx, userID, except)
+	if err != nil {
+		s.log.Error("Failed to revoke sessions", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	return &dao.RevokeSessionsResponse{Revoked: revoked}, nil
+}
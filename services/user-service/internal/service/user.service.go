@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/fairuzald/library-system/pkg/constants"
+	"github.com/fairuzald/library-system/pkg/listquery"
 	"github.com/fairuzald/library-system/pkg/logger"
+	"github.com/fairuzald/library-system/pkg/pagination"
 	"github.com/fairuzald/library-system/pkg/utils"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dao"
 	"github.com/fairuzald/library-system/services/user-service/internal/entity/dto"
@@ -18,13 +20,25 @@ import (
 
 type userService struct {
 	userRepo repository.UserRepository
+	authRepo repository.AuthRepository
 	log      *logger.Logger
+	password PasswordConfig
 }
 
-func NewUserService(userRepo repository.UserRepository, log *logger.Logger) UserService {
+func NewUserService(userRepo repository.UserRepository, authRepo repository.AuthRepository, log *logger.Logger, password PasswordConfig) UserService {
 	return &userService{
 		userRepo: userRepo,
+		authRepo: authRepo,
 		log:      log,
+		password: password,
+	}
+}
+
+// revokeTokens invalidates the user's existing tokens after a security-relevant change. The change itself
+// has already been saved, so a failure is logged rather than returned.
+func (s *userService) revokeTokens(ctx context.Context, userID uuid.UUID, endSessions bool) {
+	if _, err := revokeUserTokens(ctx, s.userRepo, s.authRepo, userID, endSessions); err != nil {
+		s.log.Error("Failed to revoke tokens", zap.Error(err), zap.String("user_id", userID.String()))
 	}
 }
 
@@ -41,6 +55,41 @@ func (s *userService) GetUserByID(ctx context.Context, id uuid.UUID) (*dao.UserR
 	return dao.NewUserResponse(user), nil
 }
 
+func (s *userService) BatchGetUsers(ctx context.Context, ids []string) (*dao.UserBatchResponse, error) {
+	if len(ids) > constants.MaxBatchSize {
+		return nil, errors.New(constants.ErrBatchTooLarge)
+	}
+
+	userIDs, invalid := utils.ParseUUIDs(ids)
+
+	users, err := s.userRepo.GetByIDs(ctx, userIDs)
+	if err != nil {
+		s.log.Error("Failed to batch get users", zap.Error(err))
+		return nil, errors.New(constants.ErrInternalServer)
+	}
+
+	byID := make(map[uuid.UUID]*model.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	response := &dao.UserBatchResponse{
+		Users:      make([]dao.UserResponse, 0, len(users)),
+		MissingIDs: append([]string{}, invalid...),
+	}
+
+	for _, id := range userIDs {
+		user, ok := byID[id]
+		if !ok {
+			response.MissingIDs = append(response.MissingIDs, id.String())
+			continue
+		}
+		response.Users = append(response.Users, *dao.NewUserResponse(user))
+	}
+
+	return response, nil
+}
+
 func (s *userService) GetUserByEmail(ctx context.Context, email string) (*dao.UserResponse, error) {
 	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
@@ -81,8 +130,9 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, req *dto.Use
 		}
 	}
 
-	if req.Email != nil {
+	if req.Email != nil && *req.Email != user.Email {
 		user.Email = *req.Email
+		user.EmailVerifiedAt = nil
 	}
 
 	if req.Username != nil {
@@ -97,10 +147,12 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, req *dto.Use
 		user.LastName = *req.LastName
 	}
 
+	roleChanged := req.Role != nil && *req.Role != user.Role
 	if req.Role != nil {
 		user.Role = *req.Role
 	}
 
+	blocked := req.Status != nil && *req.Status == constants.UserStatusBlocked && user.Status != constants.UserStatusBlocked
 	if req.Status != nil {
 		user.Status = *req.Status
 	}
@@ -118,6 +170,32 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, req *dto.Use
 		return nil, err
 	}
 
+	// Tokens carry the role, so they are reissued on refresh; a blocked user loses their sessions too
+	if roleChanged || blocked {
+		s.revokeTokens(ctx, id, blocked)
+	}
+
+	return dao.NewUserResponse(user), nil
+}
+
+// ApproveUser activates a pending account without email verification
+func (s *userService) ApproveUser(ctx context.Context, id uuid.UUID) (*dao.UserResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status != constants.UserStatusPending {
+		return nil, errors.New(constants.ErrAccountNotPending)
+	}
+
+	user.Status = constants.UserStatusActive
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		s.log.Error("Failed to approve user", zap.Error(err), zap.String("id", id.String()))
+		return nil, err
+	}
+
+	s.log.Info("User account approved", zap.String("id", id.String()))
 	return dao.NewUserResponse(user), nil
 }
 
@@ -127,14 +205,25 @@ func (s *userService) DeleteUser(ctx context.Context, id uuid.UUID) error {
 		return err
 	}
 
-	return s.userRepo.Delete(ctx, id)
+	if err := s.userRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.revokeTokens(ctx, id, true)
+	return nil
 }
 
 func (s *userService) ListUsers(ctx context.Context, filter *dto.UserFilter) (*dao.UserListResponse, error) {
 	filter.Validate()
 
-	users, count, err := s.userRepo.List(ctx, filter)
+	users, count, cursors, err := s.userRepo.List(ctx, filter)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return nil, errors.New(constants.ErrInvalidCursor)
+		}
+		if errors.Is(err, listquery.ErrInvalidFilter) || errors.Is(err, listquery.ErrInvalidSort) {
+			return nil, err
+		}
 		s.log.Error("Failed to list users", zap.Error(err))
 		return nil, err
 	}
@@ -145,6 +234,8 @@ func (s *userService) ListUsers(ctx context.Context, filter *dto.UserFilter) (*d
 		TotalPages:  (int(count) + filter.Limit - 1) / filter.Limit,
 		CurrentPage: filter.Page,
 		PageSize:    filter.Limit,
+		NextCursor:  cursors.Next,
+		PrevCursor:  cursors.Prev,
 	}
 
 	for _, user := range users {
@@ -159,28 +250,32 @@ func (s *userService) ChangePassword(ctx context.Context, id uuid.UUID, req *dto
 		return errors.New(constants.ErrRequiredField)
 	}
 
-	if len(req.NewPassword) < constants.PasswordMinLength {
-		return errors.New(constants.ErrWeakPassword)
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	user, err := s.userRepo.GetByID(ctx, id)
+	// Cached users do not carry the hash, so it is read from the database
+	currentHash, err := s.userRepo.GetPasswordHash(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password) {
+	if match, _ := s.password.Hasher.Verify(req.CurrentPassword, currentHash); !match {
 		return errors.New(constants.ErrInvalidCredentials)
 	}
 
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	hashedPassword, err := s.password.hashNew(req.NewPassword, user.Username, user.Email, user.FirstName, user.LastName)
 	if err != nil {
-		s.log.Error("Failed to hash password", zap.Error(err))
 		return err
 	}
 
-	user.Password = hashedPassword
+	if err := s.userRepo.UpdatePassword(ctx, id, hashedPassword); err != nil {
+		return err
+	}
 
-	return s.userRepo.Update(ctx, user)
+	s.revokeTokens(ctx, id, true)
+	return nil
 }
 
 func (s *userService) CreateUser(ctx context.Context, req *dto.UserCreate) (*dao.UserResponse, error) {
@@ -198,9 +293,8 @@ func (s *userService) CreateUser(ctx context.Context, req *dto.UserCreate) (*dao
 		return nil, errors.New(constants.ErrUsernameTaken)
 	}
 
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := s.password.hashNew(req.Password, req.Username, req.Email, req.FirstName, req.LastName)
 	if err != nil {
-		s.log.Error("Failed to hash password", zap.Error(err))
 		return nil, err
 	}
 
@@ -12,9 +12,11 @@ type UserService interface {
 	CreateUser(ctx context.Context, req *dto.UserCreate) (*dao.UserResponse, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*dao.UserResponse, error)
 	GetUserByEmail(ctx context.Context, email string) (*dao.UserResponse, error)
+	BatchGetUsers(ctx context.Context, ids []string) (*dao.UserBatchResponse, error)
 	GetUserByUsername(ctx context.Context, username string) (*dao.UserResponse, error)
 	UpdateUser(ctx context.Context, id uuid.UUID, req *dto.UserUpdate) (*dao.UserResponse, error)
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 	ListUsers(ctx context.Context, filter *dto.UserFilter) (*dao.UserListResponse, error)
 	ChangePassword(ctx context.Context, id uuid.UUID, req *dto.ChangePassword) error
+	ApproveUser(ctx context.Context, id uuid.UUID) (*dao.UserResponse, error)
 }